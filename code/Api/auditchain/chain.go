@@ -0,0 +1,131 @@
+// Package auditchain 操作日志的哈希链与签名检查点
+//
+// 每条日志按写入顺序分配连续的序号，并保存前一条日志的哈希和本条内容的哈希，
+// 删除、插入或修改任意一条都会使校验在该位置失败。
+// 检查点定期对链头签名并写入数据库之外的文件，用于发现整条链被重算或尾部被截断。
+package auditchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"superhoneypotguard/models"
+)
+
+// Genesis 链上第一条日志的前一条哈希
+const Genesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Head 链上最后一条日志的序号与哈希
+type Head struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Start 空链的链头
+var Start = Head{Hash: Genesis}
+
+// Record 参与哈希计算的日志字段
+// 字段及其顺序是链格式的一部分，修改后已有日志将无法通过校验
+type Record struct {
+	Seq          int64
+	PrevHash     string
+	UserID       *int
+	Username     *string
+	Operation    string
+	Method       *string
+	URL          *string
+	IP           *string
+	Location     *string
+	ForwardedFor *string
+	RequestID    *string
+	TraceID      *string
+	Params       *string
+	Result       *string
+	Status       int
+	ErrorMsg     *string
+	ExecuteTime  int
+	Action       *string
+	TargetType   *string
+	TargetID     *string
+	Changes      *string
+	CreatedAt    time.Time
+}
+
+// FromLog 取出日志中参与哈希计算的字段
+func FromLog(entry *models.OperationLog) Record {
+	return Record{
+		Seq:          entry.Seq,
+		PrevHash:     entry.PrevHash,
+		UserID:       entry.UserID,
+		Username:     entry.Username,
+		Operation:    entry.Operation,
+		Method:       entry.Method,
+		URL:          entry.URL,
+		IP:           entry.IP,
+		Location:     entry.Location,
+		ForwardedFor: entry.ForwardedFor,
+		RequestID:    entry.RequestID,
+		TraceID:      entry.TraceID,
+		Params:       entry.Params,
+		Result:       entry.Result,
+		Status:       entry.Status,
+		ErrorMsg:     entry.ErrorMsg,
+		ExecuteTime:  entry.ExecuteTime,
+		Action:       entry.Action,
+		TargetType:   entry.TargetType,
+		TargetID:     entry.TargetID,
+		Changes:      entry.Changes,
+		CreatedAt:    entry.CreatedAt,
+	}
+}
+
+// Hash 计算记录的 SHA-256 哈希，字段按 Record 中的顺序序列化为 JSON 数组，
+// 时间按秒级时间戳参与计算，与时区和数据库列的精度无关
+func (r Record) Hash() string {
+	fields := []interface{}{
+		"v1", r.Seq, r.PrevHash,
+		r.UserID, r.Username, r.Operation, r.Method, r.URL, r.IP, r.Location,
+		r.ForwardedFor, r.RequestID, r.TraceID,
+		r.Params, r.Result, r.Status, r.ErrorMsg, r.ExecuteTime,
+		r.Action, r.TargetType, r.TargetID, r.Changes,
+		r.CreatedAt.Unix(),
+	}
+	payload, _ := json.Marshal(fields)
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// Link 将 entries 依次接在 head 之后，填写序号、前一条哈希和本条哈希，返回新的链头
+// 记录时间截断到秒：operation_logs.created_at 由迁移 20261019100000_init_schema 建立，
+// 该迁移沿用已有表的列类型，早期 MySQL 库中的列为 DATETIME，只保存到秒且会四舍五入；
+// 截断后任何精度的列都能原样保存参与哈希计算的时间。未设置时间的日志使用当前时间
+func Link(head Head, entries []models.OperationLog) Head {
+	now := time.Now()
+	for i := range entries {
+		entry := &entries[i]
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = now
+		}
+		entry.CreatedAt = entry.CreatedAt.Truncate(time.Second)
+		entry.Seq = head.Seq + 1
+		entry.PrevHash = head.Hash
+		entry.Hash = FromLog(entry).Hash()
+		head = Head{Seq: entry.Seq, Hash: entry.Hash}
+	}
+	return head
+}
+
+// Check 检查 entry 能否接在 prev 之后，返回问题类型和说明，没有问题时 kind 为空
+func Check(prev Head, entry *models.OperationLog) (kind, detail string) {
+	switch {
+	case entry.Seq != prev.Seq+1:
+		return IssueGap, gapDetail(prev.Seq+1, entry.Seq)
+	case entry.PrevHash != prev.Hash:
+		return IssueBrokenLink, "记录的前一条哈希与链上前一条日志不一致"
+	case FromLog(entry).Hash() != entry.Hash:
+		return IssueModified, "日志内容与哈希不一致，内容可能被修改"
+	}
+	return "", ""
+}
@@ -0,0 +1,150 @@
+package auditchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Checkpoint 某一时刻链头的签名记录，每行一条追加写入检查点文件
+type Checkpoint struct {
+	Seq       int64     `json:"seq"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+	// PublicKey、Signature 为 base64 编码的 Ed25519 公钥和签名
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// ErrNoSigningKey 未配置检查点签名密钥
+var ErrNoSigningKey = errors.New("未配置签名密钥")
+
+// SigningKey 解析检查点签名密钥，seed 为 base64 编码的 32 字节种子。
+// 签名密钥必须单独配置，不能由其他密钥派生，否则持有该密钥的人也能伪造检查点
+func SigningKey(seed string) (ed25519.PrivateKey, error) {
+	if strings.TrimSpace(seed) == "" {
+		return nil, ErrNoSigningKey
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(seed))
+	if err != nil || len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("签名密钥必须是 base64 编码的 %d 字节种子", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(raw), nil
+}
+
+// ParsePublicKeys 解析 base64 编码的 Ed25519 公钥列表，用于校验更换签名密钥之前写入的检查点
+func ParsePublicKeys(keys []string) ([]ed25519.PublicKey, error) {
+	out := make([]ed25519.PublicKey, 0, len(keys))
+	for i, key := range keys {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("第 %d 个公钥必须是 base64 编码的 %d 字节 Ed25519 公钥", i+1, ed25519.PublicKeySize)
+		}
+		out = append(out, ed25519.PublicKey(raw))
+	}
+	return out, nil
+}
+
+// GenerateKey 生成新的签名密钥，返回 base64 编码的种子与公钥
+func GenerateKey() (seed, publicKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(priv.Seed()), base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// Sign 为链头生成签名检查点
+func Sign(key ed25519.PrivateKey, head Head, at time.Time) Checkpoint {
+	cp := Checkpoint{
+		Seq:       head.Seq,
+		Hash:      head.Hash,
+		CreatedAt: at.UTC(),
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}
+	cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, cp.message()))
+	return cp
+}
+
+// Verify 校验签名，检查点中的公钥必须是 trusted 之一（当前签名密钥的公钥和受信任的旧公钥）
+func (cp Checkpoint) Verify(trusted ...ed25519.PublicKey) error {
+	var pub ed25519.PublicKey
+	for _, key := range trusted {
+		if cp.PublicKey == base64.StdEncoding.EncodeToString(key) {
+			pub = key
+			break
+		}
+	}
+	if pub == nil {
+		return errors.New("签名公钥不是当前或受信任的密钥")
+	}
+	sig, err := base64.StdEncoding.DecodeString(cp.Signature)
+	if err != nil || !ed25519.Verify(pub, cp.message(), sig) {
+		return errors.New("签名无效")
+	}
+	return nil
+}
+
+func (cp Checkpoint) message() []byte {
+	return []byte(fmt.Sprintf("superhoneypotguard-audit-checkpoint\n%d\n%s\n%s",
+		cp.Seq, cp.Hash, cp.CreatedAt.UTC().Format(time.RFC3339Nano)))
+}
+
+// AppendCheckpoint 将检查点追加写入文件并刷盘
+func AppendCheckpoint(path string, cp Checkpoint) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadCheckpoints 读取检查点文件，文件不存在时返回空列表
+func ReadCheckpoints(path string) ([]Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []Checkpoint
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var cp Checkpoint
+		if err := json.Unmarshal(text, &cp); err != nil {
+			return checkpoints, fmt.Errorf("检查点文件第 %d 行无法解析", line)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, scanner.Err()
+}
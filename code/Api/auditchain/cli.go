@@ -0,0 +1,80 @@
+package auditchain
+
+import (
+	"fmt"
+	"io"
+)
+
+const usage = `usage: audit <command>
+
+commands:
+  verify       verify the operation log hash chain against the signed checkpoints
+  checkpoint   sign the current chain head and append it to the checkpoint file
+  keygen       generate a new checkpoint signing key (audit_signing_key)`
+
+// Chain 服务层对日志链的操作，CLI 通过它访问数据库
+type Chain interface {
+	Verify() (*Report, error)
+	// Checkpoint 写入一个检查点，链头未变化时返回 nil
+	Checkpoint() (*Checkpoint, error)
+}
+
+// RunCLI 执行 audit 子命令；open 仅在需要数据库时调用，校验未通过时返回错误
+func RunCLI(args []string, open func() (Chain, error), out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	if args[0] == "keygen" {
+		seed, pub, err := GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "audit_signing_key: %s\npublic key:        %s\n", seed, pub)
+		return nil
+	}
+
+	switch args[0] {
+	case "verify", "checkpoint":
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+
+	chain, err := open()
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "checkpoint" {
+		cp, err := chain.Checkpoint()
+		if err != nil {
+			return err
+		}
+		if cp == nil {
+			fmt.Fprintln(out, "Chain head unchanged since the last checkpoint")
+			return nil
+		}
+		fmt.Fprintf(out, "Checkpoint seq=%d hash=%s\n", cp.Seq, cp.Hash)
+		return nil
+	}
+
+	report, err := chain.Verify()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Checked %d log(s), seq %d-%d, archived through %d\n",
+		report.Checked, report.FirstSeq, report.LastSeq, report.ArchivedSeq)
+	fmt.Fprintf(out, "Head %s\n", report.HeadHash)
+	fmt.Fprintf(out, "Checkpoints %d, matched %d\n", report.Checkpoints, report.CheckpointsVerified)
+	for _, issue := range report.Issues {
+		fmt.Fprintf(out, "  %-20s seq=%-8d id=%-8d %s\n", issue.Kind, issue.Seq, issue.ID, issue.Detail)
+	}
+	if report.MoreIssues > 0 {
+		fmt.Fprintf(out, "  ... and %d more issue(s)\n", report.MoreIssues)
+	}
+	if !report.Valid {
+		return fmt.Errorf("chain verification failed with %d issue(s)", len(report.Issues)+report.MoreIssues)
+	}
+	fmt.Fprintln(out, "OK")
+	return nil
+}
@@ -0,0 +1,73 @@
+package auditchain
+
+import "fmt"
+
+// 校验发现的问题类型
+const (
+	// IssueGap 序号不连续，中间的日志被删除
+	IssueGap = "gap"
+	// IssueBrokenLink 前一条哈希对不上，日志被插入或替换
+	IssueBrokenLink = "broken_link"
+	// IssueModified 内容与哈希不一致
+	IssueModified = "modified"
+	// IssueUnchained 不在链上的日志，通常是绕过服务直接写入数据库
+	IssueUnchained = "unchained"
+	// IssueCheckpointInvalid 检查点文件无法解析或签名无效
+	IssueCheckpointInvalid = "checkpoint_invalid"
+	// IssueCheckpointMismatch 检查点记录的哈希与链上不一致，整条链可能被重算
+	IssueCheckpointMismatch = "checkpoint_mismatch"
+	// IssueTruncated 检查点之后的日志缺失，链尾被截断
+	IssueTruncated = "truncated"
+)
+
+// maxIssues 报告中保留的问题条数，超出的只计数
+const maxIssues = 100
+
+// Issue 校验发现的一个问题
+type Issue struct {
+	Kind   string `json:"kind"`
+	Seq    int64  `json:"seq,omitempty"`
+	ID     int    `json:"id,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Report 一次完整校验的结果
+type Report struct {
+	Valid bool `json:"valid"`
+	// ArchivedSeq 已归档的最后一条日志序号，校验从其下一条开始
+	ArchivedSeq int64  `json:"archivedSeq"`
+	FirstSeq    int64  `json:"firstSeq"`
+	LastSeq     int64  `json:"lastSeq"`
+	HeadHash    string `json:"headHash"`
+	Checked     int64  `json:"checked"`
+	// Checkpoints 检查点总数，CheckpointsVerified 为与链上哈希比对一致的个数
+	Checkpoints         int     `json:"checkpoints"`
+	CheckpointsVerified int     `json:"checkpointsVerified"`
+	Issues              []Issue `json:"issues"`
+	// MoreIssues 超出 Issues 上限未列出的问题数
+	MoreIssues int `json:"moreIssues"`
+}
+
+// Add 记录一个问题
+func (r *Report) Add(kind string, seq int64, id int, detail string) {
+	if len(r.Issues) >= maxIssues {
+		r.MoreIssues++
+		return
+	}
+	r.Issues = append(r.Issues, Issue{Kind: kind, Seq: seq, ID: id, Detail: detail})
+}
+
+// Finish 根据是否发现问题设置 Valid
+func (r *Report) Finish() {
+	if r.Issues == nil {
+		r.Issues = []Issue{}
+	}
+	r.Valid = len(r.Issues) == 0 && r.MoreIssues == 0
+}
+
+func gapDetail(from, to int64) string {
+	if from >= to-1 {
+		return fmt.Sprintf("缺少序号 %d 的日志", from)
+	}
+	return fmt.Sprintf("缺少序号 %d-%d 的日志", from, to-1)
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue 打印时替代敏感字段的文本
+const redactedValue = "******"
+
+// RunCLI 处理 config 子命令
+//
+//	config print [--redacted]   以配置文件格式输出最终生效的配置
+func RunCLI(args []string, cfg *Config, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: config print [--redacted]")
+	}
+
+	switch args[0] {
+	case "print":
+		fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+		fs.SetOutput(out)
+		redacted := fs.Bool("redacted", false, "隐藏密码、密钥等敏感字段")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return cfg.Print(out, *redacted)
+	default:
+		return fmt.Errorf("未知的 config 子命令 %q", args[0])
+	}
+}
+
+// Print 以 YAML 输出配置，输出内容可直接作为配置文件使用
+func (c *Config) Print(out io.Writer, redacted bool) error {
+	v := reflect.ValueOf(c).Elem()
+	for _, f := range fields {
+		value := v.Field(f.index).Interface()
+
+		var text string
+		switch val := value.(type) {
+		case time.Duration:
+			text = val.String()
+		case string:
+			if f.secret && redacted && val != "" {
+				val = redactedValue
+			}
+			data, err := yaml.Marshal(val)
+			if err != nil {
+				return err
+			}
+			text = strings.TrimSuffix(string(data), "\n")
+		default:
+			text = fmt.Sprint(val)
+		}
+
+		if _, err := fmt.Fprintf(out, "%s: %s\n", f.key, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,95 +1,209 @@
 package config
 
 import (
-	"log"
-	"os"
+	"reflect"
+	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
+	"superhoneypotguard/redact"
 )
 
+// Config 应用配置
+//
+// 每个字段通过标签声明其在各配置层中的名称：
+// key 为配置文件键名（命令行参数名为其 "_" 替换成 "-" 的形式），env 为环境变量名，
+// secret 标记敏感字段，这类字段支持从文件读取且在打印时会被隐藏。
 type Config struct {
-	Port            string
-	GinMode         string
-	DBHost          string
-	DBPort          string
-	DBName          string
-	DBUser          string
-	DBPassword      string
-	JWTSecret       string
-	JWTExpiresIn    string
-	BCryptCost      int
-	RateLimitWindow int
-	RateLimitMax    int
-	LogLevel        string
-	LogFilePath     string
-	RedisHost       string
-	RedisPort       string
-	RedisPassword   string
-	RedisDB         int
-	SMTPHost        string
-	SMTPPort        string
-	SMTPUser        string
-	SMTPPassword    string
+	Port    string `key:"port" env:"PORT"`
+	GinMode string `key:"gin_mode" env:"GIN_MODE"`
+
+	ShutdownTimeout time.Duration `key:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+
+	TrustedProxies  string `key:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	RemoteIPHeaders string `key:"remote_ip_headers" env:"REMOTE_IP_HEADERS"`
+
+	MetricsToken string `key:"metrics_token" env:"METRICS_TOKEN" secret:"true"`
+
+	HealthCheckTimeout time.Duration `key:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	HealthCritical     string        `key:"health_critical" env:"HEALTH_CRITICAL"`
+
+	TraceExporter      string `key:"trace_exporter" env:"TRACE_EXPORTER"`
+	TraceOTLPEndpoint  string `key:"trace_otlp_endpoint" env:"TRACE_OTLP_ENDPOINT"`
+	TraceFilePath      string `key:"trace_file_path" env:"TRACE_FILE_PATH"`
+	TraceSamplePercent int    `key:"trace_sample_percent" env:"TRACE_SAMPLE_PERCENT"`
+
+	DBDriver   string `key:"db_driver" env:"DB_DRIVER"`
+	DBPath     string `key:"db_path" env:"DB_PATH"`
+	DBHost     string `key:"db_host" env:"DB_HOST"`
+	DBPort     string `key:"db_port" env:"DB_PORT"`
+	DBName     string `key:"db_name" env:"DB_NAME"`
+	DBUser     string `key:"db_user" env:"DB_USER"`
+	DBPassword string `key:"db_password" env:"DB_PASSWORD" secret:"true"`
+
+	JWTSecret    string        `key:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	JWTExpiresIn time.Duration `key:"jwt_expires_in" env:"JWT_EXPIRES_IN"`
+	BCryptCost   int           `key:"bcrypt_cost" env:"BCRYPT_COST"`
+
+	SecretsKey     string `key:"secrets_key" env:"SECRETS_KEY" secret:"true"`
+	SecretsOldKeys string `key:"secrets_old_keys" env:"SECRETS_OLD_KEYS" secret:"true"`
+
+	RateLimitWindow   time.Duration `key:"rate_limit_window" env:"RATE_LIMIT_WINDOW"`
+	RateLimitMax      int           `key:"rate_limit_max_requests" env:"RATE_LIMIT_MAX_REQUESTS"`
+	RateLimitBackend  string        `key:"rate_limit_backend" env:"RATE_LIMIT_BACKEND"`
+	RateLimitPolicies string        `key:"rate_limit_policies" env:"RATE_LIMIT_POLICIES"`
+
+	LogLevel      string `key:"log_level" env:"LOG_LEVEL"`
+	LogFormat     string `key:"log_format" env:"LOG_FORMAT"`
+	LogFilePath   string `key:"log_file_path" env:"LOG_FILE_PATH"`
+	LogMaxSize    int    `key:"log_max_size" env:"LOG_MAX_SIZE"`
+	LogMaxBackups int    `key:"log_max_backups" env:"LOG_MAX_BACKUPS"`
+
+	LogBatchSize     int           `key:"log_batch_size" env:"LOG_BATCH_SIZE"`
+	LogFlushInterval time.Duration `key:"log_flush_interval" env:"LOG_FLUSH_INTERVAL"`
+	LogQueueSize     int           `key:"log_queue_size" env:"LOG_QUEUE_SIZE"`
+	LogSpoolDir      string        `key:"log_spool_dir" env:"LOG_SPOOL_DIR"`
+
+	LogRedactKeys         string `key:"log_redact_keys" env:"LOG_REDACT_KEYS"`
+	LogRedactPaths        string `key:"log_redact_paths" env:"LOG_REDACT_PATHS"`
+	LogBodyCaptureExclude string `key:"log_body_capture_exclude" env:"LOG_BODY_CAPTURE_EXCLUDE"`
+
+	AuditArchiveDir         string        `key:"audit_archive_dir" env:"AUDIT_ARCHIVE_DIR"`
+	AuditMinRetention       time.Duration `key:"audit_min_retention" env:"AUDIT_MIN_RETENTION"`
+	AuditCheckpointInterval time.Duration `key:"audit_checkpoint_interval" env:"AUDIT_CHECKPOINT_INTERVAL"`
+	AuditCheckpointFile     string        `key:"audit_checkpoint_file" env:"AUDIT_CHECKPOINT_FILE"`
+	AuditSigningKey         string        `key:"audit_signing_key" env:"AUDIT_SIGNING_KEY" secret:"true"`
+	AuditTrustedKeys        string        `key:"audit_trusted_keys" env:"AUDIT_TRUSTED_KEYS"`
+
+	RetentionInterval          time.Duration `key:"retention_interval" env:"RETENTION_INTERVAL"`
+	RetentionArchiveDir        string        `key:"retention_archive_dir" env:"RETENTION_ARCHIVE_DIR"`
+	RetentionArchive           string        `key:"retention_archive" env:"RETENTION_ARCHIVE"`
+	RetentionOperationLogs     time.Duration `key:"retention_operation_logs" env:"RETENTION_OPERATION_LOGS"`
+	RetentionVerificationCodes time.Duration `key:"retention_verification_codes" env:"RETENTION_VERIFICATION_CODES"`
+	RetentionAttackEvents      time.Duration `key:"retention_attack_events" env:"RETENTION_ATTACK_EVENTS"`
+
+	GeoIPDatabase       string        `key:"geoip_database" env:"GEOIP_DATABASE"`
+	GeoIPASNDatabase    string        `key:"geoip_asn_database" env:"GEOIP_ASN_DATABASE"`
+	GeoIPLanguage       string        `key:"geoip_language" env:"GEOIP_LANGUAGE"`
+	GeoIPReloadInterval time.Duration `key:"geoip_reload_interval" env:"GEOIP_RELOAD_INTERVAL"`
+
+	RedisHost     string `key:"redis_host" env:"REDIS_HOST"`
+	RedisPort     string `key:"redis_port" env:"REDIS_PORT"`
+	RedisPassword string `key:"redis_password" env:"REDIS_PASSWORD" secret:"true"`
+	RedisDB       int    `key:"redis_db" env:"REDIS_DB"`
+
+	SMTPHost     string `key:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     string `key:"smtp_port" env:"SMTP_PORT"`
+	SMTPUser     string `key:"smtp_user" env:"SMTP_USER"`
+	SMTPPassword string `key:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+
+	HFishBaseURL string `key:"hfish_base_url" env:"HFISH_BASE_URL"`
+	HFishAPIKey  string `key:"hfish_api_key" env:"HFISH_API_KEY" secret:"true"`
+	// HFishLegacySkipVerify 导入 default 实例时不校验证书，仅用于暂时无法配置 CA 证书或证书指纹的旧部署
+	HFishLegacySkipVerify bool `key:"hfish_legacy_tls_skip_verify" env:"HFISH_LEGACY_TLS_SKIP_VERIFY"`
+	// HFishSyncInterval 攻击数据同步间隔，查询时数据超过该时长才重新拉取；为 0 时每次查询都拉取
+	HFishSyncInterval time.Duration `key:"hfish_sync_interval" env:"HFISH_SYNC_INTERVAL"`
+	// HFishMaxResponseSize 单次调用 HFish API 读取的响应上限（MB），超过时本次调用失败
+	HFishMaxResponseSize int `key:"hfish_max_response_size" env:"HFISH_MAX_RESPONSE_SIZE"`
 }
 
 var AppConfig *Config
 
-func LoadConfig() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("Warning: .env file not found, using environment variables")
-	}
+// Default 返回内置默认配置，默认值中不包含任何凭据
+func Default() *Config {
+	return &Config{
+		Port:             "3000",
+		GinMode:          "debug",
+		ShutdownTimeout:  15 * time.Second,
+		DBDriver:         "mysql",
+		DBPath:           "data/superhoneypotguard.db",
+		DBHost:           "localhost",
+		DBPort:           "3306",
+		DBName:           "superhoneypotguard",
+		DBUser:           "root",
+		JWTExpiresIn:     24 * time.Hour,
+		BCryptCost:       10,
+		RateLimitWindow:  15 * time.Minute,
+		RateLimitMax:     100,
+		LogLevel:         "info",
+		LogFilePath:      "logs/",
+		LogBatchSize:     100,
+		LogFlushInterval: 5 * time.Second,
+		LogQueueSize:     1000,
+		LogSpoolDir:      "data/log-spool",
+		LogRedactKeys:    strings.Join(redact.DefaultKeys, ","),
+		RedisHost:        "localhost",
+		RedisPort:        "6379",
+		RedisDB:          0,
+		SMTPHost:         "smtp.163.com",
+		SMTPPort:         "587",
+		HFishBaseURL:     "https://localhost:4433/api/v1",
 
-	AppConfig = &Config{
-		Port:            getEnv("PORT", "3000"),
-		GinMode:         getEnv("GIN_MODE", "debug"),
-		DBHost:          getEnv("DB_HOST", "115.190.62.202"),
-		DBPort:          getEnv("DB_PORT", "3306"),
-		DBName:          getEnv("DB_NAME", "superhoneypotguard"),
-		DBUser:          getEnv("DB_USER", "root"),
-		DBPassword:      getEnv("DB_PASSWORD", "qwke23klja.Ki"),
-		JWTSecret:       getEnv("JWT_SECRET", "your_jwt_secret_key_here"),
-		JWTExpiresIn:    getEnv("JWT_EXPIRES_IN", "24h"),
-		BCryptCost:      getEnvAsInt("BCRYPT_COST", 10),
-		RateLimitWindow: getEnvAsInt("RATE_LIMIT_WINDOW_MS", 900000),
-		RateLimitMax:    getEnvAsInt("RATE_LIMIT_MAX_REQUESTS", 100),
-		LogLevel:        getEnv("LOG_LEVEL", "info"),
-		LogFilePath:     getEnv("LOG_FILE_PATH", "logs/"),
-		RedisHost:       getEnv("REDIS_HOST", "115.190.62.202"),
-		RedisPort:       getEnv("REDIS_PORT", "6379"),
-		RedisPassword:   getEnv("REDIS_PASSWORD", "182asi@ioqwie.qdw"),
-		RedisDB:         getEnvAsInt("REDIS_DB", 0),
-		SMTPHost:        getEnv("SMTP_HOST", "smtp.163.com"),
-		SMTPPort:        getEnv("SMTP_PORT", "587"),
-		SMTPUser:        getEnv("SMTP_USER", "sky1417167991@163.com"),
-		SMTPPassword:    getEnv("SMTP_PASSWORD", "QMgM8dSFBSHex6YB"),
-	}
-}
+		HFishSyncInterval:    time.Minute,
+		HFishMaxResponseSize: 32,
+
+		AuditArchiveDir:         "data/audit-archive",
+		AuditMinRetention:       90 * 24 * time.Hour,
+		AuditCheckpointInterval: time.Hour,
+		AuditCheckpointFile:     "data/audit-checkpoints.jsonl",
+
+		RetentionInterval:          time.Hour,
+		RetentionArchiveDir:        "data/retention-archive",
+		RetentionOperationLogs:     90 * 24 * time.Hour,
+		RetentionVerificationCodes: 24 * time.Hour,
+		RetentionAttackEvents:      90 * 24 * time.Hour,
+
+		GeoIPLanguage:       "zh-CN",
+		GeoIPReloadInterval: time.Minute,
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+		RemoteIPHeaders: "X-Forwarded-For,X-Real-IP",
+
+		RateLimitBackend:  "memory",
+		RateLimitPolicies: "auth=10/15m:ip,verification_code=5/1h:ip,hfish=120/1m:user,export=10/1h:user",
+
+		HealthCheckTimeout: 3 * time.Second,
+		HealthCritical:     "database,workers",
+
+		TraceExporter:      "none",
+		TraceOTLPEndpoint:  "http://localhost:4318",
+		TraceFilePath:      "logs/traces.jsonl",
+		TraceSamplePercent: 100,
+
+		LogFormat:     "json",
+		LogMaxSize:    100,
+		LogMaxBackups: 10,
 	}
-	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intVal := parseInt(value); intVal != 0 {
-			return intVal
+// SplitList 拆分逗号分隔的列表配置，忽略空项
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-	return defaultValue
+	return items
 }
 
-func parseInt(s string) int {
-	var result int
-	for _, c := range s {
-		if c >= '0' && c <= '9' {
-			result = result*10 + int(c-'0')
-		} else {
-			return 0
-		}
-	}
-	return result
+// field 描述 Config 中的一个配置项
+type field struct {
+	index  int
+	key    string
+	env    string
+	secret bool
 }
+
+var fields = func() []field {
+	t := reflect.TypeOf(Config{})
+	list := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		list = append(list, field{
+			index:  i,
+			key:    f.Tag.Get("key"),
+			env:    f.Tag.Get("env"),
+			secret: f.Tag.Get("secret") == "true",
+		})
+	}
+	return list
+}()
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// fileSuffix 敏感字段的"从文件读取"变体后缀，如 jwt_secret_file / JWT_SECRET_FILE
+const fileSuffix = "_file"
+
+// defaultConfigFiles 未指定配置文件时在当前目录依次查找的文件
+var defaultConfigFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// setting 某一配置层提供的单个取值
+type setting struct {
+	source string // 用于错误提示，如 "环境变量 DB_PORT"
+	key    string // 对应 Config 字段的 key，带 _file 后缀时表示值为文件路径
+	value  string
+}
+
+// Load 按 默认值 → 配置文件 → 环境变量 → 命令行参数 的顺序合成配置。
+// args 为不含程序名的命令行参数，返回值中的剩余参数为子命令及其参数。
+func Load(args []string) (*Config, []string, error) {
+	fs, configPath, flagSettings := newFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+
+	path := *configPath
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path = findDefaultConfigFile()
+	}
+	if path != "" {
+		settings, err := readConfigFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cfg.apply(settings); err != nil {
+			return nil, nil, err
+		}
+		log.Printf("已加载配置文件: %s", path)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+	if err := cfg.apply(envSettings()); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.apply(flagSettings()); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// newFlagSet 为每个配置项注册同名命令行参数，返回的函数在解析后收集显式设置过的参数
+func newFlagSet() (*flag.FlagSet, *string, func() []setting) {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configPath := fs.String("config", "", "配置文件路径（.yaml/.yml/.toml），也可通过环境变量 CONFIG_FILE 指定")
+
+	flagKeys := make(map[string]string)
+	for _, f := range fields {
+		name := flagName(f.key)
+		fs.String(name, "", fmt.Sprintf("覆盖配置项 %s（环境变量 %s）", f.key, f.env))
+		flagKeys[name] = f.key
+		if f.secret {
+			fs.String(name+"-file", "", fmt.Sprintf("从文件读取配置项 %s", f.key))
+			flagKeys[name+"-file"] = f.key + fileSuffix
+		}
+	}
+
+	collect := func() []setting {
+		var settings []setting
+		fs.Visit(func(fl *flag.Flag) {
+			key, ok := flagKeys[fl.Name]
+			if !ok {
+				return
+			}
+			settings = append(settings, setting{
+				source: "命令行参数 -" + fl.Name,
+				key:    key,
+				value:  fl.Value.String(),
+			})
+		})
+		return settings
+	}
+
+	return fs, configPath, collect
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+func findDefaultConfigFile() string {
+	for _, name := range defaultConfigFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// readConfigFile 读取扁平结构的 YAML 或 TOML 配置文件
+func readConfigFile(path string) ([]setting, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("不支持的配置文件格式 %q（支持 .yaml、.yml、.toml）", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+
+	settings := make([]setting, 0, len(raw))
+	for key, value := range raw {
+		source := fmt.Sprintf("配置文件 %s 中的 %s", path, key)
+		var str string
+		switch v := value.(type) {
+		case nil:
+			continue
+		case string:
+			str = v
+		case bool, int, int64, uint64, float64:
+			str = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("%s: 只支持标量值", source)
+		}
+		settings = append(settings, setting{source: source, key: key, value: str})
+	}
+	return settings, nil
+}
+
+// envSettings 收集已设置的环境变量，空值视为未设置
+func envSettings() []setting {
+	var settings []setting
+	for _, f := range fields {
+		if value := os.Getenv(f.env); value != "" {
+			settings = append(settings, setting{source: "环境变量 " + f.env, key: f.key, value: value})
+		}
+		if f.secret {
+			name := f.env + strings.ToUpper(fileSuffix)
+			if value := os.Getenv(name); value != "" {
+				settings = append(settings, setting{source: "环境变量 " + name, key: f.key + fileSuffix, value: value})
+			}
+		}
+	}
+
+	// 兼容旧的毫秒数配置
+	if os.Getenv("RATE_LIMIT_WINDOW") == "" {
+		if value := os.Getenv("RATE_LIMIT_WINDOW_MS"); value != "" {
+			log.Println("Warning: RATE_LIMIT_WINDOW_MS 已弃用，请改用 RATE_LIMIT_WINDOW（如 15m）")
+			settings = append(settings, setting{source: "环境变量 RATE_LIMIT_WINDOW_MS", key: "rate_limit_window", value: value + "ms"})
+		}
+	}
+
+	return settings
+}
+
+// apply 将一层配置写入 cfg，同一层内 xxx 与 xxx_file 不能同时出现
+func (c *Config) apply(settings []setting) error {
+	byKey := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+
+	seen := make(map[string]string)
+	var errs []error
+	for _, s := range settings {
+		key, fromFile := s.key, false
+		if strings.HasSuffix(key, fileSuffix) {
+			if f, ok := byKey[strings.TrimSuffix(key, fileSuffix)]; ok && f.secret {
+				key, fromFile = f.key, true
+			}
+		}
+
+		f, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: 未知配置项", s.source))
+			continue
+		}
+		if prev, dup := seen[key]; dup {
+			errs = append(errs, fmt.Errorf("%s 与 %s 重复设置了 %s", prev, s.source, key))
+			continue
+		}
+		seen[key] = s.source
+
+		value := s.value
+		if fromFile {
+			data, err := os.ReadFile(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: 读取密钥文件失败: %w", s.source, err))
+				continue
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+
+		if err := setValue(reflect.ValueOf(c).Elem().Field(f.index), value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.source, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setValue(v reflect.Value, raw string) error {
+	switch v.Interface().(type) {
+	case string:
+		v.SetString(raw)
+	case time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("无效的时长 %q（示例: 30s、15m、24h）", raw)
+		}
+		v.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("无效的整数 %q", raw)
+		}
+		v.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("无效的布尔值 %q（可选: true、false）", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("不支持的配置类型 %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"superhoneypotguard/auditchain"
+	"superhoneypotguard/ratelimit"
+	"superhoneypotguard/redact"
+	"superhoneypotguard/secrets"
+)
+
+// minReleaseSecretLength release 模式下 JWT 密钥的最小长度
+const minReleaseSecretLength = 32
+
+// placeholderValues 示例配置中使用过的占位值，出现在敏感字段中说明配置未被正确填写
+var placeholderValues = map[string]bool{
+	"changeme":  true,
+	"change_me": true,
+	"secret":    true,
+	"password":  true,
+	"123456":    true,
+}
+
+func isPlaceholder(value string) bool {
+	v := strings.ToLower(strings.TrimSpace(value))
+	return strings.HasPrefix(v, "your_") || strings.HasPrefix(v, "your-") || placeholderValues[v]
+}
+
+// Validate 检查配置是否可以用于启动服务，返回所有发现的问题
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	checkPort := func(key, value string) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 65535 {
+			fail("%s: 无效的端口 %q", key, value)
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		fail("%s: 无效的取值 %q（可选: %s）", key, value, strings.Join(allowed, ", "))
+	}
+
+	checkPort("port", c.Port)
+	oneOf("gin_mode", c.GinMode, "debug", "release", "test")
+	if c.ShutdownTimeout <= 0 {
+		fail("shutdown_timeout: 必须大于 0")
+	}
+	for _, proxy := range SplitList(c.TrustedProxies) {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				fail("trusted_proxies: 无效的 IP 或网段 %q", proxy)
+			}
+		}
+	}
+	if c.TrustedProxies != "" && len(SplitList(c.RemoteIPHeaders)) == 0 {
+		fail("remote_ip_headers: 配置了 trusted_proxies 时不能为空")
+	}
+	if c.HealthCheckTimeout <= 0 {
+		fail("health_check_timeout: 必须大于 0")
+	}
+	for _, name := range SplitList(c.HealthCritical) {
+		oneOf("health_critical", name, "database", "redis", "hfish", "smtp", "workers")
+	}
+	oneOf("trace_exporter", c.TraceExporter, "none", "otlp", "file")
+	if c.TraceExporter == "otlp" {
+		if u, err := url.Parse(c.TraceOTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("trace_otlp_endpoint: 必须是 http:// 或 https:// 开头的地址")
+		}
+	}
+	if c.TraceExporter == "file" && c.TraceFilePath == "" {
+		fail("trace_file_path: 使用 file 导出时不能为空")
+	}
+	if c.TraceSamplePercent < 0 || c.TraceSamplePercent > 100 {
+		fail("trace_sample_percent: 必须在 0 到 100 之间")
+	}
+	oneOf("log_level", c.LogLevel, "debug", "info", "warn", "error")
+	oneOf("log_format", c.LogFormat, "json", "text")
+	if c.LogMaxSize <= 0 {
+		fail("log_max_size: 必须大于 0")
+	}
+	if c.LogMaxBackups < 0 {
+		fail("log_max_backups: 不能为负数")
+	}
+	if c.LogBatchSize <= 0 {
+		fail("log_batch_size: 必须大于 0")
+	}
+	if c.LogFlushInterval <= 0 {
+		fail("log_flush_interval: 必须大于 0")
+	}
+	if c.LogQueueSize <= 0 {
+		fail("log_queue_size: 必须大于 0")
+	}
+	if _, err := redact.New(SplitList(c.LogRedactKeys), SplitList(c.LogRedactPaths)); err != nil {
+		fail("log_redact_keys/log_redact_paths: %v", err)
+	}
+	if c.AuditArchiveDir == "" {
+		fail("audit_archive_dir: 不能为空")
+	}
+	if c.AuditMinRetention < 0 {
+		fail("audit_min_retention: 不能为负数")
+	}
+	if c.AuditCheckpointInterval <= 0 {
+		fail("audit_checkpoint_interval: 必须大于 0")
+	}
+	if c.AuditCheckpointFile == "" {
+		fail("audit_checkpoint_file: 不能为空")
+	}
+	if c.AuditSigningKey == "" {
+		fail("audit_signing_key: 不能为空，请配置 AUDIT_SIGNING_KEY 或 AUDIT_SIGNING_KEY_FILE（可用 audit keygen 生成）")
+	} else if _, err := auditchain.SigningKey(c.AuditSigningKey); err != nil {
+		fail("audit_signing_key: %v", err)
+	}
+	if _, err := auditchain.ParsePublicKeys(SplitList(c.AuditTrustedKeys)); err != nil {
+		fail("audit_trusted_keys: %v", err)
+	}
+	if c.SecretsKey == "" {
+		fail("secrets_key: 不能为空，请配置 SECRETS_KEY 或 SECRETS_KEY_FILE（可用 secrets keygen 生成）")
+	} else if _, err := secrets.ParseKey(c.SecretsKey); err != nil {
+		fail("secrets_key: %v", err)
+	}
+	if _, err := secrets.ParseOldKeys(SplitList(c.SecretsOldKeys)); err != nil {
+		fail("secrets_old_keys: %v", err)
+	}
+	if c.RetentionInterval < 0 {
+		fail("retention_interval: 不能为负数")
+	}
+	if c.RetentionArchiveDir == "" {
+		fail("retention_archive_dir: 不能为空")
+	}
+	for _, name := range SplitList(c.RetentionArchive) {
+		oneOf("retention_archive", name, "operation_logs", "verification_codes", "attack_events")
+	}
+	if c.RetentionOperationLogs < 0 {
+		fail("retention_operation_logs: 不能为负数")
+	} else if c.RetentionOperationLogs > 0 && c.RetentionOperationLogs < c.AuditMinRetention {
+		fail("retention_operation_logs: 不能短于 audit_min_retention（%s）", c.AuditMinRetention)
+	}
+	if c.RetentionVerificationCodes < 0 {
+		fail("retention_verification_codes: 不能为负数")
+	}
+	if c.RetentionAttackEvents < 0 {
+		fail("retention_attack_events: 不能为负数")
+	}
+	geoDatabase := func(key, path string) {
+		if ext := strings.ToLower(filepath.Ext(path)); path != "" && ext != ".mmdb" && ext != ".csv" {
+			fail("%s: 不支持的数据库格式 %q（可选: .mmdb、.csv）", key, ext)
+		}
+	}
+	geoDatabase("geoip_database", c.GeoIPDatabase)
+	geoDatabase("geoip_asn_database", c.GeoIPASNDatabase)
+	if c.GeoIPASNDatabase != "" && c.GeoIPDatabase == "" {
+		fail("geoip_asn_database: 需要同时配置 geoip_database")
+	}
+	if c.GeoIPReloadInterval < 0 {
+		fail("geoip_reload_interval: 不能为负数")
+	}
+
+	oneOf("db_driver", c.DBDriver, "mysql", "sqlite")
+	switch c.DBDriver {
+	case "mysql":
+		if c.DBHost == "" || c.DBName == "" || c.DBUser == "" {
+			fail("db_host、db_name、db_user: 使用 mysql 时不能为空")
+		}
+		checkPort("db_port", c.DBPort)
+	case "sqlite":
+		if c.DBPath == "" {
+			fail("db_path: 使用 sqlite 时不能为空")
+		}
+	}
+
+	if c.JWTSecret == "" {
+		fail("jwt_secret: 不能为空")
+	} else if c.GinMode == "release" && len(c.JWTSecret) < minReleaseSecretLength {
+		fail("jwt_secret: release 模式下长度不能少于 %d 个字符", minReleaseSecretLength)
+	}
+	if c.JWTExpiresIn <= 0 {
+		fail("jwt_expires_in: 必须大于 0")
+	}
+	if c.BCryptCost < 4 || c.BCryptCost > 31 {
+		fail("bcrypt_cost: 必须在 4 到 31 之间")
+	}
+
+	if c.RateLimitWindow <= 0 {
+		fail("rate_limit_window: 必须大于 0")
+	}
+	if c.RateLimitMax <= 0 {
+		fail("rate_limit_max_requests: 必须大于 0")
+	}
+	if c.RateLimitWindow > 0 && c.RateLimitMax > 0 {
+		if err := (ratelimit.Rule{Window: c.RateLimitWindow, Max: c.RateLimitMax}).Validate(); err != nil {
+			fail("rate_limit_max_requests: %v", err)
+		}
+	}
+	oneOf("rate_limit_backend", c.RateLimitBackend, "memory", "redis")
+	if _, err := ratelimit.ParsePolicies(c.RateLimitPolicies); err != nil {
+		fail("rate_limit_policies: %v", err)
+	}
+
+	checkPort("redis_port", c.RedisPort)
+	if c.RedisDB < 0 {
+		fail("redis_db: 不能为负数")
+	}
+
+	checkPort("smtp_port", c.SMTPPort)
+	if isPlaceholder(c.SMTPUser) {
+		fail("smtp_user: 仍是示例占位值 %q", c.SMTPUser)
+	}
+
+	if c.HFishBaseURL != "" {
+		if u, err := url.Parse(c.HFishBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("hfish_base_url: 无效的地址 %q", c.HFishBaseURL)
+		}
+	}
+	if c.HFishSyncInterval < 0 {
+		fail("hfish_sync_interval: 不能为负数")
+	}
+	if c.HFishMaxResponseSize <= 0 {
+		fail("hfish_max_response_size: 必须大于 0")
+	}
+
+	// 敏感字段一律不允许使用示例中的占位值
+	v := reflect.ValueOf(c).Elem()
+	for _, f := range fields {
+		if f.secret && isPlaceholder(v.Field(f.index).String()) {
+			fail("%s: 仍是示例占位值，请填写真实值或通过 %s_FILE 指定密钥文件", f.key, f.env)
+		}
+	}
+
+	return errors.Join(errs...)
+}
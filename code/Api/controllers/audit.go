@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/models"
+	"superhoneypotguard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// audit 为当前请求记录审计事件，before/after 为操作前后的对象快照，用于生成字段级变更。
+// 操作失败时 before/after 传 nil，只记录事件；targetID 为 0 表示目标尚未创建
+func audit(c *gin.Context, action, targetType string, targetID int, description string, before, after interface{}) {
+	auditChanges(c, action, targetType, targetID, description, services.AuditDiff(before, after))
+}
+
+// auditChanges 同 audit，字段级变更由调用方给出
+func auditChanges(c *gin.Context, action, targetType string, targetID int, description string, changes map[string]models.AuditChange) {
+	event := models.AuditEvent{
+		Action:      action,
+		TargetType:  targetType,
+		Description: description,
+		Changes:     changes,
+	}
+	if targetID > 0 {
+		event.TargetID = strconv.Itoa(targetID)
+	}
+	middleware.RecordAudit(c, event)
+}
+
+// userAuditView 用户参与审计比对的字段，角色以编码表示
+func userAuditView(user *models.User) interface{} {
+	if user == nil {
+		return nil
+	}
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, role.RoleCode)
+	}
+	return gin.H{
+		"username": user.Username,
+		"email":    user.Email,
+		"phone":    user.Phone,
+		"realName": user.RealName,
+		"status":   user.Status,
+		"roles":    roles,
+	}
+}
+
+// roleAuditView 角色参与审计比对的字段，权限以编码表示
+func roleAuditView(role *models.Role) interface{} {
+	if role == nil {
+		return nil
+	}
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, perm := range role.Permissions {
+		permissions = append(permissions, perm.PermissionCode)
+	}
+	return gin.H{
+		"roleName":    role.RoleName,
+		"roleCode":    role.RoleCode,
+		"description": role.Description,
+		"status":      role.Status,
+		"permissions": permissions,
+	}
+}
+
+// permissionAuditView 权限参与审计比对的字段
+func permissionAuditView(perm *models.Permission) interface{} {
+	if perm == nil {
+		return nil
+	}
+	return gin.H{
+		"permissionName": perm.PermissionName,
+		"permissionCode": perm.PermissionCode,
+		"permissionType": perm.PermissionType,
+		"parentId":       perm.ParentID,
+		"path":           perm.Path,
+		"component":      perm.Component,
+		"icon":           perm.Icon,
+		"sortOrder":      perm.SortOrder,
+		"description":    perm.Description,
+		"status":         perm.Status,
+	}
+}
+
+// userLabel 审计描述中的用户名称，用户不存在时使用 ID
+func userLabel(id int, user *models.User) string {
+	if user == nil {
+		return fmt.Sprintf("#%d", id)
+	}
+	return user.Username
+}
+
+func roleLabel(id int, role *models.Role) string {
+	if role == nil {
+		return fmt.Sprintf("#%d", id)
+	}
+	return fmt.Sprintf("%s(%s)", role.RoleName, role.RoleCode)
+}
+
+func permissionLabel(id int, perm *models.Permission) string {
+	if perm == nil {
+		return fmt.Sprintf("#%d", id)
+	}
+	return fmt.Sprintf("%s(%s)", perm.PermissionName, perm.PermissionCode)
+}
+
+// hfishInstanceAuditView HFish 实例参与审计比对的字段，不包含 API Key 和客户端私钥
+func hfishInstanceAuditView(instance *models.HFishInstance) interface{} {
+	if instance == nil {
+		return nil
+	}
+	return gin.H{
+		"name":          instance.Name,
+		"baseUrl":       instance.BaseURL,
+		"tlsSkipVerify": instance.TLSSkipVerify,
+		"tlsCaCert":     instance.TLSCACert,
+		"tlsPinSha256":  instance.TLSPinSHA256,
+		"tlsClientCert": instance.TLSClientCert,
+		"enabled":       instance.Enabled,
+		"remark":        instance.Remark,
+	}
+}
+
+func hfishInstanceLabel(id int, instance *models.HFishInstance) string {
+	if instance == nil {
+		return fmt.Sprintf("#%d", id)
+	}
+	return instance.Name
+}
@@ -2,20 +2,21 @@ package controllers
 
 import (
 	"net/http"
-	"superhoneypotguard/database"
+	"strconv"
 	"superhoneypotguard/middleware"
 	"superhoneypotguard/models"
 	"superhoneypotguard/services"
 	"superhoneypotguard/utils"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-type AuthController struct{}
+type AuthController struct {
+	auth *services.AuthService
+}
 
-func NewAuthController() *AuthController {
-	return &AuthController{}
+func NewAuthController(auth *services.AuthService) *AuthController {
+	return &AuthController{auth: auth}
 }
 
 func (ctrl *AuthController) SendVerificationCode(c *gin.Context) {
@@ -28,9 +29,8 @@ func (ctrl *AuthController) SendVerificationCode(c *gin.Context) {
 		return
 	}
 
-	emailService := services.NewEmailService(database.DB)
-	if err := emailService.SendVerificationCode(req.Email); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "发送验证码失败: "+err.Error())
+	if err := ctrl.auth.SendVerificationCode(c.Request.Context(), req.Email); err != nil {
+		respondError(c, err, "发送验证码失败")
 		return
 	}
 
@@ -52,52 +52,27 @@ func (ctrl *AuthController) Register(c *gin.Context) {
 		return
 	}
 
-	if req.Email == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "邮箱不能为空")
-		return
-	}
-
-	emailService := services.NewEmailService(database.DB)
-	if !emailService.VerifyCode(req.Email, req.Code) {
-		utils.ErrorResponse(c, http.StatusBadRequest, "验证码错误或已过期")
-		return
-	}
-
-	var count int64
-	database.DB.Model(&models.User{}).Where("username = ? OR email = ?", req.Username, req.Email).Count(&count)
-	if count > 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "用户名或邮箱已存在")
-		return
-	}
-
-	hashedPassword, err := utils.HashPassword(req.Password)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "密码加密失败")
-		return
-	}
-
-	user := models.User{
+	user, err := ctrl.auth.Register(c.Request.Context(), services.RegisterInput{
 		Username: req.Username,
-		Password: hashedPassword,
-		Email:    &req.Email,
+		Password: req.Password,
+		Email:    req.Email,
+		Code:     req.Code,
 		Phone:    req.Phone,
 		RealName: req.RealName,
-		Status:   1,
-	}
-
-	if err := database.DB.Create(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "注册失败")
+	})
+	if err != nil {
+		middleware.RecordAudit(c, models.AuditEvent{Action: "auth.register", TargetType: "user", Description: "注册用户 " + req.Username})
+		respondError(c, err, "注册失败")
 		return
 	}
-
-	var defaultRole models.Role
-	database.DB.Where("role_code = ?", "USER").First(&defaultRole)
-	if defaultRole.ID > 0 {
-		database.DB.Create(&models.UserRole{
-			UserID: user.ID,
-			RoleID: defaultRole.ID,
-		})
-	}
+	middleware.RecordAudit(c, models.AuditEvent{
+		Action:      "auth.register",
+		TargetType:  "user",
+		TargetID:    strconv.Itoa(user.ID),
+		Description: "注册用户 " + user.Username,
+		ActorID:     &user.ID,
+		ActorName:   &user.Username,
+	})
 
 	utils.SuccessResponse(c, gin.H{
 		"id":       user.ID,
@@ -113,126 +88,67 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := database.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "用户名或密码错误")
-		return
-	}
-
-	if user.Status != 1 {
-		utils.ErrorResponse(c, http.StatusForbidden, "账号已被禁用")
-		return
-	}
-
-	if !utils.ComparePassword(req.Password, user.Password) {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "用户名或密码错误")
+	result, err := ctrl.auth.Login(req.Username, req.Password, utils.GetClientIP(c))
+	if err != nil {
+		middleware.RecordAudit(c, models.AuditEvent{Action: "auth.login", TargetType: "user", Description: "用户 " + req.Username + " 登录"})
+		respondError(c, err, "登录失败")
 		return
 	}
-
-	now := time.Now()
-	ip := utils.GetClientIP(c)
-	database.DB.Model(&user).Updates(map[string]interface{}{
-		"last_login_time": &now,
-		"last_login_ip":   &ip,
+	middleware.RecordAudit(c, models.AuditEvent{
+		Action:      "auth.login",
+		TargetType:  "user",
+		TargetID:    strconv.Itoa(result.User.ID),
+		Description: "用户 " + result.User.Username + " 登录",
+		ActorID:     &result.User.ID,
+		ActorName:   &result.User.Username,
 	})
 
-	var roles []models.Role
-	database.DB.Raw(`
-		SELECT r.id, r.role_name, r.role_code
-		FROM roles r
-		INNER JOIN user_roles ur ON r.id = ur.role_id
-		WHERE ur.user_id = ? AND r.status = 1
-	`, user.ID).Scan(&roles)
-
-	roleCodes := make([]string, 0, len(roles))
-	for _, role := range roles {
-		roleCodes = append(roleCodes, role.RoleCode)
-	}
-
-	var permissions []struct {
-		PermissionCode string `json:"permissionCode"`
-	}
-	database.DB.Raw(`
-		SELECT DISTINCT p.permission_code
-		FROM permissions p
-		INNER JOIN role_permissions rp ON p.id = rp.permission_id
-		INNER JOIN user_roles ur ON rp.role_id = ur.role_id
-		WHERE ur.user_id = ? AND p.status = 1
-	`, user.ID).Scan(&permissions)
-
-	permissionCodes := make([]string, 0, len(permissions))
-	for _, perm := range permissions {
-		permissionCodes = append(permissionCodes, perm.PermissionCode)
-	}
-
-	claims := &models.Claims{
-		UserID:      user.ID,
-		Username:    user.Username,
-		Roles:       roleCodes,
-		Permissions: permissionCodes,
-	}
-
-	token, err := utils.GenerateToken(claims)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "生成令牌失败")
-		return
+	permissions := make([]gin.H, 0, len(result.PermissionCodes))
+	for _, code := range result.PermissionCodes {
+		permissions = append(permissions, gin.H{"permissionCode": code})
 	}
 
 	utils.SuccessResponse(c, gin.H{
-		"token": token,
+		"token": result.Token,
 		"user": gin.H{
-			"id":          user.ID,
-			"username":    user.Username,
-			"email":       user.Email,
-			"realName":    user.RealName,
-			"roles":       roles,
+			"id":          result.User.ID,
+			"username":    result.User.Username,
+			"email":       result.User.Email,
+			"realName":    result.User.RealName,
+			"roles":       result.Roles,
 			"permissions": permissions,
 		},
 	})
 }
 
 func (ctrl *AuthController) Logout(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	audit(c, "auth.logout", "user", user.UserID, "用户 "+user.Username+" 退出登录", nil, nil)
 	utils.SuccessResponse(c, nil)
 }
 
 func (ctrl *AuthController) GetCurrentUser(c *gin.Context) {
 	user := middleware.GetCurrentUser(c)
 
-	var dbUser models.User
-	if err := database.DB.Where("id = ?", user.UserID).First(&dbUser).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
+	current, err := ctrl.auth.Current(user.UserID)
+	if err != nil {
+		respondError(c, err, "查询用户失败")
 		return
 	}
 
-	var roles []models.Role
-	database.DB.Raw(`
-		SELECT r.id, r.role_name, r.role_code
-		FROM roles r
-		INNER JOIN user_roles ur ON r.id = ur.role_id
-		WHERE ur.user_id = ? AND r.status = 1
-	`, user.UserID).Scan(&roles)
-
-	var permissions []models.Permission
-	database.DB.Raw(`
-		SELECT DISTINCT p.id, p.permission_name, p.permission_code, p.permission_type
-		FROM permissions p
-		INNER JOIN role_permissions rp ON p.id = rp.permission_id
-		INNER JOIN user_roles ur ON rp.role_id = ur.role_id
-		WHERE ur.user_id = ? AND p.status = 1
-	`, user.UserID).Scan(&permissions)
-
 	utils.SuccessResponse(c, gin.H{
 		"user": gin.H{
-			"id":            dbUser.ID,
-			"username":      dbUser.Username,
-			"email":         dbUser.Email,
-			"realName":      dbUser.RealName,
-			"status":        dbUser.Status,
-			"lastLoginTime": dbUser.LastLoginTime,
-			"createdAt":     dbUser.CreatedAt,
-			"updatedAt":     dbUser.UpdatedAt,
+			"id":                current.User.ID,
+			"username":          current.User.Username,
+			"email":             current.User.Email,
+			"realName":          current.User.RealName,
+			"status":            current.User.Status,
+			"lastLoginTime":     current.User.LastLoginTime,
+			"lastLoginLocation": current.User.LastLoginLocation,
+			"createdAt":         current.User.CreatedAt,
+			"updatedAt":         current.User.UpdatedAt,
 		},
-		"roles":       roles,
-		"permissions": permissions,
+		"roles":       current.Roles,
+		"permissions": current.Permissions,
 	})
 }
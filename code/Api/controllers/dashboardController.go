@@ -1,36 +1,26 @@
 package controllers
 
 import (
-	"superhoneypotguard/database"
-	"superhoneypotguard/models"
+	"superhoneypotguard/services"
 	"superhoneypotguard/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-type DashboardController struct{}
+type DashboardController struct {
+	dashboard *services.DashboardService
+}
 
-func NewDashboardController() *DashboardController {
-	return &DashboardController{}
+func NewDashboardController(dashboard *services.DashboardService) *DashboardController {
+	return &DashboardController{dashboard: dashboard}
 }
 
 func (ctrl *DashboardController) GetStats(c *gin.Context) {
-	var userCount int64
-	database.DB.Model(&models.User{}).Count(&userCount)
-
-	var roleCount int64
-	database.DB.Model(&models.Role{}).Count(&roleCount)
-
-	var permissionCount int64
-	database.DB.Model(&models.Permission{}).Count(&permissionCount)
-
-	var logCount int64
-	database.DB.Model(&models.OperationLog{}).Count(&logCount)
+	stats, err := ctrl.dashboard.Stats(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "查询统计数据失败")
+		return
+	}
 
-	utils.SuccessResponse(c, gin.H{
-		"userCount":    userCount,
-		"roleCount":    roleCount,
-		"permissionCount": permissionCount,
-		"logCount":      logCount,
-	})
+	utils.SuccessResponse(c, stats)
 }
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/http"
+	"superhoneypotguard/services"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError 将服务层错误映射为 HTTP 状态码并输出统一的错误响应
+func respondError(c *gin.Context, err error, fallback string) {
+	status := http.StatusInternalServerError
+	switch services.KindOf(err) {
+	case services.KindInvalid:
+		status = http.StatusBadRequest
+	case services.KindUnauthorized:
+		status = http.StatusUnauthorized
+	case services.KindForbidden:
+		status = http.StatusForbidden
+	case services.KindNotFound:
+		status = http.StatusNotFound
+	}
+
+	utils.ErrorResponse(c, status, services.MessageOf(err, fallback))
+}
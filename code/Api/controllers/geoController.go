@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"superhoneypotguard/services"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GeoController struct {
+	geo *services.GeoService
+}
+
+func NewGeoController(geo *services.GeoService) *GeoController {
+	return &GeoController{geo: geo}
+}
+
+func (ctrl *GeoController) Lookup(c *gin.Context) {
+	result, err := ctrl.geo.Lookup(c.Param("ip"))
+	if err != nil {
+		respondError(c, err, "查询 IP 地理位置失败")
+		return
+	}
+
+	utils.SuccessResponse(c, result)
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"net/http"
+
+	"superhoneypotguard/services"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	health *services.HealthService
+}
+
+func NewHealthController(health *services.HealthService) *HealthController {
+	return &HealthController{health: health}
+}
+
+// Live 存活检查，不检查依赖，进程能处理请求即返回 200
+func (ctrl *HealthController) Live(c *gin.Context) {
+	utils.StatusResponse(c, http.StatusOK, true, "服务运行正常", ctrl.health.Live())
+}
+
+// Ready 就绪检查，关键组件异常时返回 503，非关键组件异常时返回 200 并标记为 degraded
+// 无需认证，只返回整体状态，短时间内复用上一次检查结果；组件明细通过 Details 查看
+func (ctrl *HealthController) Ready(c *gin.Context) {
+	report := ctrl.health.Cached(c.Request.Context())
+	respondReadiness(c, report, report.Summary())
+}
+
+// Details 就绪检查明细，每次重新检查，包含各组件的耗时、错误信息和安全警告，状态码与 Ready 一致
+func (ctrl *HealthController) Details(c *gin.Context) {
+	report := ctrl.health.Ready(c.Request.Context())
+	respondReadiness(c, report, report)
+}
+
+func respondReadiness(c *gin.Context, report *services.HealthReport, data interface{}) {
+	if !report.Ready() {
+		utils.StatusResponse(c, http.StatusServiceUnavailable, false, "服务未就绪", data)
+		return
+	}
+
+	message := "服务就绪"
+	if report.Status == services.HealthDegraded {
+		message = "服务就绪，部分组件异常"
+	}
+	utils.StatusResponse(c, http.StatusOK, true, message, data)
+}
@@ -1,256 +1,156 @@
 package controllers
 
 import (
-	"bytes"
-	"crypto/tls"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/models"
+	"superhoneypotguard/services"
 	"superhoneypotguard/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-type HFishController struct{}
-
-func NewHFishController() *HFishController {
-	return &HFishController{}
-}
-
-const (
-	HFishBaseURL = "https://115.190.62.202:4433/api/v1"
-	APIKey       = "sOPdmLBemXeWqPmizIvkMqKrRIgdnqkqbzOMciukucEiBFVAhcotwVLoLnsGgyNa"
-)
-
-type AttackIPResponse struct {
-	Success bool       `json:"success"`
-	Message string     `json:"message"`
-	Data    []AttackIP `json:"data"`
-}
-
-type AttackIP struct {
-	ID        string `json:"id"`
-	IP        string `json:"ip"`
-	Count     int    `json:"count"`
-	FirstSeen string `json:"first_seen"`
-	LastSeen  string `json:"last_seen"`
-}
-
-type AttackDetailResponse struct {
-	Success bool           `json:"success"`
-	Message string         `json:"message"`
-	Data    []AttackDetail `json:"data"`
-}
-
-type AttackDetail struct {
-	ID          string `json:"id"`
-	IP          string `json:"ip"`
-	AttackType  string `json:"attack_type"`
-	Protocol    string `json:"protocol"`
-	Port        int    `json:"port"`
-	Payload     string `json:"payload"`
-	RequestTime string `json:"request_time"`
-	Account     string `json:"account"`
-}
-
-type AccountInfoResponse struct {
-	Success bool          `json:"success"`
-	Message string        `json:"message"`
-	Data    []AccountInfo `json:"data"`
-}
+// HFishUnavailableHeader 汇总多个实例时，调用失败而未包含在结果中的实例 ID，逗号分隔
+const HFishUnavailableHeader = "X-HFish-Unavailable"
 
-type AccountInfo struct {
-	ID          string `json:"id"`
-	Username    string `json:"username"`
-	Password    string `json:"password"`
-	Protocol    string `json:"protocol"`
-	IP          string `json:"ip"`
-	AttackCount int    `json:"attack_count"`
-}
+// HFishStaleHeader 同步失败、返回的是之前保存的攻击数据的实例 ID，逗号分隔
+const HFishStaleHeader = "X-HFish-Stale"
 
-type SysInfoResponse struct {
-	Success bool    `json:"success"`
-	Message string  `json:"message"`
-	Data    SysInfo `json:"data"`
+type HFishController struct {
+	hfish *services.HFishService
 }
 
-type SysInfo struct {
-	TotalHoneypots  int    `json:"total_honeypots"`
-	ActiveHoneypots int    `json:"active_honeypots"`
-	TotalAttacks    int    `json:"total_attacks"`
-	LastAttackTime  string `json:"last_attack_time"`
-	SystemStatus    string `json:"system_status"`
-}
-
-var httpClient = &http.Client{
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	},
+func NewHFishController(hfish *services.HFishService) *HFishController {
+	return &HFishController{hfish: hfish}
 }
 
+// GetAttackIPs 支持按 ip、startTime、endTime 筛选，按 sortBy、sortOrder 排序并分页
 func (ctrl *HFishController) GetAttackIPs(c *gin.Context) {
-	url := fmt.Sprintf("%s/attack/ip?api_key=%s", HFishBaseURL, APIKey)
-	log.Printf("调用 HFish API: %s", url)
-
-	resp, err := httpClient.Post(url, "application/json", nil)
-	if err != nil {
-		log.Printf("调用 HFish API 失败: %v", err)
-		utils.ErrorResponse(c, 500, "调用 HFish API 失败: "+err.Error())
-		return
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("HFish API 响应状态码: %d, 响应内容: %s", resp.StatusCode, string(body))
-
-	var result AttackIPResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("解析 HFish API 响应失败: %v", err)
-		utils.ErrorResponse(c, 500, "解析 HFish API 响应失败")
+	query, ok := hfishQuery(c)
+	if !ok {
 		return
 	}
-
-	if !result.Success {
-		log.Printf("HFish API 返回错误: %s", result.Message)
-		utils.ErrorResponse(c, 500, result.Message)
+	result, stale, err := ctrl.hfish.AttackIPs(c.Request.Context(), query, parseInt(c.DefaultQuery("page", "1")), parseInt(c.DefaultQuery("pageSize", "10")))
+	if err != nil {
+		respondError(c, err, "调用 HFish API 失败")
 		return
 	}
 
-	utils.SuccessResponse(c, result.Data)
+	setInstanceHeader(c, HFishStaleHeader, stale)
+	utils.SuccessResponse(c, result)
 }
 
+// GetAttackDetails 在 GetAttackIPs 的基础上还支持按 attackType、protocol、port、account 筛选
 func (ctrl *HFishController) GetAttackDetails(c *gin.Context) {
-	url := fmt.Sprintf("%s/attack/detail?api_key=%s", HFishBaseURL, APIKey)
-	log.Printf("调用 HFish API: %s", url)
-
-	resp, err := httpClient.Post(url, "application/json", nil)
-	if err != nil {
-		log.Printf("调用 HFish API 失败: %v", err)
-		utils.ErrorResponse(c, 500, "调用 HFish API 失败: "+err.Error())
+	query, ok := hfishQuery(c)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("HFish API 响应状态码: %d, 响应内容: %s", resp.StatusCode, string(body))
-
-	var result AttackDetailResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("解析 HFish API 响应失败: %v", err)
-		utils.ErrorResponse(c, 500, "解析 HFish API 响应失败")
-		return
-	}
-
-	if !result.Success {
-		log.Printf("HFish API 返回错误: %s", result.Message)
-		utils.ErrorResponse(c, 500, result.Message)
+	result, stale, err := ctrl.hfish.AttackDetails(c.Request.Context(), query, parseInt(c.DefaultQuery("page", "1")), parseInt(c.DefaultQuery("pageSize", "10")))
+	if err != nil {
+		respondError(c, err, "调用 HFish API 失败")
 		return
 	}
 
-	utils.SuccessResponse(c, result.Data)
+	setInstanceHeader(c, HFishStaleHeader, stale)
+	utils.SuccessResponse(c, result)
 }
 
 func (ctrl *HFishController) GetAccountInfo(c *gin.Context) {
-	url := fmt.Sprintf("%s/attack/account?api_key=%s", HFishBaseURL, APIKey)
-	log.Printf("调用 HFish API: %s", url)
-
-	resp, err := httpClient.Post(url, "application/json", nil)
-	if err != nil {
-		log.Printf("调用 HFish API 失败: %v", err)
-		utils.ErrorResponse(c, 500, "调用 HFish API 失败: "+err.Error())
+	instanceID, ok := instanceParam(c)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("HFish API 响应状态码: %d, 响应内容: %s", resp.StatusCode, string(body))
-
-	var result AccountInfoResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("解析 HFish API 响应失败: %v", err)
-		utils.ErrorResponse(c, 500, "解析 HFish API 响应失败")
-		return
-	}
-
-	if !result.Success {
-		log.Printf("HFish API 返回错误: %s", result.Message)
-		utils.ErrorResponse(c, 500, result.Message)
+	data, failed, err := ctrl.hfish.AccountInfo(c.Request.Context(), instanceID)
+	if err != nil {
+		respondError(c, err, "调用 HFish API 失败")
 		return
 	}
 
-	utils.SuccessResponse(c, result.Data)
+	setInstanceHeader(c, HFishUnavailableHeader, failed)
+	utils.SuccessResponse(c, data)
 }
 
 func (ctrl *HFishController) GetSysInfo(c *gin.Context) {
-	url := fmt.Sprintf("%s/hfish/sys_info?api_key=%s", HFishBaseURL, APIKey)
-	log.Printf("调用 HFish API: %s", url)
-
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		log.Printf("调用 HFish API 失败: %v", err)
-		utils.ErrorResponse(c, 500, "调用 HFish API 失败: "+err.Error())
-		return
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("HFish API 响应状态码: %d, 响应内容: %s", resp.StatusCode, string(body))
-
-	var result SysInfoResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("解析 HFish API 响应失败: %v", err)
-		utils.ErrorResponse(c, 500, "解析 HFish API 响应失败")
+	instanceID, ok := instanceParam(c)
+	if !ok {
 		return
 	}
-
-	if !result.Success {
-		log.Printf("HFish API 返回错误: %s", result.Message)
-		utils.ErrorResponse(c, 500, result.Message)
+	data, failed, err := ctrl.hfish.SysInfo(c.Request.Context(), instanceID)
+	if err != nil {
+		respondError(c, err, "调用 HFish API 失败")
 		return
 	}
 
-	utils.SuccessResponse(c, result.Data)
+	setInstanceHeader(c, HFishUnavailableHeader, failed)
+	utils.SuccessResponse(c, data)
 }
 
 func (ctrl *HFishController) BlockIP(c *gin.Context) {
-	var req struct {
-		IP     string `json:"ip" binding:"required"`
-		Reason string `json:"reason"`
-	}
-
+	var req models.BlockIPRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("参数验证失败: %v", err)
+		slog.WarnContext(c.Request.Context(), "参数验证失败", "error", err)
 		utils.ErrorResponse(c, 400, "参数验证失败")
 		return
 	}
 
-	url := fmt.Sprintf("%s/attack/ip/block?api_key=%s", HFishBaseURL, APIKey)
-	log.Printf("调用 HFish API 封禁 IP: %s, IP: %s, Reason: %s", url, req.IP, req.Reason)
-
-	jsonData, _ := json.Marshal(req)
-	resp, err := httpClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	middleware.RecordAudit(c, models.AuditEvent{
+		Action:      "hfish.block_ip",
+		TargetType:  "ip",
+		TargetID:    req.IP,
+		Description: "封禁 IP " + req.IP,
+	})
+	results, err := ctrl.hfish.BlockIP(c.Request.Context(), req.IP, req.Reason, req.InstanceIDs)
 	if err != nil {
-		log.Printf("调用 HFish API 失败: %v", err)
-		utils.ErrorResponse(c, 500, "调用 HFish API 失败: "+err.Error())
+		respondError(c, err, "封禁 IP 失败")
 		return
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("HFish API 响应状态码: %d, 响应内容: %s", resp.StatusCode, string(body))
+	utils.SuccessResponse(c, results)
+}
 
-	var result map[string]interface{}
-	json.Unmarshal(body, &result)
+// instanceParam 解析查询参数 instanceId，未指定时返回 0 表示全部实例；取值无效时已输出错误响应
+func instanceParam(c *gin.Context) (int, bool) {
+	raw := c.Query("instanceId")
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的实例 ID")
+		return 0, false
+	}
+	return id, true
+}
 
-	if result["success"] == true {
-		log.Printf("封禁 IP 成功: %s", req.IP)
-		utils.SuccessResponse(c, nil)
-	} else {
-		log.Printf("封禁 IP 失败: %s", result["message"])
-		utils.ErrorResponse(c, 500, fmt.Sprintf("封禁 IP 失败: %v", result["message"]))
+// hfishQuery 读取攻击数据的查询参数，实例 ID 无效时已写出错误响应
+func hfishQuery(c *gin.Context) (services.HFishQuery, bool) {
+	instanceID, ok := instanceParam(c)
+	return services.HFishQuery{
+		InstanceID: instanceID,
+		IP:         c.Query("ip"),
+		StartTime:  c.Query("startTime"),
+		EndTime:    c.Query("endTime"),
+		AttackType: c.Query("attackType"),
+		Protocol:   c.Query("protocol"),
+		Port:       c.Query("port"),
+		Account:    c.Query("account"),
+		SortBy:     c.Query("sortBy"),
+		SortOrder:  c.Query("sortOrder"),
+	}, ok
+}
+
+func setInstanceHeader(c *gin.Context, header string, instanceIDs []int) {
+	if len(instanceIDs) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(instanceIDs))
+	for _, id := range instanceIDs {
+		ids = append(ids, strconv.Itoa(id))
 	}
+	c.Header(header, strings.Join(ids, ","))
 }
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"net/http"
+
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/models"
+	"superhoneypotguard/redact"
+	"superhoneypotguard/services"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HFishInstanceController struct {
+	hfish *services.HFishService
+}
+
+func NewHFishInstanceController(hfish *services.HFishService) *HFishInstanceController {
+	return &HFishInstanceController{hfish: hfish}
+}
+
+func (ctrl *HFishInstanceController) GetList(c *gin.Context) {
+	instances, err := ctrl.hfish.List()
+	if err != nil {
+		respondError(c, err, "查询 HFish 实例失败")
+		return
+	}
+
+	utils.SuccessResponse(c, instances)
+}
+
+func (ctrl *HFishInstanceController) GetById(c *gin.Context) {
+	instance, err := ctrl.hfish.Get(parseInt(c.Param("id")))
+	if err != nil {
+		respondError(c, err, "查询 HFish 实例失败")
+		return
+	}
+
+	utils.SuccessResponse(c, instance)
+}
+
+func (ctrl *HFishInstanceController) Create(c *gin.Context) {
+	var req models.CreateHFishInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数验证失败")
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+
+	instance, err := ctrl.hfish.Create(req, currentUser.UserID)
+	if err != nil {
+		audit(c, "hfish_instance.create", "hfish_instance", 0, "创建 HFish 实例 "+req.Name, nil, nil)
+		respondError(c, err, "创建 HFish 实例失败")
+		return
+	}
+	audit(c, "hfish_instance.create", "hfish_instance", instance.ID, "创建 HFish 实例 "+instance.Name, nil, hfishInstanceAuditView(instance))
+
+	utils.SuccessResponse(c, instance)
+}
+
+func (ctrl *HFishInstanceController) Update(c *gin.Context) {
+	var req models.UpdateHFishInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数验证失败")
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	id := parseInt(c.Param("id"))
+	before, _ := ctrl.hfish.Get(id)
+
+	replaced, err := ctrl.hfish.Update(id, req, currentUser.UserID)
+	if err != nil {
+		audit(c, "hfish_instance.update", "hfish_instance", id, "修改 HFish 实例 "+hfishInstanceLabel(id, before), nil, nil)
+		respondError(c, err, "更新 HFish 实例失败")
+		return
+	}
+	after, _ := ctrl.hfish.Get(id)
+	changes := services.AuditDiff(hfishInstanceAuditView(before), hfishInstanceAuditView(after))
+	for _, field := range replaced {
+		if changes == nil {
+			changes = make(map[string]models.AuditChange)
+		}
+		// API Key 和客户端私钥只记录发生了替换，不记录取值
+		changes[field] = models.AuditChange{Before: redact.Mask, After: redact.Mask}
+	}
+	auditChanges(c, "hfish_instance.update", "hfish_instance", id, "修改 HFish 实例 "+hfishInstanceLabel(id, before), changes)
+
+	utils.SuccessResponse(c, after)
+}
+
+func (ctrl *HFishInstanceController) Delete(c *gin.Context) {
+	id := parseInt(c.Param("id"))
+	before, _ := ctrl.hfish.Get(id)
+
+	if err := ctrl.hfish.Delete(id); err != nil {
+		audit(c, "hfish_instance.delete", "hfish_instance", id, "删除 HFish 实例 "+hfishInstanceLabel(id, before), nil, nil)
+		respondError(c, err, "删除 HFish 实例失败")
+		return
+	}
+	audit(c, "hfish_instance.delete", "hfish_instance", id, "删除 HFish 实例 "+hfishInstanceLabel(id, before), hfishInstanceAuditView(before), nil)
+
+	utils.SuccessResponse(c, nil)
+}
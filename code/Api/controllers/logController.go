@@ -1,87 +1,146 @@
 package controllers
 
 import (
-	"superhoneypotguard/database"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"superhoneypotguard/middleware"
 	"superhoneypotguard/models"
+	"superhoneypotguard/services"
 	"superhoneypotguard/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-type LogController struct{}
+type LogController struct {
+	logs  *services.LogService
+	chain *services.AuditChainService
+}
 
-func NewLogController() *LogController {
-	return &LogController{}
+func NewLogController(logs *services.LogService, chain *services.AuditChainService) *LogController {
+	return &LogController{logs: logs, chain: chain}
 }
 
+// GetList 查询操作日志；带 cursor 参数（第一页传空值）时使用游标分页，响应中返回 nextCursor 而不统计总数
 func (ctrl *LogController) GetList(c *gin.Context) {
-	page := c.DefaultQuery("page", "1")
-	pageSize := c.DefaultQuery("pageSize", "10")
-	username := c.Query("username")
-	operation := c.Query("operation")
-	status := c.Query("status")
+	pageSize := parseInt(c.DefaultQuery("pageSize", "10"))
+
+	var (
+		result interface{}
+		err    error
+	)
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		result, err = ctrl.logs.Scroll(logQuery(c), cursor, pageSize)
+	} else {
+		result, err = ctrl.logs.List(logQuery(c), parseInt(c.DefaultQuery("page", "1")), pageSize)
+	}
+	if err != nil {
+		respondError(c, err, "查询日志失败")
+		return
+	}
 
-	query := database.DB.Model(&models.OperationLog{})
+	utils.SuccessResponse(c, result)
+}
 
-	if username != "" {
-		query = query.Where("username LIKE ?", "%"+username+"%")
+// Export 按与列表相同的条件流式导出日志，format 可选 csv、jsonl、xlsx
+func (ctrl *LogController) Export(c *gin.Context) {
+	exp, err := ctrl.logs.Export(logQuery(c), c.Query("format"))
+	if err != nil {
+		respondError(c, err, "导出日志失败")
+		return
 	}
 
-	if operation != "" {
-		query = query.Where("operation LIKE ?", "%"+operation+"%")
+	format := exp.Format()
+	filename := fmt.Sprintf("operation-logs-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	err = exp.Write(c.Writer)
+	description := fmt.Sprintf("导出操作日志（%s，%d 条）", strings.ToUpper(string(format)), exp.Count)
+	if err != nil {
+		// 响应已开始写出，无法再返回错误信息，文件不完整
+		slog.ErrorContext(c.Request.Context(), "导出操作日志失败", "format", string(format), "written", exp.Count, "error", err)
+		description = fmt.Sprintf("导出操作日志失败（%s，已写出 %d 条）", strings.ToUpper(string(format)), exp.Count)
 	}
+	middleware.RecordAudit(c, models.AuditEvent{Action: "log.export", TargetType: "log", Description: description})
+}
 
-	if status != "" {
-		query = query.Where("status = ?", status)
+// logQuery 从查询参数中读取日志过滤和排序条件
+func logQuery(c *gin.Context) services.LogQuery {
+	return services.LogQuery{
+		Username:       c.Query("username"),
+		Operation:      c.Query("operation"),
+		Status:         c.Query("status"),
+		Action:         c.Query("action"),
+		TargetType:     c.Query("targetType"),
+		TargetID:       c.Query("targetId"),
+		UserID:         c.Query("userId"),
+		Method:         c.Query("method"),
+		IP:             c.Query("ip"),
+		StartTime:      c.Query("startTime"),
+		EndTime:        c.Query("endTime"),
+		MinExecuteTime: c.Query("minExecuteTime"),
+		Keyword:        c.Query("keyword"),
+		RequestID:      c.Query("requestId"),
+		TraceID:        c.Query("traceId"),
+		SortBy:         c.Query("sortBy"),
+		SortOrder:      c.Query("sortOrder"),
 	}
-
-	var total int64
-	query.Count(&total)
-
-	offset := (parseInt(page) - 1) * parseInt(pageSize)
-
-	var logs []models.OperationLog
-	query.Offset(offset).Limit(parseInt(pageSize)).Order("created_at DESC").Find(&logs)
-
-	utils.SuccessResponse(c, models.PaginatedResponse{
-		List:     logs,
-		Total:    total,
-		Page:     parseInt(page),
-		PageSize: parseInt(pageSize),
-	})
 }
 
 func (ctrl *LogController) GetById(c *gin.Context) {
-	id := c.Param("id")
-
-	var log models.OperationLog
-	if err := database.DB.Where("id = ?", id).First(&log).Error; err != nil {
-		utils.ErrorResponse(c, 404, "日志不存在")
+	log, err := ctrl.logs.Get(parseInt(c.Param("id")))
+	if err != nil {
+		respondError(c, err, "查询日志失败")
 		return
 	}
 
 	utils.SuccessResponse(c, log)
 }
 
-func (ctrl *LogController) Delete(c *gin.Context) {
-	id := c.Param("id")
+// GetPipelineStats 异步日志管道的队列深度、落盘待回放条数及写入/丢弃计数
+func (ctrl *LogController) GetPipelineStats(c *gin.Context) {
+	utils.SuccessResponse(c, middleware.GetLogPipelineStats())
+}
+
+// Verify 校验操作日志哈希链及签名检查点，发现的问题在 issues 中列出
+func (ctrl *LogController) Verify(c *gin.Context) {
+	report, err := ctrl.chain.Verify()
+	if err != nil {
+		respondError(c, err, "校验日志失败")
+		return
+	}
+
+	utils.SuccessResponse(c, report)
+}
 
-	var log models.OperationLog
-	if err := database.DB.Where("id = ?", id).First(&log).Error; err != nil {
-		utils.ErrorResponse(c, 404, "日志不存在")
+// Archive 将指定时间之前的日志导出到归档文件后从数据库删除，没有可归档的日志时 data 为 null
+func (ctrl *LogController) Archive(c *gin.Context) {
+	var req models.ArchiveLogsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数验证失败")
 		return
 	}
 
-	if err := database.DB.Delete(&log).Error; err != nil {
-		utils.ErrorResponse(c, 500, "删除日志失败")
+	archive, err := ctrl.chain.Archive(*req.Before, services.RetentionSourceManual, currentActor(c))
+	if err != nil {
+		respondError(c, err, "归档日志失败")
 		return
 	}
 
-	utils.SuccessResponse(c, nil)
+	utils.SuccessResponse(c, archive)
 }
 
-func (ctrl *LogController) Clear(c *gin.Context) {
-	database.DB.Exec("DELETE FROM operation_logs")
+func (ctrl *LogController) GetArchives(c *gin.Context) {
+	archives, err := ctrl.chain.ListArchives()
+	if err != nil {
+		respondError(c, err, "查询归档记录失败")
+		return
+	}
 
-	utils.SuccessResponse(c, nil)
+	utils.SuccessResponse(c, archives)
 }
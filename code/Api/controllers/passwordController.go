@@ -2,7 +2,7 @@ package controllers
 
 import (
 	"net/http"
-	"superhoneypotguard/database"
+	"superhoneypotguard/middleware"
 	"superhoneypotguard/models"
 	"superhoneypotguard/services"
 	"superhoneypotguard/utils"
@@ -10,10 +10,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type PasswordController struct{}
+type PasswordController struct {
+	auth *services.AuthService
+}
 
-func NewPasswordController() *PasswordController {
-	return &PasswordController{}
+func NewPasswordController(auth *services.AuthService) *PasswordController {
+	return &PasswordController{auth: auth}
 }
 
 func (ctrl *PasswordController) SendResetPasswordCode(c *gin.Context) {
@@ -26,9 +28,8 @@ func (ctrl *PasswordController) SendResetPasswordCode(c *gin.Context) {
 		return
 	}
 
-	emailService := services.NewEmailService(database.DB)
-	if err := emailService.SendResetPasswordCode(req.Email); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "发送验证码失败: "+err.Error())
+	if err := ctrl.auth.SendResetPasswordCode(c.Request.Context(), req.Email); err != nil {
+		respondError(c, err, "发送验证码失败")
 		return
 	}
 
@@ -47,27 +48,14 @@ func (ctrl *PasswordController) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	emailService := services.NewEmailService(database.DB)
-	if !emailService.VerifyCode(req.Email, req.Code) {
-		utils.ErrorResponse(c, http.StatusBadRequest, "验证码错误或已过期")
-		return
-	}
-
-	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
-		return
-	}
-
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "密码加密失败")
-		return
-	}
-
-	user.Password = hashedPassword
-	if err := database.DB.Save(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "密码重置失败")
+	middleware.RecordAudit(c, models.AuditEvent{
+		Action:      "auth.reset_password",
+		TargetType:  "email",
+		TargetID:    req.Email,
+		Description: "通过邮箱验证码重置 " + req.Email + " 的密码",
+	})
+	if err := ctrl.auth.ResetPassword(c.Request.Context(), req.Email, req.Code, req.NewPassword); err != nil {
+		respondError(c, err, "密码重置失败")
 		return
 	}
 
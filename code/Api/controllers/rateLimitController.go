@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RateLimitController struct{}
+
+func NewRateLimitController() *RateLimitController {
+	return &RateLimitController{}
+}
+
+// GetStatus 限流策略及当前被限流的客户端
+func (ctrl *RateLimitController) GetStatus(c *gin.Context) {
+	status, err := middleware.GetRateLimitStatus(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "查询限流状态失败")
+		return
+	}
+
+	utils.SuccessResponse(c, status)
+}
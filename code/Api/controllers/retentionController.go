@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/models"
+	"superhoneypotguard/services"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RetentionController struct {
+	retention *services.RetentionService
+}
+
+func NewRetentionController(retention *services.RetentionService) *RetentionController {
+	return &RetentionController{retention: retention}
+}
+
+// GetStatus 各保留策略的配置及最近一次执行结果
+func (ctrl *RetentionController) GetStatus(c *gin.Context) {
+	status, err := ctrl.retention.Status()
+	if err != nil {
+		respondError(c, err, "查询保留策略失败")
+		return
+	}
+
+	utils.SuccessResponse(c, status)
+}
+
+func (ctrl *RetentionController) GetRuns(c *gin.Context) {
+	page := parseInt(c.DefaultQuery("page", "1"))
+	pageSize := parseInt(c.DefaultQuery("pageSize", "10"))
+
+	result, err := ctrl.retention.ListRuns(c.Query("policy"), page, pageSize)
+	if err != nil {
+		respondError(c, err, "查询保留策略执行记录失败")
+		return
+	}
+
+	utils.SuccessResponse(c, result)
+}
+
+// Run 立即执行保留策略，请求体为空时执行全部已启用的策略
+func (ctrl *RetentionController) Run(c *gin.Context) {
+	var req models.RunRetentionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "参数验证失败")
+			return
+		}
+	}
+
+	runs, err := ctrl.retention.Run(req.Policy, services.RetentionSourceManual, currentActor(c))
+	if err != nil {
+		respondError(c, err, "执行保留策略失败")
+		return
+	}
+
+	summary := make([]string, 0, len(runs))
+	for _, run := range runs {
+		if run.Status == 1 {
+			summary = append(summary, fmt.Sprintf("%s %d 条", run.Policy, run.Rows))
+		} else {
+			summary = append(summary, run.Policy+" 失败")
+		}
+	}
+	middleware.RecordAudit(c, models.AuditEvent{
+		Action:      "retention.run",
+		TargetType:  "retention_policy",
+		TargetID:    req.Policy,
+		Description: "执行数据保留策略（" + strings.Join(summary, "，") + "）",
+	})
+
+	utils.SuccessResponse(c, runs)
+}
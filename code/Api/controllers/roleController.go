@@ -2,89 +2,57 @@ package controllers
 
 import (
 	"net/http"
-	"superhoneypotguard/database"
 	"superhoneypotguard/middleware"
 	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/services"
 	"superhoneypotguard/utils"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-type RoleController struct{}
+type RoleController struct {
+	roles *services.RoleService
+}
 
-func NewRoleController() *RoleController {
-	return &RoleController{}
+func NewRoleController(roles *services.RoleService) *RoleController {
+	return &RoleController{roles: roles}
 }
 
 func (ctrl *RoleController) GetList(c *gin.Context) {
-	page := c.DefaultQuery("page", "1")
-	pageSize := c.DefaultQuery("pageSize", "10")
-	roleName := c.Query("roleName")
-	status := c.Query("status")
-
-	query := database.DB.Model(&models.Role{})
-
-	if roleName != "" {
-		query = query.Where("role_name LIKE ?", "%"+roleName+"%")
-	}
-
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
-
-	var total int64
-	query.Count(&total)
-
-	offset := (parseInt(page) - 1) * parseInt(pageSize)
-
-	var roles []models.Role
-	query.Offset(offset).Limit(parseInt(pageSize)).Order("created_at DESC").Find(&roles)
-
-	for i := range roles {
-		var permissions []models.Permission
-		database.DB.Raw(`
-			SELECT p.id, p.permission_name, p.permission_code, p.permission_type
-			FROM permissions p
-			INNER JOIN role_permissions rp ON p.id = rp.permission_id
-			WHERE rp.role_id = ?
-		`, roles[i].ID).Scan(&permissions)
-		roles[i].Permissions = permissions
+	page := parseInt(c.DefaultQuery("page", "1"))
+	pageSize := parseInt(c.DefaultQuery("pageSize", "10"))
+
+	result, err := ctrl.roles.List(repositories.RoleFilter{
+		RoleName: c.Query("roleName"),
+		Status:   c.Query("status"),
+	}, page, pageSize)
+	if err != nil {
+		respondError(c, err, "查询角色失败")
+		return
 	}
 
-	utils.SuccessResponse(c, models.PaginatedResponse{
-		List:     roles,
-		Total:    total,
-		Page:     parseInt(page),
-		PageSize: parseInt(pageSize),
-	})
+	utils.SuccessResponse(c, result)
 }
 
 func (ctrl *RoleController) GetAll(c *gin.Context) {
-	var roles []models.Role
-	database.DB.Where("status = 1").Order("id ASC").Find(&roles)
+	roles, err := ctrl.roles.ListActive()
+	if err != nil {
+		respondError(c, err, "查询角色失败")
+		return
+	}
 
 	utils.SuccessResponse(c, roles)
 }
 
 func (ctrl *RoleController) GetById(c *gin.Context) {
-	id := c.Param("id")
-
-	var role models.Role
-	if err := database.DB.Where("id = ?", id).First(&role).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "角色不存在")
+	role, err := ctrl.roles.Get(parseInt(c.Param("id")))
+	if err != nil {
+		respondError(c, err, "查询角色失败")
 		return
 	}
 
-	var permissions []models.Permission
-	database.DB.Raw(`
-		SELECT p.id, p.permission_name, p.permission_code, p.permission_type
-		FROM permissions p
-		INNER JOIN role_permissions rp ON p.id = rp.permission_id
-		WHERE rp.role_id = ?
-	`, role.ID).Scan(&permissions)
-	role.Permissions = permissions
-
 	utils.SuccessResponse(c, role)
 }
 
@@ -95,41 +63,16 @@ func (ctrl *RoleController) Create(c *gin.Context) {
 		return
 	}
 
-	var count int64
-	database.DB.Model(&models.Role{}).Where("role_name = ? OR role_code = ?", req.RoleName, req.RoleCode).Count(&count)
-	if count > 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "角色名称或角色编码已存在")
-		return
-	}
-
 	currentUser := middleware.GetCurrentUser(c)
-	status := 1
-	if req.Status != nil {
-		status = *req.Status
-	}
 
-	role := models.Role{
-		RoleName:    req.RoleName,
-		RoleCode:    req.RoleCode,
-		Description: req.Description,
-		Status:      status,
-		CreatedBy:   &currentUser.UserID,
-	}
-
-	if err := database.DB.Create(&role).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "创建角色失败")
+	role, err := ctrl.roles.Create(req, currentUser.UserID)
+	if err != nil {
+		audit(c, "role.create", "role", 0, "创建角色 "+req.RoleName, nil, nil)
+		respondError(c, err, "创建角色失败")
 		return
 	}
-
-	if len(req.PermissionIDs) > 0 {
-		for _, permissionId := range req.PermissionIDs {
-			database.DB.Create(&models.RolePermission{
-				RoleID:       role.ID,
-				PermissionID: permissionId,
-				CreatedBy:    &currentUser.UserID,
-			})
-		}
-	}
+	created, _ := ctrl.roles.Get(role.ID)
+	audit(c, "role.create", "role", role.ID, "创建角色 "+roleLabel(role.ID, created), nil, roleAuditView(created))
 
 	utils.SuccessResponse(c, gin.H{
 		"id":       role.ID,
@@ -138,14 +81,6 @@ func (ctrl *RoleController) Create(c *gin.Context) {
 }
 
 func (ctrl *RoleController) Update(c *gin.Context) {
-	id := c.Param("id")
-
-	var role models.Role
-	if err := database.DB.Where("id = ?", id).First(&role).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "角色不存在")
-		return
-	}
-
 	var req models.UpdateRoleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "参数验证失败")
@@ -153,56 +88,30 @@ func (ctrl *RoleController) Update(c *gin.Context) {
 	}
 
 	currentUser := middleware.GetCurrentUser(c)
-	updates := map[string]interface{}{
-		"role_name":  req.RoleName,
-		"description": req.Description,
-		"updated_by": currentUser.UserID,
-	}
-
-	if req.Status != nil {
-		updates["status"] = *req.Status
-	}
+	id := parseInt(c.Param("id"))
+	before, _ := ctrl.roles.Get(id)
 
-	if err := database.DB.Model(&role).Updates(updates).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "更新角色失败")
+	if err := ctrl.roles.Update(id, req, currentUser.UserID); err != nil {
+		audit(c, "role.update", "role", id, "修改角色 "+roleLabel(id, before), nil, nil)
+		respondError(c, err, "更新角色失败")
 		return
 	}
-
-	if req.PermissionIDs != nil {
-		database.DB.Where("role_id = ?", role.ID).Delete(&models.RolePermission{})
-
-		for _, permissionId := range *req.PermissionIDs {
-			database.DB.Create(&models.RolePermission{
-				RoleID:       role.ID,
-				PermissionID: permissionId,
-				CreatedBy:    &currentUser.UserID,
-			})
-		}
-	}
+	after, _ := ctrl.roles.Get(id)
+	audit(c, "role.update", "role", id, "修改角色 "+roleLabel(id, before), roleAuditView(before), roleAuditView(after))
 
 	utils.SuccessResponse(c, nil)
 }
 
 func (ctrl *RoleController) Delete(c *gin.Context) {
-	id := c.Param("id")
-
-	var count int64
-	database.DB.Model(&models.UserRole{}).Where("role_id = ?", id).Count(&count)
-	if count > 0 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "该角色下还有用户，无法删除")
-		return
-	}
-
-	var role models.Role
-	if err := database.DB.Where("id = ?", id).First(&role).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "角色不存在")
-		return
-	}
+	id := parseInt(c.Param("id"))
+	before, _ := ctrl.roles.Get(id)
 
-	if err := database.DB.Delete(&role).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "删除角色失败")
+	if err := ctrl.roles.Delete(id); err != nil {
+		audit(c, "role.delete", "role", id, "删除角色 "+roleLabel(id, before), nil, nil)
+		respondError(c, err, "删除角色失败")
 		return
 	}
+	audit(c, "role.delete", "role", id, "删除角色 "+roleLabel(id, before), roleAuditView(before), nil)
 
 	utils.SuccessResponse(c, nil)
 }
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"net/http"
+
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/models"
+	"superhoneypotguard/services"
+	"superhoneypotguard/tracing"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SettingController struct {
+	settings *services.SettingService
+}
+
+func NewSettingController(settings *services.SettingService) *SettingController {
+	return &SettingController{settings: settings}
+}
+
+func (ctrl *SettingController) GetList(c *gin.Context) {
+	utils.SuccessResponse(c, ctrl.settings.List())
+}
+
+func (ctrl *SettingController) Update(c *gin.Context) {
+	var req models.UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数验证失败")
+		return
+	}
+
+	if err := ctrl.settings.Update(req.Settings, currentActor(c)); err != nil {
+		respondError(c, err, "保存系统设置失败")
+		return
+	}
+
+	utils.SuccessResponse(c, ctrl.settings.List())
+}
+
+func (ctrl *SettingController) Reset(c *gin.Context) {
+	if err := ctrl.settings.Reset(c.Param("key"), currentActor(c)); err != nil {
+		respondError(c, err, "重置系统设置失败")
+		return
+	}
+
+	utils.SuccessResponse(c, ctrl.settings.List())
+}
+
+// currentActor 当前登录用户及其来源 IP，用于审计
+func currentActor(c *gin.Context) services.Actor {
+	user := middleware.GetCurrentUser(c)
+	return services.Actor{
+		UserID:    user.UserID,
+		Username:  user.Username,
+		IP:        utils.GetClientIP(c),
+		RequestID: c.GetString(middleware.RequestIDContextKey),
+		TraceID:   tracing.TraceID(c.Request.Context()),
+		Method:    c.Request.Method,
+		URL:       c.FullPath(),
+	}
+}
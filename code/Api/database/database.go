@@ -1,68 +1,131 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"superhoneypotguard/config"
-	"superhoneypotguard/models"
+	"superhoneypotguard/logging"
+	"superhoneypotguard/migrations"
+	"superhoneypotguard/tracing"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+)
+
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+
+	// MemoryPath 使用 SQLite 内存数据库，进程退出后数据即丢失
+	MemoryPath = ":memory:"
 )
 
 var DB *gorm.DB
 
+// InitDB 连接数据库并执行所有未执行的迁移
 func InitDB() {
-	cfg := config.AppConfig
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		cfg.DBUser,
-		cfg.DBPassword,
-		cfg.DBHost,
-		cfg.DBPort,
-		cfg.DBName,
-	)
-
-	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	if err := Connect(); err != nil {
+		logging.Fatal("连接数据库失败", "error", err)
+	}
+
+	if _, err := migrations.Up(DB); err != nil {
+		logging.Fatal("执行数据库迁移失败", "error", err)
+	}
+
+	slog.Info("数据库迁移完成")
+}
+
+// Connect 只建立连接并设置全局 DB，不执行迁移
+func Connect() error {
+	db, err := Open(config.AppConfig)
+	if err != nil {
+		return err
+	}
+
+	DB = db
+	slog.Info("数据库连接成功", "driver", config.AppConfig.DBDriver)
+	return nil
+}
+
+// Open 按配置中的 DB_DRIVER 打开数据库连接，不修改全局 DB
+func Open(cfg *config.Config) (*gorm.DB, error) {
+	dialector, err := newDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: NewLogger(GormLogLevel(cfg.LogLevel)),
 		NowFunc: func() time.Time {
 			return time.Now().Local()
 		},
 	})
-
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return nil, err
 	}
 
-	sqlDB, err := DB.DB()
-	if err != nil {
-		log.Fatalf("Failed to get database instance: %v", err)
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register tracing plugin: %w", err)
 	}
 
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database instance: %w", err)
+	}
 
-	log.Println("Database connected successfully")
+	switch {
+	case cfg.DBDriver == DriverSQLite && cfg.DBPath == MemoryPath:
+		// 每个连接都会得到一个独立的内存库，只能保留单连接
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+	case cfg.DBDriver == DriverSQLite:
+		// SQLite 同一时刻只允许一个写者
+		sqlDB.SetMaxOpenConns(1)
+	default:
+		sqlDB.SetMaxIdleConns(10)
+		sqlDB.SetMaxOpenConns(100)
+		sqlDB.SetConnMaxLifetime(time.Hour)
+	}
 
-	autoMigrate()
+	return db, nil
 }
 
-func autoMigrate() {
-	err := DB.AutoMigrate(
-		&models.User{},
-		&models.Role{},
-		&models.Permission{},
-		&models.UserRole{},
-		&models.RolePermission{},
-		&models.OperationLog{},
-	)
+func newDialector(cfg *config.Config) (gorm.Dialector, error) {
+	switch cfg.DBDriver {
+	case DriverMySQL, "":
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			cfg.DBUser,
+			cfg.DBPassword,
+			cfg.DBHost,
+			cfg.DBPort,
+			cfg.DBName,
+		)
+		return mysql.Open(dsn), nil
+	case DriverSQLite:
+		if cfg.DBPath == MemoryPath {
+			return sqlite.Open("file::memory:?_pragma=foreign_keys(1)"), nil
+		}
+		if dir := filepath.Dir(cfg.DBPath); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create sqlite directory: %w", err)
+			}
+		}
+		return sqlite.Open("file:" + cfg.DBPath + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"), nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q (expected %q or %q)", cfg.DBDriver, DriverMySQL, DriverSQLite)
+	}
+}
 
+// Ping 检查数据库连接是否可用
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("Failed to auto migrate: %v", err)
+		return err
 	}
-
-	log.Println("Database migration completed")
+	return sqlDB.PingContext(ctx)
 }
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SlowQueryThreshold 执行时间超过该值的 SQL 以 warn 级别记录
+const SlowQueryThreshold = 200 * time.Millisecond
+
+// GormLogLevel 按 LOG_LEVEL 换算 GORM 日志级别
+// 只有 debug 记录每条 SQL，info 和 warn 只记录慢查询和错误
+func GormLogLevel(level string) logger.LogLevel {
+	switch level {
+	case "debug":
+		return logger.Info
+	case "error":
+		return logger.Error
+	default:
+		return logger.Warn
+	}
+}
+
+// NewLogger 返回写入默认 slog 记录器的 GORM 日志
+//
+// SQL 只记录占位符不记录参数值，验证码、密码哈希等不会出现在日志中；
+// 使用带 context 的方法记录，请求 ID 和追踪 ID 由 logging 包附加。
+func NewLogger(level logger.LogLevel) logger.Interface {
+	return &slogLogger{level: level, slowThreshold: SlowQueryThreshold}
+}
+
+// Scan 通过记录器捕获 SQL，同样只保留占位符
+func init() {
+	logger.RecorderParamsFilter = func(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+		return sql, nil
+	}
+}
+
+type slogLogger struct {
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+func (l *slogLogger) LogMode(level logger.LogLevel) logger.Interface {
+	n := *l
+	n.level = level
+	return &n
+}
+
+func (l *slogLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *slogLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *slogLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *slogLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "SQL 执行失败", "sql", sql, "rows", rows, "elapsed", elapsed, "error", err)
+	case elapsed > l.slowThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "慢查询", "sql", sql, "rows", rows, "elapsed", elapsed, "threshold", l.slowThreshold)
+	case l.level >= logger.Info:
+		sql, rows := fc()
+		slog.DebugContext(ctx, "执行 SQL", "sql", sql, "rows", rows, "elapsed", elapsed)
+	}
+}
+
+// ParamsFilter 丢弃 SQL 参数，日志中只保留占位符
+func (l *slogLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
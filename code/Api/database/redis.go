@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"superhoneypotguard/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// OpenRedis 按 REDIS_* 配置创建 Redis 客户端并检查连通性
+// 连接失败时仍返回客户端，客户端会在之后的请求中自动重连
+func OpenRedis(cfg *config.Config) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     net.JoinHostPort(cfg.RedisHost, cfg.RedisPort),
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+		// 限流在请求路径上，Redis 故障时应尽快失败并退回到进程内限流
+		DialTimeout:  time.Second,
+		ReadTimeout:  500 * time.Millisecond,
+		WriteTimeout: 500 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return client, client.Ping(ctx).Err()
+}
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// utf8BOM 让 Excel 按 UTF-8 打开含中文的 CSV
+const utf8BOM = "\xEF\xBB\xBF"
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return nil, err
+	}
+	cw := &csvWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	for i, col := range columns {
+		cw.record[i] = col.Title
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	for i := range c.record {
+		c.record[i] = ""
+		if i < len(values) {
+			c.record[i] = neutralizeFormula(text(values[i]))
+		}
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// neutralizeFormula 日志内容来自请求，以公式字符开头的值加上单引号，防止在表格软件中被当作公式执行
+func neutralizeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,135 @@
+// Package export 将表格数据按行流式写出为 CSV、JSON Lines 或 XLSX，不在内存中保留已写出的行
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Format 导出格式
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+	XLSX  Format = "xlsx"
+)
+
+// ParseFormat 解析导出格式，空字符串视为 CSV
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case "", CSV:
+		return CSV, nil
+	case JSONL, XLSX:
+		return Format(value), nil
+	}
+	return "", fmt.Errorf("不支持的导出格式 %q（可选: csv、jsonl、xlsx）", value)
+}
+
+// ContentType 响应的 Content-Type
+func (f Format) ContentType() string {
+	switch f {
+	case JSONL:
+		return "application/x-ndjson; charset=utf-8"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Column 导出的一列，Key 用作 JSON 字段名，Title 用作 CSV 和 XLSX 的表头
+type Column struct {
+	Key   string
+	Title string
+}
+
+// Writer 按行写出数据，Close 写出格式要求的结尾但不关闭底层 io.Writer
+type Writer interface {
+	// WriteRow 写入一行，values 与列一一对应；nil 指针写为空值
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// NewWriter 创建指定格式的 Writer，CSV 和 XLSX 会先写出表头
+func NewWriter(format Format, w io.Writer, columns []Column) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w, columns)
+	case JSONL:
+		return &jsonlWriter{w: w, columns: columns}, nil
+	case XLSX:
+		return newXLSXWriter(w, columns)
+	}
+	return nil, fmt.Errorf("不支持的导出格式 %q", format)
+}
+
+// deref 取出指针指向的值，nil 指针返回 nil
+func deref(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// text 单元格的文本形式，时间使用本地时间
+func text(value interface{}) string {
+	switch v := deref(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Local().Format(time.DateTime)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type jsonlWriter struct {
+	w       io.Writer
+	columns []Column
+	buf     []byte
+}
+
+// WriteRow 按列顺序写出一个 JSON 对象
+func (j *jsonlWriter) WriteRow(values []interface{}) error {
+	j.buf = append(j.buf[:0], '{')
+	for i, col := range j.columns {
+		if i > 0 {
+			j.buf = append(j.buf, ',')
+		}
+		key, _ := json.Marshal(col.Key)
+		j.buf = append(j.buf, key...)
+		j.buf = append(j.buf, ':')
+
+		var value interface{}
+		if i < len(values) {
+			value = deref(values[i])
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		j.buf = append(j.buf, data...)
+	}
+	j.buf = append(j.buf, '}', '\n')
+	_, err := j.w.Write(j.buf)
+	return err
+}
+
+func (j *jsonlWriter) Close() error { return nil }
@@ -0,0 +1,156 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type csvRange struct {
+	from, to [16]byte
+	loc      *Location
+}
+
+// csvDatabase 按起始地址排序的不重叠地址段，二分查找
+//
+// 文件的列依次为 network,country_code,country,region,city,asn,as_org：
+// network 可以是 CIDR（1.0.1.0/24）、单个地址或 "起始-结束" 地址段，其余列可省略；
+// 第一行不是合法网段时视为表头，# 开头的行为注释
+type csvDatabase struct {
+	ranges []csvRange
+}
+
+func openCSV(path string) (*csvDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	db := &csvDatabase{}
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		from, to, err := parseNetwork(record[0])
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		loc, err := csvLocation(record)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		db.ranges = append(db.ranges, csvRange{from: from, to: to, loc: loc})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].from[:], db.ranges[j].from[:]) < 0
+	})
+	for i := 1; i < len(db.ranges); i++ {
+		if bytes.Compare(db.ranges[i].from[:], db.ranges[i-1].to[:]) <= 0 {
+			return nil, fmt.Errorf("地址段 %s 与 %s 重叠",
+				netip.AddrFrom16(db.ranges[i].from).Unmap(), netip.AddrFrom16(db.ranges[i-1].from).Unmap())
+		}
+	}
+	return db, nil
+}
+
+func (d *csvDatabase) Lookup(addr netip.Addr) (*Location, error) {
+	key := addr.Unmap().As16()
+	// 第一个起始地址大于 key 的地址段之前的那一段
+	i := sort.Search(len(d.ranges), func(i int) bool {
+		return bytes.Compare(d.ranges[i].from[:], key[:]) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(key[:], d.ranges[i].to[:]) > 0 {
+		return nil, nil
+	}
+	loc := *d.ranges[i].loc
+	return &loc, nil
+}
+
+// parseNetwork 解析 network 列，返回 16 字节形式的起止地址
+func parseNetwork(value string) (from, to [16]byte, err error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.Contains(value, "/"):
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return from, to, fmt.Errorf("无效的网段 %q", value)
+		}
+		prefix = prefix.Masked()
+		bits := prefix.Bits()
+		if prefix.Addr().Is4() {
+			bits += 96
+		}
+		from = prefix.Addr().Unmap().As16()
+		to = from
+		for i := bits; i < 128; i++ {
+			to[i/8] |= 0x80 >> (i % 8)
+		}
+		return from, to, nil
+	case strings.Contains(value, "-"):
+		start, end, _ := strings.Cut(value, "-")
+		a, errA := netip.ParseAddr(strings.TrimSpace(start))
+		b, errB := netip.ParseAddr(strings.TrimSpace(end))
+		if errA != nil || errB != nil || a.Unmap().Is4() != b.Unmap().Is4() {
+			return from, to, fmt.Errorf("无效的地址段 %q", value)
+		}
+		from, to = a.Unmap().As16(), b.Unmap().As16()
+		if bytes.Compare(from[:], to[:]) > 0 {
+			return from, to, fmt.Errorf("地址段 %q 的起始地址大于结束地址", value)
+		}
+		return from, to, nil
+	default:
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return from, to, fmt.Errorf("无效的地址 %q", value)
+		}
+		from = addr.Unmap().As16()
+		return from, from, nil
+	}
+}
+
+func csvLocation(record []string) (*Location, error) {
+	field := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	loc := &Location{
+		CountryCode: strings.ToUpper(field(1)),
+		Country:     field(2),
+		Region:      field(3),
+		City:        field(4),
+		ASOrg:       field(6),
+	}
+	if asn := strings.TrimPrefix(strings.ToUpper(field(5)), "AS"); asn != "" {
+		n, err := strconv.ParseUint(asn, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 ASN %q", field(5))
+		}
+		loc.ASN = uint(n)
+	}
+	return loc, nil
+}
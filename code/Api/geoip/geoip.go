@@ -0,0 +1,95 @@
+// Package geoip 从本地离线数据库查询 IP 的地理位置与所属自治系统
+//
+// 支持 MaxMind 格式（.mmdb，如 GeoLite2-City、GeoLite2-ASN、DB-IP Lite）和 CSV 格式（.csv），
+// 数据库文件更新后由 Resolver.Reload 重新加载，查询不会中断。
+package geoip
+
+import (
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLocationLength 与 operation_logs.location 列的长度一致
+const maxLocationLength = 100
+
+// Location 一个 IP 的地理位置，未知的字段为空
+type Location struct {
+	CountryCode string `json:"countryCode,omitempty"`
+	Country     string `json:"country,omitempty"`
+	Region      string `json:"region,omitempty"`
+	City        string `json:"city,omitempty"`
+	ASN         uint   `json:"asn,omitempty"`
+	ASOrg       string `json:"asOrg,omitempty"`
+	// Private 内网、回环等非公网地址，不查询数据库
+	Private bool `json:"private,omitempty"`
+}
+
+// String 日志中记录的位置描述，如 "中国 广东 深圳 AS4134"
+func (l *Location) String() string {
+	if l == nil {
+		return ""
+	}
+	if l.Private {
+		return "内网"
+	}
+
+	var parts []string
+	for _, name := range []string{l.Country, l.Region, l.City} {
+		if name != "" && (len(parts) == 0 || parts[len(parts)-1] != name) {
+			parts = append(parts, name)
+		}
+	}
+	if l.ASN != 0 {
+		parts = append(parts, fmt.Sprintf("AS%d", l.ASN))
+	}
+	text := strings.Join(parts, " ")
+	if utf8.RuneCountInString(text) > maxLocationLength {
+		text = string([]rune(text)[:maxLocationLength])
+	}
+	return text
+}
+
+// merge 用 other 补齐为空的字段，用于合并城市库与 ASN 库的结果
+func (l *Location) merge(other *Location) {
+	if l.CountryCode == "" {
+		l.CountryCode = other.CountryCode
+	}
+	if l.Country == "" {
+		l.Country = other.Country
+	}
+	if l.Region == "" {
+		l.Region = other.Region
+	}
+	if l.City == "" {
+		l.City = other.City
+	}
+	if l.ASN == 0 {
+		l.ASN, l.ASOrg = other.ASN, other.ASOrg
+	}
+}
+
+// Database 一个已加载的离线数据库，实现需支持并发查询
+type Database interface {
+	// Lookup 查询地址，数据库中没有该地址时返回 nil
+	Lookup(addr netip.Addr) (*Location, error)
+}
+
+// Open 按扩展名打开数据库文件，language 为 mmdb 中名称的首选语言，如 zh-CN
+func Open(path, language string) (Database, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmdb":
+		return openMMDB(path, language)
+	case ".csv":
+		return openCSV(path)
+	default:
+		return nil, fmt.Errorf("不支持的数据库格式 %q（可选: .mmdb、.csv）", filepath.Ext(path))
+	}
+}
+
+// isPrivate 不在公网路由的地址
+func isPrivate(addr netip.Addr) bool {
+	return addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsUnspecified()
+}
@@ -0,0 +1,79 @@
+package geoip
+
+import (
+	"net"
+	"net/netip"
+	"os"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbRecord 覆盖 City、Country 和 ASN 三类 MaxMind 数据库的字段，缺失的字段保持为空
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"registered_country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+type mmdbDatabase struct {
+	reader   *maxminddb.Reader
+	language string
+}
+
+// openMMDB 将整个文件读入内存而不是使用 mmap，重新加载后旧的数据库可以安全地交给 GC 回收
+func openMMDB(path, language string) (*mmdbDatabase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return &mmdbDatabase{reader: reader, language: language}, nil
+}
+
+func (d *mmdbDatabase) Lookup(addr netip.Addr) (*Location, error) {
+	var record mmdbRecord
+	_, ok, err := d.reader.LookupNetwork(net.IP(addr.AsSlice()), &record)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	loc := &Location{
+		CountryCode: record.Country.ISOCode,
+		Country:     d.name(record.Country.Names),
+		City:        d.name(record.City.Names),
+		ASN:         record.ASN,
+		ASOrg:       record.ASOrg,
+	}
+	if loc.CountryCode == "" {
+		loc.CountryCode = record.RegisteredCountry.ISOCode
+		loc.Country = d.name(record.RegisteredCountry.Names)
+	}
+	if len(record.Subdivisions) > 0 {
+		loc.Region = d.name(record.Subdivisions[0].Names)
+	}
+	return loc, nil
+}
+
+// name 优先使用配置的语言，没有时使用英文
+func (d *mmdbDatabase) name(names map[string]string) string {
+	if name := names[d.language]; name != "" {
+		return name
+	}
+	return names["en"]
+}
@@ -0,0 +1,151 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrInvalidIP  = errors.New("无效的 IP 地址")
+	ErrNoDatabase = errors.New("未加载 IP 地理位置数据库")
+)
+
+// fileStamp 用修改时间和大小判断数据库文件是否被替换
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot 一组同时加载的数据库，按配置顺序查询并合并结果
+type snapshot struct {
+	dbs      []Database
+	stamps   []fileStamp
+	loadedAt time.Time
+}
+
+// Resolver 持有当前加载的数据库，Reload 在文件变化后原子替换，进行中的查询不受影响
+type Resolver struct {
+	paths    []string
+	language string
+
+	current atomic.Pointer[snapshot]
+	// mu 串行化 Reload
+	mu sync.Mutex
+}
+
+// NewResolver 创建解析器并加载数据库，paths 为空时不启用
+// 加载失败时返回的解析器仍可使用，之后的 Reload 会重试
+func NewResolver(paths []string, language string) (*Resolver, error) {
+	r := &Resolver{paths: paths, language: language}
+	if !r.Enabled() {
+		return r, nil
+	}
+	_, err := r.Reload()
+	return r, err
+}
+
+// Enabled 是否配置了数据库
+func (r *Resolver) Enabled() bool {
+	return len(r.paths) > 0
+}
+
+// LoadedAt 当前数据库的加载时间，未加载时为零值
+func (r *Resolver) LoadedAt() time.Time {
+	if s := r.current.Load(); s != nil {
+		return s.loadedAt
+	}
+	return time.Time{}
+}
+
+// Reload 任一数据库文件变化时重新加载全部文件，返回是否发生了替换
+// 加载失败时继续使用原来的数据库
+func (r *Resolver) Reload() (bool, error) {
+	if !r.Enabled() {
+		return false, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamps := make([]fileStamp, len(r.paths))
+	for i, path := range r.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	if old := r.current.Load(); old != nil && sameStamps(old.stamps, stamps) {
+		return false, nil
+	}
+
+	next := &snapshot{stamps: stamps, loadedAt: time.Now()}
+	for _, path := range r.paths {
+		db, err := Open(path, r.language)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", path, err)
+		}
+		next.dbs = append(next.dbs, db)
+	}
+	r.current.Store(next)
+	return true, nil
+}
+
+// Lookup 查询 IP 的位置，内网地址直接返回 Private，数据库中没有该地址时返回 nil
+func (r *Resolver) Lookup(ip string) (*Location, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return nil, ErrInvalidIP
+	}
+	addr = addr.Unmap()
+	if isPrivate(addr) {
+		return &Location{Private: true}, nil
+	}
+
+	s := r.current.Load()
+	if s == nil {
+		return nil, ErrNoDatabase
+	}
+	var result *Location
+	for _, db := range s.dbs {
+		loc, err := db.Lookup(addr)
+		if err != nil {
+			return nil, err
+		}
+		if loc == nil {
+			continue
+		}
+		if result == nil {
+			result = loc
+		} else {
+			result.merge(loc)
+		}
+	}
+	return result, nil
+}
+
+// Locate 返回用于记录的位置描述，无法查询时返回空字符串
+func (r *Resolver) Locate(ip string) string {
+	loc, err := r.Lookup(ip)
+	if err != nil {
+		return ""
+	}
+	return loc.String()
+}
+
+func sameStamps(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
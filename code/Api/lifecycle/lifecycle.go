@@ -0,0 +1,228 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Component 由 Manager 启动、并在退出时按注册顺序的逆序停止的组件
+type Component struct {
+	Name string
+	// Run 在独立协程中运行，应在 ctx 取消后尽快返回；为 nil 表示没有后台任务
+	Run func(ctx context.Context) error
+	// Stop 在关闭阶段调用，ctx 带有该组件的排空时间；可为 nil
+	Stop func(ctx context.Context) error
+}
+
+type entry struct {
+	Component
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	lastBeat time.Time
+	next     time.Duration
+	err      error
+}
+
+// ComponentStatus 组件的运行状态，只包含有后台任务（Run 不为 nil）的组件
+type ComponentStatus struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+	// LastBeat 最近一次心跳，组件从未调用 Beat 时为零值
+	LastBeat time.Time `json:"lastBeat"`
+	// Stale 超过两倍预计间隔没有心跳，后台任务可能卡住
+	Stale bool   `json:"stale"`
+	Error string `json:"error,omitempty"`
+}
+
+// Healthy 组件仍在运行且心跳正常
+func (s ComponentStatus) Healthy() bool {
+	return s.Running && !s.Stale
+}
+
+type heartbeatKey struct{}
+
+// Beat 由组件的 Run 定期调用，报告后台任务仍在正常工作；next 为距离下一次心跳的最长预计间隔，
+// 超过两倍 next 没有心跳时 Status 将组件标记为停滞。ctx 必须是 Manager 传给 Run 的 ctx
+func Beat(ctx context.Context, next time.Duration) {
+	e, ok := ctx.Value(heartbeatKey{}).(*entry)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	e.lastBeat = time.Now()
+	e.next = next
+	e.mu.Unlock()
+}
+
+func (e *entry) status(now time.Time) ComponentStatus {
+	s := ComponentStatus{Name: e.Name, Running: true}
+	select {
+	case <-e.done:
+		s.Running = false
+	default:
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s.LastBeat = e.lastBeat
+	s.Stale = s.Running && e.next > 0 && now.Sub(e.lastBeat) > 2*e.next
+	if e.err != nil {
+		s.Error = e.err.Error()
+	}
+	return s
+}
+
+// Manager 管理后台组件的启动与有序关闭
+//
+// 组件按 Add 的顺序启动，关闭时逆序停止：先注册的基础设施（数据库、日志写入）
+// 最后关闭，从而保证后注册的组件（HTTP 服务、定时任务）在排空时仍然可用。
+type Manager struct {
+	mu       sync.Mutex
+	entries  []*entry
+	stopping bool
+
+	failOnce sync.Once
+	failed   chan error
+}
+
+func New() *Manager {
+	return &Manager{failed: make(chan error, 1)}
+}
+
+// Add 注册并启动组件；Run 在关闭前返回错误会触发整个进程退出
+func (m *Manager) Add(c Component) {
+	e := &entry{Component: c, done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), heartbeatKey{}, e))
+	e.cancel = cancel
+
+	m.mu.Lock()
+	m.entries = append(m.entries, e)
+	m.mu.Unlock()
+
+	if c.Run == nil {
+		close(e.done)
+		return
+	}
+
+	go func() {
+		defer close(e.done)
+		err := c.Run(ctx)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		e.mu.Lock()
+		e.err = err
+		e.mu.Unlock()
+		slog.Error("组件异常退出", "component", c.Name, "error", err)
+		m.failOnce.Do(func() {
+			m.failed <- fmt.Errorf("%s: %w", c.Name, err)
+		})
+	}()
+}
+
+// Wait 阻塞直到收到 SIGINT/SIGTERM 或某个组件异常退出，返回组件的错误
+func (m *Manager) Wait() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	// 返回后恢复默认信号处理，关闭过程中再次按 Ctrl+C 可强制退出
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+		slog.Info("收到退出信号，开始关闭服务")
+		return nil
+	case err := <-m.failed:
+		return err
+	}
+}
+
+// Shutdown 逆序停止所有组件，返回所有停止失败的错误
+//
+// 每个组件各自最多等待 timeout：前面的组件（如 HTTP 排空）超时不会挤占后面组件的时间，
+// 操作日志写入仍能在数据库连接关闭前把缓冲的日志写完
+func (m *Manager) Shutdown(timeout time.Duration) error {
+	m.mu.Lock()
+	if m.stopping {
+		m.mu.Unlock()
+		return nil
+	}
+	m.stopping = true
+	entries := append([]*entry(nil), m.entries...)
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := entries[i].stop(timeout); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stop 调用 Stop 并取消 Run，最多等待 timeout
+func (e *entry) stop(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+
+	var err error
+	if e.Stop != nil {
+		if stopErr := e.Stop(ctx); stopErr != nil {
+			err = fmt.Errorf("%s: %w", e.Name, stopErr)
+		}
+	}
+	e.cancel()
+
+	select {
+	case <-e.done:
+		slog.Info("组件已停止", "component", e.Name, "duration_ms", time.Since(start).Milliseconds())
+		return err
+	case <-ctx.Done():
+		return errors.Join(err, fmt.Errorf("%s: 等待停止超时", e.Name))
+	}
+}
+
+// Status 返回各后台组件的运行状态，顺序与注册顺序一致
+func (m *Manager) Status() []ComponentStatus {
+	m.mu.Lock()
+	entries := append([]*entry(nil), m.entries...)
+	m.mu.Unlock()
+
+	now := time.Now()
+	list := make([]ComponentStatus, 0, len(entries))
+	for _, e := range entries {
+		if e.Run != nil {
+			list = append(list, e.status(now))
+		}
+	}
+	return list
+}
+
+// Every 返回按固定间隔执行 job 的 Run 函数，ctx 取消后返回
+// 每次执行完成后发送心跳，job 卡住超过两个间隔时组件被标记为停滞
+func Every(interval time.Duration, job func()) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		Beat(ctx, interval)
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				job()
+				Beat(ctx, interval)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package logging 结构化日志
+//
+// 日志按级别过滤后以 JSON 或 logfmt 格式同时写入标准输出和按大小滚动的日志文件。
+// 使用 slog.InfoContext 等带 context 的方法记录时自动附带请求 ID 和追踪 ID；
+// 消息和字段在输出前按脱敏规则替换密码、令牌、API Key 等敏感值。
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"superhoneypotguard/redact"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FileName 日志文件名，滚动后的历史文件名为 superhoneypotguard-<时间>.log
+const FileName = "superhoneypotguard.log"
+
+// RequestIDKey 日志中请求 ID 的字段名
+const RequestIDKey = "request_id"
+
+// 日志中 OpenTelemetry 追踪 ID 和 span ID 的字段名
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// Options 日志配置
+type Options struct {
+	// Level 可选 debug、info、warn、error
+	Level string
+	// Format 可选 json、text（logfmt）
+	Format string
+	// Dir 日志文件目录，为空时只输出到标准输出
+	Dir string
+	// MaxSize 单个日志文件的大小上限（MB）
+	MaxSize int
+	// MaxBackups 保留的历史文件数，0 表示全部保留
+	MaxBackups int
+	// Redactor 脱敏规则，为 nil 时不脱敏
+	Redactor *redact.Redactor
+	// Output 标准输出，为 nil 时使用 os.Stdout
+	Output io.Writer
+}
+
+// ParseLevel 解析日志级别
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("无效的日志级别 %q", level)
+}
+
+// Setup 按配置创建日志记录器并设为默认记录器，标准库 log 包的输出同样经过它，级别为 info
+// 返回的 Closer 用于在退出时关闭日志文件
+func Setup(opts Options) (io.Closer, error) {
+	logger, closer, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return closer, nil
+}
+
+// New 按配置创建日志记录器
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	var closer io.Closer = nopCloser{}
+	if opts.Dir != "" {
+		file, err := OpenRotating(filepath.Join(opts.Dir, FileName), int64(opts.MaxSize)<<20, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = io.MultiWriter(out, file)
+		closer = file
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level, ReplaceAttr: scrubber(opts.Redactor)}
+	var h slog.Handler
+	switch opts.Format {
+	case "json", "":
+		h = slog.NewJSONHandler(out, handlerOpts)
+	case "text":
+		h = slog.NewTextHandler(out, handlerOpts)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("无效的日志格式 %q", opts.Format)
+	}
+	return slog.New(contextHandler{h}), closer, nil
+}
+
+// Fatal 记录 error 级别日志后退出进程
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// scrubber 字段名命中规则时整体替换，字符串值和错误信息中的 key=value 按规则脱敏
+func scrubber(r *redact.Redactor) func(groups []string, a slog.Attr) slog.Attr {
+	if r == nil {
+		return nil
+	}
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key != slog.MessageKey && a.Key != RequestIDKey && r.Key(a.Key) {
+			return slog.String(a.Key, redact.Mask)
+		}
+		switch v := a.Value.Any().(type) {
+		case string:
+			a.Value = slog.StringValue(r.Text(v))
+		case error:
+			a.Value = slog.StringValue(r.Text(v.Error()))
+		}
+		return a
+	}
+}
+
+// contextHandler 从 context 中取出请求 ID 和已采样的追踪 ID 附加到日志上
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && sc.IsSampled() {
+		r.AddAttrs(slog.String(TraceIDKey, sc.TraceID().String()), slog.String(SpanIDKey, sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 返回带有请求 ID 的 context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 取出 context 中的请求 ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID 生成 32 位十六进制的随机请求 ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
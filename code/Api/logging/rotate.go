@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotateTimeFormat 历史文件名中的时间，按字典序排列即为时间顺序
+const rotateTimeFormat = "20060102T150405.000"
+
+// RotatingFile 按大小滚动的日志文件
+// 写入后超过 maxSize 时，当前文件重命名为 <名称>-<时间><扩展名> 并新建文件，历史文件超过 maxBackups 个时删除最早的
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// OpenRotating 打开（不存在时创建）日志文件，maxSize 为 0 时不滚动
+func OpenRotating(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	prefix, ext := f.backupName()
+	backup := prefix + time.Now().Format(rotateTimeFormat) + ext
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("滚动日志文件失败: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// prune 删除超出保留数量的历史文件，删除失败不影响写入
+func (f *RotatingFile) prune() {
+	if f.maxBackups <= 0 {
+		return
+	}
+	prefix, ext := f.backupName()
+	backups, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil || len(backups) <= f.maxBackups {
+		return
+	}
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-f.maxBackups] {
+		os.Remove(name)
+	}
+}
+
+// backupName 历史文件名的前缀和扩展名，如 logs/superhoneypotguard- 和 .log
+func (f *RotatingFile) backupName() (prefix, ext string) {
+	ext = filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-", ext
+}
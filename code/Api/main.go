@@ -1,53 +1,244 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"superhoneypotguard/auditchain"
 	"superhoneypotguard/config"
 	"superhoneypotguard/database"
+	"superhoneypotguard/lifecycle"
+	"superhoneypotguard/logging"
 	"superhoneypotguard/middleware"
+	"superhoneypotguard/migrations"
+	"superhoneypotguard/redact"
+	"superhoneypotguard/repositories"
 	"superhoneypotguard/routes"
 	"superhoneypotguard/services"
+	"superhoneypotguard/tracing"
+	"superhoneypotguard/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
-	config.LoadConfig()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	config.AppConfig = cfg
+
+	// config 子命令用于排查配置问题，不做校验
+	if len(args) > 0 && args[0] == "config" {
+		if err := config.RunCLI(args[1:], cfg, os.Stdout); err != nil {
+			log.Fatalf("config: %v", err)
+		}
+		return
+	}
+
+	// 生成密钥不需要有效的配置，首次部署时还没有可用的 SECRETS_KEY、AUDIT_SIGNING_KEY
+	if len(args) == 2 && args[1] == "keygen" {
+		switch args[0] {
+		case "secrets":
+			if err := services.RunSecretsCLI(args[1:], nil, os.Stdout); err != nil {
+				log.Fatalf("secrets: %v", err)
+			}
+			return
+		case "audit":
+			if err := auditchain.RunCLI(args[1:], nil, os.Stdout); err != nil {
+				log.Fatalf("audit: %v", err)
+			}
+			return
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("配置校验失败:\n%v", err)
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			open := func() (*gorm.DB, error) {
+				return database.Open(cfg)
+			}
+			if err := migrations.RunCLI(args[1:], open, os.Stdout); err != nil {
+				log.Fatalf("migrate: %v", err)
+			}
+		case "audit":
+			open := func() (auditchain.Chain, error) {
+				db, err := database.Open(cfg)
+				if err != nil {
+					return nil, err
+				}
+				repos := repositories.NewRepositories(db)
+				return services.NewAuditChainService(repos.Logs, services.NewAuditService(repos.Logs), cfg)
+			}
+			if err := auditchain.RunCLI(args[1:], open, os.Stdout); err != nil {
+				log.Fatalf("audit: %v", err)
+			}
+		case "retention":
+			open := func() (*services.RetentionService, error) {
+				db, err := database.Open(cfg)
+				if err != nil {
+					return nil, err
+				}
+				repos := repositories.NewRepositories(db)
+				chain, err := services.NewAuditChainService(repos.Logs, services.NewAuditService(repos.Logs), cfg)
+				if err != nil {
+					return nil, err
+				}
+				return services.NewRetentionService(repos.Retention, chain, cfg), nil
+			}
+			if err := services.RunRetentionCLI(args[1:], open, os.Stdout); err != nil {
+				log.Fatalf("retention: %v", err)
+			}
+		case "secrets":
+			open := func() (*services.SecretService, error) {
+				db, err := database.Open(cfg)
+				if err != nil {
+					return nil, err
+				}
+				return services.NewSecretService(repositories.NewSecretRepository(db), cfg)
+			}
+			if err := services.RunSecretsCLI(args[1:], open, os.Stdout); err != nil {
+				log.Fatalf("secrets: %v", err)
+			}
+		default:
+			log.Fatalf("未知命令 %q（可用: migrate、audit、retention、secrets、config）", args[0])
+		}
+		return
+	}
 
-	cfg := config.AppConfig
 	if cfg.GinMode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	log.Printf("数据库配置:")
-	log.Printf("  Host: %s", cfg.DBHost)
-	log.Printf("  Port: %s", cfg.DBPort)
-	log.Printf("  Name: %s", cfg.DBName)
-	log.Printf("  User: %s", cfg.DBUser)
+	// 应用日志与操作日志的请求体使用同一套脱敏规则
+	redactor, err := redact.New(config.SplitList(cfg.LogRedactKeys), config.SplitList(cfg.LogRedactPaths))
+	if err != nil {
+		log.Fatalf("初始化日志脱敏规则失败: %v", err)
+	}
+	logFile, err := logging.Setup(logging.Options{
+		Level:      cfg.LogLevel,
+		Format:     cfg.LogFormat,
+		Dir:        cfg.LogFilePath,
+		MaxSize:    cfg.LogMaxSize,
+		MaxBackups: cfg.LogMaxBackups,
+		Redactor:   redactor,
+	})
+	if err != nil {
+		log.Fatalf("初始化日志失败: %v", err)
+	}
+
+	tracer, err := tracing.Setup(tracing.Options{
+		Exporter:      cfg.TraceExporter,
+		Endpoint:      cfg.TraceOTLPEndpoint,
+		FilePath:      cfg.TraceFilePath,
+		SamplePercent: cfg.TraceSamplePercent,
+	})
+	if err != nil {
+		logging.Fatal("初始化链路追踪失败", "error", err)
+	}
+
+	slog.Info("数据库配置", "driver", cfg.DBDriver, "host", cfg.DBHost, "port", cfg.DBPort, "name", cfg.DBName, "user", cfg.DBUser)
 
 	database.InitDB()
 
-	// 初始化邮件服务，创建验证码表
-	if err := services.InitEmailService(database.DB); err != nil {
-		log.Printf("邮件服务初始化失败: %v", err)
-	} else {
-		log.Printf("邮件服务初始化成功")
+	// 组件按依赖顺序注册，关闭时逆序停止：先停止接收请求，再停定时任务，
+	// 然后把缓冲的操作日志写入数据库，关闭数据库连接，最后导出剩余的追踪数据
+	lc := lifecycle.New()
+	lc.Add(lifecycle.Component{
+		Name: "链路追踪",
+		Stop: tracer.Shutdown,
+	})
+	lc.Add(lifecycle.Component{
+		Name: "数据库连接",
+		Stop: func(ctx context.Context) error {
+			sqlDB, err := database.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.Close()
+		},
+	})
+	err = middleware.ConfigureLogPipeline(middleware.LogPipelineOptions{
+		BatchSize:     cfg.LogBatchSize,
+		FlushInterval: cfg.LogFlushInterval,
+		QueueSize:     cfg.LogQueueSize,
+		SpoolDir:      cfg.LogSpoolDir,
+	})
+	if err != nil {
+		logging.Fatal("初始化操作日志管道失败", "error", err)
 	}
+	err = middleware.ConfigureLogCapture(middleware.LogCaptureOptions{
+		Redactor: redactor,
+		Exclude:  config.SplitList(cfg.LogBodyCaptureExclude),
+	})
+	if err != nil {
+		logging.Fatal("初始化日志记录规则失败", "error", err)
+	}
+	lc.Add(lifecycle.Component{
+		Name: "操作日志写入",
+		Run:  middleware.RunLogWriter,
+	})
 
-	middleware.InitRateLimiter()
-
-	r := gin.Default()
+	if err := middleware.InitRateLimiter(); err != nil {
+		logging.Fatal("初始化限流器失败", "error", err)
+	}
+	lc.Add(lifecycle.Component{
+		Name: "限流器",
+		Run:  lifecycle.Every(time.Minute, middleware.CleanupRateLimiter),
+		Stop: func(ctx context.Context) error {
+			return middleware.CloseRateLimiter()
+		},
+	})
 
-	r.Use(middleware.RateLimitMiddleware())
-	r.Use(middleware.LogMiddleware())
+	// 访问日志由 SetupRoutes 中的 AccessLogMiddleware 以结构化格式记录，不使用 gin 自带的 Logger
+	r := gin.New()
+	r.Use(middleware.RecoveryMiddleware())
+	if err := utils.ConfigureClientIP(r, config.SplitList(cfg.TrustedProxies), config.SplitList(cfg.RemoteIPHeaders)); err != nil {
+		logging.Fatal("配置受信任代理失败", "error", err)
+	}
 
-	routes.SetupRoutes(r)
+	routes.SetupRoutes(r, database.DB, lc)
 
 	addr := ":" + cfg.Port
-	log.Printf("服务器运行在 http://localhost%s", addr)
-	log.Printf("环境: %s", cfg.GinMode)
+	srv := &http.Server{Addr: addr, Handler: r}
+	lc.Add(lifecycle.Component{
+		Name: "HTTP 服务",
+		Run: func(ctx context.Context) error {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		Stop: srv.Shutdown,
+	})
+
+	slog.Info("服务已启动", "addr", addr, "mode", cfg.GinMode)
+
+	waitErr := lc.Wait()
+	if waitErr != nil {
+		slog.Error("服务异常退出", "error", waitErr)
+	}
+
+	if err := lc.Shutdown(cfg.ShutdownTimeout); err != nil {
+		slog.Error("关闭服务时出错", "error", err)
+	}
+	slog.Info("服务已退出")
+	logFile.Close()
 
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
+	if waitErr != nil {
+		os.Exit(1)
 	}
 }
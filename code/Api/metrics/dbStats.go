@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"superhoneypotguard/database"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func dbDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(Namespace, "db", name), help, nil, nil)
+}
+
+var (
+	dbMaxOpen           = dbDesc("max_open_connections", "连接池允许的最大连接数，0 表示不限制")
+	dbOpen              = dbDesc("open_connections", "已建立的连接数")
+	dbInUse             = dbDesc("in_use_connections", "正在使用的连接数")
+	dbIdle              = dbDesc("idle_connections", "空闲连接数")
+	dbWaitCount         = dbDesc("wait_count_total", "等待空闲连接的次数")
+	dbWaitDuration      = dbDesc("wait_duration_seconds_total", "等待空闲连接的累计时间")
+	dbMaxIdleClosed     = dbDesc("max_idle_closed_total", "因超出最大空闲数而关闭的连接数")
+	dbMaxIdleTimeClosed = dbDesc("max_idle_time_closed_total", "因空闲超时而关闭的连接数")
+	dbMaxLifetimeClosed = dbDesc("max_lifetime_closed_total", "因超出最长存活时间而关闭的连接数")
+)
+
+// dbStatsCollector 每次抓取时读取 database.DB 当前的连接池状态，数据库未初始化时不输出
+type dbStatsCollector struct{}
+
+func (dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{dbMaxOpen, dbOpen, dbInUse, dbIdle, dbWaitCount, dbWaitDuration, dbMaxIdleClosed, dbMaxIdleTimeClosed, dbMaxLifetimeClosed} {
+		ch <- d
+	}
+}
+
+func (dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	if database.DB == nil {
+		return
+	}
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return
+	}
+	s := sqlDB.Stats()
+
+	ch <- prometheus.MustNewConstMetric(dbMaxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbOpen, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUse, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdle, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(dbWaitCount, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDuration, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(dbMaxIdleClosed, prometheus.CounterValue, float64(s.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(dbMaxIdleTimeClosed, prometheus.CounterValue, float64(s.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(dbMaxLifetimeClosed, prometheus.CounterValue, float64(s.MaxLifetimeClosed))
+}
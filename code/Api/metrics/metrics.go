@@ -0,0 +1,114 @@
+// Package metrics 定义服务对外暴露的 Prometheus 指标，所有指标注册到 Registry，由 /metrics 输出
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Namespace 所有指标名称的前缀
+const Namespace = "superhoneypotguard"
+
+// Registry 服务自己的指标注册表，不使用全局默认注册表，避免第三方库注册的指标混入
+var Registry = prometheus.NewRegistry()
+
+// HTTP 请求，route 取自 gin 的路由模板（如 /api/user/:id），未匹配任何路由时为 unmatched
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP 请求数",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP 请求处理耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "正在处理的 HTTP 请求数",
+	})
+)
+
+// RateLimitRejections 被限流拒绝的请求数，key 为实际使用的计数维度（ip 或 user）
+var RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: Namespace,
+	Name:      "ratelimit_rejections_total",
+	Help:      "被限流拒绝的请求数",
+}, []string{"policy", "key"})
+
+// HFish API 调用，endpoint 为 HFish 接口路径，outcome 取值见 HFishOutcome* 常量
+var (
+	HFishRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "hfish_api_requests_total",
+		Help:      "HFish API 调用次数",
+	}, []string{"endpoint", "outcome"})
+
+	HFishRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "hfish_api_request_duration_seconds",
+		Help:      "HFish API 调用耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	// HFishIPActions 通过 HFish 处置 IP 的次数，action 目前只有 block，解封接口接入后使用 unblock
+	HFishIPActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "hfish_ip_actions_total",
+		Help:      "通过 HFish 处置 IP 的次数",
+	}, []string{"action", "outcome"})
+)
+
+// HFish API 调用结果
+const (
+	HFishOutcomeSuccess = "success"
+	// HFishOutcomeTransport 连接失败或超时
+	HFishOutcomeTransport = "transport_error"
+	// HFishOutcomeInvalid 响应不是合法的 JSON
+	HFishOutcomeInvalid = "invalid_response"
+	// HFishOutcomeRejected HFish 返回 success=false
+	HFishOutcomeRejected = "rejected"
+)
+
+// EmailSends 验证码邮件发送结果，kind 为 verification 或 reset_password，
+// outcome 为 sent、failed（SMTP 发送失败）或 refused（频率限制、邮箱已注册等未发送）
+var EmailSends = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: Namespace,
+	Name:      "email_sends_total",
+	Help:      "验证码邮件发送次数",
+}, []string{"kind", "outcome"})
+
+// LogIngestionLag 操作日志从请求结束到写入数据库的延迟，落盘后回放的日志同样计入
+var LogIngestionLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Namespace: Namespace,
+	Name:      "operation_log_ingestion_lag_seconds",
+	Help:      "操作日志从产生到写入数据库的延迟",
+	Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 1800, 3600},
+})
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		RateLimitRejections,
+		HFishRequests,
+		HFishRequestDuration,
+		HFishIPActions,
+		EmailSends,
+		LogIngestionLag,
+		dbStatsCollector{},
+	)
+}
+
+// Register 注册其他包自行实现的指标，如日志管道的队列深度
+func Register(cs ...prometheus.Collector) {
+	Registry.MustRegister(cs...)
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"superhoneypotguard/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditEventKey 请求上下文中保存审计事件的键
+const auditEventKey = "auditEvent"
+
+// maxOperationLength operation 列的长度上限（字符数）
+const maxOperationLength = 100
+
+// RecordAudit 为当前请求记录审计事件，LogMiddleware 会用它替换日志中的原始路由
+// 同一请求多次调用时以最后一次为准；请求失败时事件仍会写入，status 为 0
+func RecordAudit(c *gin.Context, event models.AuditEvent) {
+	c.Set(auditEventKey, event)
+}
+
+// applyAuditEvent 将请求中记录的审计事件合并进操作日志
+func applyAuditEvent(c *gin.Context, entry *models.OperationLog, capture *logCapture) {
+	value, ok := c.Get(auditEventKey)
+	if !ok {
+		return
+	}
+	event, ok := value.(models.AuditEvent)
+	if !ok {
+		return
+	}
+
+	description := event.Description
+	if description == "" {
+		description = event.Action
+	}
+	if description != "" {
+		entry.Operation = truncateRunes(description, maxOperationLength)
+	}
+	entry.Action = optionalString(event.Action)
+	entry.TargetType = optionalString(event.TargetType)
+	entry.TargetID = optionalString(event.TargetID)
+
+	if entry.UserID == nil && event.ActorID != nil {
+		entry.UserID = event.ActorID
+	}
+	if entry.Username == nil && event.ActorName != nil {
+		entry.Username = event.ActorName
+	}
+
+	if len(event.Changes) > 0 {
+		data, err := json.Marshal(event.Changes)
+		if err == nil {
+			// 变更内容按字段名组织，与请求体使用同一套脱敏规则
+			if redacted, ok := capture.redactor.JSON(data); ok {
+				data = redacted
+			}
+			changes := string(data)
+			entry.Changes = &changes
+		}
+	}
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func truncateRunes(value string, max int) string {
+	if utf8.RuneCountInString(value) <= max {
+		return value
+	}
+	return string([]rune(value)[:max])
+}
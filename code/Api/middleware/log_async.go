@@ -4,88 +4,42 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
-	"sync"
 	"time"
 
-	"superhoneypotguard/database"
 	"superhoneypotguard/models"
+	"superhoneypotguard/tracing"
+	"superhoneypotguard/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// 提交目的：实现异步日志写入，提升API响应性能
-// 提交内容：使用channel和goroutine实现异步日志记录，避免阻塞主流程
-// 提交时间：2026-01-19
+// maxBufferedResponse 为记录日志缓存的响应体上限，超出部分直接写出不缓存，避免导出等大响应占用内存
+const maxBufferedResponse = 1 << 20
 
 type responseBodyWriter struct {
 	gin.ResponseWriter
 	body *bytes.Buffer
+	// size 响应体的实际大小
+	size int
 }
 
 func (r *responseBodyWriter) Write(b []byte) (int, error) {
-	r.body.Write(b)
-	return r.ResponseWriter.Write(b)
-}
-
-// 日志缓冲区
-var logBuffer = make(chan models.OperationLog, 1000)
-var logBatchSize = 100
-var logFlushInterval = 5 * time.Second
-var wg sync.WaitGroup
-
-// 启动日志处理协程
-func init() {
-	wg.Add(1)
-	go processLogs()
-}
-
-// 异步处理日志
-func processLogs() {
-	defer wg.Done()
-
-	batch := make([]models.OperationLog, 0, logBatchSize)
-	ticker := time.NewTicker(logFlushInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case log := <-logBuffer:
-			batch = append(batch, log)
-			if len(batch) >= logBatchSize {
-				flushLogs(batch)
-				batch = batch[:0]
-			}
-
-		case <-ticker.C:
-			if len(batch) > 0 {
-				flushLogs(batch)
-				batch = batch[:0]
-			}
-		}
-	}
-}
-
-// 批量写入日志
-func flushLogs(logs []models.OperationLog) {
-	if len(logs) == 0 {
-		return
-	}
-
-	if err := database.DB.CreateInBatches(logs, logBatchSize).Error; err != nil {
-		// 如果批量写入失败，尝试逐条写入
-		for _, log := range logs {
-			database.DB.Create(&log)
-		}
+	r.size += len(b)
+	if room := maxBufferedResponse - r.body.Len(); room > 0 {
+		r.body.Write(b[:min(room, len(b))])
 	}
+	return r.ResponseWriter.Write(b)
 }
 
 // LogMiddleware 日志中间件（异步版本）
 func LogMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
+		cfg := capture.Load()
+		captureBody := !cfg.skip(c.Request.Method, c.FullPath())
 
 		var requestBody []byte
-		if c.Request.Body != nil {
+		if captureBody && c.Request.Body != nil {
 			requestBody, _ = io.ReadAll(c.Request.Body)
 			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 		}
@@ -124,24 +78,13 @@ func LogMiddleware() gin.HandlerFunc {
 
 		method := c.Request.Method
 		path := c.FullPath()
-		ip := c.ClientIP()
-
-		var paramsStr string
-		if len(requestBody) > 0 {
-			if len(requestBody) > 500 {
-				paramsStr = string(requestBody[:500])
-			} else {
-				paramsStr = string(requestBody)
-			}
-		}
+		ip := utils.GetClientIP(c)
 
-		var resultStr string
-		if len(responseBody) > 0 {
-			if len(responseBody) > 500 {
-				resultStr = responseBody[:500]
-			} else {
-				resultStr = responseBody
-			}
+		// 请求体与响应体先脱敏再截断，避免密码、令牌等明文写入日志
+		var paramsStr, resultStr string
+		if captureBody {
+			paramsStr = cfg.params(c.ContentType(), requestBody)
+			resultStr = cfg.result([]byte(responseBody), w.size)
 		}
 
 		log := models.OperationLog{
@@ -155,22 +98,26 @@ func LogMiddleware() gin.HandlerFunc {
 			Result:      &resultStr,
 			Status:      status,
 			ExecuteTime: int(duration),
+			CreatedAt:   time.Now(),
 		}
-
-		// 异步写入日志
-		select {
-		case logBuffer <- log:
-		default:
-			// 如果缓冲区满，同步写入
-			database.DB.Create(&log)
+		if chain := utils.ForwardedChain(c); chain != "" {
+			log.ForwardedFor = &chain
 		}
+		if requestID := c.GetString(RequestIDContextKey); requestID != "" {
+			log.RequestID = &requestID
+		}
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			log.TraceID = &traceID
+		}
+		if status == 0 {
+			if message, ok := responseData["message"].(string); ok && message != "" {
+				message = truncateRunes(message, 500)
+				log.ErrorMsg = &message
+			}
+		}
+		applyAuditEvent(c, &log, cfg)
 
-		c.Next()
+		// 异步写入日志，队列已满时落盘，由写入协程稍后回放
+		enqueueLog(log)
 	}
 }
-
-// Shutdown 优雅关闭日志处理
-func Shutdown() {
-	close(logBuffer)
-	wg.Wait()
-}
\ No newline at end of file
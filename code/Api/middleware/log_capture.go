@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"superhoneypotguard/redact"
+)
+
+// maxCapturedBody 请求体与响应体在操作日志中保留的最大字节数
+const maxCapturedBody = 500
+
+// LogCaptureOptions 操作日志记录请求体与响应体的方式
+type LogCaptureOptions struct {
+	// Redactor 写入日志前对请求体和响应体脱敏
+	Redactor *redact.Redactor
+	// Exclude 不记录请求体和响应体的路由，如 "/api/hfish/*"，可加方法前缀如 "POST /api/auth/login"
+	Exclude []string
+}
+
+type logCapture struct {
+	redactor *redact.Redactor
+	exclude  []captureRule
+}
+
+type captureRule struct {
+	method  string
+	pattern string
+}
+
+var capture atomic.Pointer[logCapture]
+
+func init() {
+	redactor, _ := redact.New(redact.DefaultKeys, nil)
+	capture.Store(&logCapture{redactor: redactor})
+}
+
+// ConfigureLogCapture 设置操作日志的脱敏规则和不记录内容的路由
+func ConfigureLogCapture(opts LogCaptureOptions) error {
+	cp := &logCapture{redactor: opts.Redactor}
+	if cp.redactor == nil {
+		cp.redactor, _ = redact.New(redact.DefaultKeys, nil)
+	}
+
+	for _, entry := range opts.Exclude {
+		rule, err := parseCaptureRule(entry)
+		if err != nil {
+			return err
+		}
+		if rule.pattern != "" {
+			cp.exclude = append(cp.exclude, rule)
+		}
+	}
+
+	capture.Store(cp)
+	return nil
+}
+
+func parseCaptureRule(entry string) (captureRule, error) {
+	fields := strings.Fields(entry)
+	var rule captureRule
+	switch len(fields) {
+	case 0:
+		return rule, nil
+	case 1:
+		rule.pattern = fields[0]
+	case 2:
+		rule.method, rule.pattern = strings.ToUpper(fields[0]), fields[1]
+	default:
+		return rule, fmt.Errorf("无效的路由规则 %q（示例: /api/hfish/*、POST /api/auth/login）", entry)
+	}
+	if _, err := path.Match(rule.pattern, ""); err != nil {
+		return rule, fmt.Errorf("无效的路由规则 %q", entry)
+	}
+	return rule, nil
+}
+
+// skip 判断该路由是否不记录请求体和响应体
+func (cp *logCapture) skip(method, route string) bool {
+	for _, rule := range cp.exclude {
+		if rule.method != "" && rule.method != method {
+			continue
+		}
+		if ok, _ := path.Match(rule.pattern, route); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// params 脱敏并截断请求体，无法识别格式的请求体只记录其类型和大小
+func (cp *logCapture) params(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var (
+		redacted []byte
+		ok       bool
+	)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		redacted, ok = cp.redactor.Form(body)
+	case mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		redacted, ok = cp.redactor.JSON(body)
+	}
+	if !ok {
+		if mediaType == "" {
+			mediaType = "未知类型"
+		}
+		return fmt.Sprintf("[%s 请求体 %d 字节，未记录]", mediaType, len(body))
+	}
+	return truncateBody(redacted)
+}
+
+// result 脱敏并截断响应体，size 为响应体实际大小；非 JSON 或未完整缓存的响应只记录大小
+func (cp *logCapture) result(body []byte, size int) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if size > len(body) {
+		return fmt.Sprintf("[响应体 %d 字节，未记录]", size)
+	}
+	redacted, ok := cp.redactor.JSON(body)
+	if !ok {
+		return fmt.Sprintf("[非 JSON 响应 %d 字节，未记录]", len(body))
+	}
+	return truncateBody(redacted)
+}
+
+// truncateBody 截断到 maxCapturedBody 字节，不截断多字节字符
+func truncateBody(body []byte) string {
+	if len(body) <= maxCapturedBody {
+		return string(body)
+	}
+	end := maxCapturedBody
+	for end > 0 && !utf8.RuneStart(body[end]) {
+		end--
+	}
+	return string(body[:end])
+}
@@ -0,0 +1,392 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"superhoneypotguard/lifecycle"
+	"superhoneypotguard/metrics"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+// LogPipelineOptions 异步操作日志管道的参数
+type LogPipelineOptions struct {
+	// BatchSize 单次批量写入数据库的条数
+	BatchSize int
+	// FlushInterval 未攒满一批时最长等待多久写入
+	FlushInterval time.Duration
+	// QueueSize 内存队列容量，队列满时日志直接落盘
+	QueueSize int
+	// SpoolDir 落盘目录，为空表示不落盘，此时数据库不可用期间的日志会被丢弃
+	SpoolDir string
+}
+
+// DefaultLogPipelineOptions 未调用 ConfigureLogPipeline 时使用的参数
+var DefaultLogPipelineOptions = LogPipelineOptions{
+	BatchSize:     100,
+	FlushInterval: 5 * time.Second,
+	QueueSize:     1000,
+}
+
+// LogPipelineStats 日志管道的运行指标，计数从进程启动（或重新配置）开始累计
+type LogPipelineStats struct {
+	QueueDepth    int   `json:"queueDepth"`
+	QueueCapacity int   `json:"queueCapacity"`
+	SpoolEnabled  bool  `json:"spoolEnabled"`
+	SpoolPending  int64 `json:"spoolPending"`
+	Written       int64 `json:"written"`
+	Spilled       int64 `json:"spilled"`
+	Replayed      int64 `json:"replayed"`
+	Dropped       int64 `json:"dropped"`
+}
+
+type logPipeline struct {
+	opts  LogPipelineOptions
+	queue chan models.OperationLog
+	spool *logSpool
+
+	written  atomic.Int64
+	spilled  atomic.Int64
+	replayed atomic.Int64
+	dropped  atomic.Int64
+
+	drainMu  sync.Mutex
+	replayMu sync.Mutex
+}
+
+var pipeline atomic.Pointer[logPipeline]
+
+func init() {
+	pipeline.Store(&logPipeline{
+		opts:  DefaultLogPipelineOptions,
+		queue: make(chan models.OperationLog, DefaultLogPipelineOptions.QueueSize),
+	})
+}
+
+// flushRequests 用于请求后台协程立即落库当前批次，完成后关闭传入的 channel
+var flushRequests = make(chan chan struct{})
+
+// writerStopped 当前日志写入协程退出时关闭，为 nil 表示写入协程未运行
+var (
+	writerStopped chan struct{}
+	writerMu      sync.Mutex
+)
+
+// logStore 日志持久化实现，由 InitLogStore 设置
+var (
+	logStore   repositories.LogRepository
+	logStoreMu sync.RWMutex
+)
+
+// InitLogStore 设置异步日志写入使用的仓储
+func InitLogStore(store repositories.LogRepository) {
+	logStoreMu.Lock()
+	logStore = store
+	logStoreMu.Unlock()
+}
+
+func currentLogStore() repositories.LogRepository {
+	logStoreMu.RLock()
+	defer logStoreMu.RUnlock()
+	return logStore
+}
+
+// ConfigureLogPipeline 设置日志管道参数并打开落盘目录，必须在写入协程启动前调用
+// 落盘目录中上次退出时未回放的日志会在写入协程启动后写入数据库
+func ConfigureLogPipeline(opts LogPipelineOptions) error {
+	if opts.BatchSize <= 0 || opts.FlushInterval <= 0 || opts.QueueSize <= 0 {
+		return errors.New("日志管道参数必须大于 0")
+	}
+
+	writerMu.Lock()
+	defer writerMu.Unlock()
+	if writerStopped != nil {
+		return errors.New("日志写入协程运行中，无法修改日志管道参数")
+	}
+
+	p := &logPipeline{opts: opts, queue: make(chan models.OperationLog, opts.QueueSize)}
+	if opts.SpoolDir != "" {
+		spool, err := openLogSpool(opts.SpoolDir)
+		if err != nil {
+			return err
+		}
+		p.spool = spool
+		if pending := spool.Pending(); pending > 0 {
+			slog.Info("日志落盘目录中有待回放的操作日志", "pending", pending)
+		}
+	}
+
+	old := pipeline.Swap(p)
+	// 旧队列中尚未写入的日志转入新管道
+	for drained := false; !drained; {
+		select {
+		case entry := <-old.queue:
+			enqueueLog(entry)
+		default:
+			drained = true
+		}
+	}
+	if old.spool != nil {
+		old.spool.Close()
+	}
+	return nil
+}
+
+// GetLogPipelineStats 返回日志管道当前的运行指标
+func GetLogPipelineStats() LogPipelineStats {
+	p := pipeline.Load()
+	stats := LogPipelineStats{
+		QueueDepth:    len(p.queue),
+		QueueCapacity: cap(p.queue),
+		SpoolEnabled:  p.spool != nil,
+		Written:       p.written.Load(),
+		Spilled:       p.spilled.Load(),
+		Replayed:      p.replayed.Load(),
+		Dropped:       p.dropped.Load(),
+	}
+	if p.spool != nil {
+		stats.SpoolPending = p.spool.Pending()
+	}
+	return stats
+}
+
+// enqueueLog 将日志放入内存队列，队列已满时直接落盘
+func enqueueLog(entry models.OperationLog) {
+	p := pipeline.Load()
+	select {
+	case p.queue <- entry:
+	default:
+		p.spill([]models.OperationLog{entry})
+	}
+}
+
+// FlushLogs 阻塞直到缓冲区中已有的日志全部写入，并尝试回放落盘的日志
+// 写入协程未运行（或已停止）时在当前协程中直接写入
+func FlushLogs() {
+	writerMu.Lock()
+	stopped := writerStopped
+	writerMu.Unlock()
+
+	if stopped != nil {
+		done := make(chan struct{})
+		select {
+		case flushRequests <- done:
+			<-done
+			return
+		case <-stopped:
+		}
+	}
+
+	p := pipeline.Load()
+	p.drain(nil)
+	p.replay()
+}
+
+// RunLogWriter 运行异步日志写入协程，按批量大小或时间间隔落库
+// 数据库写入失败的日志转入落盘目录，数据库恢复后按写入顺序回放
+// ctx 取消后把队列中剩余的日志全部写入（或落盘）再返回，同一时间只能运行一个
+func RunLogWriter(ctx context.Context) error {
+	stopped := make(chan struct{})
+	writerMu.Lock()
+	if writerStopped != nil {
+		writerMu.Unlock()
+		return errors.New("日志写入协程已在运行")
+	}
+	writerStopped = stopped
+	writerMu.Unlock()
+
+	defer func() {
+		writerMu.Lock()
+		writerStopped = nil
+		writerMu.Unlock()
+		close(stopped)
+	}()
+
+	p := pipeline.Load()
+	p.replay()
+
+	batch := make([]models.OperationLog, 0, p.opts.BatchSize)
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+	lifecycle.Beat(ctx, p.opts.FlushInterval)
+
+	for {
+		select {
+		case entry := <-p.queue:
+			batch = append(batch, entry)
+			if len(batch) >= p.opts.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+			p.replay()
+			lifecycle.Beat(ctx, p.opts.FlushInterval)
+
+		case done := <-flushRequests:
+			p.drain(batch)
+			batch = batch[:0]
+			p.replay()
+			close(done)
+
+		case <-ctx.Done():
+			p.drain(batch)
+			if p.spool != nil {
+				p.spool.Close()
+			}
+			return nil
+		}
+	}
+}
+
+// drain 取完队列中已排队的日志，与 pending 一起分批写入
+func (p *logPipeline) drain(pending []models.OperationLog) {
+	p.drainMu.Lock()
+	defer p.drainMu.Unlock()
+
+	for {
+		select {
+		case entry := <-p.queue:
+			pending = append(pending, entry)
+		default:
+			for len(pending) > 0 {
+				n := min(len(pending), p.opts.BatchSize)
+				p.flush(pending[:n])
+				pending = pending[n:]
+			}
+			return
+		}
+	}
+}
+
+// flush 写入一批日志，数据库不可用时落盘，被数据库拒绝的日志单独保存并计为丢弃
+func (p *logPipeline) flush(logs []models.OperationLog) {
+	rejected, unavailable := p.write(logs)
+	if unavailable {
+		p.spill(logs)
+		return
+	}
+	p.reject(rejected)
+}
+
+// write 批量写入数据库，整批失败时逐条重试以找出被拒绝的日志
+// 逐条也全部失败且日志表不可访问时视为数据库不可用，此时 unavailable 为 true
+func (p *logPipeline) write(logs []models.OperationLog) (rejected []models.OperationLog, unavailable bool) {
+	store := currentLogStore()
+	if store == nil {
+		return nil, true
+	}
+
+	if err := store.Create(logs); err == nil {
+		p.written.Add(int64(len(logs)))
+		observeIngestionLag(logs...)
+		return nil, false
+	}
+
+	for _, entry := range logs {
+		if err := store.Create([]models.OperationLog{entry}); err != nil {
+			rejected = append(rejected, entry)
+		} else {
+			observeIngestionLag(entry)
+		}
+	}
+	if len(rejected) == len(logs) {
+		if err := store.Ping(); err != nil {
+			return nil, true
+		}
+	}
+	p.written.Add(int64(len(logs) - len(rejected)))
+	return rejected, false
+}
+
+// observeIngestionLag 记录日志从产生到写入数据库的延迟
+func observeIngestionLag(logs ...models.OperationLog) {
+	now := time.Now()
+	for _, entry := range logs {
+		metrics.LogIngestionLag.Observe(now.Sub(entry.CreatedAt).Seconds())
+	}
+}
+
+// spill 将日志写入落盘目录，未启用落盘或写入失败时丢弃
+func (p *logPipeline) spill(logs []models.OperationLog) {
+	if p.spool == nil {
+		p.dropped.Add(int64(len(logs)))
+		slog.Error("数据库不可用且未启用日志落盘，丢弃操作日志", "count", len(logs))
+		return
+	}
+	if err := p.spool.Append(logs); err != nil {
+		p.dropped.Add(int64(len(logs)))
+		slog.Error("操作日志落盘失败，丢弃操作日志", "count", len(logs), "error", err)
+		return
+	}
+	p.spilled.Add(int64(len(logs)))
+}
+
+// reject 记录被数据库拒绝的日志，启用落盘时保存到 rejected.jsonl 以便排查
+func (p *logPipeline) reject(logs []models.OperationLog) {
+	if len(logs) == 0 {
+		return
+	}
+	p.dropped.Add(int64(len(logs)))
+	slog.Error("数据库拒绝写入操作日志", "count", len(logs))
+	if p.spool != nil {
+		if err := p.spool.Reject(logs); err != nil {
+			slog.Error("保存被拒绝的操作日志失败", "error", err)
+		}
+	}
+}
+
+// replay 按写入顺序回放落盘的日志，数据库仍不可用时保留剩余部分等待下次回放
+func (p *logPipeline) replay() {
+	if p.spool == nil || p.spool.Pending() == 0 {
+		return
+	}
+
+	p.replayMu.Lock()
+	defer p.replayMu.Unlock()
+
+	segments, err := p.spool.Seal()
+	if err != nil {
+		slog.Error("回放操作日志失败", "error", err)
+		return
+	}
+
+	for _, name := range segments {
+		rows, corrupt, err := p.spool.readSegment(name)
+		if err != nil {
+			slog.Error("回放操作日志失败", "file", name, "error", err)
+			return
+		}
+		if corrupt > 0 {
+			p.dropped.Add(int64(corrupt))
+			slog.Warn("落盘文件中有日志已损坏，跳过", "file", name, "count", corrupt)
+		}
+
+		for i := 0; i < len(rows); i += p.opts.BatchSize {
+			chunk := rows[i:min(i+p.opts.BatchSize, len(rows))]
+			rejected, unavailable := p.write(chunk)
+			if unavailable {
+				if err := p.spool.Ack(name, i, rows[i:]); err != nil {
+					slog.Error("更新落盘文件失败", "file", name, "error", err)
+				}
+				return
+			}
+			p.reject(rejected)
+			p.replayed.Add(int64(len(chunk) - len(rejected)))
+		}
+
+		if err := p.spool.Ack(name, len(rows), nil); err != nil {
+			slog.Error("删除落盘文件失败", "file", name, "error", err)
+			return
+		}
+	}
+}
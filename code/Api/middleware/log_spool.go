@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"superhoneypotguard/models"
+)
+
+const (
+	spoolSegmentPrefix = "segment-"
+	spoolSegmentSuffix = ".jsonl"
+	// spoolRejectedFile 数据库明确拒绝写入的日志，保留以便人工排查，不再回放
+	spoolRejectedFile = "rejected.jsonl"
+	// spoolSegmentRows 单个分段文件的最大条数，写满后切换新文件
+	spoolSegmentRows = 1000
+)
+
+// logSpool 操作日志的磁盘预写队列
+//
+// 数据库不可用或内存队列已满时，日志以 JSON Lines 追加写入分段文件并立即 fsync；
+// 数据库恢复后按文件名（即写入时间）顺序回放，回放成功的分段文件随即删除。
+type logSpool struct {
+	dir string
+
+	mu         sync.Mutex
+	active     *os.File
+	activeRows int
+	seq        int
+	pending    int64
+}
+
+// openLogSpool 打开（必要时创建）落盘目录，并统计上次退出时遗留的待回放日志
+func openLogSpool(dir string) (*logSpool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建日志落盘目录失败: %w", err)
+	}
+
+	s := &logSpool{dir: dir}
+	segments, err := s.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range segments {
+		rows, _, err := s.readSegment(name)
+		if err != nil {
+			return nil, err
+		}
+		s.pending += int64(len(rows))
+	}
+	return s, nil
+}
+
+// Pending 返回落盘待回放的日志条数
+func (s *logSpool) Pending() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// Append 追加写入日志并落盘，返回后即使进程崩溃日志也不会丢失
+func (s *logSpool) Append(logs []models.OperationLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		s.seq++
+		name := fmt.Sprintf("%s%d-%06d%s", spoolSegmentPrefix, time.Now().UnixNano(), s.seq, spoolSegmentSuffix)
+		f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("创建日志落盘文件失败: %w", err)
+		}
+		s.active = f
+		s.activeRows = 0
+	}
+
+	if err := writeJSONLines(s.active, logs); err != nil {
+		return fmt.Errorf("写入日志落盘文件失败: %w", err)
+	}
+	s.pending += int64(len(logs))
+	s.activeRows += len(logs)
+
+	if s.activeRows >= spoolSegmentRows {
+		s.sealLocked()
+	}
+	return nil
+}
+
+// Seal 关闭当前写入中的分段文件并返回所有待回放的分段，之后的写入进入新文件
+func (s *logSpool) Seal() ([]string, error) {
+	s.mu.Lock()
+	s.sealLocked()
+	s.mu.Unlock()
+
+	return s.listSegments()
+}
+
+func (s *logSpool) sealLocked() {
+	if s.active == nil {
+		return
+	}
+	if err := s.active.Close(); err != nil {
+		slog.Error("关闭日志落盘文件失败", "error", err)
+	}
+	s.active = nil
+}
+
+// Ack 记录分段中前 done 条已写入数据库，剩余 rest 条写回分段；rest 为空时删除分段
+func (s *logSpool) Ack(name string, done int, rest []models.OperationLog) error {
+	path := filepath.Join(s.dir, name)
+
+	if len(rest) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		tmp := path + ".tmp"
+		f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		err = writeJSONLines(f, rest)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.pending -= int64(done)
+	s.mu.Unlock()
+	return nil
+}
+
+// Reject 将数据库拒绝的日志移入 rejected.jsonl
+func (s *logSpool) Reject(logs []models.OperationLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(s.dir, spoolRejectedFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeJSONLines(f, logs)
+}
+
+// readSegment 读取分段文件，无法解析的行（如崩溃时写了一半）会被跳过并计数
+func (s *logSpool) readSegment(name string) ([]models.OperationLog, int, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取日志落盘文件失败: %w", err)
+	}
+	defer f.Close()
+
+	var (
+		rows    []models.OperationLog
+		corrupt int
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry models.OperationLog
+		if err := json.Unmarshal(line, &entry); err != nil {
+			corrupt++
+			continue
+		}
+		rows = append(rows, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("读取日志落盘文件失败: %w", err)
+	}
+	return rows, corrupt, nil
+}
+
+func (s *logSpool) listSegments() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志落盘目录失败: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, spoolSegmentPrefix) && strings.HasSuffix(name, spoolSegmentSuffix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	s.mu.Lock()
+	if s.active != nil {
+		// 正在写入的分段不参与回放
+		active := filepath.Base(s.active.Name())
+		for i, name := range names {
+			if name == active {
+				names = append(names[:i], names[i+1:]...)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	return names, nil
+}
+
+// Close 关闭当前写入中的分段文件
+func (s *logSpool) Close() {
+	s.mu.Lock()
+	s.sealLocked()
+	s.mu.Unlock()
+}
+
+func writeJSONLines(f *os.File, logs []models.OperationLog) error {
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, entry := range logs {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
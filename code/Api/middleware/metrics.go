@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"superhoneypotguard/metrics"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsMiddleware 统计请求数和耗时，路由标签使用路由模板而不是实际路径，避免标签数量随 ID 等参数增长
+// 需要放在限流中间件之前，被限流拒绝的请求同样计入
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := metricsMethod(c.Request.Method)
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// metricsMethod 标准方法以外的请求方法统一记为 OTHER，客户端无法通过自定义方法制造新的标签值
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出指标，token 不为空时要求 Authorization: Bearer <token>
+func MetricsHandler(token string) gin.HandlerFunc {
+	handler := promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})
+	return func(c *gin.Context) {
+		if token != "" {
+			got := []byte(c.GetHeader("Authorization"))
+			if subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) != 1 {
+				utils.ErrorResponse(c, http.StatusUnauthorized, "指标访问令牌无效")
+				c.Abort()
+				return
+			}
+		}
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+var (
+	logQueueDepthDesc    = logPipelineDesc("queue_depth", "内存队列中等待写入的操作日志数")
+	logQueueCapacityDesc = logPipelineDesc("queue_capacity", "内存队列容量")
+	logSpoolPendingDesc  = logPipelineDesc("spool_pending", "落盘待回放的操作日志数")
+	logWrittenDesc       = logPipelineDesc("written_total", "写入数据库的操作日志数")
+	logSpilledDesc       = logPipelineDesc("spilled_total", "因队列已满或数据库不可用而落盘的操作日志数")
+	logReplayedDesc      = logPipelineDesc("replayed_total", "从落盘文件回放到数据库的操作日志数")
+	logDroppedDesc       = logPipelineDesc("dropped_total", "被数据库拒绝或无法落盘而丢弃的操作日志数")
+)
+
+func logPipelineDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "operation_log", name), help, nil, nil)
+}
+
+// logPipelineCollector 抓取时读取 GetLogPipelineStats，与 /api/log/pipeline 返回的数据一致
+type logPipelineCollector struct{}
+
+func (logPipelineCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{logQueueDepthDesc, logQueueCapacityDesc, logSpoolPendingDesc, logWrittenDesc, logSpilledDesc, logReplayedDesc, logDroppedDesc} {
+		ch <- d
+	}
+}
+
+func (logPipelineCollector) Collect(ch chan<- prometheus.Metric) {
+	s := GetLogPipelineStats()
+	ch <- prometheus.MustNewConstMetric(logQueueDepthDesc, prometheus.GaugeValue, float64(s.QueueDepth))
+	ch <- prometheus.MustNewConstMetric(logQueueCapacityDesc, prometheus.GaugeValue, float64(s.QueueCapacity))
+	ch <- prometheus.MustNewConstMetric(logSpoolPendingDesc, prometheus.GaugeValue, float64(s.SpoolPending))
+	ch <- prometheus.MustNewConstMetric(logWrittenDesc, prometheus.CounterValue, float64(s.Written))
+	ch <- prometheus.MustNewConstMetric(logSpilledDesc, prometheus.CounterValue, float64(s.Spilled))
+	ch <- prometheus.MustNewConstMetric(logReplayedDesc, prometheus.CounterValue, float64(s.Replayed))
+	ch <- prometheus.MustNewConstMetric(logDroppedDesc, prometheus.CounterValue, float64(s.Dropped))
+}
+
+func init() {
+	metrics.Register(logPipelineCollector{})
+}
@@ -1,29 +1,44 @@
 package middleware
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
-	"superhoneypotguard/database"
+	"superhoneypotguard/tracing"
 	"superhoneypotguard/utils"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// PermissionChecker 判断用户当前是否拥有指定权限
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, userID int, permissionCode string) (bool, error)
+}
+
+var permissionChecker PermissionChecker
+
+// InitPermissionChecker 设置权限中间件使用的权限校验实现
+func InitPermissionChecker(checker PermissionChecker) {
+	permissionChecker = checker
+}
+
 func PermissionMiddleware(permissionCode string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := GetCurrentUser(c)
 
-		var count int64
-		database.DB.Raw(`
-			SELECT COUNT(*)
-			FROM users u
-			INNER JOIN user_roles ur ON u.id = ur.user_id
-			INNER JOIN roles r ON ur.role_id = r.id
-			INNER JOIN role_permissions rp ON r.id = rp.role_id
-			INNER JOIN permissions p ON rp.permission_id = p.id
-			WHERE u.id = ? AND p.permission_code = ? AND p.status = 1 AND r.status = 1 AND u.status = 1
-		`, user.UserID, permissionCode).Scan(&count)
-
-		if count == 0 {
+		// 权限校验单独记录为一个 span，便于区分慢在权限查询还是业务处理
+		ctx, span := tracing.Tracer().Start(c.Request.Context(), "PermissionMiddleware",
+			trace.WithAttributes(attribute.String("permission.code", permissionCode)))
+		allowed, err := permissionChecker.HasPermission(ctx, user.UserID, permissionCode)
+		span.SetAttributes(attribute.Bool("permission.allowed", allowed))
+		tracing.End(span, err)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "权限校验失败", "permission", permissionCode, "error", err)
+		}
+
+		if !allowed {
 			utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
 			c.Abort()
 			return
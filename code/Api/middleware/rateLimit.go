@@ -1,91 +1,158 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"superhoneypotguard/config"
+	"superhoneypotguard/database"
+	"superhoneypotguard/metrics"
+	"superhoneypotguard/ratelimit"
 	"superhoneypotguard/utils"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 )
 
-type IPRateLimiter struct {
-	ips map[string]*visitor
-	mu  sync.RWMutex
-	r   rateConfig
-}
+// rateLimitKeyPrefix Redis 中限流 key 的前缀
+const rateLimitKeyPrefix = "superhoneypotguard:ratelimit:"
 
-type visitor struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
-}
+// GlobalRateLimitPolicy 作用于所有请求的按 IP 限流，参数来自 RATE_LIMIT_WINDOW/RATE_LIMIT_MAX_REQUESTS
+const GlobalRateLimitPolicy = ratelimit.GlobalPolicy
+
+// rateLimitRemainingKey 上下文中已写入响应头的剩余配额，多个策略同时生效时响应头取剩余最少的一个
+const rateLimitRemainingKey = "rateLimitRemaining"
+
+// rateLimiter 限流后端及各策略的参数，全局策略可在运行时通过系统设置调整
+type rateLimiter struct {
+	backend     ratelimit.Limiter
+	backendName string
 
-type rateConfig struct {
-	limit rate.Limit
-	burst int
+	mu       sync.RWMutex
+	global   ratelimit.Rule
+	policies map[string]ratelimit.Policy
 }
 
-func NewIPRateLimiter(r rateConfig) *IPRateLimiter {
-	return &IPRateLimiter{
-		ips: make(map[string]*visitor),
-		r:   r,
+func (l *rateLimiter) policy(name string) (ratelimit.Policy, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if name == GlobalRateLimitPolicy {
+		return ratelimit.Policy{Name: name, Rule: l.global, Key: ratelimit.KeyIP}, true
 	}
+	p, ok := l.policies[name]
+	return p, ok
 }
 
-func (i *IPRateLimiter) getLimiter(ip string) *rate.Limiter {
-	i.mu.Lock()
-	defer i.mu.Unlock()
+var globalLimiter *rateLimiter
 
-	v, exists := i.ips[ip]
-	if !exists {
-		limiter := rate.NewLimiter(i.r.limit, i.r.burst)
-		i.ips[ip] = &visitor{limiter, time.Now()}
-		return limiter
-	}
+// InitRateLimiter 按 RATE_LIMIT_BACKEND 创建限流器并加载 RATE_LIMIT_POLICIES 中的策略
+// 使用 Redis 时连接失败不会阻止启动，限流暂时退回到进程内，Redis 恢复后自动切回
+func InitRateLimiter() error {
+	cfg := config.AppConfig
 
-	v.lastSeen = time.Now()
-	return v.limiter
-}
+	policies, err := ratelimit.ParsePolicies(cfg.RateLimitPolicies)
+	if err != nil {
+		return err
+	}
 
-func (i *IPRateLimiter) CleanupStaleVisitors() {
-	i.mu.Lock()
-	defer i.mu.Unlock()
+	var backend ratelimit.Limiter
+	switch cfg.RateLimitBackend {
+	case "", "memory":
+		backend = ratelimit.NewMemory()
+	case "redis":
+		// 连接失败时不中断启动，首次限流时退回到进程内状态并记录警告
+		client, _ := database.OpenRedis(cfg)
+		backend = ratelimit.NewRedis(client, rateLimitKeyPrefix)
+	default:
+		return fmt.Errorf("不支持的限流后端 %q", cfg.RateLimitBackend)
+	}
 
-	for ip, v := range i.ips {
-		if time.Since(v.lastSeen) > 3*time.Minute {
-			delete(i.ips, ip)
-		}
+	l := &rateLimiter{
+		backend:     backend,
+		backendName: cfg.RateLimitBackend,
+		global:      ratelimit.Rule{Window: cfg.RateLimitWindow, Max: cfg.RateLimitMax},
+		policies:    make(map[string]ratelimit.Policy, len(policies)),
+	}
+	if l.backendName == "" {
+		l.backendName = "memory"
 	}
+	for _, p := range policies {
+		l.policies[p.Name] = p
+	}
+	globalLimiter = l
+	return nil
 }
 
-var globalLimiter *IPRateLimiter
+// CleanupRateLimiter 清理限流器中已恢复满额的 key，由定时任务调用
+func CleanupRateLimiter() {
+	if globalLimiter != nil {
+		globalLimiter.backend.Cleanup()
+	}
+}
 
-func InitRateLimiter() {
-	cfg := config.AppConfig
-	window := time.Duration(cfg.RateLimitWindow) * time.Millisecond
-	maxRequests := cfg.RateLimitMax
+// CloseRateLimiter 关闭限流后端的连接
+func CloseRateLimiter() error {
+	if globalLimiter == nil {
+		return nil
+	}
+	return globalLimiter.backend.Close()
+}
 
-	limiter := rate.Every(time.Duration(window.Milliseconds()/int64(maxRequests)) * time.Millisecond)
-	globalLimiter = NewIPRateLimiter(rateConfig{
-		limit: limiter,
-		burst: maxRequests,
-	})
+// PingRateLimiter 检查限流后端的连通性，进程内限流始终可用
+func PingRateLimiter(ctx context.Context) error {
+	if p, ok := globalLimiter.backend.(interface{ Ping(context.Context) error }); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
 
-	go func() {
-		for range time.Tick(time.Minute) {
-			globalLimiter.CleanupStaleVisitors()
-		}
-	}()
+// ConfigureRateLimiter 在运行时调整全局限流参数，未初始化或参数无效时忽略
+func ConfigureRateLimiter(window time.Duration, maxRequests int) {
+	rule := ratelimit.Rule{Window: window, Max: maxRequests}
+	if globalLimiter == nil || rule.Validate() != nil {
+		return
+	}
+	globalLimiter.mu.Lock()
+	defer globalLimiter.mu.Unlock()
+	globalLimiter.global = rule
 }
 
+// RateLimitMiddleware 全局限流，每个客户端 IP 共用一份配额
 func RateLimitMiddleware() gin.HandlerFunc {
+	return RateLimitPolicy(GlobalRateLimitPolicy)
+}
+
+// RateLimitPolicy 按名称套用限流策略，与全局限流叠加生效；RATE_LIMIT_POLICIES 中没有该策略时不限流
+// 按 user 维度计数的策略需放在 AuthMiddleware 之后，未登录的请求按 IP 计数
+func RateLimitPolicy(name string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		limiter := globalLimiter.getLimiter(c.ClientIP())
+		p, ok := globalLimiter.policy(name)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		keyType, value := rateLimitKey(c, p.Key)
+		result, err := globalLimiter.backend.Allow(c.Request.Context(), p.Name+":"+keyType+":"+value, p.Rule)
+		if err != nil {
+			// 限流后端异常时放行，避免限流故障导致整个服务不可用
+			slog.ErrorContext(c.Request.Context(), "限流检查失败", "policy", p.Name, "error", err)
+			c.Next()
+			return
+		}
 
-		if !limiter.Allow() {
+		setRateLimitHeaders(c, p, result)
+		if !result.Allowed {
+			metrics.RateLimitRejections.WithLabelValues(p.Name, keyType).Inc()
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
 			utils.ErrorResponse(c, http.StatusTooManyRequests, "请求过于频繁，请稍后再试")
 			c.Abort()
 			return
@@ -95,16 +162,103 @@ func RateLimitMiddleware() gin.HandlerFunc {
 	}
 }
 
-func AuthRateLimitMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		limiter := rate.NewLimiter(rate.Every(3*time.Minute), 5)
-
-		if !limiter.Allow() {
-			utils.ErrorResponse(c, http.StatusTooManyRequests, "登录请求过于频繁，请稍后再试")
-			c.Abort()
-			return
+// rateLimitKey 按策略的维度取计数对象，未登录时退回到 IP
+func rateLimitKey(c *gin.Context, keyType string) (string, string) {
+	if keyType == ratelimit.KeyUser {
+		if userID, ok := c.Get("userId"); ok {
+			return ratelimit.KeyUser, fmt.Sprint(userID)
 		}
+	}
+	return ratelimit.KeyIP, utils.GetClientIP(c)
+}
 
-		c.Next()
+// setRateLimitHeaders 写入 RateLimit-* 响应头，多个策略同时生效时保留剩余配额最少的一个
+func setRateLimitHeaders(c *gin.Context, p ratelimit.Policy, result ratelimit.Result) {
+	if prev, ok := c.Get(rateLimitRemainingKey); ok && prev.(int) <= result.Remaining {
+		return
 	}
+	c.Set(rateLimitRemainingKey, result.Remaining)
+
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", p.Rule.Max, ceilSeconds(p.Rule.Window)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// RateLimitPolicyInfo 一个限流策略的参数
+type RateLimitPolicyInfo struct {
+	Name   string `json:"name"`
+	Limit  int    `json:"limit"`
+	Window string `json:"window"`
+	Key    string `json:"key"`
+}
+
+// ThrottledClient 正在被限流的客户端
+type ThrottledClient struct {
+	Policy  string `json:"policy"`
+	KeyType string `json:"keyType"`
+	Key     string `json:"key"`
+	// RetryAfter 距离可以再次请求的秒数
+	RetryAfter int       `json:"retryAfter"`
+	Until      time.Time `json:"until"`
+}
+
+// RateLimitStatus 限流后端、各策略参数及当前被限流的客户端
+type RateLimitStatus struct {
+	Backend string `json:"backend"`
+	// Degraded 为 true 时 Redis 不可用，各实例暂时各自限流
+	Degraded  bool                  `json:"degraded"`
+	Policies  []RateLimitPolicyInfo `json:"policies"`
+	Throttled []ThrottledClient     `json:"throttled"`
+}
+
+// GetRateLimitStatus 汇总限流状态，被限流的客户端按解除时间从晚到早排列
+func GetRateLimitStatus(ctx context.Context) (*RateLimitStatus, error) {
+	l := globalLimiter
+	status := &RateLimitStatus{Backend: l.backendName, Throttled: []ThrottledClient{}}
+	if d, ok := l.backend.(interface{ Degraded() bool }); ok {
+		status.Degraded = d.Degraded()
+	}
+
+	global, _ := l.policy(GlobalRateLimitPolicy)
+	status.Policies = append(status.Policies, policyInfo(global))
+	l.mu.RLock()
+	for _, p := range l.policies {
+		status.Policies = append(status.Policies, policyInfo(p))
+	}
+	l.mu.RUnlock()
+	sort.Slice(status.Policies[1:], func(i, j int) bool {
+		return status.Policies[i+1].Name < status.Policies[j+1].Name
+	})
+
+	throttled, err := l.backend.Throttled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, t := range throttled {
+		parts := strings.SplitN(t.Key, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		status.Throttled = append(status.Throttled, ThrottledClient{
+			Policy:     parts[0],
+			KeyType:    parts[1],
+			Key:        parts[2],
+			RetryAfter: ceilSeconds(t.Until.Sub(now)),
+			Until:      t.Until,
+		})
+	}
+	sort.Slice(status.Throttled, func(i, j int) bool {
+		return status.Throttled[i].Until.After(status.Throttled[j].Until)
+	})
+	return status, nil
+}
+
+func policyInfo(p ratelimit.Policy) RateLimitPolicyInfo {
+	return RateLimitPolicyInfo{Name: p.Name, Limit: p.Rule.Max, Window: p.Rule.Window.String(), Key: p.Key}
 }
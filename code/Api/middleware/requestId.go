@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"superhoneypotguard/logging"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求 ID 的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDContextKey gin 上下文中保存请求 ID 的键
+const RequestIDContextKey = "requestId"
+
+// maxRequestIDLength 沿用传入请求 ID 的最大长度
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware 沿用客户端或上游代理传入的 X-Request-ID，没有或格式不合法时重新生成，并写入响应头
+// 请求 ID 同时放入请求的 context，之后用该 context 记录的日志、操作日志和调用 HFish 的请求都带上同一个 ID
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		c.Set(RequestIDContextKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// validRequestID 只接受字母、数字和 -_.: ，避免换行等字符混入日志
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLogMiddleware 请求完成后记录一行访问日志，5xx 为 error 级别，4xx 为 warn 级别
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+			slog.String("client_ip", utils.GetClientIP(c)),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+		}
+		if userID, ok := c.Get("userId"); ok {
+			attrs = append(attrs, slog.Any("user_id", userID))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "请求完成", attrs...)
+	}
+}
+
+// RecoveryMiddleware 捕获处理请求时的 panic，记录带请求 ID 和调用栈的错误日志后返回 500
+func RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "处理请求时发生 panic",
+			"method", c.Request.Method, "path", c.Request.URL.Path, "error", err, "stack", string(debug.Stack()))
+		utils.ErrorResponse(c, http.StatusInternalServerError, "服务器内部错误")
+		c.Abort()
+	})
+}
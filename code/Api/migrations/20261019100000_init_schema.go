@@ -0,0 +1,144 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 以下结构体是该版本时的表结构快照，之后模型变更应新增迁移而不是修改这里
+
+type userV1 struct {
+	ID            int        `gorm:"primaryKey;autoIncrement;comment:用户ID"`
+	Username      string     `gorm:"uniqueIndex:idx_users_username;not null;size:50;comment:用户名"`
+	Password      string     `gorm:"not null;size:255;comment:密码(加密后)"`
+	Email         *string    `gorm:"uniqueIndex:idx_users_email;size:100;comment:邮箱"`
+	Phone         *string    `gorm:"size:20;comment:手机号"`
+	RealName      *string    `gorm:"column:real_name;size:50;comment:真实姓名"`
+	Status        int        `gorm:"default:1;index:idx_users_status;comment:0-禁用,1-启用"`
+	LastLoginTime *time.Time `gorm:"column:last_login_time;comment:最后登录时间"`
+	LastLoginIP   *string    `gorm:"column:last_login_ip;size:50;comment:最后登录IP"`
+	CreatedAt     time.Time  `gorm:"comment:创建时间"`
+	UpdatedAt     time.Time  `gorm:"comment:更新时间"`
+	CreatedBy     *int       `gorm:"comment:创建人ID"`
+	UpdatedBy     *int       `gorm:"comment:更新人ID"`
+}
+
+func (userV1) TableName() string { return "users" }
+
+type roleV1 struct {
+	ID          int       `gorm:"primaryKey;autoIncrement;comment:角色ID"`
+	RoleName    string    `gorm:"column:role_name;uniqueIndex:idx_roles_role_name;not null;size:50;comment:角色名称"`
+	RoleCode    string    `gorm:"column:role_code;uniqueIndex:idx_roles_role_code;not null;size:50;comment:角色编码"`
+	Description *string   `gorm:"size:200;comment:角色描述"`
+	Status      int       `gorm:"default:1;index:idx_roles_status;comment:0-禁用,1-启用"`
+	CreatedAt   time.Time `gorm:"comment:创建时间"`
+	UpdatedAt   time.Time `gorm:"comment:更新时间"`
+	CreatedBy   *int      `gorm:"comment:创建人ID"`
+	UpdatedBy   *int      `gorm:"comment:更新人ID"`
+}
+
+func (roleV1) TableName() string { return "roles" }
+
+type permissionV1 struct {
+	ID             int       `gorm:"primaryKey;autoIncrement;comment:权限ID"`
+	PermissionName string    `gorm:"column:permission_name;not null;size:50;comment:权限名称"`
+	PermissionCode string    `gorm:"column:permission_code;uniqueIndex:idx_permissions_permission_code;not null;size:100;comment:权限编码"`
+	PermissionType string    `gorm:"column:permission_type;not null;size:20;comment:menu-菜单,button-按钮,api-接口"`
+	ParentID       int       `gorm:"column:parent_id;default:0;index:idx_permissions_parent_id;comment:父权限ID"`
+	Path           *string   `gorm:"size:200;comment:路由路径"`
+	Component      *string   `gorm:"size:200;comment:组件路径"`
+	Icon           *string   `gorm:"size:50;comment:图标"`
+	SortOrder      int       `gorm:"column:sort_order;default:0;comment:排序"`
+	Description    *string   `gorm:"size:200;comment:权限描述"`
+	Status         int       `gorm:"default:1;index:idx_permissions_status;comment:0-禁用,1-启用"`
+	CreatedAt      time.Time `gorm:"comment:创建时间"`
+	UpdatedAt      time.Time `gorm:"comment:更新时间"`
+}
+
+func (permissionV1) TableName() string { return "permissions" }
+
+type userRoleV1 struct {
+	ID        int       `gorm:"primaryKey;autoIncrement"`
+	UserID    int       `gorm:"column:user_id;not null;uniqueIndex:uk_user_role,priority:1;index:idx_user_roles_user_id"`
+	RoleID    int       `gorm:"column:role_id;not null;uniqueIndex:uk_user_role,priority:2;index:idx_user_roles_role_id"`
+	CreatedAt time.Time `gorm:"comment:创建时间"`
+	CreatedBy *int      `gorm:"comment:创建人ID"`
+}
+
+func (userRoleV1) TableName() string { return "user_roles" }
+
+type rolePermissionV1 struct {
+	ID           int       `gorm:"primaryKey;autoIncrement"`
+	RoleID       int       `gorm:"column:role_id;not null;uniqueIndex:uk_role_permission,priority:1;index:idx_role_permissions_role_id"`
+	PermissionID int       `gorm:"column:permission_id;not null;uniqueIndex:uk_role_permission,priority:2;index:idx_role_permissions_permission_id"`
+	CreatedAt    time.Time `gorm:"comment:创建时间"`
+	CreatedBy    *int      `gorm:"comment:创建人ID"`
+}
+
+func (rolePermissionV1) TableName() string { return "role_permissions" }
+
+type operationLogV1 struct {
+	ID          int       `gorm:"primaryKey;autoIncrement"`
+	UserID      *int      `gorm:"column:user_id;index:idx_user_created,priority:1"`
+	Username    *string   `gorm:"size:50"`
+	Operation   string    `gorm:"not null;size:100"`
+	Method      *string   `gorm:"size:10"`
+	URL         *string   `gorm:"size:500"`
+	IP          *string   `gorm:"size:50"`
+	Location    *string   `gorm:"size:100"`
+	Params      *string   `gorm:"type:text"`
+	Result      *string   `gorm:"type:text"`
+	Status      int       `gorm:"default:1;comment:0-失败,1-成功"`
+	ErrorMsg    *string   `gorm:"column:error_msg;size:500"`
+	ExecuteTime int       `gorm:"column:execute_time;comment:执行时间(ms)"`
+	CreatedAt   time.Time `gorm:"index:idx_user_created,priority:2;index:idx_operation_logs_created_at"`
+}
+
+func (operationLogV1) TableName() string { return "operation_logs" }
+
+type verificationCodeV1 struct {
+	Code      string    `gorm:"size:10"`
+	Email     string    `gorm:"size:100;index:idx_verification_codes_email"`
+	ExpiresAt time.Time `gorm:"index:idx_verification_codes_expires_at"`
+	SentAt    time.Time
+}
+
+func (verificationCodeV1) TableName() string { return "verification_codes" }
+
+func init() {
+	register(Migration{
+		Version: 20261019100000,
+		Name:    "init_schema",
+		Up: func(tx *gorm.DB) error {
+			// 早期库中关联表没有唯一约束，先清理重复行
+			if err := dedupe(tx, "user_roles", "user_id, role_id"); err != nil {
+				return err
+			}
+			if err := dedupe(tx, "role_permissions", "role_id, permission_id"); err != nil {
+				return err
+			}
+
+			return ensureSchema(tx,
+				&userV1{},
+				&roleV1{},
+				&permissionV1{},
+				&userRoleV1{},
+				&rolePermissionV1{},
+				&operationLogV1{},
+				&verificationCodeV1{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx,
+				"verification_codes",
+				"operation_logs",
+				"role_permissions",
+				"user_roles",
+				"permissions",
+				"roles",
+				"users",
+			)
+		},
+	})
+}
@@ -0,0 +1,229 @@
+package migrations
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	adminRoleCode = "admin"
+	userRoleCode  = "user"
+	adminUsername = "admin"
+	// adminInitialPassword 默认管理员初始密码，首次登录后应立即修改
+	adminInitialPassword = "admin123"
+)
+
+// permissionSeed 以父权限编码描述权限树，插入时再解析为 parent_id
+type permissionSeed struct {
+	Code       string
+	Name       string
+	Type       string
+	ParentCode string
+	Path       string
+	Component  string
+	Icon       string
+	SortOrder  int
+	Desc       string
+}
+
+var rbacPermissionSeeds = []permissionSeed{
+	{Code: "dashboard:view", Name: "首页", Type: "menu", Path: "/dashboard", Component: "Dashboard", Icon: "DashboardOutlined", SortOrder: 0, Desc: "首页仪表盘"},
+	{Code: "system", Name: "系统管理", Type: "menu", Path: "/system", Icon: "SettingOutlined", SortOrder: 1, Desc: "系统管理"},
+	{Code: "user:manage", Name: "用户管理", Type: "menu", ParentCode: "system", Path: "/system/user", Component: "UserManage", Icon: "UserOutlined", SortOrder: 1, Desc: "用户管理"},
+	{Code: "role:manage", Name: "角色管理", Type: "menu", ParentCode: "system", Path: "/system/role", Component: "RoleManage", Icon: "TeamOutlined", SortOrder: 2, Desc: "角色管理"},
+	{Code: "permission:manage", Name: "权限管理", Type: "menu", ParentCode: "system", Path: "/system/permission", Component: "PermissionManage", Icon: "SafetyOutlined", SortOrder: 3, Desc: "权限管理"},
+	{Code: "log:manage", Name: "操作日志", Type: "menu", ParentCode: "system", Path: "/system/log", Component: "LogManage", Icon: "FileTextOutlined", SortOrder: 4, Desc: "操作日志"},
+	{Code: "log:view", Name: "查看日志", Type: "button", ParentCode: "log:manage", SortOrder: 1, Desc: "查看日志详情"},
+	{Code: "log:delete", Name: "删除日志", Type: "button", ParentCode: "log:manage", SortOrder: 2, Desc: "删除日志"},
+	{Code: "log:clear", Name: "清空日志", Type: "button", ParentCode: "log:manage", SortOrder: 3, Desc: "清空所有日志"},
+	{Code: "hfish:view", Name: "HFish 数据", Type: "menu", Path: "/hfish", Component: "HFishData", Icon: "SecurityScanOutlined", SortOrder: 5, Desc: "HFish 蜜罐数据查看"},
+	{Code: "hfish:block", Name: "封禁 IP", Type: "button", ParentCode: "hfish:view", SortOrder: 1, Desc: "手动封禁 IP 地址"},
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// seedPermissions 按编码插入缺失的权限，并授予 admin 角色；已存在的权限保持不变
+func seedPermissions(tx *gorm.DB, seeds []permissionSeed) error {
+	for _, seed := range seeds {
+		var existing permissionV1
+		err := tx.Where("permission_code = ?", seed.Code).First(&existing).Error
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		parentID := 0
+		if seed.ParentCode != "" {
+			var parent permissionV1
+			if err := tx.Where("permission_code = ?", seed.ParentCode).First(&parent).Error; err != nil {
+				return err
+			}
+			parentID = parent.ID
+		}
+
+		if err := tx.Create(&permissionV1{
+			PermissionName: seed.Name,
+			PermissionCode: seed.Code,
+			PermissionType: seed.Type,
+			ParentID:       parentID,
+			Path:           optional(seed.Path),
+			Component:      optional(seed.Component),
+			Icon:           optional(seed.Icon),
+			SortOrder:      seed.SortOrder,
+			Description:    optional(seed.Desc),
+			Status:         1,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return grantToAdmin(tx, seeds)
+}
+
+func grantToAdmin(tx *gorm.DB, seeds []permissionSeed) error {
+	var admin roleV1
+	if err := tx.Where("role_code = ?", adminRoleCode).First(&admin).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	codes := make([]string, 0, len(seeds))
+	for _, seed := range seeds {
+		codes = append(codes, seed.Code)
+	}
+
+	var permissions []permissionV1
+	if err := tx.Where("permission_code IN ?", codes).Find(&permissions).Error; err != nil {
+		return err
+	}
+
+	for _, p := range permissions {
+		var count int64
+		if err := tx.Model(&rolePermissionV1{}).
+			Where("role_id = ? AND permission_id = ?", admin.ID, p.ID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := tx.Create(&rolePermissionV1{RoleID: admin.ID, PermissionID: p.ID}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removePermissions 删除指定编码的权限及其授权关系
+func removePermissions(tx *gorm.DB, seeds []permissionSeed) error {
+	codes := make([]string, 0, len(seeds))
+	for _, seed := range seeds {
+		codes = append(codes, seed.Code)
+	}
+
+	ids := tx.Model(&permissionV1{}).Select("id").Where("permission_code IN ?", codes)
+	if err := tx.Where("permission_id IN (?)", ids).Delete(&rolePermissionV1{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("permission_code IN ?", codes).Delete(&permissionV1{}).Error
+}
+
+func seedRole(tx *gorm.DB, code, name, desc string) (*roleV1, error) {
+	var role roleV1
+	err := tx.Where("role_code = ?", code).First(&role).Error
+	if err == nil {
+		return &role, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	role = roleV1{RoleCode: code, RoleName: name, Description: optional(desc), Status: 1}
+	if err := tx.Create(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func init() {
+	register(Migration{
+		Version: 20261019100100,
+		Name:    "seed_rbac",
+		Up: func(tx *gorm.DB) error {
+			adminRole, err := seedRole(tx, adminRoleCode, "超级管理员", "拥有系统所有权限")
+			if err != nil {
+				return err
+			}
+			if _, err := seedRole(tx, userRoleCode, "普通用户", "普通用户权限"); err != nil {
+				return err
+			}
+
+			if err := seedPermissions(tx, rbacPermissionSeeds); err != nil {
+				return err
+			}
+
+			var admin userV1
+			err = tx.Where("username = ?", adminUsername).First(&admin).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				hash, err := bcrypt.GenerateFromPassword([]byte(adminInitialPassword), bcrypt.DefaultCost)
+				if err != nil {
+					return err
+				}
+				admin = userV1{
+					Username: adminUsername,
+					Password: string(hash),
+					Email:    optional("admin@example.com"),
+					RealName: optional("系统管理员"),
+					Status:   1,
+				}
+				if err := tx.Create(&admin).Error; err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Model(&userRoleV1{}).
+				Where("user_id = ? AND role_id = ?", admin.ID, adminRole.ID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return tx.Create(&userRoleV1{UserID: admin.ID, RoleID: adminRole.ID}).Error
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := removePermissions(tx, rbacPermissionSeeds); err != nil {
+				return err
+			}
+
+			seededRoles := func() *gorm.DB {
+				return tx.Model(&roleV1{}).Select("id").Where("role_code IN ?", []string{adminRoleCode, userRoleCode})
+			}
+			if err := tx.Where("role_id IN (?)", seededRoles()).Delete(&userRoleV1{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("role_id IN (?)", seededRoles()).Delete(&rolePermissionV1{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("role_code IN ?", []string{adminRoleCode, userRoleCode}).Delete(&roleV1{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("username = ?", adminUsername).Delete(&userV1{}).Error
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type systemSettingV1 struct {
+	Key       string    `gorm:"column:setting_key;primaryKey;size:100;comment:设置键"`
+	Value     string    `gorm:"column:setting_value;type:text;comment:设置值"`
+	UpdatedAt time.Time `gorm:"comment:更新时间"`
+	UpdatedBy *int      `gorm:"column:updated_by;comment:更新人ID"`
+}
+
+func (systemSettingV1) TableName() string { return "system_settings" }
+
+var systemSettingsPermissionSeeds = []permissionSeed{
+	{Code: "system:settings", Name: "系统设置", Type: "menu", ParentCode: "system", Path: "/system/settings", Component: "SystemSettings", Icon: "ControlOutlined", SortOrder: 5, Desc: "限流、SMTP、HFish 等运行时设置"},
+}
+
+func init() {
+	register(Migration{
+		Version: 20261019100200,
+		Name:    "system_settings",
+		Up: func(tx *gorm.DB) error {
+			if err := ensureSchema(tx, &systemSettingV1{}); err != nil {
+				return err
+			}
+			return seedPermissions(tx, systemSettingsPermissionSeeds)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := removePermissions(tx, systemSettingsPermissionSeeds); err != nil {
+				return err
+			}
+			return dropTables(tx, "system_settings")
+		},
+	})
+}
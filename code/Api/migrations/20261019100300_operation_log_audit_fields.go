@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// operationLogV2 在 V1 基础上增加审计事件的动作、对象和字段变更
+type operationLogV2 struct {
+	ID          int       `gorm:"primaryKey;autoIncrement"`
+	UserID      *int      `gorm:"column:user_id;index:idx_user_created,priority:1"`
+	Username    *string   `gorm:"size:50"`
+	Operation   string    `gorm:"not null;size:100"`
+	Method      *string   `gorm:"size:10"`
+	URL         *string   `gorm:"size:500"`
+	IP          *string   `gorm:"size:50"`
+	Location    *string   `gorm:"size:100"`
+	Params      *string   `gorm:"type:text"`
+	Result      *string   `gorm:"type:text"`
+	Status      int       `gorm:"default:1;comment:0-失败,1-成功"`
+	ErrorMsg    *string   `gorm:"column:error_msg;size:500"`
+	ExecuteTime int       `gorm:"column:execute_time;comment:执行时间(ms)"`
+	Action      *string   `gorm:"size:50;index:idx_operation_logs_action;comment:审计动作"`
+	TargetType  *string   `gorm:"column:target_type;size:50;index:idx_operation_logs_target,priority:1;comment:操作对象类型"`
+	TargetID    *string   `gorm:"column:target_id;size:100;index:idx_operation_logs_target,priority:2;comment:操作对象ID"`
+	Changes     *string   `gorm:"type:text;comment:字段变更(JSON)"`
+	CreatedAt   time.Time `gorm:"index:idx_user_created,priority:2;index:idx_operation_logs_created_at"`
+}
+
+func (operationLogV2) TableName() string { return "operation_logs" }
+
+func init() {
+	register(Migration{
+		Version: 20261019100300,
+		Name:    "operation_log_audit_fields",
+		Up: func(tx *gorm.DB) error {
+			return ensureSchema(tx, &operationLogV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, idx := range []string{"idx_operation_logs_action", "idx_operation_logs_target"} {
+				if m.HasIndex(&operationLogV2{}, idx) {
+					if err := m.DropIndex(&operationLogV2{}, idx); err != nil {
+						return err
+					}
+				}
+			}
+			for _, column := range []string{"action", "target_type", "target_id", "changes"} {
+				if m.HasColumn(&operationLogV2{}, column) {
+					if err := m.DropColumn(&operationLogV2{}, column); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,127 @@
+package migrations
+
+import (
+	"time"
+
+	"superhoneypotguard/auditchain"
+
+	"gorm.io/gorm"
+)
+
+// operationLogV3 在 V2 基础上增加哈希链的序号、前一条哈希和本条哈希
+// seq 先允许为空，由迁移按 id 顺序为已有日志补齐
+type operationLogV3 struct {
+	ID          int       `gorm:"primaryKey;autoIncrement"`
+	UserID      *int      `gorm:"column:user_id;index:idx_user_created,priority:1"`
+	Username    *string   `gorm:"size:50"`
+	Operation   string    `gorm:"not null;size:100"`
+	Method      *string   `gorm:"size:10"`
+	URL         *string   `gorm:"size:500"`
+	IP          *string   `gorm:"size:50"`
+	Location    *string   `gorm:"size:100"`
+	Params      *string   `gorm:"type:text"`
+	Result      *string   `gorm:"type:text"`
+	Status      int       `gorm:"default:1;comment:0-失败,1-成功"`
+	ErrorMsg    *string   `gorm:"column:error_msg;size:500"`
+	ExecuteTime int       `gorm:"column:execute_time;comment:执行时间(ms)"`
+	Action      *string   `gorm:"size:50;index:idx_operation_logs_action;comment:审计动作"`
+	TargetType  *string   `gorm:"column:target_type;size:50;index:idx_operation_logs_target,priority:1;comment:操作对象类型"`
+	TargetID    *string   `gorm:"column:target_id;size:100;index:idx_operation_logs_target,priority:2;comment:操作对象ID"`
+	Changes     *string   `gorm:"type:text;comment:字段变更(JSON)"`
+	Seq         *int64    `gorm:"uniqueIndex:idx_operation_logs_seq;comment:哈希链序号"`
+	PrevHash    *string   `gorm:"column:prev_hash;size:64;comment:前一条日志的哈希"`
+	Hash        *string   `gorm:"size:64;comment:本条日志的哈希"`
+	CreatedAt   time.Time `gorm:"index:idx_user_created,priority:2;index:idx_operation_logs_created_at"`
+}
+
+func (operationLogV3) TableName() string { return "operation_logs" }
+
+type auditArchiveV1 struct {
+	ID         int       `gorm:"primaryKey;autoIncrement"`
+	FromSeq    int64     `gorm:"column:from_seq;comment:归档的第一条日志序号"`
+	ToSeq      int64     `gorm:"column:to_seq;uniqueIndex;comment:归档的最后一条日志序号"`
+	LastHash   string    `gorm:"column:last_hash;size:64;comment:归档的最后一条日志哈希"`
+	Count      int64     `gorm:"comment:归档条数"`
+	File       string    `gorm:"size:255;comment:归档文件名"`
+	FileSHA256 string    `gorm:"column:file_sha256;size:64;comment:归档文件 SHA-256"`
+	Before     time.Time `gorm:"column:archived_before;comment:归档截止时间"`
+	CreatedAt  time.Time `gorm:"comment:归档时间"`
+	CreatedBy  *int      `gorm:"column:created_by;comment:操作人ID"`
+}
+
+func (auditArchiveV1) TableName() string { return "audit_archives" }
+
+// chainBackfillBatch 补齐哈希链时每批处理的日志条数
+const chainBackfillBatch = 500
+
+func init() {
+	register(Migration{
+		Version: 20261019100400,
+		Name:    "audit_hash_chain",
+		Up: func(tx *gorm.DB) error {
+			if err := ensureSchema(tx, &operationLogV3{}, &auditArchiveV1{}); err != nil {
+				return err
+			}
+			return backfillChain(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if m.HasIndex(&operationLogV3{}, "idx_operation_logs_seq") {
+				if err := m.DropIndex(&operationLogV3{}, "idx_operation_logs_seq"); err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"seq", "prev_hash", "hash"} {
+				if m.HasColumn(&operationLogV3{}, column) {
+					if err := m.DropColumn(&operationLogV3{}, column); err != nil {
+						return err
+					}
+				}
+			}
+			return dropTables(tx, "audit_archives")
+		},
+	})
+}
+
+// backfillChain 按 id 顺序把尚未上链的日志接到链尾
+func backfillChain(tx *gorm.DB) error {
+	head := auditchain.Start
+	var last operationLogV3
+	err := tx.Where("seq IS NOT NULL").Order("seq DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		return err
+	}
+	if last.Seq != nil {
+		head = auditchain.Head{Seq: *last.Seq, Hash: *last.Hash}
+	}
+
+	for {
+		var batch []operationLogV3
+		if err := tx.Where("seq IS NULL").Order("id").Limit(chainBackfillBatch).Find(&batch).Error; err != nil {
+			return err
+		}
+		for i := range batch {
+			row := &batch[i]
+			seq, prev := head.Seq+1, head.Hash
+			hash := auditchain.Record{
+				Seq: seq, PrevHash: prev,
+				UserID: row.UserID, Username: row.Username, Operation: row.Operation,
+				Method: row.Method, URL: row.URL, IP: row.IP, Location: row.Location,
+				Params: row.Params, Result: row.Result, Status: row.Status,
+				ErrorMsg: row.ErrorMsg, ExecuteTime: row.ExecuteTime,
+				Action: row.Action, TargetType: row.TargetType, TargetID: row.TargetID,
+				Changes: row.Changes, CreatedAt: row.CreatedAt,
+			}.Hash()
+			err := tx.Model(&operationLogV3{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"seq": seq, "prev_hash": prev, "hash": hash,
+			}).Error
+			if err != nil {
+				return err
+			}
+			head = auditchain.Head{Seq: seq, Hash: hash}
+		}
+		if len(batch) < chainBackfillBatch {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// operationLogV4 在 V3 基础上增加 16 字节形式的 IP，用于按网段检索
+type operationLogV4 struct {
+	ID          int       `gorm:"primaryKey;autoIncrement"`
+	UserID      *int      `gorm:"column:user_id;index:idx_user_created,priority:1"`
+	Username    *string   `gorm:"size:50"`
+	Operation   string    `gorm:"not null;size:100"`
+	Method      *string   `gorm:"size:10"`
+	URL         *string   `gorm:"size:500"`
+	IP          *string   `gorm:"size:50"`
+	IPBin       []byte    `gorm:"column:ip_bin;size:16;index:idx_operation_logs_ip;comment:IP 的 16 字节形式"`
+	Location    *string   `gorm:"size:100"`
+	Params      *string   `gorm:"type:text"`
+	Result      *string   `gorm:"type:text"`
+	Status      int       `gorm:"default:1;comment:0-失败,1-成功"`
+	ErrorMsg    *string   `gorm:"column:error_msg;size:500"`
+	ExecuteTime int       `gorm:"column:execute_time;comment:执行时间(ms)"`
+	Action      *string   `gorm:"size:50;index:idx_operation_logs_action;comment:审计动作"`
+	TargetType  *string   `gorm:"column:target_type;size:50;index:idx_operation_logs_target,priority:1;comment:操作对象类型"`
+	TargetID    *string   `gorm:"column:target_id;size:100;index:idx_operation_logs_target,priority:2;comment:操作对象ID"`
+	Changes     *string   `gorm:"type:text;comment:字段变更(JSON)"`
+	Seq         *int64    `gorm:"uniqueIndex:idx_operation_logs_seq;comment:哈希链序号"`
+	PrevHash    *string   `gorm:"column:prev_hash;size:64;comment:前一条日志的哈希"`
+	Hash        *string   `gorm:"size:64;comment:本条日志的哈希"`
+	CreatedAt   time.Time `gorm:"index:idx_user_created,priority:2;index:idx_operation_logs_created_at"`
+}
+
+func (operationLogV4) TableName() string { return "operation_logs" }
+
+func init() {
+	register(Migration{
+		Version: 20261019100500,
+		Name:    "operation_log_search",
+		Up: func(tx *gorm.DB) error {
+			if err := ensureSchema(tx, &operationLogV4{}); err != nil {
+				return err
+			}
+			return backfillIPBin(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if m.HasIndex(&operationLogV4{}, "idx_operation_logs_ip") {
+				if err := m.DropIndex(&operationLogV4{}, "idx_operation_logs_ip"); err != nil {
+					return err
+				}
+			}
+			if m.HasColumn(&operationLogV4{}, "ip_bin") {
+				return m.DropColumn(&operationLogV4{}, "ip_bin")
+			}
+			return nil
+		},
+	})
+}
+
+// backfillIPBin 为已有日志补齐 ip_bin，无法解析的 IP 保持为空
+func backfillIPBin(tx *gorm.DB) error {
+	lastID := 0
+	for {
+		var batch []operationLogV4
+		err := tx.Select("id", "ip").Where("id > ? AND ip IS NOT NULL AND ip_bin IS NULL", lastID).
+			Order("id").Limit(chainBackfillBatch).Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range batch {
+			lastID = row.ID
+			addr, err := netip.ParseAddr(strings.TrimSpace(*row.IP))
+			if err != nil {
+				continue
+			}
+			key := addr.Unmap().As16()
+			if err := tx.Model(&operationLogV4{}).Where("id = ?", row.ID).Update("ip_bin", key[:]).Error; err != nil {
+				return err
+			}
+		}
+		if len(batch) < chainBackfillBatch {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type retentionRunV1 struct {
+	ID          int       `gorm:"primaryKey;autoIncrement"`
+	Policy      string    `gorm:"size:50;index:idx_retention_runs_policy,priority:1;comment:保留策略"`
+	Source      string    `gorm:"size:20;comment:触发方式"`
+	Cutoff      time.Time `gorm:"comment:清理截止时间"`
+	Rows        int64     `gorm:"comment:清理条数"`
+	ArchiveFile *string   `gorm:"column:archive_file;size:255;comment:归档文件"`
+	Status      int       `gorm:"comment:0-失败,1-成功"`
+	ErrorMsg    *string   `gorm:"column:error_msg;size:500"`
+	StartedAt   time.Time `gorm:"index:idx_retention_runs_policy,priority:2"`
+	FinishedAt  time.Time
+	CreatedBy   *int `gorm:"column:created_by;comment:执行人ID"`
+}
+
+func (retentionRunV1) TableName() string { return "retention_runs" }
+
+func init() {
+	register(Migration{
+		Version: 20261019100600,
+		Name:    "retention_runs",
+		Up: func(tx *gorm.DB) error {
+			return ensureSchema(tx, &retentionRunV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "retention_runs")
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// userV2 在 V1 基础上增加最后登录地点
+type userV2 struct {
+	ID                int        `gorm:"primaryKey;autoIncrement;comment:用户ID"`
+	Username          string     `gorm:"uniqueIndex:idx_users_username;not null;size:50;comment:用户名"`
+	Password          string     `gorm:"not null;size:255;comment:密码(加密后)"`
+	Email             *string    `gorm:"uniqueIndex:idx_users_email;size:100;comment:邮箱"`
+	Phone             *string    `gorm:"size:20;comment:手机号"`
+	RealName          *string    `gorm:"column:real_name;size:50;comment:真实姓名"`
+	Status            int        `gorm:"default:1;index:idx_users_status;comment:0-禁用,1-启用"`
+	LastLoginTime     *time.Time `gorm:"column:last_login_time;comment:最后登录时间"`
+	LastLoginIP       *string    `gorm:"column:last_login_ip;size:50;comment:最后登录IP"`
+	LastLoginLocation *string    `gorm:"column:last_login_location;size:100;comment:最后登录地点"`
+	CreatedAt         time.Time  `gorm:"comment:创建时间"`
+	UpdatedAt         time.Time  `gorm:"comment:更新时间"`
+	CreatedBy         *int       `gorm:"comment:创建人ID"`
+	UpdatedBy         *int       `gorm:"comment:更新人ID"`
+}
+
+func (userV2) TableName() string { return "users" }
+
+func init() {
+	register(Migration{
+		Version: 20261019100700,
+		Name:    "user_login_location",
+		Up: func(tx *gorm.DB) error {
+			return ensureSchema(tx, &userV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&userV2{}, "last_login_location") {
+				return tx.Migrator().DropColumn(&userV2{}, "last_login_location")
+			}
+			return nil
+		},
+	})
+}
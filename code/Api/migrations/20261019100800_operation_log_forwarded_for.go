@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// operationLogV5 在 V4 基础上增加请求的完整转发链
+type operationLogV5 struct {
+	ID           int       `gorm:"primaryKey;autoIncrement"`
+	UserID       *int      `gorm:"column:user_id;index:idx_user_created,priority:1"`
+	Username     *string   `gorm:"size:50"`
+	Operation    string    `gorm:"not null;size:100"`
+	Method       *string   `gorm:"size:10"`
+	URL          *string   `gorm:"size:500"`
+	IP           *string   `gorm:"size:50"`
+	IPBin        []byte    `gorm:"column:ip_bin;size:16;index:idx_operation_logs_ip;comment:IP 的 16 字节形式"`
+	ForwardedFor *string   `gorm:"column:forwarded_for;size:500;comment:请求经过的转发链"`
+	Location     *string   `gorm:"size:100"`
+	Params       *string   `gorm:"type:text"`
+	Result       *string   `gorm:"type:text"`
+	Status       int       `gorm:"default:1;comment:0-失败,1-成功"`
+	ErrorMsg     *string   `gorm:"column:error_msg;size:500"`
+	ExecuteTime  int       `gorm:"column:execute_time;comment:执行时间(ms)"`
+	Action       *string   `gorm:"size:50;index:idx_operation_logs_action;comment:审计动作"`
+	TargetType   *string   `gorm:"column:target_type;size:50;index:idx_operation_logs_target,priority:1;comment:操作对象类型"`
+	TargetID     *string   `gorm:"column:target_id;size:100;index:idx_operation_logs_target,priority:2;comment:操作对象ID"`
+	Changes      *string   `gorm:"type:text;comment:字段变更(JSON)"`
+	Seq          *int64    `gorm:"uniqueIndex:idx_operation_logs_seq;comment:哈希链序号"`
+	PrevHash     *string   `gorm:"column:prev_hash;size:64;comment:前一条日志的哈希"`
+	Hash         *string   `gorm:"size:64;comment:本条日志的哈希"`
+	CreatedAt    time.Time `gorm:"index:idx_user_created,priority:2;index:idx_operation_logs_created_at"`
+}
+
+func (operationLogV5) TableName() string { return "operation_logs" }
+
+func init() {
+	register(Migration{
+		Version: 20261019100800,
+		Name:    "operation_log_forwarded_for",
+		Up: func(tx *gorm.DB) error {
+			return ensureSchema(tx, &operationLogV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&operationLogV5{}, "forwarded_for") {
+				return tx.Migrator().DropColumn(&operationLogV5{}, "forwarded_for")
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type hfishInstanceV1 struct {
+	ID            int     `gorm:"primaryKey;autoIncrement"`
+	Name          string  `gorm:"uniqueIndex;not null;size:50;comment:实例名称"`
+	BaseURL       string  `gorm:"column:base_url;not null;size:255;comment:管理端 API 地址"`
+	APIKey        string  `gorm:"column:api_key;type:text;comment:加密后的 API Key"`
+	TLSSkipVerify bool    `gorm:"column:tls_skip_verify;comment:是否跳过证书校验"`
+	Enabled       bool    `gorm:"comment:是否启用"`
+	Remark        *string `gorm:"size:200"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	CreatedBy     *int `gorm:"column:created_by"`
+	UpdatedBy     *int `gorm:"column:updated_by"`
+}
+
+func (hfishInstanceV1) TableName() string { return "hfish_instances" }
+
+var hfishInstancePermissionSeeds = []permissionSeed{
+	{Code: "hfish:manage", Name: "管理 HFish 实例", Type: "button", ParentCode: "hfish:view", SortOrder: 2, Desc: "添加、修改、删除 HFish 管理端实例"},
+}
+
+func init() {
+	register(Migration{
+		Version: 20261019110100,
+		Name:    "hfish_instances",
+		Up: func(tx *gorm.DB) error {
+			if err := ensureSchema(tx, &hfishInstanceV1{}); err != nil {
+				return err
+			}
+			return seedPermissions(tx, hfishInstancePermissionSeeds)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := removePermissions(tx, hfishInstancePermissionSeeds); err != nil {
+				return err
+			}
+			return dropTables(tx, "hfish_instances")
+		},
+	})
+}
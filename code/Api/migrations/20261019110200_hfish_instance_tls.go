@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// hfishInstanceV2 在 V1 基础上增加每个实例的 TLS 设置
+type hfishInstanceV2 struct {
+	ID            int     `gorm:"primaryKey;autoIncrement"`
+	Name          string  `gorm:"uniqueIndex;not null;size:50;comment:实例名称"`
+	BaseURL       string  `gorm:"column:base_url;not null;size:255;comment:管理端 API 地址"`
+	APIKey        string  `gorm:"column:api_key;type:text;comment:加密后的 API Key"`
+	TLSSkipVerify bool    `gorm:"column:tls_skip_verify;comment:是否跳过证书校验"`
+	TLSCACert     string  `gorm:"column:tls_ca_cert;type:text;comment:信任的 CA 证书(PEM)"`
+	TLSPinSHA256  string  `gorm:"column:tls_pin_sha256;size:300;comment:固定的服务端证书 SHA-256 指纹"`
+	TLSClientCert string  `gorm:"column:tls_client_cert;type:text;comment:mTLS 客户端证书(PEM)"`
+	TLSClientKey  string  `gorm:"column:tls_client_key;type:text;comment:加密后的 mTLS 客户端私钥"`
+	Enabled       bool    `gorm:"comment:是否启用"`
+	Remark        *string `gorm:"size:200"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	CreatedBy     *int `gorm:"column:created_by"`
+	UpdatedBy     *int `gorm:"column:updated_by"`
+}
+
+func (hfishInstanceV2) TableName() string { return "hfish_instances" }
+
+func init() {
+	register(Migration{
+		Version: 20261019110200,
+		Name:    "hfish_instance_tls",
+		Up: func(tx *gorm.DB) error {
+			return ensureSchema(tx, &hfishInstanceV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range []string{"tls_ca_cert", "tls_pin_sha256", "tls_client_cert", "tls_client_key"} {
+				if !m.HasColumn(&hfishInstanceV2{}, column) {
+					continue
+				}
+				if err := m.DropColumn(&hfishInstanceV2{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
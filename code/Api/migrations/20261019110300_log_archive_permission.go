@@ -0,0 +1,33 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// removedLogPermissionSeeds 删除、清空日志的接口已由哈希链归档取代，对应的权限不再使用
+var removedLogPermissionSeeds = []permissionSeed{
+	{Code: "log:delete", Name: "删除日志", Type: "button", ParentCode: "log:manage", SortOrder: 2, Desc: "删除日志"},
+	{Code: "log:clear", Name: "清空日志", Type: "button", ParentCode: "log:manage", SortOrder: 3, Desc: "清空所有日志"},
+}
+
+// logArchivePermissionSeeds 归档会从数据库中删除日志，与查看日志分开授权
+var logArchivePermissionSeeds = []permissionSeed{
+	{Code: "log:archive", Name: "归档日志", Type: "button", ParentCode: "log:manage", SortOrder: 2, Desc: "归档并从数据库中删除过期的操作日志"},
+}
+
+func init() {
+	register(Migration{
+		Version: 20261019110300,
+		Name:    "log_archive_permission",
+		Up: func(tx *gorm.DB) error {
+			if err := removePermissions(tx, removedLogPermissionSeeds); err != nil {
+				return err
+			}
+			return seedPermissions(tx, logArchivePermissionSeeds)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := removePermissions(tx, logArchivePermissionSeeds); err != nil {
+				return err
+			}
+			return seedPermissions(tx, removedLogPermissionSeeds)
+		},
+	})
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type hfishAttackIPV1 struct {
+	ID          int        `gorm:"primaryKey;autoIncrement"`
+	InstanceID  int        `gorm:"column:instance_id;uniqueIndex:idx_hfish_attack_ips_source,priority:1;comment:HFish 实例ID"`
+	IP          string     `gorm:"column:ip;size:64;uniqueIndex:idx_hfish_attack_ips_source,priority:2"`
+	IPBin       []byte     `gorm:"column:ip_bin;size:16;index:idx_hfish_attack_ips_ip;comment:IP 的 16 字节形式"`
+	SourceID    string     `gorm:"column:source_id;size:64;comment:HFish 中的记录ID"`
+	Count       int        `gorm:"comment:攻击次数"`
+	FirstSeen   string     `gorm:"column:first_seen;size:32;comment:首次出现时间(原始值)"`
+	LastSeen    string     `gorm:"column:last_seen;size:32;comment:最后出现时间(原始值)"`
+	FirstSeenAt *time.Time `gorm:"column:first_seen_at"`
+	LastSeenAt  *time.Time `gorm:"column:last_seen_at;index:idx_hfish_attack_ips_last_seen"`
+	SyncedAt    time.Time  `gorm:"column:synced_at;comment:最近同步时间"`
+}
+
+func (hfishAttackIPV1) TableName() string { return "hfish_attack_ips" }
+
+type hfishAttackDetailV1 struct {
+	ID          int    `gorm:"primaryKey;autoIncrement"`
+	InstanceID  int    `gorm:"column:instance_id;uniqueIndex:idx_hfish_attack_details_source,priority:1;comment:HFish 实例ID"`
+	SourceID    string `gorm:"column:source_id;size:64;uniqueIndex:idx_hfish_attack_details_source,priority:2;comment:HFish 中的记录ID"`
+	IP          string `gorm:"column:ip;size:64"`
+	IPBin       []byte `gorm:"column:ip_bin;size:16;index:idx_hfish_attack_details_ip;comment:IP 的 16 字节形式"`
+	AttackType  string `gorm:"column:attack_type;size:50"`
+	Protocol    string `gorm:"size:50"`
+	Port        int
+	Payload     string     `gorm:"type:text"`
+	Account     string     `gorm:"type:text"`
+	RequestTime string     `gorm:"column:request_time;size:32;comment:请求时间(原始值)"`
+	RequestedAt *time.Time `gorm:"column:requested_at;index:idx_hfish_attack_details_requested_at"`
+	SyncedAt    time.Time  `gorm:"column:synced_at;comment:同步时间"`
+}
+
+func (hfishAttackDetailV1) TableName() string { return "hfish_attack_details" }
+
+func init() {
+	register(Migration{
+		Version: 20261019110400,
+		Name:    "hfish_attacks",
+		Up: func(tx *gorm.DB) error {
+			return ensureSchema(tx, &hfishAttackIPV1{}, &hfishAttackDetailV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "hfish_attack_details", "hfish_attack_ips")
+		},
+	})
+}
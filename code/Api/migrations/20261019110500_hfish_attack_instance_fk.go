@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// hfishAttackIPV2、hfishAttackDetailV2 在 V1 基础上以外键关联实例，删除实例时数据库级联删除同步的数据，
+// 删除后仍在进行的同步也无法再为该实例写入数据
+type hfishAttackIPV2 struct {
+	ID          int             `gorm:"primaryKey;autoIncrement"`
+	InstanceID  int             `gorm:"column:instance_id;uniqueIndex:idx_hfish_attack_ips_source,priority:1;comment:HFish 实例ID"`
+	Instance    hfishInstanceV2 `gorm:"foreignKey:InstanceID;constraint:OnDelete:CASCADE"`
+	IP          string          `gorm:"column:ip;size:64;uniqueIndex:idx_hfish_attack_ips_source,priority:2"`
+	IPBin       []byte          `gorm:"column:ip_bin;size:16;index:idx_hfish_attack_ips_ip;comment:IP 的 16 字节形式"`
+	SourceID    string          `gorm:"column:source_id;size:64;comment:HFish 中的记录ID"`
+	Count       int             `gorm:"comment:攻击次数"`
+	FirstSeen   string          `gorm:"column:first_seen;size:32;comment:首次出现时间(原始值)"`
+	LastSeen    string          `gorm:"column:last_seen;size:32;comment:最后出现时间(原始值)"`
+	FirstSeenAt *time.Time      `gorm:"column:first_seen_at"`
+	LastSeenAt  *time.Time      `gorm:"column:last_seen_at;index:idx_hfish_attack_ips_last_seen"`
+	SyncedAt    time.Time       `gorm:"column:synced_at;comment:最近同步时间"`
+}
+
+func (hfishAttackIPV2) TableName() string { return "hfish_attack_ips" }
+
+type hfishAttackDetailV2 struct {
+	ID          int             `gorm:"primaryKey;autoIncrement"`
+	InstanceID  int             `gorm:"column:instance_id;uniqueIndex:idx_hfish_attack_details_source,priority:1;comment:HFish 实例ID"`
+	Instance    hfishInstanceV2 `gorm:"foreignKey:InstanceID;constraint:OnDelete:CASCADE"`
+	SourceID    string          `gorm:"column:source_id;size:64;uniqueIndex:idx_hfish_attack_details_source,priority:2;comment:HFish 中的记录ID"`
+	IP          string          `gorm:"column:ip;size:64"`
+	IPBin       []byte          `gorm:"column:ip_bin;size:16;index:idx_hfish_attack_details_ip;comment:IP 的 16 字节形式"`
+	AttackType  string          `gorm:"column:attack_type;size:50"`
+	Protocol    string          `gorm:"size:50"`
+	Port        int
+	Payload     string     `gorm:"type:text"`
+	Account     string     `gorm:"type:text"`
+	RequestTime string     `gorm:"column:request_time;size:32;comment:请求时间(原始值)"`
+	RequestedAt *time.Time `gorm:"column:requested_at;index:idx_hfish_attack_details_requested_at"`
+	SyncedAt    time.Time  `gorm:"column:synced_at;comment:同步时间"`
+}
+
+func (hfishAttackDetailV2) TableName() string { return "hfish_attack_details" }
+
+func init() {
+	register(Migration{
+		Version: 20261019110500,
+		Name:    "hfish_attack_instance_fk",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, model := range []interface{}{&hfishAttackIPV2{}, &hfishAttackDetailV2{}} {
+				stmt := &gorm.Statement{DB: tx}
+				if err := stmt.Parse(model); err != nil {
+					return err
+				}
+				// 先清理已删除实例遗留的数据，否则无法创建外键
+				if err := tx.Exec("DELETE FROM " + stmt.Schema.Table + " WHERE instance_id NOT IN (SELECT id FROM hfish_instances)").Error; err != nil {
+					return err
+				}
+				if m.HasConstraint(model, "Instance") {
+					continue
+				}
+				if err := m.CreateConstraint(model, "Instance"); err != nil {
+					return err
+				}
+			}
+			// SQLite 通过重建表添加外键，重建后的表没有索引，需要补齐
+			return ensureSchema(tx, &hfishAttackIPV2{}, &hfishAttackDetailV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, model := range []interface{}{&hfishAttackIPV2{}, &hfishAttackDetailV2{}} {
+				if !m.HasConstraint(model, "Instance") {
+					continue
+				}
+				if err := m.DropConstraint(model, "Instance"); err != nil {
+					return err
+				}
+			}
+			return ensureSchema(tx, &hfishAttackIPV1{}, &hfishAttackDetailV1{})
+		},
+	})
+}
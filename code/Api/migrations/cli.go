@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+const usage = `usage: migrate <command> [args]
+
+commands:
+  up              apply all pending migrations
+  down [n]        revert the last n migrations (default 1)
+  status          show applied and pending migrations
+  create <name>   generate a new migration file in ./migrations`
+
+// RunCLI 执行 migrate 子命令；open 仅在需要数据库时调用
+func RunCLI(args []string, open func() (*gorm.DB, error), out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			return fmt.Errorf("missing migration name\n%s", usage)
+		}
+		path, err := Create("migrations", args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s\n", path)
+		return nil
+	}
+
+	db, err := open()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		ran, err := Up(db)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Applied %d migration(s)\n", len(ran))
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		reverted, err := Down(db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Reverted %d migration(s)\n", len(reverted))
+		return nil
+
+	case "status":
+		statuses, err := StatusOf(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Missing {
+				state += " (missing from binary)"
+			}
+			fmt.Fprintf(out, "%d  %-40s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
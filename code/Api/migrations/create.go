@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+const migrationTemplate = `package migrations
+
+import "gorm.io/gorm"
+
+func init() {
+	register(Migration{
+		Version: %d,
+		Name:    %q,
+		Up: func(tx *gorm.DB) error {
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
+`
+
+// Create 在 dir 下生成一个以当前时间为版本号的迁移文件模板
+func Create(dir, name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !migrationNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid migration name %q: use lowercase letters, digits and underscores", name)
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	path := filepath.Join(dir, version+"_"+name+".go")
+
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+
+	v, _ := strconv.ParseInt(version, 10, 64)
+	content := fmt.Sprintf(migrationTemplate, v, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ensureSchema 表不存在时创建；已存在时只补齐缺失的列和索引，不修改已有列
+// 用于兼容早期由 AutoMigrate 或 db/init.sql 建好的库
+func ensureSchema(tx *gorm.DB, models ...interface{}) error {
+	m := tx.Migrator()
+
+	for _, model := range models {
+		if !m.HasTable(model) {
+			if err := m.CreateTable(model); err != nil {
+				return err
+			}
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || m.HasColumn(model, field.DBName) {
+				continue
+			}
+			if err := m.AddColumn(model, field.Name); err != nil {
+				return fmt.Errorf("add column %s.%s: %w", stmt.Schema.Table, field.DBName, err)
+			}
+		}
+
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if m.HasIndex(model, idx.Name) {
+				continue
+			}
+			if err := m.CreateIndex(model, idx.Name); err != nil {
+				return fmt.Errorf("create index %s: %w", idx.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// dropTables 按给定顺序删除表
+func dropTables(tx *gorm.DB, tables ...string) error {
+	for _, table := range tables {
+		if err := tx.Migrator().DropTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dedupe 删除 columns 组合重复的行（保留 id 最小的一条），为创建唯一索引做准备
+func dedupe(tx *gorm.DB, table string, columns string) error {
+	if !tx.Migrator().HasTable(table) {
+		return nil
+	}
+	// 外层再包一层子查询，避免 MySQL 不允许在 DELETE 中直接引用目标表
+	return tx.Exec(fmt.Sprintf(
+		"DELETE FROM %s WHERE id NOT IN (SELECT id FROM (SELECT MIN(id) AS id FROM %s GROUP BY %s) AS keep_rows)",
+		table, table, columns,
+	)).Error
+}
@@ -0,0 +1,190 @@
+package migrations
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一个版本化的数据库变更，Up/Down 在同一事务中执行
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration 记录已执行的迁移版本
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 单个迁移的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Missing 表示数据库中有记录但当前程序中找不到对应迁移
+	Missing bool
+}
+
+var registry = map[int64]Migration{}
+
+func register(m Migration) {
+	if _, exists := registry[m.Version]; exists {
+		panic(fmt.Sprintf("migrations: duplicate version %d (%s)", m.Version, m.Name))
+	}
+	registry[m.Version] = m
+}
+
+// All 按版本升序返回所有已注册的迁移
+func All() []Migration {
+	list := make([]Migration, 0, len(registry))
+	for _, m := range registry {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+func ensureTable(db *gorm.DB) error {
+	return db.AutoMigrate(&SchemaMigration{})
+}
+
+func applied(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := db.Order("version ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+// Up 依次执行所有未执行的迁移，返回本次执行的迁移
+func Up(db *gorm.DB) ([]Migration, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, m := range All() {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %d_%s up: %w", m.Version, m.Name, err)
+		}
+
+		slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+		ran = append(ran, m)
+	}
+
+	return ran, nil
+}
+
+// Down 回滚最近执行的 steps 个迁移
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var rows []SchemaMigration
+	if err := db.Order("version DESC").Limit(steps).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for _, row := range rows {
+		m, ok := registry[row.Version]
+		if !ok {
+			return reverted, fmt.Errorf("migration %d_%s is not known to this binary", row.Version, row.Name)
+		}
+		if m.Down == nil {
+			return reverted, fmt.Errorf("migration %d_%s is irreversible", m.Version, m.Name)
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d_%s down: %w", m.Version, m.Name, err)
+		}
+
+		slog.Info("Reverted migration", "version", m.Version, "name", m.Name)
+		reverted = append(reverted, m)
+	}
+
+	return reverted, nil
+}
+
+// StatusOf 列出所有迁移及其执行情况
+func StatusOf(db *gorm.DB) ([]Status, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Status
+	for _, m := range All() {
+		s := Status{Version: m.Version, Name: m.Name}
+		if row, ok := done[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			s.Applied = true
+			s.AppliedAt = &appliedAt
+		}
+		result = append(result, s)
+	}
+
+	for version, row := range done {
+		if _, ok := registry[version]; !ok {
+			appliedAt := row.AppliedAt
+			result = append(result, Status{
+				Version:   version,
+				Name:      row.Name,
+				Applied:   true,
+				AppliedAt: &appliedAt,
+				Missing:   true,
+			})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
@@ -5,34 +5,35 @@ import (
 )
 
 type User struct {
-	ID            int       `json:"id" gorm:"primaryKey;autoIncrement"`
-	Username      string    `json:"username" gorm:"uniqueIndex;not null;size:50"`
-	Password      string    `json:"-" gorm:"not null;size:255"`
-	Email         *string   `json:"email" gorm:"uniqueIndex;size:100"`
-	Phone         *string   `json:"phone" gorm:"size:20"`
-	RealName      *string   `json:"realName" gorm:"column:real_name;size:50"`
-	Status        int       `json:"status" gorm:"default:1;comment:0-禁用,1-启用"`
-	LastLoginTime *time.Time `json:"lastLoginTime" gorm:"column:last_login_time"`
-	LastLoginIP   *string   `json:"lastLoginIp" gorm:"column:last_login_ip;size:50"`
-	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
-	CreatedBy     *int      `json:"created_by"`
-	UpdatedBy     *int      `json:"updated_by"`
-	Roles         []Role    `json:"roles" gorm:"many2many:user_roles;"`
+	ID                int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	Username          string     `json:"username" gorm:"uniqueIndex;not null;size:50"`
+	Password          string     `json:"-" gorm:"not null;size:255"`
+	Email             *string    `json:"email" gorm:"uniqueIndex;size:100"`
+	Phone             *string    `json:"phone" gorm:"size:20"`
+	RealName          *string    `json:"realName" gorm:"column:real_name;size:50"`
+	Status            int        `json:"status" gorm:"comment:0-禁用,1-启用"`
+	LastLoginTime     *time.Time `json:"lastLoginTime" gorm:"column:last_login_time"`
+	LastLoginIP       *string    `json:"lastLoginIp" gorm:"column:last_login_ip;size:50"`
+	LastLoginLocation *string    `json:"lastLoginLocation" gorm:"column:last_login_location;size:100"`
+	CreatedAt         time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	CreatedBy         *int       `json:"created_by"`
+	UpdatedBy         *int       `json:"updated_by"`
+	Roles             []Role     `json:"roles" gorm:"many2many:user_roles;"`
 }
 
 type Role struct {
-	ID          int         `json:"id" gorm:"primaryKey;autoIncrement"`
-	RoleName    string      `json:"roleName" gorm:"column:role_name;uniqueIndex;not null;size:50"`
-	RoleCode    string      `json:"roleCode" gorm:"column:role_code;uniqueIndex;not null;size:50"`
-	Description *string     `json:"description" gorm:"size:200"`
-	Status      int         `json:"status" gorm:"default:1;comment:0-禁用,1-启用"`
-	CreatedAt   time.Time   `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time   `json:"updatedAt" gorm:"autoUpdateTime"`
-	CreatedBy   *int        `json:"created_by"`
-	UpdatedBy   *int        `json:"updated_by"`
+	ID          int          `json:"id" gorm:"primaryKey;autoIncrement"`
+	RoleName    string       `json:"roleName" gorm:"column:role_name;uniqueIndex;not null;size:50"`
+	RoleCode    string       `json:"roleCode" gorm:"column:role_code;uniqueIndex;not null;size:50"`
+	Description *string      `json:"description" gorm:"size:200"`
+	Status      int          `json:"status" gorm:"comment:0-禁用,1-启用"`
+	CreatedAt   time.Time    `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time    `json:"updatedAt" gorm:"autoUpdateTime"`
+	CreatedBy   *int         `json:"created_by"`
+	UpdatedBy   *int         `json:"updated_by"`
 	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;"`
-	Users       []User      `json:"-" gorm:"many2many:user_roles;"`
+	Users       []User       `json:"-" gorm:"many2many:user_roles;"`
 }
 
 type Permission struct {
@@ -46,7 +47,7 @@ type Permission struct {
 	Icon           *string      `json:"icon" gorm:"size:50"`
 	SortOrder      int          `json:"sortOrder" gorm:"column:sort_order;default:0"`
 	Description    *string      `json:"description" gorm:"size:200"`
-	Status         int          `json:"status" gorm:"default:1;comment:0-禁用,1-启用"`
+	Status         int          `json:"status" gorm:"comment:0-禁用,1-启用"`
 	CreatedAt      time.Time    `json:"createdAt" gorm:"autoCreateTime"`
 	UpdatedAt      time.Time    `json:"updatedAt" gorm:"autoUpdateTime"`
 	Children       []Permission `json:"children" gorm:"-"`
@@ -74,22 +75,183 @@ type RolePermission struct {
 // 提交时间：2026-01-19
 
 type OperationLog struct {
-	ID          int       `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID      *int      `json:"userId" gorm:"column:user_id;index:idx_user_created"`
-	Username    *string   `json:"username" gorm:"size:50"`
-	Operation   string    `json:"operation" gorm:"not null;size:100"`
-	Method      *string   `json:"method" gorm:"size:10"`
-	URL         *string   `json:"url" gorm:"size:500"`
-	IP          *string   `json:"ip" gorm:"size:50"`
+	ID        int     `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    *int    `json:"userId" gorm:"column:user_id;index:idx_user_created"`
+	Username  *string `json:"username" gorm:"size:50"`
+	Operation string  `json:"operation" gorm:"not null;size:100"`
+	Method    *string `json:"method" gorm:"size:10"`
+	URL       *string `json:"url" gorm:"size:500"`
+	IP        *string `json:"ip" gorm:"size:50"`
+	IPBin     []byte  `json:"-" gorm:"column:ip_bin;size:16;index:idx_operation_logs_ip"`
+	// ForwardedFor 请求头中声明的完整转发链，未经校验，仅供追查；IP 才是受信任的客户端地址
+	ForwardedFor *string `json:"forwardedFor" gorm:"column:forwarded_for;size:500"`
+	// RequestID 请求的 X-Request-ID，与应用日志中的 request_id 对应
+	RequestID *string `json:"requestId" gorm:"column:request_id;size:128;index:idx_operation_logs_request_id"`
+	// TraceID 请求的 OpenTelemetry 追踪 ID，未启用追踪或未采样时为空
+	TraceID     *string   `json:"traceId" gorm:"column:trace_id;size:32;index:idx_operation_logs_trace_id"`
 	Location    *string   `json:"location" gorm:"size:100"`
 	Params      *string   `json:"params" gorm:"type:text"`
 	Result      *string   `json:"result" gorm:"type:text"`
-	Status      int       `json:"status" gorm:"default:1;comment:0-失败,1-成功"`
+	Status      int       `json:"status" gorm:"comment:0-失败,1-成功"`
 	ErrorMsg    *string   `json:"errorMsg" gorm:"column:error_msg;size:500"`
 	ExecuteTime int       `json:"executeTime" gorm:"column:execute_time;comment:执行时间(ms)"`
+	Action      *string   `json:"action" gorm:"size:50"`
+	TargetType  *string   `json:"targetType" gorm:"column:target_type;size:50"`
+	TargetID    *string   `json:"targetId" gorm:"column:target_id;size:100"`
+	Changes     *string   `json:"changes" gorm:"type:text"`
+	Seq         int64     `json:"seq" gorm:"uniqueIndex:idx_operation_logs_seq"`
+	PrevHash    string    `json:"prevHash" gorm:"column:prev_hash;size:64"`
+	Hash        string    `json:"hash" gorm:"size:64"`
 	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime;index:idx_user_created"`
 }
 
+// AuditArchive 一次操作日志归档的锚点
+// 归档后的日志从数据库删除，链的校验从 ToSeq 的下一条开始，其前一条哈希必须等于 LastHash
+type AuditArchive struct {
+	ID         int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	FromSeq    int64     `json:"fromSeq" gorm:"column:from_seq"`
+	ToSeq      int64     `json:"toSeq" gorm:"column:to_seq;uniqueIndex"`
+	LastHash   string    `json:"lastHash" gorm:"column:last_hash;size:64"`
+	Count      int64     `json:"count"`
+	File       string    `json:"file" gorm:"size:255"`
+	FileSHA256 string    `json:"fileSha256" gorm:"column:file_sha256;size:64"`
+	Before     time.Time `json:"before" gorm:"column:archived_before"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	CreatedBy  *int      `json:"createdBy" gorm:"column:created_by"`
+}
+
+// RetentionRun 一次数据保留策略的执行记录
+type RetentionRun struct {
+	ID     int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Policy string `json:"policy" gorm:"size:50;index:idx_retention_runs_policy,priority:1"`
+	// Source 触发方式：schedule 定时任务、manual 接口手动执行、cli 命令行
+	Source string `json:"source" gorm:"size:20"`
+	// Cutoff 早于该时间的数据被清理
+	Cutoff      time.Time `json:"cutoff"`
+	Rows        int64     `json:"rows"`
+	ArchiveFile *string   `json:"archiveFile" gorm:"column:archive_file;size:255"`
+	Status      int       `json:"status"`
+	ErrorMsg    *string   `json:"errorMsg" gorm:"column:error_msg;size:500"`
+	StartedAt   time.Time `json:"startedAt" gorm:"index:idx_retention_runs_policy,priority:2"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	CreatedBy   *int      `json:"createdBy" gorm:"column:created_by"`
+}
+
+// RunRetentionRequest 手动执行保留策略，Policy 为空时执行全部已启用的策略
+type RunRetentionRequest struct {
+	Policy string `json:"policy"`
+}
+
+// AuditEvent 一次业务操作的审计事件
+// 控制器在处理请求时产生，替换该请求日志中的原始路由，使日志可读且可按动作和对象检索
+type AuditEvent struct {
+	// Action 动作标识，如 user.disable、role.create
+	Action string
+	// TargetType、TargetID 被操作的对象，如 user / 12
+	TargetType string
+	TargetID   string
+	// Description 人类可读的描述，如 "禁用用户 bob"
+	Description string
+	// Changes 字段级的变更前后值
+	Changes map[string]AuditChange
+	// ActorID、ActorName 为空时使用当前登录用户，用于登录、注册等请求开始时尚未认证的操作
+	ActorID   *int
+	ActorName *string
+}
+
+// AuditChange 单个字段变更前后的值
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// VerificationCode 邮箱验证码记录
+type VerificationCode struct {
+	Code      string
+	Email     string
+	ExpiresAt time.Time
+	SentAt    time.Time
+}
+
+// SystemSetting 运行时可在控制台修改的系统设置，未保存的键使用配置文件中的值
+type SystemSetting struct {
+	Key       string    `json:"key" gorm:"column:setting_key;primaryKey;size:100"`
+	Value     string    `json:"value" gorm:"column:setting_value;type:text"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	UpdatedBy *int      `json:"updatedBy" gorm:"column:updated_by"`
+}
+
+// HFishInstance 一个 HFish 管理端节点，API Key 加密后保存
+type HFishInstance struct {
+	ID      int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name    string `json:"name" gorm:"uniqueIndex;not null;size:50"`
+	BaseURL string `json:"baseUrl" gorm:"column:base_url;not null;size:255"`
+	// APIKey 加密后的 API Key，不直接返回给客户端
+	APIKey string `json:"-" gorm:"column:api_key;type:text"`
+	// MaskedAPIKey 返回给客户端的脱敏值
+	MaskedAPIKey string `json:"apiKey" gorm:"-"`
+	// TLSSkipVerify 不校验证书，需显式开启，健康检查中会给出警告
+	TLSSkipVerify bool `json:"tlsSkipVerify" gorm:"column:tls_skip_verify"`
+	// TLSCACert 信任的 CA 证书（PEM），配置后不再使用系统根证书
+	TLSCACert string `json:"tlsCaCert" gorm:"column:tls_ca_cert;type:text"`
+	// TLSPinSHA256 固定的服务端证书 SHA-256 指纹，多个用逗号分隔
+	TLSPinSHA256 string `json:"tlsPinSha256" gorm:"column:tls_pin_sha256;size:300"`
+	// TLSClientCert mTLS 客户端证书（PEM）
+	TLSClientCert string `json:"tlsClientCert" gorm:"column:tls_client_cert;type:text"`
+	// TLSClientKey 加密后的 mTLS 客户端私钥，不直接返回给客户端
+	TLSClientKey string `json:"-" gorm:"column:tls_client_key;type:text"`
+	// MaskedTLSClientKey 返回给客户端的脱敏值
+	MaskedTLSClientKey string    `json:"tlsClientKey" gorm:"-"`
+	Enabled            bool      `json:"enabled"`
+	Remark             *string   `json:"remark" gorm:"size:200"`
+	CreatedAt          time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt          time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	CreatedBy          *int      `json:"createdBy"`
+	UpdatedBy          *int      `json:"updatedBy"`
+}
+
+func (HFishInstance) TableName() string { return "hfish_instances" }
+
+// HFishAttackIP 从 HFish 实例同步的攻击来源 IP，同一实例中每个 IP 一行，再次同步时更新统计
+type HFishAttackIP struct {
+	ID         int    `gorm:"primaryKey;autoIncrement"`
+	InstanceID int    `gorm:"column:instance_id;uniqueIndex:idx_hfish_attack_ips_source,priority:1"`
+	IP         string `gorm:"column:ip;size:64;uniqueIndex:idx_hfish_attack_ips_source,priority:2"`
+	IPBin      []byte `gorm:"column:ip_bin;size:16;index:idx_hfish_attack_ips_ip"`
+	// SourceID HFish 中的记录 ID
+	SourceID string `gorm:"column:source_id;size:64"`
+	Count    int
+	// FirstSeen、LastSeen 为 HFish 返回的原始时间，FirstSeenAt、LastSeenAt 为解析后的值，无法解析时为空
+	FirstSeen   string     `gorm:"column:first_seen;size:32"`
+	LastSeen    string     `gorm:"column:last_seen;size:32"`
+	FirstSeenAt *time.Time `gorm:"column:first_seen_at"`
+	LastSeenAt  *time.Time `gorm:"column:last_seen_at;index:idx_hfish_attack_ips_last_seen"`
+	SyncedAt    time.Time  `gorm:"column:synced_at"`
+}
+
+func (HFishAttackIP) TableName() string { return "hfish_attack_ips" }
+
+// HFishAttackDetail 从 HFish 实例同步的攻击详情，按实例和 HFish 中的记录 ID 去重，
+// HFish 端清理后本地仍保留，直到被数据保留策略删除
+type HFishAttackDetail struct {
+	ID          int    `gorm:"primaryKey;autoIncrement"`
+	InstanceID  int    `gorm:"column:instance_id;uniqueIndex:idx_hfish_attack_details_source,priority:1"`
+	SourceID    string `gorm:"column:source_id;size:64;uniqueIndex:idx_hfish_attack_details_source,priority:2"`
+	IP          string `gorm:"column:ip;size:64"`
+	IPBin       []byte `gorm:"column:ip_bin;size:16;index:idx_hfish_attack_details_ip"`
+	AttackType  string `gorm:"column:attack_type;size:50"`
+	Protocol    string `gorm:"size:50"`
+	Port        int
+	Payload     string `gorm:"type:text"`
+	Account     string `gorm:"type:text"`
+	RequestTime string `gorm:"column:request_time;size:32"`
+	// RequestedAt 解析后的请求时间，无法解析时为空
+	RequestedAt *time.Time `gorm:"column:requested_at;index:idx_hfish_attack_details_requested_at"`
+	SyncedAt    time.Time  `gorm:"column:synced_at"`
+}
+
+func (HFishAttackDetail) TableName() string { return "hfish_attack_details" }
+
 type RegisterRequest struct {
 	Username string  `json:"username" binding:"required,min=3,max=50"`
 	Password string  `json:"password" binding:"required,min=6"`
@@ -122,7 +284,7 @@ type UpdateUserRequest struct {
 }
 
 type UpdateUserStatusRequest struct {
-	Status int `json:"status" binding:"required,oneof=0 1"`
+	Status *int `json:"status" binding:"required,oneof=0 1"`
 }
 
 type ResetPasswordRequest struct {
@@ -147,7 +309,7 @@ type UpdateRoleRequest struct {
 type CreatePermissionRequest struct {
 	PermissionName string  `json:"permissionName" binding:"required"`
 	PermissionCode string  `json:"permissionCode" binding:"required"`
-	PermissionType string `json:"permissionType" binding:"required,oneof=menu button api"`
+	PermissionType string  `json:"permissionType" binding:"required,oneof=menu button api"`
 	ParentID       *int    `json:"parentId"`
 	Path           *string `json:"path"`
 	Component      *string `json:"component"`
@@ -169,12 +331,63 @@ type UpdatePermissionRequest struct {
 	Status         *int    `json:"status"`
 }
 
+// ArchiveLogsRequest 归档 Before 之前的操作日志
+type ArchiveLogsRequest struct {
+	Before *time.Time `json:"before" binding:"required"`
+}
+
+type UpdateSettingsRequest struct {
+	Settings map[string]interface{} `json:"settings" binding:"required"`
+}
+
+type CreateHFishInstanceRequest struct {
+	Name          string  `json:"name" binding:"required,max=50"`
+	BaseURL       string  `json:"baseUrl" binding:"required"`
+	APIKey        string  `json:"apiKey" binding:"required"`
+	TLSSkipVerify bool    `json:"tlsSkipVerify"`
+	TLSCACert     string  `json:"tlsCaCert"`
+	TLSPinSHA256  string  `json:"tlsPinSha256"`
+	TLSClientCert string  `json:"tlsClientCert"`
+	TLSClientKey  string  `json:"tlsClientKey"`
+	Enabled       *bool   `json:"enabled"`
+	Remark        *string `json:"remark"`
+}
+
+// UpdateHFishInstanceRequest 未提交的字段保持不变，APIKey、TLSClientKey 为空或脱敏值时不修改；
+// TLSClientCert 提交空字符串时同时清除客户端私钥
+type UpdateHFishInstanceRequest struct {
+	Name          *string `json:"name" binding:"omitempty,max=50"`
+	BaseURL       *string `json:"baseUrl"`
+	APIKey        *string `json:"apiKey"`
+	TLSSkipVerify *bool   `json:"tlsSkipVerify"`
+	TLSCACert     *string `json:"tlsCaCert"`
+	TLSPinSHA256  *string `json:"tlsPinSha256"`
+	TLSClientCert *string `json:"tlsClientCert"`
+	TLSClientKey  *string `json:"tlsClientKey"`
+	Enabled       *bool   `json:"enabled"`
+	Remark        *string `json:"remark"`
+}
+
+// BlockIPRequest 在指定的 HFish 实例上封禁 IP，InstanceIDs 为空时发往全部已启用的实例
+type BlockIPRequest struct {
+	IP          string `json:"ip" binding:"required"`
+	Reason      string `json:"reason"`
+	InstanceIDs []int  `json:"instanceIds"`
+}
+
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// CursorPage 游标分页结果，NextCursor 为空表示没有更多数据
+type CursorPage struct {
+	List       interface{} `json:"list"`
+	NextCursor string      `json:"nextCursor"`
+	PageSize   int         `json:"pageSize"`
+}
+
 type PaginatedResponse struct {
 	List     interface{} `json:"list"`
 	Total    int64       `json:"total"`
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory 进程内限流，多个实例之间不共享配额
+type Memory struct {
+	// Now 当前时间，测试中可以替换
+	Now func() time.Time
+
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	throttled map[string]time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{Now: time.Now, tats: make(map[string]time.Time), throttled: make(map[string]time.Time)}
+}
+
+func (m *Memory) Allow(_ context.Context, key string, rule Rule) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+	result, tat := gcra(now, m.tats[key], rule)
+	m.tats[key] = tat
+	if !result.Allowed {
+		m.throttled[key] = now.Add(result.RetryAfter)
+	}
+	return result, nil
+}
+
+func (m *Memory) Throttled(context.Context) ([]Throttle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+	var list []Throttle
+	for key, until := range m.throttled {
+		if until.After(now) {
+			list = append(list, Throttle{Key: key, Until: until})
+		}
+	}
+	return list, nil
+}
+
+// Cleanup 理论到达时间已过的 key 配额已经恢复满额，删除后不影响限流结果
+func (m *Memory) Cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+	for key, tat := range m.tats {
+		if !tat.After(now) {
+			delete(m.tats, key)
+		}
+	}
+	for key, until := range m.throttled {
+		if !until.After(now) {
+			delete(m.throttled, key)
+		}
+	}
+}
+
+// Len 当前保存状态的 key 数量
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.tats)
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package ratelimit 按 key 限制请求频率
+//
+// 限流采用 GCRA（通用信元速率算法）：每个 key 只保存一个"理论到达时间"，
+// 效果等同于容量为 Max、每 Window/Max 补充一个令牌的令牌桶，
+// 但状态只有一个时间戳，便于在 Redis 中用一条脚本原子地完成判断和更新。
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule 窗口 Window 内最多 Max 次请求，允许一次性用完
+type Rule struct {
+	Window time.Duration
+	Max    int
+}
+
+// interval 补充一次配额的间隔
+func (r Rule) interval() time.Duration {
+	return r.Window / time.Duration(r.Max)
+}
+
+// Validate 窗口和次数必须大于 0，且补充间隔不小于 1µs（Redis 脚本以微秒计算，间隔为 0 时无法限流）
+func (r Rule) Validate() error {
+	if r.Window <= 0 || r.Max <= 0 {
+		return fmt.Errorf("窗口和次数必须大于 0")
+	}
+	if r.interval() < time.Microsecond {
+		return fmt.Errorf("%d 次/%s 的补充间隔小于 1µs，请减少次数或加大窗口", r.Max, r.Window)
+	}
+	return nil
+}
+
+// Result 一次请求的限流结果
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining 本次请求之后剩余的配额
+	Remaining int
+	// RetryAfter 被拒绝时距离下一次可以请求的时间
+	RetryAfter time.Duration
+	// ResetAfter 距离配额完全恢复的时间
+	ResetAfter time.Duration
+}
+
+// Throttle 一个正在被限流的 key，Until 之后才能再次请求
+type Throttle struct {
+	Key   string
+	Until time.Time
+}
+
+// Limiter 限流后端，实现需支持并发调用
+type Limiter interface {
+	// Allow 为 key 消耗一次配额，被拒绝的 key 记录到 Throttled 中
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+	// Throttled 当前仍在限流中的 key
+	Throttled(ctx context.Context) ([]Throttle, error)
+	// Cleanup 释放已恢复满额的 key 占用的内存，由定时任务调用
+	Cleanup()
+	Close() error
+}
+
+// 限流维度：按客户端 IP 或登录用户计数
+const (
+	KeyIP   = "ip"
+	KeyUser = "user"
+)
+
+// GlobalPolicy 全局限流策略的名称，参数来自 rate_limit_window/rate_limit_max_requests，不能在策略列表中重新定义
+const GlobalPolicy = "global"
+
+// Policy 一个命名的限流策略
+type Policy struct {
+	Name string
+	Rule Rule
+	// Key 计数维度，取值为 KeyIP 或 KeyUser
+	Key string
+}
+
+// String 与 ParsePolicies 接受的格式一致，如 auth=10/15m0s:ip
+func (p Policy) String() string {
+	return fmt.Sprintf("%s=%d/%s:%s", p.Name, p.Rule.Max, p.Rule.Window, p.Key)
+}
+
+// ParsePolicies 解析逗号分隔的策略列表，每项形如 "名称=次数/窗口:维度"，如 "auth=10/15m:ip"
+// 维度省略时按 IP 计数
+func ParsePolicies(spec string) ([]Policy, error) {
+	var policies []Policy
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !validPolicyName(name) {
+			return nil, fmt.Errorf("无效的限流策略 %q（格式: 名称=次数/窗口:维度）", item)
+		}
+		if name == GlobalPolicy {
+			return nil, fmt.Errorf("限流策略 %s 为全局限流保留", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("限流策略 %s 重复", name)
+		}
+		seen[name] = true
+
+		value, key, hasKey := strings.Cut(value, ":")
+		key = strings.TrimSpace(key)
+		if !hasKey {
+			key = KeyIP
+		}
+		if key != KeyIP && key != KeyUser {
+			return nil, fmt.Errorf("限流策略 %s: 无效的维度 %q（可选: ip、user）", name, key)
+		}
+
+		count, window, ok := strings.Cut(value, "/")
+		max, err := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || err != nil || max <= 0 {
+			return nil, fmt.Errorf("限流策略 %s: 无效的次数 %q", name, count)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(window))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("限流策略 %s: 无效的窗口 %q", name, window)
+		}
+
+		rule := Rule{Window: d, Max: max}
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("限流策略 %s: %v", name, err)
+		}
+
+		policies = append(policies, Policy{Name: name, Rule: rule, Key: key})
+	}
+	return policies, nil
+}
+
+// validPolicyName 策略名称只能包含字母、数字、下划线和连字符，名称会作为限流 key 的一部分
+func validPolicyName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// gcra 根据 key 当前的理论到达时间 tat 判断本次请求，返回结果和新的 tat（被拒绝时不变）
+func gcra(now, tat time.Time, rule Rule) (Result, time.Time) {
+	if tat.Before(now) {
+		tat = now
+	}
+	interval := rule.interval()
+	newTAT := tat.Add(interval)
+	allowAt := newTAT.Add(-rule.Window)
+
+	if now.Before(allowAt) {
+		return Result{
+			Limit:      rule.Max,
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}, tat
+	}
+	return Result{
+		Allowed:    true,
+		Limit:      rule.Max,
+		Remaining:  int(now.Add(rule.Window).Sub(newTAT) / interval),
+		ResetAfter: newTAT.Sub(now),
+	}, newTAT
+}
+
+func microseconds(n int64) time.Duration {
+	return time.Duration(n) * time.Microsecond
+}
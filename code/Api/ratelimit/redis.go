@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript 与 gcra 相同的算法，时间取 Redis 服务器时间（微秒），避免各实例时钟不一致
+// 需要 Redis 5.0 及以上（脚本按效果复制，允许在写入前调用 TIME）
+//
+// 脚本只访问 KEYS[1] 一个限流 key，Redis Cluster 中按 key 分散到各个槽；
+// ARGV[1] 补充间隔，ARGV[2] 窗口，单位均为微秒
+// 返回 {是否允许, 剩余配额, 重试等待, 恢复满额等待, 服务器时间}，时间单位为微秒
+var gcraScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local tat = now
+local stored = redis.call('GET', KEYS[1])
+if stored then
+  tat = math.max(tonumber(stored), now)
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - window
+if now < allow_at then
+  return {0, 0, allow_at - now, tat - now, now}
+end
+
+redis.call('SET', KEYS[1], new_tat, 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now + window - new_tat) / interval), 0, new_tat - now, now}
+`)
+
+// throttleScript 把被拒绝的 key 记入有序集合（分值为可再次请求的时间）并清理已到期的成员
+// KEYS[1] 有序集合，ARGV[1] 服务器时间，ARGV[2] 可再次请求的时间（微秒），ARGV[3] 被拒绝的 key
+var throttleScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local allow_at = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+redis.call('ZADD', KEYS[1], allow_at, ARGV[3])
+local wait = math.ceil((allow_at - now) / 1000)
+if redis.call('PTTL', KEYS[1]) < wait then
+  redis.call('PEXPIRE', KEYS[1], wait)
+end
+return 1
+`)
+
+// Redis 多个实例共享配额的限流，状态保存在 Redis 中并随配额恢复自动过期
+// Redis 不可用时退回到进程内限流，恢复后自动切回
+type Redis struct {
+	client   redis.UniversalClient
+	prefix   string
+	fallback *Memory
+	degraded atomic.Bool
+}
+
+// NewRedis prefix 为限流 key 的前缀，用于与同一 Redis 中的其他数据区分
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix, fallback: NewMemory()}
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	values, err := gcraScript.Run(ctx, r.client, []string{r.prefix + key},
+		rule.interval().Microseconds(), rule.Window.Microseconds()).Int64Slice()
+	if err != nil {
+		// 只在切换到进程内限流时记录一次，恢复前不再重复
+		if r.degraded.CompareAndSwap(false, true) {
+			slog.WarnContext(ctx, "Redis 限流不可用，暂时使用进程内限流", "error", err)
+		}
+		return r.fallback.Allow(ctx, key, rule)
+	}
+	if r.degraded.CompareAndSwap(true, false) {
+		slog.InfoContext(ctx, "Redis 限流已恢复")
+	}
+
+	result := Result{
+		Allowed:    values[0] == 1,
+		Limit:      rule.Max,
+		Remaining:  int(values[1]),
+		RetryAfter: microseconds(values[2]),
+		ResetAfter: microseconds(values[3]),
+	}
+	if !result.Allowed {
+		// 有序集合只用于展示限流中的客户端，与限流 key 不在同一个槽，单独记录，失败不影响本次判断
+		now := values[4]
+		if err := throttleScript.Run(ctx, r.client, []string{r.throttledKey()}, now, now+values[2], r.prefix+key).Err(); err != nil {
+			slog.DebugContext(ctx, "记录限流中的客户端失败", "error", err)
+		}
+	}
+	return result, nil
+}
+
+// Throttled 读取有序集合中尚未到期的 key，Redis 不可用时返回进程内的记录
+func (r *Redis) Throttled(ctx context.Context) ([]Throttle, error) {
+	now, err := r.client.Time(ctx).Result()
+	if err != nil {
+		return r.fallback.Throttled(ctx)
+	}
+	members, err := r.client.ZRangeByScoreWithScores(ctx, r.throttledKey(), &redis.ZRangeBy{
+		Min: fmt.Sprintf("(%d", now.UnixMicro()),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Throttle, 0, len(members))
+	for _, m := range members {
+		key, _ := m.Member.(string)
+		list = append(list, Throttle{
+			Key:   strings.TrimPrefix(key, r.prefix),
+			Until: time.UnixMicro(int64(m.Score)),
+		})
+	}
+	return list, nil
+}
+
+func (r *Redis) throttledKey() string {
+	return r.prefix + "throttled"
+}
+
+// Ping 检查 Redis 连通性，用于就绪检查
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Degraded 是否因 Redis 不可用而使用进程内限流
+func (r *Redis) Degraded() bool {
+	return r.degraded.Load()
+}
+
+// Cleanup Redis 中的 key 自动过期，只需清理降级期间的进程内状态
+func (r *Redis) Cleanup() {
+	r.fallback.Cleanup()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,221 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Mask 替代敏感值的文本
+const Mask = "******"
+
+// DefaultKeys 默认按键名脱敏的规则
+var DefaultKeys = []string{"*password", "*token", "code", "*api_key", "*secret", "*client_key"}
+
+// Redactor 按规则替换 JSON 或表单中的敏感值
+//
+// 键名规则匹配任意层级上的对象键，比较时忽略大小写以及 "_"、"-"，
+// 支持 path.Match 通配符，如 "*password" 同时匹配 password、newPassword 和 smtp_password。
+// 路径规则以 "$" 开头，按层级定位，"*" 或 "[*]" 匹配任意键或数组下标，如 "$.data.list[*].ip"。
+type Redactor struct {
+	keys  []string
+	paths [][]string
+}
+
+// New 解析规则，规则无效时返回错误
+func New(keys, paths []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, key := range keys {
+		key = normalizeKey(key)
+		if key == "" {
+			continue
+		}
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("无效的脱敏键名规则 %q", key)
+		}
+		r.keys = append(r.keys, key)
+	}
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		segments, err := parsePath(p)
+		if err != nil {
+			return nil, err
+		}
+		r.paths = append(r.paths, segments)
+	}
+	return r, nil
+}
+
+// JSON 脱敏 JSON 文本，body 不是合法 JSON 时 ok 为 false
+func (r *Redactor) JSON(body []byte) (redacted []byte, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return nil, false
+	}
+
+	doc = r.walk(doc, nil)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, false
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), true
+}
+
+// Form 脱敏 application/x-www-form-urlencoded 文本，只应用键名规则
+func (r *Redactor) Form(body []byte) (redacted []byte, ok bool) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, false
+	}
+	for key, vals := range values {
+		if r.matchKey(key) {
+			for i := range vals {
+				vals[i] = Mask
+			}
+		}
+	}
+	return []byte(values.Encode()), true
+}
+
+// textPair 自由文本中的 key=value、key: value 或 "key":"value"
+var textPair = regexp.MustCompile(`([A-Za-z][A-Za-z0-9_\-]*)("?\s*[=:]\s*"?)([^\s"&,;]+)`)
+
+// bearerToken Authorization 头中的 Bearer 令牌
+var bearerToken = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+
+// Key 键名是否命中键名规则
+func (r *Redactor) Key(key string) bool {
+	return r.matchKey(key)
+}
+
+// Text 脱敏自由文本（如日志消息、错误信息），替换键名命中规则的 key=value 值和 Bearer 令牌
+// 如 "GET /x?api_key=abc" 变为 "GET /x?api_key=******"
+func (r *Redactor) Text(s string) string {
+	s = bearerToken.ReplaceAllString(s, "${1}"+Mask)
+	return textPair.ReplaceAllStringFunc(s, func(pair string) string {
+		m := textPair.FindStringSubmatch(pair)
+		if !r.matchKey(m[1]) {
+			return pair
+		}
+		return m[1] + m[2] + Mask
+	})
+}
+
+func (r *Redactor) walk(value interface{}, at []string) interface{} {
+	if r.matchPath(at) {
+		return Mask
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childAt := append(at[:len(at):len(at)], key)
+			if r.matchKey(key) {
+				v[key] = Mask
+				continue
+			}
+			v[key] = r.walk(child, childAt)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.walk(child, append(at[:len(at):len(at)], strconv.Itoa(i)))
+		}
+	}
+	return value
+}
+
+func (r *Redactor) matchKey(key string) bool {
+	key = normalizeKey(key)
+	for _, pattern := range r.keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) matchPath(at []string) bool {
+	if len(at) == 0 {
+		return false
+	}
+	for _, segments := range r.paths {
+		if len(segments) != len(at) {
+			continue
+		}
+		matched := true
+		for i, segment := range segments {
+			if segment != "*" && segment != at[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeKey 统一大小写并去掉分隔符，使 api_key、apiKey、API-KEY 视为同一个键
+func normalizeKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	return strings.NewReplacer("_", "", "-", "").Replace(key)
+}
+
+// parsePath 将 "$.a.b[*].c" 解析为 ["a", "b", "*", "c"]
+func parsePath(p string) ([]string, error) {
+	invalid := fmt.Errorf("无效的脱敏路径规则 %q（示例: $.data.token、$.list[*].password）", p)
+	if !strings.HasPrefix(p, "$") {
+		return nil, invalid
+	}
+
+	var segments []string
+	rest := p[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, invalid
+			}
+			segments = append(segments, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, invalid
+			}
+			index := rest[1:end]
+			if index != "*" {
+				if _, err := strconv.Atoi(index); err != nil {
+					return nil, invalid
+				}
+			}
+			segments = append(segments, index)
+			rest = rest[end+1:]
+		default:
+			return nil, invalid
+		}
+	}
+	if len(segments) == 0 {
+		return nil, invalid
+	}
+	return segments, nil
+}
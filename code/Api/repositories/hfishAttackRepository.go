@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"time"
+
+	"superhoneypotguard/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// hfishSaveBatchSize 同步写入时每批插入的行数
+const hfishSaveBatchSize = 500
+
+// HFishAttackFilter 攻击数据查询条件，零值字段不参与过滤
+type HFishAttackFilter struct {
+	// InstanceIDs 只返回这些实例的数据，为空时不返回任何数据
+	InstanceIDs []int
+	// IP 单个地址或网段，见 ParseIPRange
+	IP *IPRange
+	// StartTime、EndTime 包含 StartTime、不包含 EndTime；攻击 IP 按活跃区间与之有交集筛选
+	StartTime *time.Time
+	EndTime   *time.Time
+	// AttackTypes、Protocols 小写形式，忽略大小写匹配；Account 忽略大小写按包含匹配，仅攻击详情使用
+	AttackTypes []string
+	Protocols   []string
+	Ports       []int
+	Account     string
+}
+
+// HFishAttackIPSortFields 攻击 IP 允许排序的字段，键为接口参数，值为列名
+var HFishAttackIPSortFields = map[string]string{
+	"lastSeen":  "last_seen_at",
+	"firstSeen": "first_seen_at",
+	"count":     "count",
+	"ip":        "ip_bin",
+}
+
+// HFishAttackDetailSortFields 攻击详情允许排序的字段，键为接口参数，值为列名
+var HFishAttackDetailSortFields = map[string]string{
+	"requestTime": "requested_at",
+	"ip":          "ip_bin",
+	"port":        "port",
+	"attackType":  "attack_type",
+	"protocol":    "protocol",
+}
+
+// HFishAttackSort 排序方式，相同取值的行按实例、写入顺序排列
+type HFishAttackSort struct {
+	Field string
+	Desc  bool
+}
+
+func (s HFishAttackSort) order() string {
+	direction := " ASC"
+	if s.Desc {
+		direction = " DESC"
+	}
+	return s.Field + direction + ", instance_id ASC, id ASC"
+}
+
+// HFishAttackRepository 保存从 HFish 实例同步的攻击数据，查询时在数据库中过滤、排序和分页
+type HFishAttackRepository interface {
+	// SaveIPs 写入一个实例的攻击 IP，已存在的 IP 更新统计
+	SaveIPs(rows []models.HFishAttackIP) error
+	// SaveDetails 写入一个实例的攻击详情，已存在的记录保持不变
+	SaveDetails(rows []models.HFishAttackDetail) error
+	ListIPs(filter HFishAttackFilter, sort HFishAttackSort, offset, limit int) ([]models.HFishAttackIP, int64, error)
+	ListDetails(filter HFishAttackFilter, sort HFishAttackSort, offset, limit int) ([]models.HFishAttackDetail, int64, error)
+}
+
+type gormHFishAttackRepository struct {
+	db *gorm.DB
+}
+
+func NewHFishAttackRepository(db *gorm.DB) HFishAttackRepository {
+	return &gormHFishAttackRepository{db: db}
+}
+
+func (r *gormHFishAttackRepository) SaveIPs(rows []models.HFishAttackIP) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instance_id"}, {Name: "ip"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ip_bin", "source_id", "count", "first_seen", "last_seen", "first_seen_at", "last_seen_at", "synced_at"}),
+	}).CreateInBatches(rows, hfishSaveBatchSize).Error
+}
+
+func (r *gormHFishAttackRepository) SaveDetails(rows []models.HFishAttackDetail) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instance_id"}, {Name: "source_id"}},
+		DoNothing: true,
+	}).CreateInBatches(rows, hfishSaveBatchSize).Error
+}
+
+func (r *gormHFishAttackRepository) ListIPs(filter HFishAttackFilter, sort HFishAttackSort, offset, limit int) ([]models.HFishAttackIP, int64, error) {
+	query := applyHFishAttackFilter(r.db.Model(&models.HFishAttackIP{}), filter)
+	if filter.StartTime != nil {
+		query = query.Where("last_seen_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("first_seen_at < ?", *filter.EndTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []models.HFishAttackIP
+	if err := query.Offset(offset).Limit(limit).Order(sort.order()).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+func (r *gormHFishAttackRepository) ListDetails(filter HFishAttackFilter, sort HFishAttackSort, offset, limit int) ([]models.HFishAttackDetail, int64, error) {
+	query := applyHFishAttackFilter(r.db.Model(&models.HFishAttackDetail{}), filter)
+	if filter.StartTime != nil {
+		query = query.Where("requested_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("requested_at < ?", *filter.EndTime)
+	}
+	if len(filter.AttackTypes) > 0 {
+		query = query.Where("LOWER(attack_type) IN ?", filter.AttackTypes)
+	}
+	if len(filter.Protocols) > 0 {
+		query = query.Where("LOWER(protocol) IN ?", filter.Protocols)
+	}
+	if len(filter.Ports) > 0 {
+		query = query.Where("port IN ?", filter.Ports)
+	}
+	if filter.Account != "" {
+		query = query.Where("LOWER(account) LIKE ?", "%"+filter.Account+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []models.HFishAttackDetail
+	if err := query.Offset(offset).Limit(limit).Order(sort.order()).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// applyHFishAttackFilter 两张表共有的实例和 IP 条件
+func applyHFishAttackFilter(query *gorm.DB, filter HFishAttackFilter) *gorm.DB {
+	if len(filter.InstanceIDs) == 0 {
+		return query.Where("1 = 0")
+	}
+	query = query.Where("instance_id IN ?", filter.InstanceIDs)
+	if filter.IP != nil {
+		query = query.Where("ip_bin BETWEEN ? AND ?", filter.IP.From, filter.IP.To)
+	}
+	return query
+}
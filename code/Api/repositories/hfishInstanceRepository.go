@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"superhoneypotguard/models"
+
+	"gorm.io/gorm"
+)
+
+type HFishInstanceRepository interface {
+	List() ([]models.HFishInstance, error)
+	FindByID(id int) (*models.HFishInstance, error)
+	ExistsByName(name string, excludeID int) (bool, error)
+	Count() (int64, error)
+	Create(instance *models.HFishInstance) error
+	Update(id int, updates map[string]interface{}) error
+	Delete(id int) error
+}
+
+type gormHFishInstanceRepository struct {
+	db *gorm.DB
+}
+
+func NewHFishInstanceRepository(db *gorm.DB) HFishInstanceRepository {
+	return &gormHFishInstanceRepository{db: db}
+}
+
+func (r *gormHFishInstanceRepository) List() ([]models.HFishInstance, error) {
+	var instances []models.HFishInstance
+	err := r.db.Order("id ASC").Find(&instances).Error
+	return instances, err
+}
+
+func (r *gormHFishInstanceRepository) FindByID(id int) (*models.HFishInstance, error) {
+	var instance models.HFishInstance
+	if err := r.db.Where("id = ?", id).First(&instance).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &instance, nil
+}
+
+// ExistsByName 检查名称是否已被其他实例使用，excludeID 为修改中的实例
+func (r *gormHFishInstanceRepository) ExistsByName(name string, excludeID int) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.HFishInstance{}).Where("name = ? AND id <> ?", name, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormHFishInstanceRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.HFishInstance{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormHFishInstanceRepository) Create(instance *models.HFishInstance) error {
+	return r.db.Create(instance).Error
+}
+
+func (r *gormHFishInstanceRepository) Update(id int, updates map[string]interface{}) error {
+	return r.db.Model(&models.HFishInstance{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// Delete 删除实例及从该实例同步的攻击数据；攻击数据表以外键关联实例，删除时仍在进行的同步也无法再写入
+func (r *gormHFishInstanceRepository) Delete(id int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("instance_id = ?", id).Delete(&models.HFishAttackIP{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("instance_id = ?", id).Delete(&models.HFishAttackDetail{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.HFishInstance{}, id).Error
+	})
+}
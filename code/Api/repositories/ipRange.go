@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// IPRange 以 16 字节形式表示的 IP 闭区间，IPv4 使用 IPv4 映射地址，与 ip_bin 列的存储方式一致
+type IPRange struct {
+	From []byte
+	To   []byte
+}
+
+// IPKey 将日志中的 IP 转换为 ip_bin 列的值，无法解析时返回 nil
+func IPKey(ip *string) []byte {
+	if ip == nil {
+		return nil
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(*ip))
+	if err != nil {
+		return nil
+	}
+	return addr16(addr)
+}
+
+// ParseIPRange 解析单个地址（如 10.0.0.1）或 CIDR 网段（如 10.0.0.0/8、2001:db8::/32）
+func ParseIPRange(value string) (*IPRange, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 IP 地址 %q", value)
+		}
+		key := addr16(addr)
+		return &IPRange{From: key, To: key}, nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return nil, fmt.Errorf("无效的网段 %q", value)
+	}
+	prefix = prefix.Masked()
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+
+	from := addr16(prefix.Addr())
+	to := make([]byte, len(from))
+	copy(to, from)
+	for i := bits; i < 128; i++ {
+		to[i/8] |= 0x80 >> (i % 8)
+	}
+	return &IPRange{From: from, To: to}, nil
+}
+
+// addr16 地址的 16 字节形式，IPv4 转换为 IPv4 映射地址
+func addr16(addr netip.Addr) []byte {
+	key := addr.Unmap().As16()
+	return key[:]
+}
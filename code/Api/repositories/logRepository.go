@@ -0,0 +1,321 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"superhoneypotguard/auditchain"
+	"superhoneypotguard/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LogFilter 操作日志查询条件，零值字段不参与过滤
+type LogFilter struct {
+	Username  string
+	Operation string
+	Status    string
+	// Action 审计动作，以 * 结尾时按前缀匹配，如 user.*
+	Action     string
+	TargetType string
+	TargetID   string
+	UserID     *int
+	Methods    []string
+	// IP 单个地址或网段，见 ParseIPRange
+	IP *IPRange
+	// StartTime、EndTime 记录时间范围，包含 StartTime、不包含 EndTime
+	StartTime *time.Time
+	EndTime   *time.Time
+	// MinExecuteTime 执行时间下限（毫秒）
+	MinExecuteTime *int
+	// Keyword 在描述、URL、用户名、请求体、响应体和错误信息中模糊匹配
+	Keyword string
+	// RequestID 请求 ID，精确匹配
+	RequestID string
+	// TraceID 追踪 ID，精确匹配
+	TraceID string
+}
+
+// LogSortFields 允许排序的字段，键为接口参数，值为列名
+var LogSortFields = map[string]string{
+	"createdAt":   "created_at",
+	"executeTime": "execute_time",
+	"id":          "id",
+}
+
+// LogSort 排序方式，Field 为 LogSortFields 中的列名，相同取值按 id 同向排序
+type LogSort struct {
+	Field string
+	Desc  bool
+}
+
+// DefaultLogSort 按记录时间倒序
+var DefaultLogSort = LogSort{Field: "created_at", Desc: true}
+
+// LogCursor 游标分页的位置：上一页最后一条日志的排序字段值和 id
+type LogCursor struct {
+	Value interface{}
+	ID    int
+}
+
+type LogRepository interface {
+	FindByID(id int) (*models.OperationLog, error)
+	List(filter LogFilter, sort LogSort, offset, limit int) ([]models.OperationLog, int64, error)
+	// Scan 按 sort 顺序返回 after 之后的 limit 条日志，after 为 nil 时从头开始；不统计总数，适合大表翻页和导出
+	Scan(filter LogFilter, sort LogSort, after *LogCursor, limit int) ([]models.OperationLog, error)
+	Count(ctx context.Context) (int64, error)
+	// Create 将日志依次接到哈希链尾部后写入，会填写每条日志的序号和哈希
+	Create(logs []models.OperationLog) error
+	// Ping 检查日志表当前能否访问，用于区分数据库不可用与单条数据被拒绝
+	Ping() error
+	// Chain 按序号顺序返回 afterSeq 之后的日志
+	Chain(afterSeq int64, limit int) ([]models.OperationLog, error)
+	// CountUnchained 统计没有序号的日志
+	CountUnchained() (int64, error)
+	// Head 当前链头；日志全部归档时为最近一次归档的锚点
+	Head() (auditchain.Head, error)
+	// LatestArchive 最近一次归档，没有归档时返回 ErrNotFound
+	LatestArchive() (*models.AuditArchive, error)
+	ListArchives() ([]models.AuditArchive, error)
+	// Archive 删除 archive 覆盖的日志并保存归档锚点
+	Archive(archive *models.AuditArchive) error
+}
+
+// chainMu 串行化本进程内的链尾追加；多个实例共用数据库时由 head 查询上的行锁保证
+var chainMu sync.Mutex
+
+type gormLogRepository struct {
+	db *gorm.DB
+}
+
+func NewLogRepository(db *gorm.DB) LogRepository {
+	return &gormLogRepository{db: db}
+}
+
+func (r *gormLogRepository) FindByID(id int) (*models.OperationLog, error) {
+	var log models.OperationLog
+	if err := r.db.Where("id = ?", id).First(&log).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &log, nil
+}
+
+func (r *gormLogRepository) List(filter LogFilter, sort LogSort, offset, limit int) ([]models.OperationLog, int64, error) {
+	query := applyLogFilter(r.db.Model(&models.OperationLog{}), filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []models.OperationLog
+	if err := query.Offset(offset).Limit(limit).Order(sort.order()).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+func (r *gormLogRepository) Scan(filter LogFilter, sort LogSort, after *LogCursor, limit int) ([]models.OperationLog, error) {
+	query := applyLogFilter(r.db.Model(&models.OperationLog{}), filter)
+
+	if after != nil {
+		op := ">"
+		if sort.Desc {
+			op = "<"
+		}
+		if sort.Field == "id" {
+			query = query.Where("id "+op+" ?", after.ID)
+		} else {
+			query = query.Where(
+				"("+sort.Field+" "+op+" ? OR ("+sort.Field+" = ? AND id "+op+" ?))",
+				after.Value, after.Value, after.ID,
+			)
+		}
+	}
+
+	var logs []models.OperationLog
+	err := query.Limit(limit).Order(sort.order()).Find(&logs).Error
+	return logs, err
+}
+
+func (s LogSort) order() string {
+	direction := " ASC"
+	if s.Desc {
+		direction = " DESC"
+	}
+	if s.Field == "id" {
+		return "id" + direction
+	}
+	return s.Field + direction + ", id" + direction
+}
+
+func applyLogFilter(query *gorm.DB, filter LogFilter) *gorm.DB {
+	if filter.Username != "" {
+		query = query.Where("username LIKE ?", "%"+filter.Username+"%")
+	}
+
+	if filter.Operation != "" {
+		query = query.Where("operation LIKE ?", "%"+filter.Operation+"%")
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if prefix, ok := strings.CutSuffix(filter.Action, "*"); ok {
+		query = query.Where("action LIKE ?", prefix+"%")
+	} else if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+
+	if filter.TraceID != "" {
+		query = query.Where("trace_id = ?", filter.TraceID)
+	}
+
+	if len(filter.Methods) > 0 {
+		query = query.Where("method IN ?", filter.Methods)
+	}
+
+	if filter.IP != nil {
+		query = query.Where("ip_bin BETWEEN ? AND ?", filter.IP.From, filter.IP.To)
+	}
+
+	if filter.StartTime != nil {
+		query = query.Where("created_at >= ?", *filter.StartTime)
+	}
+
+	if filter.EndTime != nil {
+		query = query.Where("created_at < ?", *filter.EndTime)
+	}
+
+	if filter.MinExecuteTime != nil {
+		query = query.Where("execute_time >= ?", *filter.MinExecuteTime)
+	}
+
+	if filter.Keyword != "" {
+		like := "%" + filter.Keyword + "%"
+		query = query.Where(
+			"operation LIKE ? OR url LIKE ? OR username LIKE ? OR params LIKE ? OR result LIKE ? OR error_msg LIKE ?",
+			like, like, like, like, like, like,
+		)
+	}
+
+	return query
+}
+
+func (r *gormLogRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.OperationLog{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormLogRepository) Create(logs []models.OperationLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	chainMu.Lock()
+	defer chainMu.Unlock()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		head, err := chainHead(tx.Clauses(clause.Locking{Strength: "UPDATE"}), tx)
+		if err != nil {
+			return err
+		}
+		auditchain.Link(head, logs)
+		for i := range logs {
+			logs[i].IPBin = IPKey(logs[i].IP)
+		}
+		return tx.CreateInBatches(logs, len(logs)).Error
+	})
+}
+
+// chainHead 读取链尾的日志，没有日志时使用最近一次归档的锚点
+func chainHead(logs, archives *gorm.DB) (auditchain.Head, error) {
+	var last models.OperationLog
+	err := logs.Select("seq", "hash").Where("seq IS NOT NULL").Order("seq DESC").Take(&last).Error
+	if err == nil {
+		return auditchain.Head{Seq: last.Seq, Hash: last.Hash}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return auditchain.Head{}, err
+	}
+
+	var archive models.AuditArchive
+	err = archives.Order("to_seq DESC").Take(&archive).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return auditchain.Start, nil
+	}
+	if err != nil {
+		return auditchain.Head{}, err
+	}
+	return auditchain.Head{Seq: archive.ToSeq, Hash: archive.LastHash}, nil
+}
+
+func (r *gormLogRepository) Ping() error {
+	var ids []int
+	return r.db.Model(&models.OperationLog{}).Limit(1).Pluck("id", &ids).Error
+}
+
+func (r *gormLogRepository) Chain(afterSeq int64, limit int) ([]models.OperationLog, error) {
+	var logs []models.OperationLog
+	err := r.db.Where("seq > ?", afterSeq).Order("seq").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+func (r *gormLogRepository) CountUnchained() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.OperationLog{}).Where("seq IS NULL").Count(&count).Error
+	return count, err
+}
+
+func (r *gormLogRepository) Head() (auditchain.Head, error) {
+	return chainHead(r.db, r.db)
+}
+
+func (r *gormLogRepository) LatestArchive() (*models.AuditArchive, error) {
+	var archive models.AuditArchive
+	if err := r.db.Order("to_seq DESC").First(&archive).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &archive, nil
+}
+
+func (r *gormLogRepository) ListArchives() ([]models.AuditArchive, error) {
+	var archives []models.AuditArchive
+	err := r.db.Order("to_seq DESC").Find(&archives).Error
+	return archives, err
+}
+
+func (r *gormLogRepository) Archive(archive *models.AuditArchive) error {
+	chainMu.Lock()
+	defer chainMu.Unlock()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("seq BETWEEN ? AND ?", archive.FromSeq, archive.ToSeq).Delete(&models.OperationLog{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(archive).Error
+	})
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"superhoneypotguard/models"
+
+	"gorm.io/gorm"
+)
+
+type PermissionRepository interface {
+	FindByID(id int) (*models.Permission, error)
+	ExistsByCode(permissionCode string) (bool, error)
+	ListAll() ([]models.Permission, error)
+	ListActive() ([]models.Permission, error)
+	Count(ctx context.Context) (int64, error)
+	CountChildren(parentID int) (int64, error)
+	Create(permission *models.Permission) error
+	Update(id int, updates map[string]interface{}) error
+	Delete(id int) error
+}
+
+type gormPermissionRepository struct {
+	db *gorm.DB
+}
+
+func NewPermissionRepository(db *gorm.DB) PermissionRepository {
+	return &gormPermissionRepository{db: db}
+}
+
+func (r *gormPermissionRepository) FindByID(id int) (*models.Permission, error) {
+	var permission models.Permission
+	if err := r.db.Where("id = ?", id).First(&permission).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &permission, nil
+}
+
+func (r *gormPermissionRepository) ExistsByCode(permissionCode string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Permission{}).Where("permission_code = ?", permissionCode).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormPermissionRepository) ListAll() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Order("sort_order ASC, id ASC").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *gormPermissionRepository) ListActive() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Where("status = 1").Order("sort_order ASC, id ASC").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *gormPermissionRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Permission{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormPermissionRepository) CountChildren(parentID int) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Permission{}).Where("parent_id = ?", parentID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormPermissionRepository) Create(permission *models.Permission) error {
+	return r.db.Create(permission).Error
+}
+
+func (r *gormPermissionRepository) Update(id int, updates map[string]interface{}) error {
+	return r.db.Model(&models.Permission{ID: id}).Updates(updates).Error
+}
+
+func (r *gormPermissionRepository) Delete(id int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("permission_id = ?", id).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Permission{ID: id}).Error
+	})
+}
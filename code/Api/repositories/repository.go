@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound 记录不存在，屏蔽具体存储实现的错误类型
+var ErrNotFound = errors.New("record not found")
+
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Repositories 汇总所有仓储，便于一次性注入服务层
+type Repositories struct {
+	Users             UserRepository
+	Roles             RoleRepository
+	Permissions       PermissionRepository
+	Logs              LogRepository
+	VerificationCodes VerificationCodeRepository
+	Settings          SettingRepository
+	Retention         RetentionRepository
+	HFishInstances    HFishInstanceRepository
+	HFishAttacks      HFishAttackRepository
+	Secrets           SecretRepository
+}
+
+func NewRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:             NewUserRepository(db),
+		Roles:             NewRoleRepository(db),
+		Permissions:       NewPermissionRepository(db),
+		Logs:              NewLogRepository(db),
+		VerificationCodes: NewVerificationCodeRepository(db),
+		Settings:          NewSettingRepository(db),
+		Retention:         NewRetentionRepository(db),
+		HFishInstances:    NewHFishInstanceRepository(db),
+		HFishAttacks:      NewHFishAttackRepository(db),
+		Secrets:           NewSecretRepository(db),
+	}
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"time"
+
+	"superhoneypotguard/models"
+
+	"gorm.io/gorm"
+)
+
+// RowSink 接收即将删除的行，用于清理前归档
+type RowSink interface {
+	Write(row map[string]interface{}) error
+	// Close 在全部行写入后调用，返回 nil 后才执行删除
+	Close() error
+}
+
+// RetentionRepository 按时间列清理过期数据，并保存保留策略的执行记录
+// 表名和列名来自代码中定义的保留策略，不接受外部输入
+type RetentionRepository interface {
+	// Purge 在一个事务中删除 column 早于 cutoff 的行，sink 不为 nil 时先将这些行逐行写入 sink
+	// sink 返回错误时不删除任何数据
+	Purge(table, column string, cutoff time.Time, sink RowSink) (int64, error)
+	CreateRun(run *models.RetentionRun) error
+	// LatestRuns 每个策略最近一次的执行记录
+	LatestRuns() ([]models.RetentionRun, error)
+	ListRuns(policy string, offset, limit int) ([]models.RetentionRun, int64, error)
+}
+
+type gormRetentionRepository struct {
+	db *gorm.DB
+}
+
+func NewRetentionRepository(db *gorm.DB) RetentionRepository {
+	return &gormRetentionRepository{db: db}
+}
+
+func (r *gormRetentionRepository) Purge(table, column string, cutoff time.Time, sink RowSink) (int64, error) {
+	var deleted int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if sink != nil {
+			if err := copyRows(tx, table, column, cutoff, sink); err != nil {
+				return err
+			}
+		}
+
+		result := tx.Exec("DELETE FROM "+tx.Statement.Quote(table)+" WHERE "+tx.Statement.Quote(column)+" < ?", cutoff)
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+// copyRows 按时间顺序将待删除的行流式写入 sink，不把整张表读入内存
+func copyRows(tx *gorm.DB, table, column string, cutoff time.Time, sink RowSink) error {
+	rows, err := tx.Table(table).Where(tx.Statement.Quote(column)+" < ?", cutoff).Order(tx.Statement.Quote(column)).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err := tx.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := sink.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	return sink.Close()
+}
+
+func (r *gormRetentionRepository) CreateRun(run *models.RetentionRun) error {
+	return r.db.Create(run).Error
+}
+
+func (r *gormRetentionRepository) LatestRuns() ([]models.RetentionRun, error) {
+	var runs []models.RetentionRun
+	latest := r.db.Model(&models.RetentionRun{}).Select("MAX(id)").Group("policy")
+	err := r.db.Where("id IN (?)", latest).Find(&runs).Error
+	return runs, err
+}
+
+func (r *gormRetentionRepository) ListRuns(policy string, offset, limit int) ([]models.RetentionRun, int64, error) {
+	query := r.db.Model(&models.RetentionRun{})
+	if policy != "" {
+		query = query.Where("policy = ?", policy)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var runs []models.RetentionRun
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&runs).Error
+	return runs, total, err
+}
@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"context"
+	"superhoneypotguard/models"
+
+	"gorm.io/gorm"
+)
+
+// RoleFilter 角色列表查询条件
+type RoleFilter struct {
+	RoleName string
+	Status   string
+}
+
+type RoleRepository interface {
+	FindByID(id int) (*models.Role, error)
+	FindByCode(roleCode string) (*models.Role, error)
+	ExistsByNameOrCode(roleName, roleCode string) (bool, error)
+	List(filter RoleFilter, offset, limit int) ([]models.Role, int64, error)
+	ListActive() ([]models.Role, error)
+	Count(ctx context.Context) (int64, error)
+	CountUsers(roleID int) (int64, error)
+	Create(role *models.Role) error
+	Update(id int, updates map[string]interface{}) error
+	Delete(id int) error
+	GetPermissions(roleID int) ([]models.Permission, error)
+	AssignPermissions(roleID int, permissionIDs []int, createdBy *int) error
+	ReplacePermissions(roleID int, permissionIDs []int, createdBy *int) error
+}
+
+type gormRoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &gormRoleRepository{db: db}
+}
+
+func (r *gormRoleRepository) FindByID(id int) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.Where("id = ?", id).First(&role).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &role, nil
+}
+
+func (r *gormRoleRepository) FindByCode(roleCode string) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.Where("role_code = ?", roleCode).First(&role).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &role, nil
+}
+
+func (r *gormRoleRepository) ExistsByNameOrCode(roleName, roleCode string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Role{}).Where("role_name = ? OR role_code = ?", roleName, roleCode).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormRoleRepository) List(filter RoleFilter, offset, limit int) ([]models.Role, int64, error) {
+	query := r.db.Model(&models.Role{})
+
+	if filter.RoleName != "" {
+		query = query.Where("role_name LIKE ?", "%"+filter.RoleName+"%")
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var roles []models.Role
+	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&roles).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return roles, total, nil
+}
+
+func (r *gormRoleRepository) ListActive() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Where("status = 1").Order("id ASC").Find(&roles).Error
+	return roles, err
+}
+
+func (r *gormRoleRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Role{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormRoleRepository) CountUsers(roleID int) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UserRole{}).Where("role_id = ?", roleID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormRoleRepository) Create(role *models.Role) error {
+	return r.db.Create(role).Error
+}
+
+func (r *gormRoleRepository) Update(id int, updates map[string]interface{}) error {
+	return r.db.Model(&models.Role{ID: id}).Updates(updates).Error
+}
+
+func (r *gormRoleRepository) Delete(id int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", id).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Role{ID: id}).Error
+	})
+}
+
+func (r *gormRoleRepository) GetPermissions(roleID int) ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Table("permissions AS p").
+		Select("p.id, p.permission_name, p.permission_code, p.permission_type").
+		Joins("INNER JOIN role_permissions AS rp ON p.id = rp.permission_id").
+		Where("rp.role_id = ?", roleID).
+		Scan(&permissions).Error
+	return permissions, err
+}
+
+func (r *gormRoleRepository) AssignPermissions(roleID int, permissionIDs []int, createdBy *int) error {
+	for _, permissionID := range permissionIDs {
+		if err := r.db.Create(&models.RolePermission{
+			RoleID:       roleID,
+			PermissionID: permissionID,
+			CreatedBy:    createdBy,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *gormRoleRepository) ReplacePermissions(roleID int, permissionIDs []int, createdBy *int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return (&gormRoleRepository{db: tx}).AssignPermissions(roleID, permissionIDs, createdBy)
+	})
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+)
+
+// SecretColumn 一个保存加密值的列，Where 为空时包含表中全部行
+type SecretColumn struct {
+	Table     string
+	KeyColumn string
+	Column    string
+	Where     string
+	Args      []interface{}
+}
+
+// SecretValue 一行中的加密值，Key 为该行主键的字符串形式
+type SecretValue struct {
+	Key   string `gorm:"column:row_key"`
+	Value string `gorm:"column:row_value"`
+}
+
+// SecretRepository 按列批量读取和替换加密值，用于更换主密钥后重新加密
+type SecretRepository interface {
+	List(col SecretColumn) ([]SecretValue, error)
+	// Replace 仅当当前值仍为 old 时写入 value，返回是否写入，避免覆盖并发修改
+	Replace(col SecretColumn, key, old, value string) (bool, error)
+}
+
+type gormSecretRepository struct {
+	db *gorm.DB
+}
+
+func NewSecretRepository(db *gorm.DB) SecretRepository {
+	return &gormSecretRepository{db: db}
+}
+
+func (r *gormSecretRepository) scope(col SecretColumn) *gorm.DB {
+	query := r.db.Table(col.Table)
+	if col.Where != "" {
+		query = query.Where(col.Where, col.Args...)
+	}
+	return query
+}
+
+func (r *gormSecretRepository) List(col SecretColumn) ([]SecretValue, error) {
+	var values []SecretValue
+	err := r.scope(col).
+		Select(col.KeyColumn + " AS row_key, " + col.Column + " AS row_value").
+		Where(col.Column + " IS NOT NULL AND " + col.Column + " <> ''").
+		Order(col.KeyColumn + " ASC").
+		Scan(&values).Error
+	return values, err
+}
+
+func (r *gormSecretRepository) Replace(col SecretColumn, key, old, value string) (bool, error) {
+	result := r.scope(col).
+		Where(col.KeyColumn+" = ? AND "+col.Column+" = ?", key, old).
+		Update(col.Column, value)
+	return result.RowsAffected == 1, result.Error
+}
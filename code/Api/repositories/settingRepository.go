@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"superhoneypotguard/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SettingRepository interface {
+	List() ([]models.SystemSetting, error)
+	Save(settings []models.SystemSetting) error
+	Delete(key string) error
+}
+
+type gormSettingRepository struct {
+	db *gorm.DB
+}
+
+func NewSettingRepository(db *gorm.DB) SettingRepository {
+	return &gormSettingRepository{db: db}
+}
+
+func (r *gormSettingRepository) List() ([]models.SystemSetting, error) {
+	var settings []models.SystemSetting
+	err := r.db.Order("setting_key ASC").Find(&settings).Error
+	return settings, err
+}
+
+// Save 在同一事务中插入或覆盖多个设置
+func (r *gormSettingRepository) Save(settings []models.SystemSetting) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range settings {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "setting_key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"setting_value", "updated_at", "updated_by"}),
+			}).Create(&settings[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *gormSettingRepository) Delete(key string) error {
+	return r.db.Where("setting_key = ?", key).Delete(&models.SystemSetting{}).Error
+}
@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"context"
+	"superhoneypotguard/models"
+
+	"gorm.io/gorm"
+)
+
+// UserFilter 用户列表查询条件
+type UserFilter struct {
+	Username string
+	Status   string
+}
+
+type UserRepository interface {
+	FindByID(id int) (*models.User, error)
+	FindByUsername(username string) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+	ExistsByUsernameOrEmail(username string, email *string) (bool, error)
+	List(filter UserFilter, offset, limit int) ([]models.User, int64, error)
+	Count(ctx context.Context) (int64, error)
+	Create(user *models.User) error
+	Update(id int, updates map[string]interface{}) error
+	Delete(id int) error
+	GetRoles(userID int, activeOnly bool) ([]models.Role, error)
+	GetPermissions(userID int) ([]models.Permission, error)
+	AssignRoles(userID int, roleIDs []int, createdBy *int) error
+	ReplaceRoles(userID int, roleIDs []int, createdBy *int) error
+	HasPermission(ctx context.Context, userID int, permissionCode string) (bool, error)
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+func (r *gormUserRepository) FindByID(id int) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByUsername(username string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) ExistsByUsernameOrEmail(username string, email *string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("username = ? OR email = ?", username, email).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormUserRepository) List(filter UserFilter, offset, limit int) ([]models.User, int64, error) {
+	query := r.db.Model(&models.User{})
+
+	if filter.Username != "" {
+		query = query.Where("username LIKE ?", "%"+filter.Username+"%")
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (r *gormUserRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormUserRepository) Create(user *models.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *gormUserRepository) Update(id int, updates map[string]interface{}) error {
+	return r.db.Model(&models.User{ID: id}).Updates(updates).Error
+}
+
+func (r *gormUserRepository) Delete(id int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{ID: id}).Error
+	})
+}
+
+func (r *gormUserRepository) GetRoles(userID int, activeOnly bool) ([]models.Role, error) {
+	query := r.db.Table("roles AS r").
+		Select("r.id, r.role_name, r.role_code").
+		Joins("INNER JOIN user_roles AS ur ON r.id = ur.role_id").
+		Where("ur.user_id = ?", userID)
+	if activeOnly {
+		query = query.Where("r.status = ?", 1)
+	}
+
+	var roles []models.Role
+	err := query.Scan(&roles).Error
+	return roles, err
+}
+
+func (r *gormUserRepository) GetPermissions(userID int) ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Table("permissions AS p").
+		Distinct("p.id", "p.permission_name", "p.permission_code", "p.permission_type").
+		Joins("INNER JOIN role_permissions AS rp ON p.id = rp.permission_id").
+		Joins("INNER JOIN user_roles AS ur ON rp.role_id = ur.role_id").
+		Where("ur.user_id = ? AND p.status = ?", userID, 1).
+		Scan(&permissions).Error
+	return permissions, err
+}
+
+func (r *gormUserRepository) AssignRoles(userID int, roleIDs []int, createdBy *int) error {
+	for _, roleID := range roleIDs {
+		if err := r.db.Create(&models.UserRole{
+			UserID:    userID,
+			RoleID:    roleID,
+			CreatedBy: createdBy,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *gormUserRepository) ReplaceRoles(userID int, roleIDs []int, createdBy *int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		return (&gormUserRepository{db: tx}).AssignRoles(userID, roleIDs, createdBy)
+	})
+}
+
+func (r *gormUserRepository) HasPermission(ctx context.Context, userID int, permissionCode string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("users AS u").
+		Joins("INNER JOIN user_roles AS ur ON u.id = ur.user_id").
+		Joins("INNER JOIN roles AS r ON ur.role_id = r.id").
+		Joins("INNER JOIN role_permissions AS rp ON r.id = rp.role_id").
+		Joins("INNER JOIN permissions AS p ON rp.permission_id = p.id").
+		Where("u.id = ? AND p.permission_code = ?", userID, permissionCode).
+		Where("p.status = ? AND r.status = ? AND u.status = ?", 1, 1, 1).
+		Count(&count).Error
+	return count > 0, err
+}
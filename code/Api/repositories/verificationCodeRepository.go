@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"time"
+
+	"superhoneypotguard/models"
+
+	"gorm.io/gorm"
+)
+
+type VerificationCodeRepository interface {
+	Create(code *models.VerificationCode) error
+	FindValid(email, code string, now time.Time) (*models.VerificationCode, error)
+	FindLatest(email string) (*models.VerificationCode, error)
+	ListSentBetween(email string, from, to time.Time) ([]models.VerificationCode, error)
+	Delete(email, code string) error
+}
+
+type gormVerificationCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewVerificationCodeRepository(db *gorm.DB) VerificationCodeRepository {
+	return &gormVerificationCodeRepository{db: db}
+}
+
+func (r *gormVerificationCodeRepository) Create(code *models.VerificationCode) error {
+	return r.db.Create(code).Error
+}
+
+func (r *gormVerificationCodeRepository) FindValid(email, code string, now time.Time) (*models.VerificationCode, error) {
+	var verificationCode models.VerificationCode
+	if err := r.db.Where("email = ? AND code = ? AND expires_at > ?", email, code, now).First(&verificationCode).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &verificationCode, nil
+}
+
+func (r *gormVerificationCodeRepository) FindLatest(email string) (*models.VerificationCode, error) {
+	var verificationCode models.VerificationCode
+	if err := r.db.Where("email = ?", email).Order("sent_at DESC").First(&verificationCode).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &verificationCode, nil
+}
+
+func (r *gormVerificationCodeRepository) ListSentBetween(email string, from, to time.Time) ([]models.VerificationCode, error) {
+	var codes []models.VerificationCode
+	err := r.db.Where("email = ? AND sent_at > ? AND sent_at < ?", email, from, to).Order("sent_at ASC").Find(&codes).Error
+	return codes, err
+}
+
+// Delete 按邮箱和验证码删除，验证码表没有主键
+func (r *gormVerificationCodeRepository) Delete(email, code string) error {
+	return r.db.Where("email = ? AND code = ?", email, code).Delete(&models.VerificationCode{}).Error
+}
@@ -1,38 +1,143 @@
 package routes
 
 import (
+	"context"
+	"log/slog"
+
+	"superhoneypotguard/config"
 	"superhoneypotguard/controllers"
+	"superhoneypotguard/database"
+	"superhoneypotguard/geoip"
+	"superhoneypotguard/lifecycle"
+	"superhoneypotguard/logging"
 	"superhoneypotguard/middleware"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func SetupRoutes(r *gin.Engine) {
-	authController := controllers.NewAuthController()
-	userController := controllers.NewUserController()
-	roleController := controllers.NewRoleController()
-	permissionController := controllers.NewPermissionController()
-	dashboardController := controllers.NewDashboardController()
-	logController := controllers.NewLogController()
-	hfishController := controllers.NewHFishController()
-	passwordController := controllers.NewPasswordController()
+// SetupRoutes 组装仓储、服务与控制器并注册路由，服务依赖的定时任务注册到 lc
+func SetupRoutes(r *gin.Engine, db *gorm.DB, lc *lifecycle.Manager) {
+	repos := repositories.NewRepositories(db)
 
-	api := r.Group("/api")
-	{
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{
-				"success":   true,
-				"message":   "服务运行正常",
-				"timestamp": gin.H{},
-			})
+	geoResolver, err := geoip.NewResolver(geoDatabases(config.AppConfig), config.AppConfig.GeoIPLanguage)
+	if err != nil {
+		slog.Warn("加载 IP 地理位置数据库失败，将在文件就绪后重试", "error", err)
+	}
+	geoService := services.NewGeoService(geoResolver)
+	// 所有写入操作日志的路径（请求日志、审计记录、落盘回放）都经过该仓储补充地理位置
+	repos.Logs = geoService.LocateLogs(repos.Logs)
+
+	auditService := services.NewAuditService(repos.Logs)
+	secretService, err := services.NewSecretService(repos.Secrets, config.AppConfig)
+	if err != nil {
+		logging.Fatal("初始化敏感字段加密失败", "error", err)
+	}
+	// 更换主密钥后的首次启动把旧密钥加密的值和早期保存的明文改用当前主密钥加密
+	rotateSecrets(secretService)
+	hfishService := services.NewHFishService(repos.HFishInstances, repos.HFishAttacks, repos.Settings, secretService, geoService, config.AppConfig)
+	// 导入会删除系统设置中早期保存的 HFish 地址，需在加载系统设置之前执行
+	if err := hfishService.ImportLegacy(); err != nil {
+		slog.Warn("导入 HFish 实例失败", "error", err)
+	}
+	if err := hfishService.Load(); err != nil {
+		slog.Warn("加载 HFish 实例失败", "error", err)
+	}
+	settingService := services.NewSettingService(repos.Settings, auditService, secretService, config.AppConfig)
+	if err := settingService.Load(); err != nil {
+		slog.Warn("加载系统设置失败，使用启动配置", "error", err)
+	}
+	current := settingService.Current()
+
+	mailer := services.NewSMTPMailer(current.SMTP)
+
+	// 系统设置变更后立即应用到限流器和邮件发送器
+	settingService.Subscribe(func(s services.RuntimeSettings) {
+		middleware.ConfigureRateLimiter(s.RateLimitWindow, s.RateLimitMax)
+		mailer.Configure(s.SMTP)
+	})
+
+	emailService := services.NewEmailService(repos.VerificationCodes, repos.Users, mailer)
+	authService := services.NewAuthService(repos.Users, repos.Roles, emailService, geoService)
+	userService := services.NewUserService(repos.Users)
+	roleService := services.NewRoleService(repos.Roles)
+	permissionService := services.NewPermissionService(repos.Permissions)
+	dashboardService := services.NewDashboardService(repos.Users, repos.Roles, repos.Permissions, repos.Logs)
+	logService := services.NewLogService(repos.Logs)
+	auditChainService, err := services.NewAuditChainService(repos.Logs, auditService, config.AppConfig)
+	if err != nil {
+		logging.Fatal("初始化操作日志校验失败", "error", err)
+	}
+	retentionService := services.NewRetentionService(repos.Retention, auditChainService, config.AppConfig)
+
+	middleware.InitPermissionChecker(userService)
+	middleware.InitLogStore(repos.Logs)
+
+	if interval := config.AppConfig.GeoIPReloadInterval; geoResolver.Enabled() && interval > 0 {
+		lc.Add(lifecycle.Component{
+			Name: "IP 地理位置数据库重载",
+			Run:  lifecycle.Every(interval, geoService.Reload),
+		})
+	}
+	if interval := retentionService.Interval(); interval > 0 {
+		lc.Add(lifecycle.Component{
+			Name: "数据保留策略",
+			Run:  lifecycle.Every(interval, retentionService.RunScheduled),
+		})
+	}
+	if interval := config.AppConfig.HFishSyncInterval; interval > 0 {
+		lc.Add(lifecycle.Component{
+			Name: "HFish 攻击数据同步",
+			Run:  lifecycle.Every(interval, hfishService.Sync),
+		})
+	}
+	if interval := config.AppConfig.AuditCheckpointInterval; interval > 0 {
+		lc.Add(lifecycle.Component{
+			Name: "操作日志检查点",
+			Run:  lifecycle.Every(interval, auditChainService.WriteCheckpoint),
 		})
+	}
+
+	authController := controllers.NewAuthController(authService)
+	userController := controllers.NewUserController(userService)
+	roleController := controllers.NewRoleController(roleService)
+	permissionController := controllers.NewPermissionController(permissionService)
+	dashboardController := controllers.NewDashboardController(dashboardService)
+	logController := controllers.NewLogController(logService, auditChainService)
+	hfishController := controllers.NewHFishController(hfishService)
+	hfishInstanceController := controllers.NewHFishInstanceController(hfishService)
+	geoController := controllers.NewGeoController(geoService)
+	passwordController := controllers.NewPasswordController(authService)
+	settingController := controllers.NewSettingController(settingService)
+	retentionController := controllers.NewRetentionController(retentionService)
+	rateLimitController := controllers.NewRateLimitController()
+	healthService := services.NewHealthService(config.AppConfig.HealthCheckTimeout, healthChecks(db, mailer, hfishService, lc)...)
+	healthController := controllers.NewHealthController(healthService)
+
+	// 指标与健康检查在追踪、访问日志和操作日志中间件之前注册，监控系统和探针的频繁请求不会写满日志和追踪；
+	// 就绪检查会访问外部依赖，单独套用全局限流
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.MetricsMiddleware())
+	r.GET("/metrics", middleware.MetricsHandler(config.AppConfig.MetricsToken))
+	r.GET("/api/health", healthController.Live)
+	r.GET("/api/health/live", healthController.Live)
+	r.GET("/api/health/ready", middleware.RateLimitMiddleware(), healthController.Ready)
 
+	r.Use(middleware.TracingMiddleware())
+	r.Use(middleware.AccessLogMiddleware())
+	r.Use(middleware.RateLimitMiddleware())
+	r.Use(middleware.LogMiddleware())
+
+	api := r.Group("/api")
+	{
 		auth := api.Group("/auth")
 		{
-			auth.POST("/send-verification-code", authController.SendVerificationCode)
-			auth.POST("/send-reset-code", passwordController.SendResetPasswordCode)
-			auth.POST("/register", authController.Register)
-			auth.POST("/login", middleware.AuthRateLimitMiddleware(), authController.Login)
+			auth.POST("/send-verification-code", middleware.RateLimitPolicy("verification_code"), authController.SendVerificationCode)
+			auth.POST("/send-reset-code", middleware.RateLimitPolicy("verification_code"), passwordController.SendResetPasswordCode)
+			auth.POST("/register", middleware.RateLimitPolicy("auth"), authController.Register)
+			auth.POST("/login", middleware.RateLimitPolicy("auth"), authController.Login)
 			auth.POST("/logout", middleware.AuthMiddleware(), authController.Logout)
 			auth.GET("/current", middleware.AuthMiddleware(), authController.GetCurrentUser)
 		}
@@ -81,25 +186,48 @@ func SetupRoutes(r *gin.Engine) {
 		log.Use(middleware.AuthMiddleware())
 		{
 			log.GET("/list", middleware.PermissionMiddleware("log:manage"), logController.GetList)
+			log.GET("/export", middleware.PermissionMiddleware("log:manage"), middleware.RateLimitPolicy("export"), logController.Export)
+			log.GET("/pipeline", middleware.PermissionMiddleware("log:manage"), logController.GetPipelineStats)
+			log.GET("/verify", middleware.PermissionMiddleware("log:manage"), logController.Verify)
+			log.GET("/archives", middleware.PermissionMiddleware("log:manage"), logController.GetArchives)
+			log.POST("/archive", middleware.PermissionMiddleware("log:archive"), logController.Archive)
 			log.GET("/:id", middleware.PermissionMiddleware("log:manage"), logController.GetById)
-			log.DELETE("/:id", middleware.PermissionMiddleware("log:manage"), logController.Delete)
-			log.DELETE("/clear", middleware.PermissionMiddleware("log:manage"), logController.Clear)
 		}
 
 		hfish := api.Group("/hfish")
-		hfish.Use(middleware.AuthMiddleware())
+		hfish.Use(middleware.AuthMiddleware(), middleware.RateLimitPolicy("hfish"))
 		{
 			hfish.GET("/attack/ips", middleware.PermissionMiddleware("hfish:view"), hfishController.GetAttackIPs)
 			hfish.GET("/attack/details", middleware.PermissionMiddleware("hfish:view"), hfishController.GetAttackDetails)
 			hfish.GET("/account/info", middleware.PermissionMiddleware("hfish:view"), hfishController.GetAccountInfo)
 			hfish.GET("/sys/info", middleware.PermissionMiddleware("hfish:view"), hfishController.GetSysInfo)
 			hfish.POST("/block/ip", middleware.PermissionMiddleware("hfish:block"), hfishController.BlockIP)
+			hfish.GET("/instances", middleware.PermissionMiddleware("hfish:view"), hfishInstanceController.GetList)
+			hfish.GET("/instances/:id", middleware.PermissionMiddleware("hfish:manage"), hfishInstanceController.GetById)
+			hfish.POST("/instances", middleware.PermissionMiddleware("hfish:manage"), hfishInstanceController.Create)
+			hfish.PUT("/instances/:id", middleware.PermissionMiddleware("hfish:manage"), hfishInstanceController.Update)
+			hfish.DELETE("/instances/:id", middleware.PermissionMiddleware("hfish:manage"), hfishInstanceController.Delete)
+		}
+
+		api.GET("/geo/:ip", middleware.AuthMiddleware(), middleware.PermissionMiddleware("hfish:view"), geoController.Lookup)
+
+		system := api.Group("/system")
+		system.Use(middleware.AuthMiddleware())
+		{
+			system.GET("/settings", middleware.PermissionMiddleware("system:settings"), settingController.GetList)
+			system.PUT("/settings", middleware.PermissionMiddleware("system:settings"), settingController.Update)
+			system.DELETE("/settings/:key", middleware.PermissionMiddleware("system:settings"), settingController.Reset)
+			system.GET("/retention", middleware.PermissionMiddleware("system:settings"), retentionController.GetStatus)
+			system.GET("/retention/runs", middleware.PermissionMiddleware("system:settings"), retentionController.GetRuns)
+			system.POST("/retention/run", middleware.PermissionMiddleware("system:settings"), retentionController.Run)
+			system.GET("/ratelimit", middleware.PermissionMiddleware("system:settings"), rateLimitController.GetStatus)
+			system.GET("/health", middleware.PermissionMiddleware("system:settings"), healthController.Details)
 		}
 
 		password := api.Group("/password")
 		password.Use(middleware.AuthMiddleware())
 		{
-			password.POST("/send-reset-code", passwordController.SendResetPasswordCode)
+			password.POST("/send-reset-code", middleware.RateLimitPolicy("verification_code"), passwordController.SendResetPasswordCode)
 			password.POST("/reset", passwordController.ResetPassword)
 		}
 
@@ -111,3 +239,55 @@ func SetupRoutes(r *gin.Engine) {
 		})
 	}
 }
+
+// geoDatabases 按查询顺序排列的地理位置数据库，ASN 库只用于补充城市库中缺少的字段
+func geoDatabases(cfg *config.Config) []string {
+	var paths []string
+	for _, path := range []string{cfg.GeoIPDatabase, cfg.GeoIPASNDatabase} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// rotateSecrets 重新加密敏感字段，失败只记录日志，相应的值仍可用旧密钥解密
+func rotateSecrets(secrets *services.SecretService) {
+	results, err := secrets.Rotate()
+	if err != nil {
+		slog.Error("重新加密敏感字段失败", "error", err)
+		return
+	}
+	for _, r := range results {
+		if r.Reencrypted > 0 || r.Encrypted > 0 {
+			slog.Info("已使用当前主密钥重新加密敏感字段", "field", r.Field, "reencrypted", r.Reencrypted, "encrypted", r.Encrypted, "key_id", secrets.KeyID())
+		}
+		for _, f := range r.Failed {
+			slog.Error("重新加密敏感字段失败", "field", r.Field, "row", f)
+		}
+	}
+}
+
+// healthChecks 就绪检查包含的组件，HEALTH_CRITICAL 中列出的组件异常时服务视为未就绪
+func healthChecks(db *gorm.DB, mailer *services.SMTPMailer, hfish *services.HFishService, lc *lifecycle.Manager) []services.HealthCheck {
+	checks := []services.HealthCheck{
+		{Name: "database", Check: func(ctx context.Context) error { return database.Ping(ctx, db) }},
+	}
+	if config.AppConfig.RateLimitBackend == "redis" {
+		checks = append(checks, services.HealthCheck{Name: "redis", Check: middleware.PingRateLimiter})
+	}
+	checks = append(checks,
+		services.HealthCheck{Name: "hfish", Check: hfish.Ping, Warnings: hfish.Warnings},
+		services.HealthCheck{Name: "smtp", Check: mailer.Ping},
+		services.HealthCheck{Name: "workers", Check: services.WorkersCheck(lc)},
+	)
+
+	critical := make(map[string]bool)
+	for _, name := range config.SplitList(config.AppConfig.HealthCritical) {
+		critical[name] = true
+	}
+	for i := range checks {
+		checks[i].Critical = critical[checks[i].Name]
+	}
+	return checks
+}
@@ -0,0 +1,212 @@
+// Package secrets 数据库中敏感字段的加密存储
+//
+// 使用 AES-256-GCM 加密，密文格式为 "v1:<密钥ID>:<base64(nonce|密文)>"，
+// 密钥 ID 为密钥 SHA-256 的前 8 个十六进制字符，用于识别密文由哪个密钥加密。
+// 加密时传入的 label（如 hfish_instances.api_key）作为附加数据参与认证，密文不能挪用到其他字段。
+//
+// 更换主密钥时把原密钥加入旧密钥列表，旧密钥只用于解密；用当前主密钥重新加密全部密文后即可删除旧密钥。
+// 主密钥必须单独配置，与 JWT 密钥等其他配置无关。
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize 主密钥长度（AES-256）
+const KeySize = 32
+
+// version 当前的密文格式版本
+const version = "v1"
+
+// ErrUnknownKey 密文由当前未配置的密钥加密
+var ErrUnknownKey = errors.New("密文由未知的密钥加密")
+
+// ErrNoKey 未配置主密钥
+var ErrNoKey = errors.New("未配置主密钥")
+
+// Cipher 使用主密钥加密敏感字段，使用主密钥或旧密钥解密
+type Cipher struct {
+	id   string
+	aead cipher.AEAD
+	// keys 可用于解密的全部密钥，按密钥 ID 索引
+	keys map[string]cipher.AEAD
+}
+
+// ParseKey 解析 base64 编码的 32 字节密钥，未配置时返回 ErrNoKey
+func ParseKey(key string) ([]byte, error) {
+	if strings.TrimSpace(key) == "" {
+		return nil, ErrNoKey
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(raw) != KeySize {
+		return nil, fmt.Errorf("主密钥必须是 base64 编码的 %d 字节密钥", KeySize)
+	}
+	return raw, nil
+}
+
+// ParseOldKeys 解析旧密钥列表，每项为 base64 编码的 32 字节密钥
+func ParseOldKeys(keys []string) ([][]byte, error) {
+	out := make([][]byte, 0, len(keys))
+	for i, key := range keys {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		raw, err := ParseKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个旧密钥: %w", i+1, err)
+		}
+		out = append(out, raw)
+	}
+	return out, nil
+}
+
+// New 按配置创建 Cipher，key 为主密钥，oldKeys 为只用于解密的旧密钥
+func New(key string, oldKeys []string) (*Cipher, error) {
+	raw, err := ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	olds, err := ParseOldKeys(oldKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cipher{keys: make(map[string]cipher.AEAD)}
+	if c.id, c.aead, err = newAEAD(raw); err != nil {
+		return nil, err
+	}
+	c.keys[c.id] = c.aead
+	for _, old := range olds {
+		id, aead, err := newAEAD(old)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := c.keys[id]; !ok {
+			c.keys[id] = aead
+		}
+	}
+	return c, nil
+}
+
+func newAEAD(raw []byte) (string, cipher.AEAD, error) {
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", nil, err
+	}
+	return idOf(raw), aead, nil
+}
+
+// idOf 返回密钥的标识，即 SHA-256 的前 4 字节
+func idOf(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:4])
+}
+
+// GenerateKey 生成新的主密钥，返回 base64 编码
+func GenerateKey() (string, error) {
+	raw := make([]byte, KeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// KeyID 主密钥的标识，写入密文中
+func (c *Cipher) KeyID() string {
+	return c.id
+}
+
+// IsEncrypted 值是否为 Encrypt 生成的密文，用于识别加密存储之前写入的明文
+func IsEncrypted(value string) bool {
+	_, _, err := parse(value)
+	return err == nil
+}
+
+// KeyIDOfValue 返回密文的密钥 ID，不是密文时返回错误
+func KeyIDOfValue(value string) (string, error) {
+	keyID, _, err := parse(value)
+	return keyID, err
+}
+
+// Reencrypt 把旧密钥加密的密文改用主密钥加密，返回新密文以及是否发生了变化；
+// 已由主密钥加密的密文和空字符串原样返回
+func (c *Cipher) Reencrypt(label, value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	keyID, _, err := parse(value)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == c.id {
+		return value, false, nil
+	}
+	plain, err := c.Decrypt(label, value)
+	if err != nil {
+		return "", false, err
+	}
+	sealed, err := c.Encrypt(label, plain)
+	return sealed, err == nil, err
+}
+
+// Encrypt 加密 plaintext，空字符串原样返回
+func (c *Cipher) Encrypt(label, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(label))
+	return version + ":" + c.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 使用主密钥或旧密钥解密 Encrypt 生成的密文，空字符串原样返回；label 必须与加密时一致
+func (c *Cipher) Decrypt(label, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	keyID, data, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	aead, ok := c.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrUnknownKey, keyID)
+	}
+	n := aead.NonceSize()
+	if len(data) < n {
+		return "", errors.New("密文长度无效")
+	}
+	plain, err := aead.Open(nil, data[:n], data[n:], []byte(label))
+	if err != nil {
+		return "", errors.New("密文校验失败")
+	}
+	return string(plain), nil
+}
+
+// parse 拆分密文中的密钥 ID 和数据
+func parse(value string) (string, []byte, error) {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) != 3 || parts[0] != version {
+		return "", nil, errors.New("无效的密文格式")
+	}
+	data, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, errors.New("无效的密文格式")
+	}
+	return parts[1], data, nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+// Actor 发起操作的用户，用于审计记录
+type Actor struct {
+	UserID   int
+	Username string
+	IP       string
+	// RequestID 触发事件的请求 ID，定时任务等非请求来源为空
+	RequestID string
+	// TraceID 触发事件的请求的追踪 ID
+	TraceID string
+	// Method、URL 触发事件的请求方法和路由，定时任务和命令行执行时为空
+	Method string
+	URL    string
+}
+
+// AuditService 写入不依附于请求日志的审计事件，如一次请求中逐项产生的设置变更
+// 与单个请求一一对应的事件由控制器通过 middleware.RecordAudit 写入该请求的日志
+type AuditService struct {
+	logs repositories.LogRepository
+}
+
+func NewAuditService(logs repositories.LogRepository) *AuditService {
+	return &AuditService{logs: logs}
+}
+
+// Record 以操作日志的形式写入一条审计记录，detail 会序列化为 JSON 存入 params
+// 操作人不是登录用户（UserID 为 0）时不记录用户 ID，method、url 和 IP 为空时不记录
+// 审计写入失败不影响业务结果，只记录错误
+func (s *AuditService) Record(actor Actor, event models.AuditEvent, method, url string, detail interface{}) {
+	entry := models.OperationLog{
+		Username:  &actor.Username,
+		Operation: event.Description,
+		Status:    1,
+	}
+	if entry.Operation == "" {
+		entry.Operation = event.Action
+	}
+	if actor.UserID != 0 {
+		entry.UserID = &actor.UserID
+	}
+	if method != "" {
+		entry.Method = &method
+	}
+	if url != "" {
+		entry.URL = &url
+	}
+	if actor.IP != "" {
+		entry.IP = &actor.IP
+	}
+	if actor.RequestID != "" {
+		entry.RequestID = &actor.RequestID
+	}
+	if actor.TraceID != "" {
+		entry.TraceID = &actor.TraceID
+	}
+	if event.Action != "" {
+		entry.Action = &event.Action
+	}
+	if event.TargetType != "" {
+		entry.TargetType = &event.TargetType
+	}
+	if event.TargetID != "" {
+		entry.TargetID = &event.TargetID
+	}
+	if len(event.Changes) > 0 {
+		entry.Changes = marshalAudit(event.Changes)
+	}
+	if detail != nil {
+		entry.Params = marshalAudit(detail)
+	}
+
+	if err := s.logs.Create([]models.OperationLog{entry}); err != nil {
+		slog.Error("写入审计记录失败", "action", event.Action, "error", err)
+	}
+}
+
+func marshalAudit(value interface{}) *string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		slog.Error("序列化审计内容失败", "error", err)
+		return nil
+	}
+	text := string(data)
+	return &text
+}
+
+// AuditDiff 比较两个对象序列化为 JSON 后的顶层字段，返回取值不同的字段
+// before 为 nil 表示新建，after 为 nil 表示删除；ignore 中的字段不参与比较
+func AuditDiff(before, after interface{}, ignore ...string) map[string]models.AuditChange {
+	beforeFields, afterFields := auditFields(before), auditFields(after)
+
+	skip := make(map[string]bool, len(ignore))
+	for _, field := range ignore {
+		skip[field] = true
+	}
+
+	changes := make(map[string]models.AuditChange)
+	for field, value := range afterFields {
+		if skip[field] {
+			continue
+		}
+		if old, ok := beforeFields[field]; !ok || !reflect.DeepEqual(old, value) {
+			changes[field] = models.AuditChange{Before: old, After: value}
+		}
+	}
+	for field, old := range beforeFields {
+		if _, ok := afterFields[field]; !ok && !skip[field] {
+			changes[field] = models.AuditChange{Before: old}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+// auditFields 将对象转换为字段名到 JSON 值的映射，nil 返回空映射
+func auditFields(value interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return fields
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
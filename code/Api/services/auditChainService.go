@@ -0,0 +1,311 @@
+package services
+
+import (
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"superhoneypotguard/auditchain"
+	"superhoneypotguard/config"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+// chainBatchSize 校验与归档时每次读取的日志条数
+const chainBatchSize = 1000
+
+// AuditChainService 校验操作日志哈希链、归档过期日志并定期写入签名检查点
+type AuditChainService struct {
+	logs  repositories.LogRepository
+	audit *AuditService
+
+	archiveDir     string
+	minRetention   time.Duration
+	checkpointFile string
+	key            ed25519.PrivateKey
+	// trusted 校验检查点时接受的公钥：当前签名密钥的公钥和更换密钥之前使用的公钥
+	trusted []ed25519.PublicKey
+
+	// mu 串行化归档与检查点写入
+	mu sync.Mutex
+}
+
+func NewAuditChainService(logs repositories.LogRepository, audit *AuditService, cfg *config.Config) (*AuditChainService, error) {
+	key, err := auditchain.SigningKey(cfg.AuditSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := auditchain.ParsePublicKeys(config.SplitList(cfg.AuditTrustedKeys))
+	if err != nil {
+		return nil, err
+	}
+	return &AuditChainService{
+		logs:           logs,
+		audit:          audit,
+		archiveDir:     cfg.AuditArchiveDir,
+		minRetention:   cfg.AuditMinRetention,
+		checkpointFile: cfg.AuditCheckpointFile,
+		key:            key,
+		trusted:        append([]ed25519.PublicKey{key.Public().(ed25519.PublicKey)}, previous...),
+	}, nil
+}
+
+// start 链上现存第一条日志之前的位置：最近一次归档的锚点，没有归档时为链的起点
+func (s *AuditChainService) start() (auditchain.Head, error) {
+	archive, err := s.logs.LatestArchive()
+	if errors.Is(err, repositories.ErrNotFound) {
+		return auditchain.Start, nil
+	}
+	if err != nil {
+		return auditchain.Head{}, err
+	}
+	return auditchain.Head{Seq: archive.ToSeq, Hash: archive.LastHash}, nil
+}
+
+// Verify 从最近一次归档的锚点开始逐条校验日志，并与检查点文件中的签名链头比对
+func (s *AuditChainService) Verify() (*auditchain.Report, error) {
+	start, err := s.start()
+	if err != nil {
+		return nil, internal("校验日志链失败", err)
+	}
+	report := &auditchain.Report{ArchivedSeq: start.Seq, LastSeq: start.Seq, HeadHash: start.Hash}
+
+	// 按序号索引检查点，遍历到对应日志时比对哈希
+	checkpoints, err := auditchain.ReadCheckpoints(s.checkpointFile)
+	if err != nil {
+		report.Add(auditchain.IssueCheckpointInvalid, 0, 0, err.Error())
+	}
+	report.Checkpoints = len(checkpoints)
+	pending := make(map[int64][]auditchain.Checkpoint)
+	for _, cp := range checkpoints {
+		if err := cp.Verify(s.trusted...); err != nil {
+			report.Add(auditchain.IssueCheckpointInvalid, cp.Seq, 0, fmt.Sprintf("%s 的检查点%v", cp.CreatedAt.Local().Format(time.DateTime), err))
+			continue
+		}
+		switch {
+		case cp.Seq < start.Seq:
+			// 已归档的部分由归档文件保存，不再比对
+		case cp.Seq == start.Seq:
+			s.compareCheckpoint(report, cp, start.Hash, 0)
+		default:
+			pending[cp.Seq] = append(pending[cp.Seq], cp)
+		}
+	}
+
+	unchained, err := s.logs.CountUnchained()
+	if err != nil {
+		return nil, internal("校验日志链失败", err)
+	}
+	if unchained > 0 {
+		report.Add(auditchain.IssueUnchained, 0, 0, fmt.Sprintf("%d 条日志没有链序号，可能被直接写入数据库", unchained))
+	}
+
+	prev := start
+	for {
+		batch, err := s.logs.Chain(prev.Seq, chainBatchSize)
+		if err != nil {
+			return nil, internal("校验日志链失败", err)
+		}
+		for i := range batch {
+			entry := &batch[i]
+			if report.Checked == 0 {
+				report.FirstSeq = entry.Seq
+			}
+			report.Checked++
+
+			if kind, detail := auditchain.Check(prev, entry); kind != "" {
+				report.Add(kind, entry.Seq, entry.ID, detail)
+			}
+			for _, cp := range pending[entry.Seq] {
+				s.compareCheckpoint(report, cp, entry.Hash, entry.ID)
+			}
+			delete(pending, entry.Seq)
+
+			// 后续日志与数据库中保存的哈希比对，一处问题不会导致之后全部报错
+			prev = auditchain.Head{Seq: entry.Seq, Hash: entry.Hash}
+		}
+		if len(batch) < chainBatchSize {
+			break
+		}
+	}
+	report.LastSeq, report.HeadHash = prev.Seq, prev.Hash
+
+	for seq, cps := range pending {
+		for _, cp := range cps {
+			if seq > report.LastSeq {
+				report.Add(auditchain.IssueTruncated, seq, 0, fmt.Sprintf("%s 的检查点记录的序号 %d 已不存在，链尾日志可能被删除", cp.CreatedAt.Local().Format(time.DateTime), seq))
+			} else {
+				report.Add(auditchain.IssueCheckpointMismatch, seq, 0, fmt.Sprintf("%s 的检查点记录的序号 %d 不在日志中", cp.CreatedAt.Local().Format(time.DateTime), seq))
+			}
+		}
+	}
+
+	report.Finish()
+	return report, nil
+}
+
+func (s *AuditChainService) compareCheckpoint(report *auditchain.Report, cp auditchain.Checkpoint, hash string, id int) {
+	if cp.Hash != hash {
+		report.Add(auditchain.IssueCheckpointMismatch, cp.Seq, id, fmt.Sprintf("与 %s 的签名检查点不一致，日志链可能被重算", cp.CreatedAt.Local().Format(time.DateTime)))
+		return
+	}
+	report.CheckpointsVerified++
+}
+
+// Checkpoint 对当前链头签名并追加到检查点文件，链头自上次检查点以来未变化时返回 nil
+func (s *AuditChainService) Checkpoint() (*auditchain.Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	head, err := s.logs.Head()
+	if err != nil {
+		return nil, internal("读取日志链头失败", err)
+	}
+	if head.Seq == 0 {
+		return nil, nil
+	}
+
+	checkpoints, err := auditchain.ReadCheckpoints(s.checkpointFile)
+	if err == nil && len(checkpoints) > 0 && checkpoints[len(checkpoints)-1].Seq == head.Seq {
+		return nil, nil
+	}
+
+	cp := auditchain.Sign(s.key, head, time.Now())
+	if err := auditchain.AppendCheckpoint(s.checkpointFile, cp); err != nil {
+		return nil, internal("写入检查点失败", err)
+	}
+	return &cp, nil
+}
+
+// WriteCheckpoint 供定时任务调用，失败只记录日志
+func (s *AuditChainService) WriteCheckpoint() {
+	if _, err := s.Checkpoint(); err != nil {
+		slog.Error("写入操作日志检查点失败", "error", err)
+	}
+}
+
+// ListArchives 历次归档记录，按序号倒序
+func (s *AuditChainService) ListArchives() ([]models.AuditArchive, error) {
+	archives, err := s.logs.ListArchives()
+	if err != nil {
+		return nil, internal("查询归档记录失败", err)
+	}
+	return archives, nil
+}
+
+// Archive 将 before 之前的日志写入归档文件后从数据库删除，并记录归档操作
+// source 为触发方式（RetentionSource*），与 actor 中的请求方法和路由一同写入审计记录
+//
+// 只归档链首连续的一段：遇到第一条不早于 before 的日志即停止，保证剩余日志仍是一条完整的链。
+// before 不能晚于最短保留期限；待归档的日志必须先通过校验，避免把篡改过的链固化到锚点中。
+// 没有可归档的日志时返回 nil。
+func (s *AuditChainService) Archive(before time.Time, source string, actor Actor) (*models.AuditArchive, error) {
+	if s.archiveDir == "" {
+		return nil, invalid("未配置日志归档目录")
+	}
+	if limit := time.Now().Add(-s.minRetention); before.After(limit) {
+		return nil, invalid(fmt.Sprintf("操作日志至少保留 %s，只能归档 %s 之前的日志", s.minRetention, limit.Format(time.DateTime)))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, err := s.start()
+	if err != nil {
+		return nil, internal("归档日志失败", err)
+	}
+
+	if err := os.MkdirAll(s.archiveDir, 0o755); err != nil {
+		return nil, internal("创建归档目录失败", err)
+	}
+	tmp, err := os.CreateTemp(s.archiveDir, ".archive-*.tmp")
+	if err != nil {
+		return nil, internal("创建归档文件失败", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	digest := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(tmp, digest))
+	enc := json.NewEncoder(gz)
+
+	archive := &models.AuditArchive{Before: before}
+	if actor.UserID != 0 {
+		archive.CreatedBy = &actor.UserID
+	}
+	prev := start
+scan:
+	for {
+		batch, err := s.logs.Chain(prev.Seq, chainBatchSize)
+		if err != nil {
+			return nil, internal("归档日志失败", err)
+		}
+		for i := range batch {
+			entry := &batch[i]
+			if !entry.CreatedAt.Before(before) {
+				break scan
+			}
+			if kind, detail := auditchain.Check(prev, entry); kind != "" {
+				return nil, invalid(fmt.Sprintf("序号 %d 的日志未通过校验（%s），请先排查后再归档", entry.Seq, detail))
+			}
+			if err := enc.Encode(entry); err != nil {
+				return nil, internal("写入归档文件失败", err)
+			}
+			if archive.Count == 0 {
+				archive.FromSeq = entry.Seq
+			}
+			archive.Count++
+			prev = auditchain.Head{Seq: entry.Seq, Hash: entry.Hash}
+		}
+		if len(batch) < chainBatchSize {
+			break
+		}
+	}
+	if archive.Count == 0 {
+		return nil, nil
+	}
+	archive.ToSeq, archive.LastHash = prev.Seq, prev.Hash
+
+	if err := gz.Close(); err != nil {
+		return nil, internal("写入归档文件失败", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, internal("写入归档文件失败", err)
+	}
+	archive.File = fmt.Sprintf("operation-logs-%d-%d.jsonl.gz", archive.FromSeq, archive.ToSeq)
+	archive.FileSHA256 = hex.EncodeToString(digest.Sum(nil))
+	path := filepath.Join(s.archiveDir, archive.File)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, internal("写入归档文件失败", err)
+	}
+
+	if err := s.logs.Archive(archive); err != nil {
+		os.Remove(path)
+		return nil, internal("归档日志失败", err)
+	}
+
+	s.audit.Record(actor, models.AuditEvent{
+		Action:      "log.archive",
+		TargetType:  "log_archive",
+		TargetID:    strconv.Itoa(archive.ID),
+		Description: fmt.Sprintf("归档操作日志 #%d-#%d（%d 条）", archive.FromSeq, archive.ToSeq, archive.Count),
+	}, actor.Method, actor.URL, map[string]interface{}{
+		"source":     source,
+		"before":     before,
+		"file":       archive.File,
+		"fileSha256": archive.FileSHA256,
+		"lastHash":   archive.LastHash,
+	})
+	return archive, nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/utils"
+)
+
+// defaultRoleCode 自助注册用户默认分配的角色
+const defaultRoleCode = "user"
+
+// RegisterInput 注册参数
+type RegisterInput struct {
+	Username string
+	Password string
+	Email    string
+	Code     string
+	Phone    *string
+	RealName *string
+}
+
+// LoginResult 登录成功后返回的令牌与用户信息
+type LoginResult struct {
+	Token           string
+	User            *models.User
+	Roles           []models.Role
+	PermissionCodes []string
+}
+
+// CurrentUser 当前登录用户的资料、角色与权限
+type CurrentUser struct {
+	User        *models.User
+	Roles       []models.Role
+	Permissions []models.Permission
+}
+
+type AuthService struct {
+	users repositories.UserRepository
+	roles repositories.RoleRepository
+	email *EmailService
+	geo   *GeoService
+}
+
+func NewAuthService(users repositories.UserRepository, roles repositories.RoleRepository, email *EmailService, geo *GeoService) *AuthService {
+	return &AuthService{users: users, roles: roles, email: email, geo: geo}
+}
+
+func (s *AuthService) SendVerificationCode(ctx context.Context, email string) error {
+	if err := s.email.SendVerificationCode(ctx, email); err != nil {
+		return &Error{Kind: KindInternal, Message: "发送验证码失败: " + err.Error(), Err: err}
+	}
+	return nil
+}
+
+func (s *AuthService) SendResetPasswordCode(ctx context.Context, email string) error {
+	if err := s.email.SendResetPasswordCode(ctx, email); err != nil {
+		return &Error{Kind: KindInternal, Message: "发送验证码失败: " + err.Error(), Err: err}
+	}
+	return nil
+}
+
+func (s *AuthService) Register(ctx context.Context, in RegisterInput) (*models.User, error) {
+	if in.Email == "" {
+		return nil, invalid("邮箱不能为空")
+	}
+
+	if !s.email.VerifyCode(ctx, in.Email, in.Code) {
+		return nil, invalid("验证码错误或已过期")
+	}
+
+	exists, err := s.users.ExistsByUsernameOrEmail(in.Username, &in.Email)
+	if err != nil {
+		return nil, internal("注册失败", err)
+	}
+	if exists {
+		return nil, invalid("用户名或邮箱已存在")
+	}
+
+	hashedPassword, err := utils.HashPassword(in.Password)
+	if err != nil {
+		return nil, internal("密码加密失败", err)
+	}
+
+	user := &models.User{
+		Username: in.Username,
+		Password: hashedPassword,
+		Email:    &in.Email,
+		Phone:    in.Phone,
+		RealName: in.RealName,
+		Status:   1,
+	}
+
+	if err := s.users.Create(user); err != nil {
+		return nil, internal("注册失败", err)
+	}
+
+	defaultRole, err := s.roles.FindByCode(defaultRoleCode)
+	if err == nil {
+		if err := s.users.AssignRoles(user.ID, []int{defaultRole.ID}, nil); err != nil {
+			return nil, internal("分配默认角色失败", err)
+		}
+	} else if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, internal("注册失败", err)
+	}
+
+	return user, nil
+}
+
+func (s *AuthService) Login(username, password, ip string) (*LoginResult, error) {
+	user, err := s.users.FindByUsername(username)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, unauthorized("用户名或密码错误")
+	}
+	if err != nil {
+		return nil, internal("登录失败", err)
+	}
+
+	if user.Status != 1 {
+		return nil, forbidden("账号已被禁用")
+	}
+
+	if !utils.ComparePassword(password, user.Password) {
+		return nil, unauthorized("用户名或密码错误")
+	}
+
+	now := time.Now()
+	if err := s.users.Update(user.ID, map[string]interface{}{
+		"last_login_time":     &now,
+		"last_login_ip":       &ip,
+		"last_login_location": s.geo.Locate(ip),
+	}); err != nil {
+		return nil, internal("登录失败", err)
+	}
+
+	roles, err := s.users.GetRoles(user.ID, true)
+	if err != nil {
+		return nil, internal("查询用户角色失败", err)
+	}
+
+	roleCodes := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleCodes = append(roleCodes, role.RoleCode)
+	}
+
+	permissions, err := s.users.GetPermissions(user.ID)
+	if err != nil {
+		return nil, internal("查询用户权限失败", err)
+	}
+
+	permissionCodes := make([]string, 0, len(permissions))
+	for _, perm := range permissions {
+		permissionCodes = append(permissionCodes, perm.PermissionCode)
+	}
+
+	token, err := utils.GenerateToken(&models.Claims{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Roles:       roleCodes,
+		Permissions: permissionCodes,
+	})
+	if err != nil {
+		return nil, internal("生成令牌失败", err)
+	}
+
+	return &LoginResult{
+		Token:           token,
+		User:            user,
+		Roles:           roles,
+		PermissionCodes: permissionCodes,
+	}, nil
+}
+
+func (s *AuthService) Current(userID int) (*CurrentUser, error) {
+	user, err := s.users.FindByID(userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, notFound("用户不存在")
+	}
+	if err != nil {
+		return nil, internal("查询用户失败", err)
+	}
+
+	roles, err := s.users.GetRoles(userID, true)
+	if err != nil {
+		return nil, internal("查询用户角色失败", err)
+	}
+
+	permissions, err := s.users.GetPermissions(userID)
+	if err != nil {
+		return nil, internal("查询用户权限失败", err)
+	}
+
+	return &CurrentUser{User: user, Roles: roles, Permissions: permissions}, nil
+}
+
+// ResetPassword 通过邮箱验证码重置密码
+func (s *AuthService) ResetPassword(ctx context.Context, email, code, newPassword string) error {
+	if !s.email.VerifyCode(ctx, email, code) {
+		return invalid("验证码错误或已过期")
+	}
+
+	user, err := s.users.FindByEmail(email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return notFound("用户不存在")
+	}
+	if err != nil {
+		return internal("密码重置失败", err)
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return internal("密码加密失败", err)
+	}
+
+	if err := s.users.Update(user.ID, map[string]interface{}{"password": hashedPassword}); err != nil {
+		return internal("密码重置失败", err)
+	}
+
+	return nil
+}
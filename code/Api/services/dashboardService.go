@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+
+	"superhoneypotguard/repositories"
+)
+
+// DashboardStats 首页统计数据
+type DashboardStats struct {
+	UserCount       int64 `json:"userCount"`
+	RoleCount       int64 `json:"roleCount"`
+	PermissionCount int64 `json:"permissionCount"`
+	LogCount        int64 `json:"logCount"`
+}
+
+type DashboardService struct {
+	users       repositories.UserRepository
+	roles       repositories.RoleRepository
+	permissions repositories.PermissionRepository
+	logs        repositories.LogRepository
+}
+
+func NewDashboardService(
+	users repositories.UserRepository,
+	roles repositories.RoleRepository,
+	permissions repositories.PermissionRepository,
+	logs repositories.LogRepository,
+) *DashboardService {
+	return &DashboardService{
+		users:       users,
+		roles:       roles,
+		permissions: permissions,
+		logs:        logs,
+	}
+}
+
+func (s *DashboardService) Stats(ctx context.Context) (*DashboardStats, error) {
+	var stats DashboardStats
+	var err error
+
+	if stats.UserCount, err = s.users.Count(ctx); err != nil {
+		return nil, internal("查询统计数据失败", err)
+	}
+	if stats.RoleCount, err = s.roles.Count(ctx); err != nil {
+		return nil, internal("查询统计数据失败", err)
+	}
+	if stats.PermissionCount, err = s.permissions.Count(ctx); err != nil {
+		return nil, internal("查询统计数据失败", err)
+	}
+	if stats.LogCount, err = s.logs.Count(ctx); err != nil {
+		return nil, internal("查询统计数据失败", err)
+	}
+
+	return &stats, nil
+}
@@ -1,39 +1,44 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/big"
+	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"superhoneypotguard/config"
+	"superhoneypotguard/metrics"
 	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/tracing"
 
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/gomail.v2"
-	"gorm.io/gorm"
 )
 
-type EmailService struct {
-	DB *gorm.DB
+// Mailer 邮件发送器，便于在测试中替换为内存实现
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
 }
 
-func NewEmailService(db *gorm.DB) *EmailService {
-	return &EmailService{DB: db}
+type EmailService struct {
+	codes  repositories.VerificationCodeRepository
+	users  repositories.UserRepository
+	mailer Mailer
 }
 
-type VerificationCode struct {
-	Code      string
-	Email     string
-	ExpiresAt time.Time
-	SentAt    time.Time
+func NewEmailService(codes repositories.VerificationCodeRepository, users repositories.UserRepository, mailer Mailer) *EmailService {
+	return &EmailService{codes: codes, users: users, mailer: mailer}
 }
 
-var verificationCodes = make(map[string]*VerificationCode)
-
 // 提交目的：优化SMTP连接配置，支持多种端口和加密方式
 // 提交内容：添加统一的sendEmail方法，改进TLS/SSL配置，增强日志记录
 // 提交时间：2026-01-18
@@ -42,34 +47,113 @@ var verificationCodes = make(map[string]*VerificationCode)
 // 提交内容：修改EmailService结构，添加数据库支持，实现验证码持久化
 // 提交时间：2026-01-19
 
-// InitEmailService 初始化邮件服务，创建验证码表
-func InitEmailService(db *gorm.DB) error {
-	// 创建验证码表
-	if err := db.AutoMigrate(&VerificationCode{}); err != nil {
-		log.Printf("创建验证码表失败: %v", err)
-		return err
+// SMTPMailer 基于 SMTP 的邮件发送器，配置可在运行时通过 Configure 更新
+type SMTPMailer struct {
+	mu       sync.RWMutex
+	settings SMTPSettings
+}
+
+func NewSMTPMailer(settings SMTPSettings) *SMTPMailer {
+	return &SMTPMailer{settings: settings}
+}
+
+// Configure 替换 SMTP 配置，之后发送的邮件使用新配置
+func (m *SMTPMailer) Configure(settings SMTPSettings) {
+	m.mu.Lock()
+	m.settings = settings
+	m.mu.Unlock()
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, email, subject, body string) (err error) {
+	m.mu.RLock()
+	cfg := m.settings
+	m.mu.RUnlock()
+
+	// 收件人属于个人信息，不写入 span
+	ctx, span := tracing.Tracer().Start(ctx, "SMTP send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.ServerAddress(cfg.Host), semconv.ServerPort(cfg.Port)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	logger := slog.With("to", email, "smtp_host", cfg.Host, "smtp_port", cfg.Port)
+
+	from := cfg.User
+	to := []string{email}
+	port := cfg.Port
+
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", from)
+	msg.SetHeader("To", strings.Join(to, ","))
+	msg.SetHeader("Subject", subject)
+	msg.SetHeader("MIME-Version", "1.0")
+	msg.SetHeader("Content-Type", "text/html; charset=UTF-8")
+	msg.SetBody("text/html", body)
+
+	d := gomail.NewDialer(cfg.Host, port, cfg.User, cfg.Password)
+
+	// 根据端口配置加密方式
+	switch port {
+	case 465:
+		// SSL/TLS 加密
+		d.SSL = true
+	case 587:
+		// STARTTLS 加密
+		d.TLSConfig = &tls.Config{
+			InsecureSkipVerify: false,
+			ServerName:         cfg.Host,
+		}
+	case 25:
+		// 通常非加密，但一些服务商可能需要 TLS
+		d.TLSConfig = &tls.Config{
+			InsecureSkipVerify: false,
+			ServerName:         cfg.Host,
+		}
+	default:
+		// 其他端口默认使用 TLS
+		d.TLSConfig = &tls.Config{
+			InsecureSkipVerify: false,
+			ServerName:         cfg.Host,
+		}
+	}
+
+	logger.DebugContext(ctx, "开始发送邮件", "ssl", d.SSL)
+
+	// 发送邮件
+	if err := d.DialAndSend(msg); err != nil {
+		logger.ErrorContext(ctx, "发送邮件失败", "error", err)
+		return fmt.Errorf("发送邮件失败: %v", err)
 	}
-	log.Printf("验证码表初始化成功")
+
+	logger.InfoContext(ctx, "邮件发送成功")
 	return nil
 }
 
-// sendEmail 统一的邮件发送方法
-// isResetPassword: 是否为密码重置验证码（true=重置密码，false=注册）
-func (s *EmailService) sendEmail(email, subject, body string, isResetPassword bool) error {
-	cfg := config.AppConfig
+// Ping 检查能否连接 SMTP 服务器，只建立 TCP 连接，不进行认证
+func (m *SMTPMailer) Ping(ctx context.Context) error {
+	m.mu.RLock()
+	cfg := m.settings
+	m.mu.RUnlock()
 
-	log.Printf("准备发送邮件到: %s", email)
-	log.Printf("SMTP 配置详情: Host=%s, Port=%s, User=%s", cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
 
-	// 根据场景应用不同的验证码发送频率限制
+// checkSendAllowed 根据场景检查验证码发送频率限制
+// isResetPassword: 是否为密码重置验证码（true=重置密码，false=注册）
+func (s *EmailService) checkSendAllowed(ctx context.Context, email string, isResetPassword bool) error {
 	if isResetPassword {
 		// 密码重置：3分钟内最多10次，超过后需要等待10分钟
-		var recentCodes []VerificationCode
 		threeMinutesAgo := time.Now().Add(-3 * time.Minute)
 		tenMinutesAgo := time.Now().Add(-10 * time.Minute)
 
-		if err := s.DB.Where("email = ? AND sent_at > ? AND sent_at < ?", email, tenMinutesAgo, threeMinutesAgo).Find(&recentCodes).Error; err != nil {
-			log.Printf("查询验证码记录失败: %v", err)
+		recentCodes, err := s.codes.ListSentBetween(email, tenMinutesAgo, threeMinutesAgo)
+		if err != nil {
+			slog.ErrorContext(ctx, "查询验证码记录失败", "error", err)
 			return fmt.Errorf("系统错误，请稍后重试")
 		}
 
@@ -88,99 +172,73 @@ func (s *EmailService) sendEmail(email, subject, body string, isResetPassword bo
 				oldestCode := recentCodes[0]
 				waitTime := 10*time.Minute - time.Since(oldestCode.SentAt)
 				remainingSeconds := int(waitTime.Seconds())
-				log.Printf("密码重置验证码发送过于频繁，请 %d 秒后再试", remainingSeconds)
+				slog.WarnContext(ctx, "密码重置验证码发送过于频繁", "email", email, "retry_after_seconds", remainingSeconds)
 				return fmt.Errorf("验证码发送过于频繁，请 %d 秒后再试", remainingSeconds)
-			} else {
-				// 如果3分钟内都是最近10分钟的记录，则已达到限制
-				log.Printf("密码重置验证码3分钟内已发送10次，请10分钟后再试")
-				return fmt.Errorf("验证码发送过于频繁，请10分钟后再试")
-			}
-		}
-	} else {
-		// 注册：检查邮箱是否已注册
-		var existingUser models.User
-		if err := s.DB.Where("email = ?", email).First(&existingUser).Error; err == nil {
-			log.Printf("邮箱 %s 已被注册", email)
-			return fmt.Errorf("该邮箱已被注册")
-		} else if err != gorm.ErrRecordNotFound {
-			log.Printf("查询用户失败: %v", err)
-			return fmt.Errorf("系统错误，请稍后重试")
-		}
-
-		// 注册：60秒内不能重复发送
-		var existingCode VerificationCode
-		if err := s.DB.Where("email = ?", email).Order("sent_at DESC").First(&existingCode).Error; err == nil {
-			timeSinceLastSent := time.Since(existingCode.SentAt)
-			if timeSinceLastSent < 60*time.Second {
-				log.Printf("邮箱 %s 最近已发送过验证码，距离上次发送: %v 秒", email, timeSinceLastSent.Seconds())
-				return fmt.Errorf("验证码发送过于频繁，请 %d 秒后再试", 60-int(timeSinceLastSent.Seconds()))
 			}
+			// 如果3分钟内都是最近10分钟的记录，则已达到限制
+			slog.WarnContext(ctx, "密码重置验证码3分钟内已发送10次", "email", email)
+			return fmt.Errorf("验证码发送过于频繁，请10分钟后再试")
 		}
+		return nil
 	}
 
-	from := cfg.SMTPUser
-	to := []string{email}
+	// 注册：检查邮箱是否已注册
+	if _, err := s.users.FindByEmail(email); err == nil {
+		slog.InfoContext(ctx, "邮箱已被注册", "email", email)
+		return fmt.Errorf("该邮箱已被注册")
+	} else if !errors.Is(err, repositories.ErrNotFound) {
+		slog.ErrorContext(ctx, "查询用户失败", "error", err)
+		return fmt.Errorf("系统错误，请稍后重试")
+	}
 
-	port, err := strconv.Atoi(cfg.SMTPPort)
-	if err != nil {
-		log.Printf("SMTP 端口转换失败: %v", err)
-		return fmt.Errorf("SMTP 端口转换失败: %v", err)
+	// 注册：60秒内不能重复发送
+	if existingCode, err := s.codes.FindLatest(email); err == nil {
+		timeSinceLastSent := time.Since(existingCode.SentAt)
+		if timeSinceLastSent < 60*time.Second {
+			slog.InfoContext(ctx, "邮箱最近已发送过验证码", "email", email, "since_last_seconds", int(timeSinceLastSent.Seconds()))
+			return fmt.Errorf("验证码发送过于频繁，请 %d 秒后再试", 60-int(timeSinceLastSent.Seconds()))
+		}
 	}
 
-	log.Printf("SMTP 端口: %d", port)
+	return nil
+}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", from)
-	m.SetHeader("To", strings.Join(to, ","))
-	m.SetHeader("Subject", subject)
-	m.SetHeader("MIME-Version", "1.0")
-	m.SetHeader("Content-Type", "text/html; charset=UTF-8")
-	m.SetBody("text/html", body)
+// sendCode 检查频率限制、发送邮件并持久化验证码
+func (s *EmailService) sendCode(ctx context.Context, email, subject, body, code string, isResetPassword bool) error {
+	kind := "verification"
+	if isResetPassword {
+		kind = "reset_password"
+	}
 
-	d := gomail.NewDialer(cfg.SMTPHost, port, cfg.SMTPUser, cfg.SMTPPassword)
+	if err := s.checkSendAllowed(ctx, email, isResetPassword); err != nil {
+		metrics.EmailSends.WithLabelValues(kind, "refused").Inc()
+		return err
+	}
 
-	// 根据端口配置加密方式
-	switch port {
-	case 465:
-		// SSL/TLS 加密
-		d.SSL = true
-		log.Printf("使用 SSL 加密 (端口 465)")
-	case 587:
-		// STARTTLS 加密
-		d.TLSConfig = &tls.Config{
-			InsecureSkipVerify: false,
-			ServerName:         cfg.SMTPHost,
-		}
-		log.Printf("使用 TLS/STARTTLS 加密 (端口 587)")
-	case 25:
-		// 通常非加密，但一些服务商可能需要 TLS
-		d.TLSConfig = &tls.Config{
-			InsecureSkipVerify: false,
-			ServerName:         cfg.SMTPHost,
-		}
-		log.Printf("使用 TLS 加密 (端口 25)")
-	default:
-		// 其他端口默认使用 TLS
-		d.TLSConfig = &tls.Config{
-			InsecureSkipVerify: false,
-			ServerName:         cfg.SMTPHost,
-		}
-		log.Printf("使用 TLS 加密 (端口 %d)", port)
+	if err := s.mailer.Send(ctx, email, subject, body); err != nil {
+		metrics.EmailSends.WithLabelValues(kind, "failed").Inc()
+		return err
 	}
+	metrics.EmailSends.WithLabelValues(kind, "sent").Inc()
 
-	log.Printf("开始连接 SMTP 服务器: %s:%d", cfg.SMTPHost, port)
+	// 存储验证码到数据库
+	verificationCode := &models.VerificationCode{
+		Code:      code,
+		Email:     email,
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+		SentAt:    time.Now(),
+	}
 
-	// 发送邮件
-	if err := d.DialAndSend(m); err != nil {
-		log.Printf("发送邮件失败: %v", err)
-		return fmt.Errorf("发送邮件失败: %v", err)
+	if err := s.codes.Create(verificationCode); err != nil {
+		slog.ErrorContext(ctx, "存储验证码失败", "error", err)
+		return fmt.Errorf("存储验证码失败: %v", err)
 	}
 
-	log.Printf("邮件发送成功到: %s", email)
+	slog.InfoContext(ctx, "验证码已发送", "email", email, "kind", kind)
 	return nil
 }
 
-func (s *EmailService) SendVerificationCode(email string) error {
+func (s *EmailService) SendVerificationCode(ctx context.Context, email string) error {
 	code := generateVerificationCode()
 
 	subject := "SuperHoneyPotGuard 注册验证码"
@@ -195,28 +253,10 @@ func (s *EmailService) SendVerificationCode(email string) error {
 		<p>此邮件由系统自动发送，请勿回复。</p>
 	`, code)
 
-	if err := s.sendEmail(email, subject, body, false); err != nil {
-		return err
-	}
-
-	// 存储验证码到数据库
-	verificationCode := &VerificationCode{
-		Code:      code,
-		Email:     email,
-		ExpiresAt: time.Now().Add(5 * time.Minute),
-		SentAt:    time.Now(),
-	}
-
-	if err := s.DB.Create(verificationCode).Error; err != nil {
-		log.Printf("存储验证码失败: %v", err)
-		return fmt.Errorf("存储验证码失败: %v", err)
-	}
-
-	log.Printf("验证码存储成功: %s -> %s", email, code)
-	return nil
+	return s.sendCode(ctx, email, subject, body, code, false)
 }
 
-func (s *EmailService) SendResetPasswordCode(email string) error {
+func (s *EmailService) SendResetPasswordCode(ctx context.Context, email string) error {
 	code := generateVerificationCode()
 
 	subject := "SuperHoneyPotGuard 密码重置验证码"
@@ -231,43 +271,24 @@ func (s *EmailService) SendResetPasswordCode(email string) error {
 		<p>此邮件由系统自动发送，请勿回复。</p>
 	`, code)
 
-	if err := s.sendEmail(email, subject, body, true); err != nil {
-		return err
-	}
-
-	// 存储验证码到数据库
-	verificationCode := &VerificationCode{
-		Code:      code,
-		Email:     email,
-		ExpiresAt: time.Now().Add(5 * time.Minute),
-		SentAt:    time.Now(),
-	}
-
-	if err := s.DB.Create(verificationCode).Error; err != nil {
-		log.Printf("存储验证码失败: %v", err)
-		return fmt.Errorf("存储验证码失败: %v", err)
-	}
-
-	log.Printf("验证码存储成功: %s -> %s", email, code)
-	return nil
+	return s.sendCode(ctx, email, subject, body, code, true)
 }
 
-func (s *EmailService) VerifyCode(email, code string) bool {
-	var verificationCode VerificationCode
-	if err := s.DB.Where("email = ? AND code = ? AND expires_at > ?", email, code, time.Now()).First(&verificationCode).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			log.Printf("验证码不存在或已过期: %s", email)
+func (s *EmailService) VerifyCode(ctx context.Context, email, code string) bool {
+	if _, err := s.codes.FindValid(email, code, time.Now()); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			slog.InfoContext(ctx, "验证码不存在或已过期", "email", email)
 		} else {
-			log.Printf("查询验证码失败: %v", err)
+			slog.ErrorContext(ctx, "查询验证码失败", "error", err)
 		}
 		return false
 	}
 
-	log.Printf("验证码验证成功: %s", email)
+	slog.InfoContext(ctx, "验证码验证成功", "email", email)
 
 	// 验证成功后删除验证码
-	if err := s.DB.Delete(&verificationCode).Error; err != nil {
-		log.Printf("删除验证码失败: %v", err)
+	if err := s.codes.Delete(email, code); err != nil {
+		slog.ErrorContext(ctx, "删除验证码失败", "error", err)
 	}
 
 	return true
@@ -282,14 +303,3 @@ func generateVerificationCode() string {
 	}
 	return code
 }
-
-// CleanupExpiredCodes 清理过期的验证码
-func (s *EmailService) CleanupExpiredCodes() {
-	// 从数据库中删除过期的验证码
-	result := s.DB.Where("expires_at < ?", time.Now()).Delete(&VerificationCode{})
-	if result.Error != nil {
-		log.Printf("清理过期验证码失败: %v", result.Error)
-	} else {
-		log.Printf("清理过期验证码成功，删除了 %d 条记录", result.RowsAffected)
-	}
-}
@@ -0,0 +1,70 @@
+package services
+
+import "errors"
+
+// ErrorKind 业务错误分类，由控制器映射为 HTTP 状态码
+type ErrorKind int
+
+const (
+	KindInvalid ErrorKind = iota + 1
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindInternal
+)
+
+// Error 服务层返回给调用方的业务错误，Message 可直接展示给用户
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func invalid(message string) error {
+	return &Error{Kind: KindInvalid, Message: message}
+}
+
+func unauthorized(message string) error {
+	return &Error{Kind: KindUnauthorized, Message: message}
+}
+
+func forbidden(message string) error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+func notFound(message string) error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func internal(message string, err error) error {
+	return &Error{Kind: KindInternal, Message: message, Err: err}
+}
+
+// KindOf 返回错误分类，非业务错误一律视为内部错误
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// MessageOf 返回可展示给用户的错误信息
+func MessageOf(err error, fallback string) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	return fallback
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+
+	"superhoneypotguard/geoip"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+// GeoLookup /api/geo/:ip 的查询结果
+type GeoLookup struct {
+	IP string `json:"ip"`
+	// Location 与操作日志中记录的位置描述一致
+	Location string          `json:"location"`
+	Geo      *geoip.Location `json:"geo"`
+}
+
+// GeoService 查询 IP 地理位置，为操作日志、登录记录和 HFish 攻击数据补充位置
+type GeoService struct {
+	resolver *geoip.Resolver
+}
+
+func NewGeoService(resolver *geoip.Resolver) *GeoService {
+	return &GeoService{resolver: resolver}
+}
+
+func (s *GeoService) Lookup(ip string) (*GeoLookup, error) {
+	loc, err := s.resolver.Lookup(ip)
+	switch {
+	case errors.Is(err, geoip.ErrInvalidIP):
+		return nil, invalid("无效的 IP 地址")
+	case err != nil:
+		return nil, internal("IP 地理位置数据库不可用", err)
+	case loc == nil:
+		return nil, notFound("未找到该 IP 的地理位置")
+	}
+	return &GeoLookup{IP: ip, Location: loc.String(), Geo: loc}, nil
+}
+
+// Find 查询 IP 的位置，无法查询时返回 nil
+func (s *GeoService) Find(ip string) *geoip.Location {
+	loc, err := s.resolver.Lookup(ip)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// Locate 用于记录的位置描述，无法查询时返回 nil
+func (s *GeoService) Locate(ip string) *string {
+	text := s.resolver.Locate(ip)
+	if text == "" {
+		return nil
+	}
+	return &text
+}
+
+// Reload 数据库文件变化时重新加载，由定时任务调用
+func (s *GeoService) Reload() {
+	reloaded, err := s.resolver.Reload()
+	if err != nil {
+		slog.Warn("重新加载 IP 地理位置数据库失败，继续使用当前数据库", "error", err)
+		return
+	}
+	if reloaded {
+		slog.Info("IP 地理位置数据库已重新加载")
+	}
+}
+
+// LocateLogs 包装日志仓储，写入前按 IP 补充地理位置；未配置数据库时原样返回
+func (s *GeoService) LocateLogs(logs repositories.LogRepository) repositories.LogRepository {
+	if !s.resolver.Enabled() {
+		return logs
+	}
+	return &locatingLogRepository{LogRepository: logs, geo: s}
+}
+
+// locatingLogRepository 位置参与日志的哈希计算，必须在写入前确定
+type locatingLogRepository struct {
+	repositories.LogRepository
+	geo *GeoService
+}
+
+func (r *locatingLogRepository) Create(logs []models.OperationLog) error {
+	for i := range logs {
+		if logs[i].Location == nil && logs[i].IP != nil {
+			logs[i].Location = r.geo.Locate(*logs[i].IP)
+		}
+	}
+	return r.LogRepository.Create(logs)
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"superhoneypotguard/lifecycle"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// 健康检查的整体状态
+const (
+	HealthOK = "ok"
+	// HealthDown 单个组件检查失败
+	HealthDown = "down"
+	// HealthDegraded 非关键组件异常，服务仍可处理请求
+	HealthDegraded = "degraded"
+	// HealthUnavailable 关键组件异常，服务未就绪
+	HealthUnavailable = "unavailable"
+)
+
+// HealthCheck 一项就绪检查，Critical 的检查失败时服务视为未就绪
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+	// Warnings 不影响可用性但需要关注的配置问题，如未校验证书，可为空
+	Warnings func() []string
+}
+
+// ComponentHealth 单个组件的检查结果
+type ComponentHealth struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	// LatencyMs 检查耗时（毫秒）
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+	// Warnings 组件可用但存在安全或配置隐患
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// HealthReport 就绪检查结果，组件顺序与注册顺序一致
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Components []ComponentHealth `json:"components"`
+}
+
+// Ready 服务能否接收流量
+func (r *HealthReport) Ready() bool {
+	return r.Status != HealthUnavailable
+}
+
+// Summary 不含组件明细的就绪结果，供未认证的探针使用
+func (r *HealthReport) Summary() ReadinessReport {
+	return ReadinessReport{Status: r.Status, Timestamp: r.Timestamp}
+}
+
+// ReadinessReport 就绪检查的整体状态，不包含组件错误、实例地址等内部信息
+type ReadinessReport struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// LivenessReport 存活检查结果，只反映进程本身是否在运行
+type LivenessReport struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	StartedAt time.Time `json:"startedAt"`
+	// Uptime 运行时长（秒）
+	Uptime int64 `json:"uptime"`
+}
+
+type HealthService struct {
+	checks    []HealthCheck
+	timeout   time.Duration
+	startedAt time.Time
+
+	// group 合并并发的就绪检查，cached 为最近一次检查结果
+	group  singleflight.Group
+	mu     sync.Mutex
+	cached *HealthReport
+}
+
+// NewHealthService timeout 为单项检查的最长等待时间，各项检查并行执行；
+// 同时也是 Cached 复用上一次结果的时长
+func NewHealthService(timeout time.Duration, checks ...HealthCheck) *HealthService {
+	return &HealthService{checks: checks, timeout: timeout, startedAt: time.Now()}
+}
+
+func (s *HealthService) Live() LivenessReport {
+	now := time.Now()
+	return LivenessReport{
+		Status:    HealthOK,
+		Timestamp: now,
+		StartedAt: s.startedAt,
+		Uptime:    int64(now.Sub(s.startedAt).Seconds()),
+	}
+}
+
+// Cached 返回不超过 timeout 的最近一次检查结果，过期时只有一个调用方重新检查，其余等待其结果
+// 供未认证的探针使用，频繁请求不会放大到数据库、SMTP 和 HFish
+func (s *HealthService) Cached(ctx context.Context) *HealthReport {
+	s.mu.Lock()
+	cached := s.cached
+	s.mu.Unlock()
+	if cached != nil && time.Since(cached.Timestamp) < s.timeout {
+		return cached
+	}
+
+	v, _, _ := s.group.Do("ready", func() (interface{}, error) {
+		// 检查不随单个探针请求取消，等待中的调用方共享同一结果
+		return s.Ready(context.WithoutCancel(ctx)), nil
+	})
+	return v.(*HealthReport)
+}
+
+// Ready 并行执行所有检查：关键组件异常时为 unavailable，只有非关键组件异常时为 degraded
+// 结果同时作为 Cached 的缓存
+func (s *HealthService) Ready(ctx context.Context) *HealthReport {
+	report := s.check(ctx)
+	s.mu.Lock()
+	s.cached = report
+	s.mu.Unlock()
+	return report
+}
+
+func (s *HealthService) check(ctx context.Context) *HealthReport {
+	report := &HealthReport{
+		Status:     HealthOK,
+		Timestamp:  time.Now(),
+		Components: make([]ComponentHealth, len(s.checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Components[i] = s.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	for _, c := range report.Components {
+		if c.Status == HealthOK {
+			continue
+		}
+		if c.Critical {
+			report.Status = HealthUnavailable
+		} else if report.Status == HealthOK {
+			report.Status = HealthDegraded
+		}
+	}
+	return report
+}
+
+func (s *HealthService) run(ctx context.Context, check HealthCheck) ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(ctx)
+	result := ComponentHealth{
+		Name:      check.Name,
+		Status:    HealthOK,
+		Critical:  check.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if check.Warnings != nil {
+		result.Warnings = check.Warnings()
+	}
+	if err != nil {
+		result.Status = HealthDown
+		result.Error = err.Error()
+		if ctx.Err() == context.DeadlineExceeded {
+			result.Error = fmt.Sprintf("检查超时（%s）", s.timeout)
+		}
+	}
+	return result
+}
+
+// WorkersCheck 检查后台组件是否仍在运行且心跳正常
+func WorkersCheck(lc *lifecycle.Manager) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var problems []string
+		for _, c := range lc.Status() {
+			switch {
+			case !c.Running && c.Error != "":
+				problems = append(problems, fmt.Sprintf("%s 已退出: %s", c.Name, c.Error))
+			case !c.Running:
+				problems = append(problems, c.Name+" 已退出")
+			case c.Stale:
+				problems = append(problems, fmt.Sprintf("%s 自 %s 起没有心跳", c.Name, c.LastBeat.Format(time.RFC3339)))
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%s", strings.Join(problems, "；"))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"superhoneypotguard/geoip"
+	"superhoneypotguard/logging"
+	"superhoneypotguard/metrics"
+	"superhoneypotguard/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type AttackIP struct {
+	ID        string `json:"id"`
+	IP        string `json:"ip"`
+	Count     int    `json:"count"`
+	FirstSeen string `json:"first_seen"`
+	LastSeen  string `json:"last_seen"`
+	// Geo 由本服务根据 IP 查询补充，不来自 HFish
+	Geo *geoip.Location `json:"geo,omitempty"`
+	// InstanceID、Instance 为数据来源的 HFish 实例，由本服务补充
+	InstanceID int    `json:"instanceId"`
+	Instance   string `json:"instance"`
+}
+
+type AttackDetail struct {
+	ID          string `json:"id"`
+	IP          string `json:"ip"`
+	AttackType  string `json:"attack_type"`
+	Protocol    string `json:"protocol"`
+	Port        int    `json:"port"`
+	Payload     string `json:"payload"`
+	RequestTime string `json:"request_time"`
+	Account     string `json:"account"`
+	// Geo 由本服务根据 IP 查询补充，不来自 HFish
+	Geo *geoip.Location `json:"geo,omitempty"`
+	// InstanceID、Instance 为数据来源的 HFish 实例，由本服务补充
+	InstanceID int    `json:"instanceId"`
+	Instance   string `json:"instance"`
+}
+
+type AccountInfo struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Protocol    string `json:"protocol"`
+	IP          string `json:"ip"`
+	AttackCount int    `json:"attack_count"`
+	// Geo 由本服务根据 IP 查询补充，不来自 HFish
+	Geo *geoip.Location `json:"geo,omitempty"`
+	// InstanceID、Instance 为数据来源的 HFish 实例，由本服务补充
+	InstanceID int    `json:"instanceId"`
+	Instance   string `json:"instance"`
+}
+
+type SysInfo struct {
+	TotalHoneypots  int    `json:"total_honeypots"`
+	ActiveHoneypots int    `json:"active_honeypots"`
+	TotalAttacks    int    `json:"total_attacks"`
+	LastAttackTime  string `json:"last_attack_time"`
+	SystemStatus    string `json:"system_status"`
+	InstanceID      int    `json:"instanceId,omitempty"`
+	Instance        string `json:"instance,omitempty"`
+	// Instances 汇总多个实例时各实例的系统信息
+	Instances []SysInfo `json:"instances,omitempty"`
+}
+
+// hfishResponse HFish API 的通用响应结构
+type hfishResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// HFishEndpoint 一个 HFish 实例的连接参数
+type HFishEndpoint struct {
+	Name    string
+	BaseURL string
+	APIKey  string
+	// TLSSkipVerify 不校验 HFish 的证书，API Key 可能被中间人截获，只应在临时排查时使用
+	TLSSkipVerify bool
+	// TLSCACert 信任的 CA 证书（PEM）
+	TLSCACert string
+	// TLSPinSHA256 固定的服务端证书 SHA-256 指纹，逗号分隔
+	TLSPinSHA256 string
+	// TLSClientCert、TLSClientKey mTLS 客户端证书和私钥（PEM）
+	TLSClientCert string
+	TLSClientKey  string
+	// MaxResponseSize 读取响应的上限（MB），为 0 时不限制
+	MaxResponseSize int
+}
+
+// HFishClient 调用一个 HFish 实例的管理端 API，实例配置变化时由 HFishService 重新创建
+type HFishClient struct {
+	name       string
+	baseURL    string
+	apiKey     string
+	maxBytes   int64
+	httpClient *http.Client
+}
+
+// NewHFishClient TLS 配置无效时返回错误
+func NewHFishClient(ep HFishEndpoint) (*HFishClient, error) {
+	tlsConfig, err := hfishTLSConfig(ep)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &HFishClient{
+		name:       ep.Name,
+		baseURL:    ep.BaseURL,
+		apiKey:     ep.APIKey,
+		maxBytes:   int64(ep.MaxResponseSize) << 20,
+		httpClient: &http.Client{Transport: transport},
+	}, nil
+}
+
+// Name 实例名称
+func (c *HFishClient) Name() string {
+	return c.name
+}
+
+// Close 关闭空闲连接，实例配置变化后旧客户端不再使用
+func (c *HFishClient) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+func (c *HFishClient) AttackIPs(ctx context.Context) ([]AttackIP, error) {
+	var data []AttackIP
+	err := c.call(ctx, http.MethodPost, "/attack/ip", nil, &data)
+	return data, err
+}
+
+func (c *HFishClient) AttackDetails(ctx context.Context) ([]AttackDetail, error) {
+	var data []AttackDetail
+	err := c.call(ctx, http.MethodPost, "/attack/detail", nil, &data)
+	return data, err
+}
+
+func (c *HFishClient) AccountInfo(ctx context.Context) ([]AccountInfo, error) {
+	var data []AccountInfo
+	err := c.call(ctx, http.MethodPost, "/attack/account", nil, &data)
+	return data, err
+}
+
+func (c *HFishClient) SysInfo(ctx context.Context) (*SysInfo, error) {
+	var data SysInfo
+	if err := c.call(ctx, http.MethodGet, "/hfish/sys_info", nil, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (c *HFishClient) BlockIP(ctx context.Context, ip, reason string) error {
+	body := map[string]string{"ip": ip, "reason": reason}
+	err := c.call(ctx, http.MethodPost, "/attack/ip/block", body, nil)
+
+	// HFish 返回 success=false 时错误中不包含底层 error
+	var e *Error
+	if errors.As(err, &e) && e.Err == nil {
+		metrics.HFishIPActions.WithLabelValues("block", "rejected").Inc()
+		slog.WarnContext(ctx, "封禁 IP 失败", "instance", c.name, "ip", ip, "error", e.Message)
+		return internal("封禁 IP 失败: "+e.Message, nil)
+	}
+	if err != nil {
+		metrics.HFishIPActions.WithLabelValues("block", "error").Inc()
+		return err
+	}
+
+	metrics.HFishIPActions.WithLabelValues("block", "success").Inc()
+	slog.InfoContext(ctx, "封禁 IP 成功", "instance", c.name, "ip", ip)
+	return nil
+}
+
+// Ping 通过系统信息接口检查 HFish 是否可达以及 API Key 是否有效
+func (c *HFishClient) Ping(ctx context.Context) error {
+	if c.baseURL == "" {
+		return errors.New("未配置 HFish 地址")
+	}
+
+	err := c.call(ctx, http.MethodGet, "/hfish/sys_info", nil, nil)
+	var e *Error
+	if errors.As(err, &e) && e.Err == nil {
+		return fmt.Errorf("HFish 返回错误: %s", e.Message)
+	}
+	if errors.As(err, &e) {
+		return errors.New(e.Message)
+	}
+	return err
+}
+
+// call 调用 HFish API，并按接口路径记录耗时、结果和 span
+// ctx 中有请求 ID 时通过 X-Request-ID 传给 HFish，追踪上下文通过 traceparent 传递；
+// 日志、span 和返回的错误中不包含带 API Key 的完整地址
+func (c *HFishClient) call(ctx context.Context, method, path string, body interface{}, out interface{}) (err error) {
+	url := fmt.Sprintf("%s%s?api_key=%s", c.baseURL, path, neturl.QueryEscape(c.apiKey))
+
+	ctx, span := tracing.Tracer().Start(ctx, "HFish "+method+" "+path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLPath(path),
+			attribute.String("hfish.instance", c.name),
+		),
+	)
+	if u, perr := neturl.Parse(c.baseURL); perr == nil {
+		span.SetAttributes(semconv.ServerAddress(u.Hostname()))
+	}
+
+	start := time.Now()
+	outcome := metrics.HFishOutcomeSuccess
+	defer func() {
+		metrics.HFishRequests.WithLabelValues(path, outcome).Inc()
+		metrics.HFishRequestDuration.WithLabelValues(path).Observe(time.Since(start).Seconds())
+		span.SetAttributes(attribute.String("hfish.outcome", outcome))
+		tracing.End(span, err)
+	}()
+	logger := slog.With("instance", c.name, "method", method, "endpoint", path)
+	logger.DebugContext(ctx, "调用 HFish API")
+
+	var reader io.Reader
+	if body != nil {
+		jsonData, _ := json.Marshal(body)
+		reader = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return internal("调用 HFish API 失败: "+err.Error(), err)
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		outcome = metrics.HFishOutcomeTransport
+		// url.Error 中带有完整地址，只保留底层错误
+		var ue *neturl.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		if isCertificateError(err) {
+			logger.ErrorContext(ctx, "HFish 证书校验失败，请在实例设置中配置 CA 证书或证书指纹", "error", err)
+			return internal("HFish 证书校验失败，请在实例设置中配置 CA 证书或证书指纹: "+err.Error(), err)
+		}
+		logger.ErrorContext(ctx, "调用 HFish API 失败", "error", err, "duration_ms", time.Since(start).Milliseconds())
+		return internal("调用 HFish API 失败: "+err.Error(), err)
+	}
+	defer resp.Body.Close()
+
+	src := io.Reader(resp.Body)
+	if c.maxBytes > 0 {
+		// 多读一个字节用于判断是否超出上限，超出时不解析，避免一次读入过多数据
+		src = io.LimitReader(resp.Body, c.maxBytes+1)
+	}
+	respBody, _ := io.ReadAll(src)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if c.maxBytes > 0 && int64(len(respBody)) > c.maxBytes {
+		outcome = metrics.HFishOutcomeInvalid
+		message := fmt.Sprintf("HFish 响应超过 %d MB 上限，请调大 HFISH_MAX_RESPONSE_SIZE", c.maxBytes>>20)
+		logger.ErrorContext(ctx, message)
+		return internal(message, nil)
+	}
+	logger.DebugContext(ctx, "HFish API 响应", "status", resp.StatusCode, "bytes", len(respBody), "duration_ms", time.Since(start).Milliseconds())
+
+	var result hfishResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		outcome = metrics.HFishOutcomeInvalid
+		logger.ErrorContext(ctx, "解析 HFish API 响应失败", "status", resp.StatusCode, "error", err)
+		return internal("解析 HFish API 响应失败", err)
+	}
+
+	if !result.Success {
+		outcome = metrics.HFishOutcomeRejected
+		logger.WarnContext(ctx, "HFish API 返回错误", "error", result.Message)
+		return internal(result.Message, nil)
+	}
+
+	if out != nil && len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			outcome = metrics.HFishOutcomeInvalid
+			logger.ErrorContext(ctx, "解析 HFish API 响应失败", "error", err)
+			return internal("解析 HFish API 响应失败", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"superhoneypotguard/repositories"
+)
+
+// HFishQuery 攻击 IP、攻击详情的查询参数，取值为接口传入的原始字符串，由服务统一校验
+type HFishQuery struct {
+	// InstanceID 为 0 时汇总全部已启用的实例
+	InstanceID int
+	// IP 单个地址或 CIDR 网段
+	IP string
+	// StartTime、EndTime 格式同操作日志查询；攻击 IP 按活跃区间（首次到最后出现）与之有交集筛选
+	StartTime string
+	EndTime   string
+	// AttackType、Protocol、Port 多个以逗号分隔，Account 按包含匹配，仅攻击详情支持
+	AttackType string
+	Protocol   string
+	Port       string
+	Account    string
+	// SortBy 可选字段见 repositories.HFishAttackIPSortFields、HFishAttackDetailSortFields，SortOrder 可选 asc、desc
+	SortBy    string
+	SortOrder string
+}
+
+// parseHFishFilter 校验过滤条件，details 为 false 时不接受仅攻击详情支持的条件；
+// 返回的条件中不含实例，由调用方在同步后填写
+func parseHFishFilter(q HFishQuery, details bool) (repositories.HFishAttackFilter, error) {
+	var filter repositories.HFishAttackFilter
+
+	if !details {
+		for _, param := range [][2]string{{"attackType", q.AttackType}, {"protocol", q.Protocol}, {"port", q.Port}, {"account", q.Account}} {
+			if param[1] != "" {
+				return filter, invalid(fmt.Sprintf("攻击 IP 不支持按 %s 筛选", param[0]))
+			}
+		}
+	}
+
+	if q.IP != "" {
+		ipRange, err := repositories.ParseIPRange(q.IP)
+		if err != nil {
+			return filter, invalid(err.Error())
+		}
+		filter.IP = ipRange
+	}
+
+	var err error
+	if filter.StartTime, err = parseQueryTime("startTime", q.StartTime); err != nil {
+		return filter, err
+	}
+	if filter.EndTime, err = parseQueryTime("endTime", q.EndTime); err != nil {
+		return filter, err
+	}
+	if filter.StartTime != nil && filter.EndTime != nil && !filter.StartTime.Before(*filter.EndTime) {
+		return filter, invalid("开始时间必须早于结束时间")
+	}
+
+	filter.AttackTypes = splitLower(q.AttackType)
+	filter.Protocols = splitLower(q.Protocol)
+	for _, item := range splitLower(q.Port) {
+		port, err := strconv.Atoi(item)
+		if err != nil || port < 1 || port > 65535 {
+			return filter, invalid(fmt.Sprintf("无效的端口 %q", item))
+		}
+		filter.Ports = append(filter.Ports, port)
+	}
+	filter.Account = strings.ToLower(strings.TrimSpace(q.Account))
+	return filter, nil
+}
+
+// parseHFishSort 校验排序方式，fields 为允许的字段到列名的映射，未指定字段时使用 defaultField 倒序
+func parseHFishSort(q HFishQuery, fields map[string]string, defaultField string) (repositories.HFishAttackSort, error) {
+	field := defaultField
+	if q.SortBy != "" {
+		field = q.SortBy
+	}
+	column, ok := fields[field]
+	if !ok {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return repositories.HFishAttackSort{}, invalid(fmt.Sprintf("不支持按 %q 排序（可选: %s）", q.SortBy, strings.Join(names, "、")))
+	}
+
+	sort := repositories.HFishAttackSort{Field: column}
+	switch strings.ToLower(q.SortOrder) {
+	case "", "desc":
+		sort.Desc = true
+	case "asc":
+	default:
+		return sort, invalid(fmt.Sprintf("无效的排序方向 %q（可选: asc、desc）", q.SortOrder))
+	}
+	return sort, nil
+}
+
+// hfishTimeLayouts HFish 返回的时间格式，按本地时间解析
+var hfishTimeLayouts = []string{time.DateTime, time.RFC3339}
+
+// parseHFishTime 解析 HFish 返回的时间，无法解析时返回 nil，这类行不参与按时间筛选
+func parseHFishTime(value string) *time.Time {
+	for _, layout := range hfishTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+func splitLower(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
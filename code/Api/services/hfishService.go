@@ -0,0 +1,795 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/models"
+	"superhoneypotguard/redact"
+	"superhoneypotguard/repositories"
+)
+
+// 加密 HFish API Key 和 mTLS 客户端私钥时的附加数据
+const (
+	hfishAPIKeyLabel    = "hfish_instances.api_key"
+	hfishClientKeyLabel = "hfish_instances.tls_client_key"
+)
+
+// legacyHFishInstance 从启动配置导入的实例名称
+const legacyHFishInstance = "default"
+
+// hfishTarget 一个已加载的 HFish 实例
+type hfishTarget struct {
+	instance models.HFishInstance
+	client   *HFishClient
+	// err 实例无法调用的原因，如 API Key 无法解密、TLS 配置无效
+	err error
+	// ips、details 攻击数据的同步状态，实例重新加载后从头开始
+	ips     syncState
+	details syncState
+}
+
+// HFishBlockResult 一个实例上的封禁结果
+type HFishBlockResult struct {
+	InstanceID int    `json:"instanceId"`
+	Instance   string `json:"instance"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message,omitempty"`
+}
+
+// HFishService 管理 HFish 实例登记表，并把查询和封禁分发到一个或多个实例
+//
+// 查询未指定实例时并发调用全部已启用的实例并合并结果，每行标注来源实例；
+// 部分实例失败时返回其余实例的数据，失败的实例 ID 单独返回，全部失败时才返回错误。
+// 攻击 IP 和攻击详情先同步到数据库再查询，见 Sync。
+type HFishService struct {
+	instances repositories.HFishInstanceRepository
+	attacks   repositories.HFishAttackRepository
+	settings  repositories.SettingRepository
+	secrets   *SecretService
+	geo       *GeoService
+	cfg       *config.Config
+
+	// writeMu 串行化实例的增删改与重新加载
+	writeMu sync.Mutex
+	mu      sync.RWMutex
+	targets []*hfishTarget
+}
+
+func NewHFishService(instances repositories.HFishInstanceRepository, attacks repositories.HFishAttackRepository, settings repositories.SettingRepository, secrets *SecretService, geo *GeoService, cfg *config.Config) *HFishService {
+	return &HFishService{instances: instances, attacks: attacks, settings: settings, secrets: secrets, geo: geo, cfg: cfg}
+}
+
+// ImportLegacy 实例表为空时，把启动配置中的 HFISH_BASE_URL、HFISH_API_KEY
+// （或早期保存在系统设置中的 hfish_base_url、hfish_api_key）导入为名为 default 的实例。
+// 导入的实例默认校验证书，只有显式配置 HFISH_LEGACY_TLS_SKIP_VERIFY 时才沿用此前不校验证书的行为；
+// 已导入后系统设置中的旧值会被删除。
+func (s *HFishService) ImportLegacy() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	count, err := s.instances.Count()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	baseURL, apiKey := s.cfg.HFishBaseURL, s.cfg.HFishAPIKey
+	rows, err := s.settings.List()
+	if err != nil {
+		return err
+	}
+	var legacyKeys []string
+	for _, row := range rows {
+		switch row.Key {
+		case "hfish_base_url":
+			baseURL = row.Value
+		case "hfish_api_key":
+			apiKey = row.Value
+		default:
+			continue
+		}
+		legacyKeys = append(legacyKeys, row.Key)
+	}
+	if baseURL == "" || apiKey == "" {
+		return nil
+	}
+
+	encrypted, err := s.secrets.Encrypt(hfishAPIKeyLabel, apiKey)
+	if err != nil {
+		return err
+	}
+	if err := s.instances.Create(&models.HFishInstance{
+		Name:          legacyHFishInstance,
+		BaseURL:       baseURL,
+		APIKey:        encrypted,
+		TLSSkipVerify: s.cfg.HFishLegacySkipVerify,
+		Enabled:       true,
+	}); err != nil {
+		return err
+	}
+	if s.cfg.HFishLegacySkipVerify {
+		slog.Warn("导入的 HFish 实例不校验 TLS 证书，请尽快配置 CA 证书或证书指纹", "instance", legacyHFishInstance)
+	}
+	for _, key := range legacyKeys {
+		if err := s.settings.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("已将启动配置中的 HFish 地址导入为实例", "instance", legacyHFishInstance)
+	return nil
+}
+
+// Load 从数据库加载实例并为已启用的实例创建客户端
+func (s *HFishService) Load() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.reload()
+}
+
+// reload 重新创建全部客户端，调用方需持有 writeMu
+func (s *HFishService) reload() error {
+	instances, err := s.instances.List()
+	if err != nil {
+		return err
+	}
+
+	targets := make([]*hfishTarget, 0, len(instances))
+	for _, instance := range instances {
+		target := &hfishTarget{instance: instance}
+		ep, err := s.endpoint(&instance)
+		if err != nil {
+			slog.Error("解密 HFish 实例密钥失败", "instance", instance.Name, "error", err)
+			target.err = err
+		} else if instance.Enabled {
+			if target.client, err = NewHFishClient(ep); err != nil {
+				slog.Error("HFish 实例的 TLS 配置无效", "instance", instance.Name, "error", err)
+				target.err = fmt.Errorf("TLS 配置无效: %w", err)
+			}
+			if instance.TLSSkipVerify {
+				slog.Warn("HFish 实例未校验 TLS 证书，API Key 可能被中间人截获", "instance", instance.Name)
+			}
+		}
+		targets = append(targets, target)
+	}
+
+	s.mu.Lock()
+	old := s.targets
+	s.targets = targets
+	s.mu.Unlock()
+
+	for _, target := range old {
+		if target.client != nil {
+			target.client.Close()
+		}
+	}
+	return nil
+}
+
+// endpoint 解密实例的 API Key 和客户端私钥
+func (s *HFishService) endpoint(instance *models.HFishInstance) (HFishEndpoint, error) {
+	apiKey, err := s.secrets.Decrypt(hfishAPIKeyLabel, instance.APIKey)
+	if err != nil {
+		return HFishEndpoint{}, fmt.Errorf("解密 API Key 失败: %w", err)
+	}
+	clientKey, err := s.secrets.Decrypt(hfishClientKeyLabel, instance.TLSClientKey)
+	if err != nil {
+		return HFishEndpoint{}, fmt.Errorf("解密客户端私钥失败: %w", err)
+	}
+	return HFishEndpoint{
+		Name:          instance.Name,
+		BaseURL:       instance.BaseURL,
+		APIKey:        apiKey,
+		TLSSkipVerify: instance.TLSSkipVerify,
+		TLSCACert:     instance.TLSCACert,
+		TLSPinSHA256:  instance.TLSPinSHA256,
+		TLSClientCert: instance.TLSClientCert,
+		TLSClientKey:  clientKey,
+
+		MaxResponseSize: s.cfg.HFishMaxResponseSize,
+	}, nil
+}
+
+// List 返回全部实例，API Key 和客户端私钥只返回脱敏值
+func (s *HFishService) List() ([]models.HFishInstance, error) {
+	instances, err := s.instances.List()
+	if err != nil {
+		return nil, internal("查询 HFish 实例失败", err)
+	}
+	for i := range instances {
+		maskInstance(&instances[i])
+	}
+	return instances, nil
+}
+
+func (s *HFishService) Get(id int) (*models.HFishInstance, error) {
+	instance, err := s.findInstance(id)
+	if err != nil {
+		return nil, err
+	}
+	maskInstance(instance)
+	return instance, nil
+}
+
+func (s *HFishService) Create(req models.CreateHFishInstanceRequest, operatorID int) (*models.HFishInstance, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkInstance(name, req.BaseURL, 0); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.APIKey) == "" || req.APIKey == redact.Mask {
+		return nil, invalid("API Key 不能为空")
+	}
+	encrypted, err := s.secrets.Encrypt(hfishAPIKeyLabel, strings.TrimSpace(req.APIKey))
+	if err != nil {
+		return nil, internal("加密 API Key 失败", err)
+	}
+
+	ep := HFishEndpoint{
+		TLSSkipVerify: req.TLSSkipVerify,
+		TLSCACert:     strings.TrimSpace(req.TLSCACert),
+		TLSPinSHA256:  req.TLSPinSHA256,
+		TLSClientCert: strings.TrimSpace(req.TLSClientCert),
+		TLSClientKey:  strings.TrimSpace(req.TLSClientKey),
+	}
+	if ep.TLSClientKey == redact.Mask {
+		ep.TLSClientKey = ""
+	}
+	if err := checkTLS(&ep); err != nil {
+		return nil, err
+	}
+	clientKey, err := s.secrets.Encrypt(hfishClientKeyLabel, ep.TLSClientKey)
+	if err != nil {
+		return nil, internal("加密客户端私钥失败", err)
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	instance := &models.HFishInstance{
+		Name:          name,
+		BaseURL:       strings.TrimSpace(req.BaseURL),
+		APIKey:        encrypted,
+		TLSSkipVerify: ep.TLSSkipVerify,
+		TLSCACert:     ep.TLSCACert,
+		TLSPinSHA256:  ep.TLSPinSHA256,
+		TLSClientCert: ep.TLSClientCert,
+		TLSClientKey:  clientKey,
+		Enabled:       enabled,
+		Remark:        req.Remark,
+		CreatedBy:     &operatorID,
+	}
+	if err := s.instances.Create(instance); err != nil {
+		return nil, internal("创建 HFish 实例失败", err)
+	}
+	if err := s.reload(); err != nil {
+		return nil, internal("加载 HFish 实例失败", err)
+	}
+
+	maskInstance(instance)
+	return instance, nil
+}
+
+// Update 修改实例，返回被替换的密钥字段（apiKey、tlsClientKey），审计时只记录发生了替换
+// 修改地址时必须重新提供 API Key 和已配置的客户端私钥，否则已保存的密钥会被发往新地址
+func (s *HFishService) Update(id int, req models.UpdateHFishInstanceRequest, operatorID int) ([]string, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	instance, err := s.findInstance(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"updated_by": operatorID}
+	name, baseURL := instance.Name, instance.BaseURL
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+		updates["name"] = name
+	}
+	if req.BaseURL != nil {
+		baseURL = strings.TrimSpace(*req.BaseURL)
+		updates["base_url"] = baseURL
+	}
+	if err := s.checkInstance(name, baseURL, id); err != nil {
+		return nil, err
+	}
+	moved := baseURL != instance.BaseURL
+	if moved && !secretProvided(req.APIKey) {
+		return nil, invalid("修改 HFish 地址时必须重新填写 API Key")
+	}
+
+	var changed []string
+	if secretProvided(req.APIKey) {
+		encrypted, err := s.secrets.Encrypt(hfishAPIKeyLabel, strings.TrimSpace(*req.APIKey))
+		if err != nil {
+			return nil, internal("加密 API Key 失败", err)
+		}
+		updates["api_key"] = encrypted
+		changed = append(changed, "apiKey")
+	}
+
+	current, err := s.endpoint(instance)
+	if err != nil {
+		return nil, internal("解密 HFish 实例密钥失败", err)
+	}
+	ep := HFishEndpoint{
+		TLSSkipVerify: instance.TLSSkipVerify,
+		TLSCACert:     instance.TLSCACert,
+		TLSPinSHA256:  instance.TLSPinSHA256,
+		TLSClientCert: instance.TLSClientCert,
+		TLSClientKey:  current.TLSClientKey,
+	}
+	if req.TLSSkipVerify != nil {
+		ep.TLSSkipVerify = *req.TLSSkipVerify
+	}
+	if req.TLSCACert != nil {
+		ep.TLSCACert = strings.TrimSpace(*req.TLSCACert)
+	}
+	if req.TLSPinSHA256 != nil {
+		ep.TLSPinSHA256 = *req.TLSPinSHA256
+	}
+	clientKeyChanged := false
+	if req.TLSClientCert != nil {
+		ep.TLSClientCert = strings.TrimSpace(*req.TLSClientCert)
+		if ep.TLSClientCert == "" && ep.TLSClientKey != "" {
+			ep.TLSClientKey = ""
+			clientKeyChanged = true
+		}
+	}
+	if secretProvided(req.TLSClientKey) {
+		ep.TLSClientKey = strings.TrimSpace(*req.TLSClientKey)
+		clientKeyChanged = true
+	}
+	if moved && ep.TLSClientKey != "" && !secretProvided(req.TLSClientKey) {
+		return nil, invalid("修改 HFish 地址时必须重新填写客户端私钥")
+	}
+	if err := checkTLS(&ep); err != nil {
+		return nil, err
+	}
+	updates["tls_skip_verify"] = ep.TLSSkipVerify
+	updates["tls_ca_cert"] = ep.TLSCACert
+	updates["tls_pin_sha256"] = ep.TLSPinSHA256
+	updates["tls_client_cert"] = ep.TLSClientCert
+	if clientKeyChanged {
+		encrypted, err := s.secrets.Encrypt(hfishClientKeyLabel, ep.TLSClientKey)
+		if err != nil {
+			return nil, internal("加密客户端私钥失败", err)
+		}
+		updates["tls_client_key"] = encrypted
+		changed = append(changed, "tlsClientKey")
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.Remark != nil {
+		updates["remark"] = req.Remark
+	}
+
+	if err := s.instances.Update(id, updates); err != nil {
+		return nil, internal("更新 HFish 实例失败", err)
+	}
+	if err := s.reload(); err != nil {
+		return nil, internal("加载 HFish 实例失败", err)
+	}
+	return changed, nil
+}
+
+func (s *HFishService) Delete(id int) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if _, err := s.findInstance(id); err != nil {
+		return err
+	}
+	if err := s.instances.Delete(id); err != nil {
+		return internal("删除 HFish 实例失败", err)
+	}
+	if err := s.reload(); err != nil {
+		return internal("加载 HFish 实例失败", err)
+	}
+	return nil
+}
+
+func (s *HFishService) findInstance(id int) (*models.HFishInstance, error) {
+	instance, err := s.instances.FindByID(id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, notFound("HFish 实例不存在")
+	}
+	if err != nil {
+		return nil, internal("查询 HFish 实例失败", err)
+	}
+	return instance, nil
+}
+
+// checkInstance 校验实例名称和地址，excludeID 为修改中的实例
+func (s *HFishService) checkInstance(name, baseURL string, excludeID int) error {
+	if name == "" {
+		return invalid("实例名称不能为空")
+	}
+	if err := absoluteURL(strings.TrimSpace(baseURL)); err != nil {
+		return invalid("HFish 地址" + err.Error())
+	}
+	exists, err := s.instances.ExistsByName(name, excludeID)
+	if err != nil {
+		return internal("查询 HFish 实例失败", err)
+	}
+	if exists {
+		return invalid("实例名称已存在")
+	}
+	return nil
+}
+
+// absoluteURL 校验实例地址为 http 或 https 的绝对地址
+func absoluteURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("无效的地址 %q", value)
+	}
+	return nil
+}
+
+// checkTLS 校验 TLS 设置并规范化证书指纹，ep 中的客户端私钥为明文
+func checkTLS(ep *HFishEndpoint) error {
+	pins, err := normalizeFingerprints(ep.TLSPinSHA256)
+	if err != nil {
+		return invalid(err.Error())
+	}
+	ep.TLSPinSHA256 = pins
+	if _, err := hfishTLSConfig(*ep); err != nil {
+		return invalid(err.Error())
+	}
+	return nil
+}
+
+// secretProvided 密钥字段是否提交了新值，空字符串和脱敏值表示不修改
+func secretProvided(value *string) bool {
+	return value != nil && strings.TrimSpace(*value) != "" && *value != redact.Mask
+}
+
+func maskInstance(instance *models.HFishInstance) {
+	if instance.APIKey != "" {
+		instance.MaskedAPIKey = redact.Mask
+	}
+	if instance.TLSClientKey != "" {
+		instance.MaskedTLSClientKey = redact.Mask
+	}
+}
+
+// resolve 返回调用目标，未指定实例时为全部已启用的实例
+func (s *HFishService) resolve(instanceIDs ...int) ([]*hfishTarget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(instanceIDs) == 0 {
+		var targets []*hfishTarget
+		for _, target := range s.targets {
+			if target.instance.Enabled {
+				targets = append(targets, target)
+			}
+		}
+		if len(targets) == 0 {
+			return nil, invalid("没有已启用的 HFish 实例")
+		}
+		return targets, nil
+	}
+
+	seen := make(map[int]bool, len(instanceIDs))
+	targets := make([]*hfishTarget, 0, len(instanceIDs))
+	for _, id := range instanceIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		target := s.findTarget(id)
+		if target == nil {
+			return nil, notFound(fmt.Sprintf("HFish 实例 #%d 不存在", id))
+		}
+		if !target.instance.Enabled {
+			return nil, invalid(fmt.Sprintf("HFish 实例 %s 已停用", target.instance.Name))
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// findTarget 按 ID 查找已加载的实例，调用方需持有读锁
+func (s *HFishService) findTarget(id int) *hfishTarget {
+	for _, target := range s.targets {
+		if target.instance.ID == id {
+			return target
+		}
+	}
+	return nil
+}
+
+// selector 将查询参数中的实例 ID 转换为 resolve 的参数，0 表示全部实例
+func selector(instanceID int) []int {
+	if instanceID == 0 {
+		return nil
+	}
+	return []int{instanceID}
+}
+
+// hfishResult 一个实例的调用结果
+type hfishResult[T any] struct {
+	target *hfishTarget
+	data   T
+	err    error
+}
+
+// fanOut 并发调用每个实例，结果与 targets 顺序一致
+func fanOut[T any](ctx context.Context, targets []*hfishTarget, fn func(context.Context, *hfishTarget) (T, error)) []hfishResult[T] {
+	results := make([]hfishResult[T], len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		results[i].target = target
+		if target.err != nil {
+			results[i].err = internal(target.err.Error(), nil)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, target *hfishTarget) {
+			defer wg.Done()
+			results[i].data, results[i].err = fn(ctx, target)
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+// merge 合并各实例返回的列表，返回调用失败的实例 ID；全部失败时返回错误
+func merge[T any](results []hfishResult[[]T], tag func(row *T, instance models.HFishInstance)) ([]T, []int, error) {
+	rows := make([]T, 0)
+	var failed []int
+	var problems []string
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result.target.instance.ID)
+			problems = append(problems, result.target.instance.Name+": "+MessageOf(result.err, "调用 HFish API 失败"))
+			continue
+		}
+		for i := range result.data {
+			tag(&result.data[i], result.target.instance)
+		}
+		rows = append(rows, result.data...)
+	}
+
+	if len(failed) == len(results) {
+		if len(results) == 1 {
+			return nil, nil, results[0].err
+		}
+		return nil, nil, internal("所有 HFish 实例调用失败: "+strings.Join(problems, "; "), nil)
+	}
+	if len(failed) > 0 {
+		slog.Warn("部分 HFish 实例调用失败", "instances", problems)
+	}
+	return rows, failed, nil
+}
+
+// AttackIPs 按条件查询攻击来源 IP 并分页，只为返回的一页补充地理位置。
+// HFish API 不支持筛选，数据过期的实例先同步到数据库，再由数据库过滤、排序和分页；
+// 同步失败的实例返回已保存的数据，其 ID 作为第二个返回值
+func (s *HFishService) AttackIPs(ctx context.Context, q HFishQuery, page, pageSize int) (*models.PaginatedResponse, []int, error) {
+	filter, err := parseHFishFilter(q, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort, err := parseHFishSort(q, repositories.HFishAttackIPSortFields, "lastSeen")
+	if err != nil {
+		return nil, nil, err
+	}
+	targets, err := s.resolve(selector(q.InstanceID)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	stale := syncDataset(ctx, targets, s.attackIPs())
+
+	filter.InstanceIDs = instanceIDs(targets)
+	page, pageSize = max(page, 1), clampPageSize(pageSize)
+	rows, total, err := s.attacks.ListIPs(filter, sort, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, nil, internal("查询攻击 IP 失败", err)
+	}
+
+	names := instanceNames(targets)
+	list := make([]AttackIP, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, AttackIP{
+			ID:         row.SourceID,
+			IP:         row.IP,
+			Count:      row.Count,
+			FirstSeen:  row.FirstSeen,
+			LastSeen:   row.LastSeen,
+			Geo:        s.geo.Find(row.IP),
+			InstanceID: row.InstanceID,
+			Instance:   names[row.InstanceID],
+		})
+	}
+	return &models.PaginatedResponse{List: list, Total: total, Page: page, PageSize: pageSize}, stale, nil
+}
+
+// AttackDetails 按条件查询攻击详情并分页，同步和筛选方式同 AttackIPs
+func (s *HFishService) AttackDetails(ctx context.Context, q HFishQuery, page, pageSize int) (*models.PaginatedResponse, []int, error) {
+	filter, err := parseHFishFilter(q, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort, err := parseHFishSort(q, repositories.HFishAttackDetailSortFields, "requestTime")
+	if err != nil {
+		return nil, nil, err
+	}
+	targets, err := s.resolve(selector(q.InstanceID)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	stale := syncDataset(ctx, targets, s.attackDetails())
+
+	filter.InstanceIDs = instanceIDs(targets)
+	page, pageSize = max(page, 1), clampPageSize(pageSize)
+	rows, total, err := s.attacks.ListDetails(filter, sort, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, nil, internal("查询攻击详情失败", err)
+	}
+
+	names := instanceNames(targets)
+	list := make([]AttackDetail, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, AttackDetail{
+			ID:          row.SourceID,
+			IP:          row.IP,
+			AttackType:  row.AttackType,
+			Protocol:    row.Protocol,
+			Port:        row.Port,
+			Payload:     row.Payload,
+			RequestTime: row.RequestTime,
+			Account:     row.Account,
+			Geo:         s.geo.Find(row.IP),
+			InstanceID:  row.InstanceID,
+			Instance:    names[row.InstanceID],
+		})
+	}
+	return &models.PaginatedResponse{List: list, Total: total, Page: page, PageSize: pageSize}, stale, nil
+}
+
+func (s *HFishService) AccountInfo(ctx context.Context, instanceID int) ([]AccountInfo, []int, error) {
+	targets, err := s.resolve(selector(instanceID)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := fanOut(ctx, targets, func(ctx context.Context, target *hfishTarget) ([]AccountInfo, error) {
+		return target.client.AccountInfo(ctx)
+	})
+	return merge(results, func(row *AccountInfo, instance models.HFishInstance) {
+		row.InstanceID, row.Instance = instance.ID, instance.Name
+		row.Geo = s.geo.Find(row.IP)
+	})
+}
+
+// SysInfo 查询系统信息；汇总多个实例时数量相加，Instances 中为各实例的信息，
+// 各实例状态不一致或有实例调用失败时 SystemStatus 为 partial
+func (s *HFishService) SysInfo(ctx context.Context, instanceID int) (*SysInfo, []int, error) {
+	targets, err := s.resolve(selector(instanceID)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := fanOut(ctx, targets, func(ctx context.Context, target *hfishTarget) ([]SysInfo, error) {
+		info, err := target.client.SysInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []SysInfo{*info}, nil
+	})
+	infos, failed, err := merge(results, func(row *SysInfo, instance models.HFishInstance) {
+		row.InstanceID, row.Instance = instance.ID, instance.Name
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(targets) == 1 {
+		return &infos[0], failed, nil
+	}
+
+	total := &SysInfo{Instances: infos}
+	for i, info := range infos {
+		total.TotalHoneypots += info.TotalHoneypots
+		total.ActiveHoneypots += info.ActiveHoneypots
+		total.TotalAttacks += info.TotalAttacks
+		if info.LastAttackTime > total.LastAttackTime {
+			total.LastAttackTime = info.LastAttackTime
+		}
+		if i == 0 {
+			total.SystemStatus = info.SystemStatus
+		} else if info.SystemStatus != total.SystemStatus {
+			total.SystemStatus = "partial"
+		}
+	}
+	if len(failed) > 0 {
+		total.SystemStatus = "partial"
+	}
+	return total, failed, nil
+}
+
+// BlockIP 在指定实例上封禁 IP，instanceIDs 为空时发往全部已启用的实例；
+// 返回每个实例的结果，全部失败时返回错误
+func (s *HFishService) BlockIP(ctx context.Context, ip, reason string, instanceIDs []int) ([]HFishBlockResult, error) {
+	targets, err := s.resolve(instanceIDs...)
+	if err != nil {
+		return nil, err
+	}
+	results := fanOut(ctx, targets, func(ctx context.Context, target *hfishTarget) (struct{}, error) {
+		return struct{}{}, target.client.BlockIP(ctx, ip, reason)
+	})
+
+	out := make([]HFishBlockResult, 0, len(results))
+	var problems []string
+	for _, result := range results {
+		item := HFishBlockResult{InstanceID: result.target.instance.ID, Instance: result.target.instance.Name, Success: result.err == nil}
+		if result.err != nil {
+			item.Message = MessageOf(result.err, "封禁 IP 失败")
+			problems = append(problems, item.Instance+": "+item.Message)
+		}
+		out = append(out, item)
+	}
+
+	if len(problems) == len(results) {
+		if len(results) == 1 {
+			return nil, results[0].err
+		}
+		return nil, internal("所有 HFish 实例封禁失败: "+strings.Join(problems, "; "), nil)
+	}
+	return out, nil
+}
+
+// Warnings 已启用实例中可能泄露 API Key 的连接配置，在健康检查中作为警告展示
+func (s *HFishService) Warnings() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var warnings []string
+	for _, target := range s.targets {
+		instance := target.instance
+		switch {
+		case !instance.Enabled:
+		case instance.TLSSkipVerify:
+			warnings = append(warnings, instance.Name+": 未校验 TLS 证书，API Key 可能被中间人截获")
+		case strings.HasPrefix(strings.ToLower(instance.BaseURL), "http://"):
+			warnings = append(warnings, instance.Name+": 使用明文 HTTP 连接，API Key 可能被截获")
+		}
+	}
+	return warnings
+}
+
+// Ping 检查全部已启用的实例，任一实例不可用时返回错误
+func (s *HFishService) Ping(ctx context.Context) error {
+	targets, err := s.resolve()
+	if err != nil {
+		return errors.New("未配置已启用的 HFish 实例")
+	}
+	results := fanOut(ctx, targets, func(ctx context.Context, target *hfishTarget) (struct{}, error) {
+		return struct{}{}, target.client.Ping(ctx)
+	})
+
+	var problems []string
+	for _, result := range results {
+		if result.err != nil {
+			problems = append(problems, result.target.instance.Name+": "+result.err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+// syncState 一类攻击数据在一个实例上的同步状态，mu 保证同一实例同时只有一次拉取
+type syncState struct {
+	mu sync.Mutex
+	at time.Time
+}
+
+// hfishDataset 一类从 HFish 同步到数据库的攻击数据
+type hfishDataset struct {
+	name string
+	// interval 距上次同步未超过该时长时直接使用数据库中的数据，为 0 时总是拉取
+	interval time.Duration
+	state    func(target *hfishTarget) *syncState
+	// pull 拉取实例上的全部数据并写入数据库
+	pull func(ctx context.Context, target *hfishTarget, now time.Time) error
+}
+
+func (s *HFishService) attackIPs() hfishDataset {
+	return hfishDataset{
+		name:     "攻击 IP",
+		interval: s.cfg.HFishSyncInterval,
+		state:    func(target *hfishTarget) *syncState { return &target.ips },
+		pull: func(ctx context.Context, target *hfishTarget, now time.Time) error {
+			rows, err := target.client.AttackIPs(ctx)
+			if err != nil {
+				return err
+			}
+			// 最后出现时间超过保留时长的 IP 会被 attack_events 保留策略清理，HFish 端仍返回时不再写入
+			cutoff := s.attackEventsCutoff(now)
+			records := make([]models.HFishAttackIP, 0, len(rows))
+			for _, row := range rows {
+				lastSeenAt := parseHFishTime(row.LastSeen)
+				if lastSeenAt != nil && lastSeenAt.Before(cutoff) {
+					continue
+				}
+				records = append(records, models.HFishAttackIP{
+					InstanceID:  target.instance.ID,
+					IP:          row.IP,
+					IPBin:       repositories.IPKey(&row.IP),
+					SourceID:    row.ID,
+					Count:       row.Count,
+					FirstSeen:   row.FirstSeen,
+					LastSeen:    row.LastSeen,
+					FirstSeenAt: parseHFishTime(row.FirstSeen),
+					LastSeenAt:  lastSeenAt,
+					SyncedAt:    now,
+				})
+			}
+			if err := s.attacks.SaveIPs(records); err != nil {
+				return internal("保存攻击 IP 失败", err)
+			}
+			return nil
+		},
+	}
+}
+
+func (s *HFishService) attackDetails() hfishDataset {
+	return hfishDataset{
+		name:     "攻击详情",
+		interval: s.cfg.HFishSyncInterval,
+		state:    func(target *hfishTarget) *syncState { return &target.details },
+		pull: func(ctx context.Context, target *hfishTarget, now time.Time) error {
+			rows, err := target.client.AttackDetails(ctx)
+			if err != nil {
+				return err
+			}
+			// 超过保留时长的记录会被 attack_events 保留策略清理，HFish 端仍返回时不再写入
+			cutoff := s.attackEventsCutoff(now)
+			records := make([]models.HFishAttackDetail, 0, len(rows))
+			for _, row := range rows {
+				requestedAt := parseHFishTime(row.RequestTime)
+				if requestedAt != nil && requestedAt.Before(cutoff) {
+					continue
+				}
+				records = append(records, models.HFishAttackDetail{
+					InstanceID:  target.instance.ID,
+					SourceID:    row.ID,
+					IP:          row.IP,
+					IPBin:       repositories.IPKey(&row.IP),
+					AttackType:  row.AttackType,
+					Protocol:    row.Protocol,
+					Port:        row.Port,
+					Payload:     row.Payload,
+					Account:     row.Account,
+					RequestTime: row.RequestTime,
+					RequestedAt: requestedAt,
+					SyncedAt:    now,
+				})
+			}
+			if err := s.attacks.SaveDetails(records); err != nil {
+				return internal("保存攻击详情失败", err)
+			}
+			return nil
+		},
+	}
+}
+
+// attackEventsCutoff attack_events 保留策略的清理时间点，未启用时为零值
+func (s *HFishService) attackEventsCutoff(now time.Time) time.Time {
+	if keep := s.cfg.RetentionAttackEvents; keep > 0 {
+		return now.Add(-keep)
+	}
+	return time.Time{}
+}
+
+// syncDataset 并发同步 targets 中数据已过期的实例，返回同步失败的实例 ID。
+// 同步失败的实例之前保存的数据仍在数据库中，由调用方照常查询并标记为过期。
+func syncDataset(ctx context.Context, targets []*hfishTarget, d hfishDataset) []int {
+	results := fanOut(ctx, targets, func(ctx context.Context, target *hfishTarget) (struct{}, error) {
+		state := d.state(target)
+		state.mu.Lock()
+		defer state.mu.Unlock()
+
+		now := time.Now()
+		if d.interval > 0 && !state.at.IsZero() && now.Sub(state.at) < d.interval {
+			return struct{}{}, nil
+		}
+		if err := d.pull(ctx, target, now); err != nil {
+			return struct{}{}, err
+		}
+		state.at = now
+		return struct{}{}, nil
+	})
+
+	var failed []int
+	var problems []string
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result.target.instance.ID)
+			problems = append(problems, result.target.instance.Name+": "+MessageOf(result.err, "调用 HFish API 失败"))
+		}
+	}
+	if len(failed) > 0 {
+		slog.WarnContext(ctx, "同步 HFish 攻击数据失败，使用已保存的数据", "dataset", d.name, "instances", problems)
+	}
+	return failed
+}
+
+// Sync 拉取全部已启用实例的攻击 IP 和攻击详情写入数据库，由定时任务按 HFISH_SYNC_INTERVAL 调用，
+// 使查询时通常无需等待 HFish；失败只记录日志，下次查询或同步时重试
+func (s *HFishService) Sync() {
+	targets, err := s.resolve()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.HFishSyncInterval)
+	defer cancel()
+
+	for _, d := range []hfishDataset{s.attackIPs(), s.attackDetails()} {
+		// 定时任务总是拉取，避免与查询触发的同步错开后隔一个周期才更新
+		d.interval = 0
+		syncDataset(ctx, targets, d)
+	}
+}
+
+func instanceIDs(targets []*hfishTarget) []int {
+	ids := make([]int, 0, len(targets))
+	for _, target := range targets {
+		ids = append(ids, target.instance.ID)
+	}
+	return ids
+}
+
+func instanceNames(targets []*hfishTarget) map[int]string {
+	names := make(map[int]string, len(targets))
+	for _, target := range targets {
+		names[target.instance.ID] = target.instance.Name
+	}
+	return names
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// hfishTLSConfig 按实例配置构建 TLS 设置：
+//   - 配置 CA 证书时只信任这些 CA，否则使用系统根证书
+//   - 配置证书指纹时服务端证书必须与其中之一一致；未同时配置 CA 证书时以指纹代替证书链校验，适用于自签名证书
+//   - TLSSkipVerify 完全不校验证书，不能与 CA 证书、证书指纹同时配置
+func hfishTLSConfig(ep HFishEndpoint) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	pins, err := parseFingerprints(ep.TLSPinSHA256)
+	if err != nil {
+		return nil, err
+	}
+	if ep.TLSSkipVerify && (ep.TLSCACert != "" || len(pins) > 0) {
+		return nil, errors.New("跳过证书校验时不能配置 CA 证书或证书指纹")
+	}
+
+	if ep.TLSCACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(ep.TLSCACert)) {
+			return nil, errors.New("CA 证书不是有效的 PEM 证书")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(pins) > 0 {
+		// 只有指纹时跳过证书链校验，由 VerifyConnection 校验指纹
+		cfg.InsecureSkipVerify = ep.TLSCACert == ""
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("HFish 未提供证书")
+			}
+			sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+			for _, pin := range pins {
+				if bytes.Equal(sum[:], pin) {
+					return nil
+				}
+			}
+			return fmt.Errorf("HFish 证书指纹 %s 与配置不一致", hex.EncodeToString(sum[:]))
+		}
+	} else if ep.TLSSkipVerify {
+		cfg.InsecureSkipVerify = true
+	}
+
+	if ep.TLSClientCert != "" || ep.TLSClientKey != "" {
+		if ep.TLSClientCert == "" || ep.TLSClientKey == "" {
+			return nil, errors.New("客户端证书和私钥必须同时配置")
+		}
+		cert, err := tls.X509KeyPair([]byte(ep.TLSClientCert), []byte(ep.TLSClientKey))
+		if err != nil {
+			return nil, errors.New("客户端证书或私钥无效")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// isCertificateError 错误是否由服务端证书未通过校验引起
+func isCertificateError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verifyErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// parseFingerprints 解析逗号分隔的 SHA-256 证书指纹，允许使用冒号分隔字节（openssl 输出的格式）
+func parseFingerprints(value string) ([][]byte, error) {
+	var pins [][]byte
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ReplaceAll(strings.TrimSpace(item), ":", "")
+		if item == "" {
+			continue
+		}
+		pin, err := hex.DecodeString(item)
+		if err != nil || len(pin) != sha256.Size {
+			return nil, fmt.Errorf("证书指纹 %q 不是有效的 SHA-256 指纹", item)
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+// normalizeFingerprints 把证书指纹统一为小写十六进制、逗号分隔的形式
+func normalizeFingerprints(value string) (string, error) {
+	pins, err := parseFingerprints(value)
+	if err != nil {
+		return "", err
+	}
+	out := make([]string, len(pins))
+	for i, pin := range pins {
+		out[i] = hex.EncodeToString(pin)
+	}
+	return strings.Join(out, ","), nil
+}
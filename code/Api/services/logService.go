@@ -0,0 +1,375 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"superhoneypotguard/export"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+const (
+	// maxLogPageSize 单页最多返回的日志条数
+	maxLogPageSize = 1000
+	// exportBatchSize 导出时每次从数据库读取的日志条数
+	exportBatchSize = 500
+)
+
+// LogQuery 操作日志查询参数，取值为接口传入的原始字符串，由服务统一校验
+type LogQuery struct {
+	Username   string
+	Operation  string
+	Status     string
+	Action     string
+	TargetType string
+	TargetID   string
+	UserID     string
+	// Method 请求方法，多个以逗号分隔
+	Method string
+	// IP 单个地址或 CIDR 网段
+	IP string
+	// StartTime、EndTime 支持 RFC3339、"2006-01-02 15:04:05" 和 "2006-01-02"，无时区时按本地时间
+	StartTime      string
+	EndTime        string
+	MinExecuteTime string
+	Keyword        string
+	RequestID      string
+	TraceID        string
+	// SortBy 可选 createdAt、executeTime、id，SortOrder 可选 asc、desc
+	SortBy    string
+	SortOrder string
+}
+
+type LogService struct {
+	logs repositories.LogRepository
+}
+
+func NewLogService(logs repositories.LogRepository) *LogService {
+	return &LogService{logs: logs}
+}
+
+func (s *LogService) List(query LogQuery, page, pageSize int) (*models.PaginatedResponse, error) {
+	filter, sort, err := parseLogQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	page, pageSize = max(page, 1), clampPageSize(pageSize)
+
+	logs, total, err := s.logs.List(filter, sort, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, internal("查询日志失败", err)
+	}
+
+	return &models.PaginatedResponse{
+		List:     logs,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// Scroll 游标分页，cursor 为空时返回第一页；不统计总数，翻页开销与表大小无关
+// 游标与排序方式绑定，翻页时需保持相同的 SortBy 和 SortOrder
+func (s *LogService) Scroll(query LogQuery, cursor string, pageSize int) (*models.CursorPage, error) {
+	filter, sort, err := parseLogQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	pageSize = clampPageSize(pageSize)
+
+	var after *repositories.LogCursor
+	if cursor != "" {
+		if after, err = decodeLogCursor(cursor, sort); err != nil {
+			return nil, err
+		}
+	}
+
+	// 多取一条判断是否还有下一页
+	logs, err := s.logs.Scan(filter, sort, after, pageSize+1)
+	if err != nil {
+		return nil, internal("查询日志失败", err)
+	}
+
+	result := &models.CursorPage{List: logs, PageSize: pageSize}
+	if len(logs) > pageSize {
+		logs = logs[:pageSize]
+		result.List = logs
+		result.NextCursor = encodeLogCursor(sort, &logs[pageSize-1])
+	}
+	return result, nil
+}
+
+func (s *LogService) Get(id int) (*models.OperationLog, error) {
+	log, err := s.logs.FindByID(id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, notFound("日志不存在")
+	}
+	if err != nil {
+		return nil, internal("查询日志失败", err)
+	}
+	return log, nil
+}
+
+// LogExport 一次待写出的导出，创建时已完成参数校验
+type LogExport struct {
+	logs   repositories.LogRepository
+	filter repositories.LogFilter
+	sort   repositories.LogSort
+	format export.Format
+	// Count 已写出的日志条数
+	Count int64
+}
+
+// logExportColumns 导出的列及顺序
+var logExportColumns = []export.Column{
+	{Key: "id", Title: "日志ID"},
+	{Key: "seq", Title: "链序号"},
+	{Key: "createdAt", Title: "时间"},
+	{Key: "userId", Title: "用户ID"},
+	{Key: "username", Title: "用户名"},
+	{Key: "operation", Title: "操作"},
+	{Key: "action", Title: "审计动作"},
+	{Key: "targetType", Title: "对象类型"},
+	{Key: "targetId", Title: "对象ID"},
+	{Key: "method", Title: "请求方法"},
+	{Key: "url", Title: "请求URL"},
+	{Key: "ip", Title: "IP地址"},
+	{Key: "location", Title: "地理位置"},
+	{Key: "status", Title: "状态"},
+	{Key: "errorMsg", Title: "错误信息"},
+	{Key: "executeTime", Title: "执行时间(ms)"},
+	{Key: "params", Title: "请求参数"},
+	{Key: "result", Title: "返回结果"},
+	{Key: "changes", Title: "字段变更"},
+	{Key: "hash", Title: "哈希"},
+	{Key: "forwardedFor", Title: "转发链"},
+	{Key: "requestId", Title: "请求ID"},
+	{Key: "traceId", Title: "追踪ID"},
+}
+
+// Export 校验查询参数并准备导出，调用 Write 时才读取数据
+func (s *LogService) Export(query LogQuery, format string) (*LogExport, error) {
+	f, err := export.ParseFormat(format)
+	if err != nil {
+		return nil, invalid(err.Error())
+	}
+	filter, sort, err := parseLogQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return &LogExport{logs: s.logs, filter: filter, sort: sort, format: f}, nil
+}
+
+// Format 导出格式
+func (e *LogExport) Format() export.Format {
+	return e.format
+}
+
+// Write 按游标分批读取并写出符合条件的全部日志，内存占用与导出总量无关
+// w 实现 Flush 时（如 HTTP 响应）每批写完后刷新
+func (e *LogExport) Write(w io.Writer) error {
+	out, err := export.NewWriter(e.format, w, logExportColumns)
+	if err != nil {
+		return err
+	}
+	flusher, _ := w.(interface{ Flush() })
+
+	var after *repositories.LogCursor
+	for {
+		batch, err := e.logs.Scan(e.filter, e.sort, after, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			entry := &batch[i]
+			err := out.WriteRow([]interface{}{
+				entry.ID, entry.Seq, entry.CreatedAt, entry.UserID, entry.Username,
+				entry.Operation, entry.Action, entry.TargetType, entry.TargetID,
+				entry.Method, entry.URL, entry.IP, entry.Location, entry.Status,
+				entry.ErrorMsg, entry.ExecuteTime, entry.Params, entry.Result,
+				entry.Changes, entry.Hash, entry.ForwardedFor, entry.RequestID, entry.TraceID,
+			})
+			if err != nil {
+				return err
+			}
+			e.Count++
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(batch) < exportBatchSize {
+			break
+		}
+		after = logCursorOf(e.sort, &batch[len(batch)-1])
+	}
+	return out.Close()
+}
+
+func clampPageSize(pageSize int) int {
+	if pageSize < 1 {
+		return 10
+	}
+	return min(pageSize, maxLogPageSize)
+}
+
+// parseLogQuery 校验查询参数并转换为仓储的过滤条件和排序方式
+func parseLogQuery(q LogQuery) (repositories.LogFilter, repositories.LogSort, error) {
+	filter := repositories.LogFilter{
+		Username:   q.Username,
+		Operation:  q.Operation,
+		Status:     q.Status,
+		Action:     q.Action,
+		TargetType: q.TargetType,
+		TargetID:   q.TargetID,
+		Keyword:    strings.TrimSpace(q.Keyword),
+		RequestID:  strings.TrimSpace(q.RequestID),
+		TraceID:    strings.TrimSpace(q.TraceID),
+	}
+
+	if q.UserID != "" {
+		id, err := strconv.Atoi(q.UserID)
+		if err != nil {
+			return filter, repositories.LogSort{}, invalid(fmt.Sprintf("无效的用户ID %q", q.UserID))
+		}
+		filter.UserID = &id
+	}
+
+	for _, method := range strings.Split(q.Method, ",") {
+		if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+			filter.Methods = append(filter.Methods, method)
+		}
+	}
+
+	if q.IP != "" {
+		ipRange, err := repositories.ParseIPRange(q.IP)
+		if err != nil {
+			return filter, repositories.LogSort{}, invalid(err.Error())
+		}
+		filter.IP = ipRange
+	}
+
+	var err error
+	if filter.StartTime, err = parseQueryTime("startTime", q.StartTime); err != nil {
+		return filter, repositories.LogSort{}, err
+	}
+	if filter.EndTime, err = parseQueryTime("endTime", q.EndTime); err != nil {
+		return filter, repositories.LogSort{}, err
+	}
+	if filter.StartTime != nil && filter.EndTime != nil && !filter.StartTime.Before(*filter.EndTime) {
+		return filter, repositories.LogSort{}, invalid("开始时间必须早于结束时间")
+	}
+
+	if q.MinExecuteTime != "" {
+		ms, err := strconv.Atoi(q.MinExecuteTime)
+		if err != nil || ms < 0 {
+			return filter, repositories.LogSort{}, invalid(fmt.Sprintf("无效的执行时间 %q", q.MinExecuteTime))
+		}
+		filter.MinExecuteTime = &ms
+	}
+
+	sort := repositories.DefaultLogSort
+	if q.SortBy != "" {
+		field, ok := repositories.LogSortFields[q.SortBy]
+		if !ok {
+			return filter, sort, invalid(fmt.Sprintf("不支持按 %q 排序（可选: createdAt、executeTime、id）", q.SortBy))
+		}
+		sort.Field = field
+	}
+	switch strings.ToLower(q.SortOrder) {
+	case "", "desc":
+		sort.Desc = true
+	case "asc":
+		sort.Desc = false
+	default:
+		return filter, sort, invalid(fmt.Sprintf("无效的排序方向 %q（可选: asc、desc）", q.SortOrder))
+	}
+
+	return filter, sort, nil
+}
+
+// queryTimeLayouts 查询参数支持的时间格式，按顺序尝试
+var queryTimeLayouts = []string{time.RFC3339Nano, time.DateTime, "2006-01-02T15:04:05", time.DateOnly}
+
+func parseQueryTime(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range queryTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, invalid(fmt.Sprintf("%s: 无效的时间 %q", name, value))
+}
+
+// logCursorToken 游标的序列化形式，S 记录排序方式，防止换了排序后继续使用旧游标
+type logCursorToken struct {
+	S  string          `json:"s"`
+	V  json.RawMessage `json:"v,omitempty"`
+	ID int             `json:"id"`
+}
+
+func logCursorOf(sort repositories.LogSort, entry *models.OperationLog) *repositories.LogCursor {
+	cursor := &repositories.LogCursor{ID: entry.ID}
+	switch sort.Field {
+	case "created_at":
+		cursor.Value = entry.CreatedAt
+	case "execute_time":
+		cursor.Value = entry.ExecuteTime
+	}
+	return cursor
+}
+
+func sortKey(sort repositories.LogSort) string {
+	if sort.Desc {
+		return sort.Field + ".desc"
+	}
+	return sort.Field + ".asc"
+}
+
+func encodeLogCursor(sort repositories.LogSort, entry *models.OperationLog) string {
+	cursor := logCursorOf(sort, entry)
+	token := logCursorToken{S: sortKey(sort), ID: cursor.ID}
+	if cursor.Value != nil {
+		token.V, _ = json.Marshal(cursor.Value)
+	}
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLogCursor(value string, sort repositories.LogSort) (*repositories.LogCursor, error) {
+	bad := invalid("无效的分页游标")
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, bad
+	}
+	var token logCursorToken
+	if err := json.Unmarshal(data, &token); err != nil || token.S != sortKey(sort) {
+		return nil, bad
+	}
+
+	cursor := &repositories.LogCursor{ID: token.ID}
+	switch sort.Field {
+	case "created_at":
+		var t time.Time
+		if err := json.Unmarshal(token.V, &t); err != nil {
+			return nil, bad
+		}
+		cursor.Value = t
+	case "execute_time":
+		var ms int
+		if err := json.Unmarshal(token.V, &ms); err != nil {
+			return nil, bad
+		}
+		cursor.Value = ms
+	}
+	return cursor, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+type PermissionService struct {
+	permissions repositories.PermissionRepository
+}
+
+func NewPermissionService(permissions repositories.PermissionRepository) *PermissionService {
+	return &PermissionService{permissions: permissions}
+}
+
+func (s *PermissionService) Tree() ([]models.Permission, error) {
+	permissions, err := s.permissions.ListAll()
+	if err != nil {
+		return nil, internal("查询权限失败", err)
+	}
+	return buildTree(permissions, 0), nil
+}
+
+func buildTree(permissions []models.Permission, parentId int) []models.Permission {
+	var result []models.Permission
+	for _, p := range permissions {
+		if p.ParentID == parentId {
+			p.Children = buildTree(permissions, p.ID)
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (s *PermissionService) ListActive() ([]models.Permission, error) {
+	permissions, err := s.permissions.ListActive()
+	if err != nil {
+		return nil, internal("查询权限失败", err)
+	}
+	return permissions, nil
+}
+
+func (s *PermissionService) Get(id int) (*models.Permission, error) {
+	permission, err := s.permissions.FindByID(id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, notFound("权限不存在")
+	}
+	if err != nil {
+		return nil, internal("查询权限失败", err)
+	}
+	return permission, nil
+}
+
+func (s *PermissionService) Create(req models.CreatePermissionRequest) (*models.Permission, error) {
+	exists, err := s.permissions.ExistsByCode(req.PermissionCode)
+	if err != nil {
+		return nil, internal("创建权限失败", err)
+	}
+	if exists {
+		return nil, invalid("权限编码已存在")
+	}
+
+	parentId := 0
+	if req.ParentID != nil {
+		parentId = *req.ParentID
+	}
+
+	sortOrder := 0
+	if req.SortOrder != nil {
+		sortOrder = *req.SortOrder
+	}
+
+	status := 1
+	if req.Status != nil {
+		status = *req.Status
+	}
+
+	permission := &models.Permission{
+		PermissionName: req.PermissionName,
+		PermissionCode: req.PermissionCode,
+		PermissionType: req.PermissionType,
+		ParentID:       parentId,
+		Path:           req.Path,
+		Component:      req.Component,
+		Icon:           req.Icon,
+		SortOrder:      sortOrder,
+		Description:    req.Description,
+		Status:         status,
+	}
+
+	if err := s.permissions.Create(permission); err != nil {
+		return nil, internal("创建权限失败", err)
+	}
+
+	return permission, nil
+}
+
+func (s *PermissionService) Update(id int, req models.UpdatePermissionRequest) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+
+	parentId := 0
+	if req.ParentID != nil {
+		parentId = *req.ParentID
+	}
+
+	sortOrder := 0
+	if req.SortOrder != nil {
+		sortOrder = *req.SortOrder
+	}
+
+	status := 1
+	if req.Status != nil {
+		status = *req.Status
+	}
+
+	updates := map[string]interface{}{
+		"permission_name": req.PermissionName,
+		"permission_type": req.PermissionType,
+		"parent_id":       parentId,
+		"path":            req.Path,
+		"component":       req.Component,
+		"icon":            req.Icon,
+		"sort_order":      sortOrder,
+		"description":     req.Description,
+		"status":          status,
+	}
+
+	if err := s.permissions.Update(id, updates); err != nil {
+		return internal("更新权限失败", err)
+	}
+
+	return nil
+}
+
+func (s *PermissionService) Delete(id int) error {
+	count, err := s.permissions.CountChildren(id)
+	if err != nil {
+		return internal("删除权限失败", err)
+	}
+	if count > 0 {
+		return invalid("该权限下还有子权限，无法删除")
+	}
+
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+
+	if err := s.permissions.Delete(id); err != nil {
+		return internal("删除权限失败", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const retentionUsage = `usage: retention <command>
+
+commands:
+  status         show the retention policies and their last run
+  run [policy]   purge expired rows now, for all enabled policies or only the given one`
+
+// RunRetentionCLI 执行 retention 子命令，有策略执行失败时返回错误
+func RunRetentionCLI(args []string, open func() (*RetentionService, error), out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", retentionUsage)
+	}
+	switch {
+	case args[0] == "status" && len(args) == 1:
+	case args[0] == "run" && len(args) <= 2:
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], retentionUsage)
+	}
+
+	s, err := open()
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "status" {
+		status, err := s.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Interval %s\n", status.Interval)
+		for _, p := range status.Policies {
+			last := "never run"
+			if run := p.LastRun; run != nil {
+				last = fmt.Sprintf("last run %s, %d row(s)", run.StartedAt.Format(time.DateTime), run.Rows)
+				if run.Status != 1 {
+					last += ", failed"
+				}
+			}
+			retention := p.Retention
+			if !p.Enabled {
+				retention = "disabled"
+			}
+			fmt.Fprintf(out, "  %-20s %-12s archive=%-5t %s\n", p.Name, retention, p.Archive, last)
+		}
+		return nil
+	}
+
+	var policy string
+	if len(args) == 2 {
+		policy = args[1]
+	}
+	runs, err := s.Run(policy, RetentionSourceCLI, retentionActor)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, run := range runs {
+		if run.Status != 1 {
+			failed++
+			fmt.Fprintf(out, "  %-20s FAILED %s\n", run.Policy, *run.ErrorMsg)
+			continue
+		}
+		fmt.Fprintf(out, "  %-20s purged %d row(s) before %s", run.Policy, run.Rows, run.Cutoff.Format(time.DateTime))
+		if run.ArchiveFile != nil {
+			fmt.Fprintf(out, ", archived to %s", *run.ArchiveFile)
+		}
+		fmt.Fprintln(out)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d retention policy run(s) failed", failed)
+	}
+	fmt.Fprintln(out, "OK")
+	return nil
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+// 保留策略的触发方式
+const (
+	RetentionSourceSchedule = "schedule"
+	RetentionSourceManual   = "manual"
+	RetentionSourceCLI      = "cli"
+)
+
+// retentionActor 定时任务执行保留策略时记录的操作人
+var retentionActor = Actor{Username: "retention"}
+
+// purgeFunc 清理 cutoff 之前的数据，返回清理条数和归档文件名（未归档时为空）；source 为触发方式
+type purgeFunc func(p *RetentionPolicy, cutoff time.Time, source string, actor Actor) (int64, string, error)
+
+// RetentionPolicy 一张表的数据保留策略
+type RetentionPolicy struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	// Retention 数据保留时长，为 0 时不清理
+	Retention time.Duration `json:"-"`
+	// Archive 清理前是否将数据写入 gzip 压缩的 JSONL 归档文件
+	Archive bool `json:"archive"`
+
+	purge purgeFunc
+}
+
+// RetentionPolicyStatus 策略配置及最近一次执行结果
+type RetentionPolicyStatus struct {
+	*RetentionPolicy
+	Retention string               `json:"retention"`
+	Enabled   bool                 `json:"enabled"`
+	LastRun   *models.RetentionRun `json:"lastRun"`
+}
+
+type RetentionStatus struct {
+	// Interval 定时执行间隔，为 0 时只能手动执行
+	Interval string                  `json:"interval"`
+	Policies []RetentionPolicyStatus `json:"policies"`
+}
+
+// RetentionService 按保留策略定期清理过期数据，并记录每次执行的结果
+type RetentionService struct {
+	repo       repositories.RetentionRepository
+	chain      *AuditChainService
+	archiveDir string
+	interval   time.Duration
+	policies   []*RetentionPolicy
+
+	// mu 防止定时任务与手动执行同时清理
+	mu sync.Mutex
+}
+
+func NewRetentionService(repo repositories.RetentionRepository, chain *AuditChainService, cfg *config.Config) *RetentionService {
+	archive := make(map[string]bool)
+	for _, name := range config.SplitList(cfg.RetentionArchive) {
+		archive[name] = true
+	}
+
+	s := &RetentionService{
+		repo:       repo,
+		chain:      chain,
+		archiveDir: cfg.RetentionArchiveDir,
+		interval:   cfg.RetentionInterval,
+	}
+	s.policies = []*RetentionPolicy{
+		// 操作日志构成哈希链，只能通过链归档移除，始终归档
+		{Name: "operation_logs", Title: "操作日志", Retention: cfg.RetentionOperationLogs, Archive: true, purge: s.archiveOperationLogs},
+		// 验证码按过期时间计算保留时长
+		{Name: "verification_codes", Title: "邮箱验证码", Retention: cfg.RetentionVerificationCodes, Archive: archive["verification_codes"], purge: s.purgeTable("verification_codes", "expires_at")},
+		// 从 HFish 同步的攻击详情按请求时间、攻击 IP 按最后出现时间计算，无法解析时间的记录不清理
+		{Name: "attack_events", Title: "HFish 攻击数据", Retention: cfg.RetentionAttackEvents, Archive: archive["attack_events"], purge: s.purgeTables(
+			s.purgeTable("hfish_attack_details", "requested_at"),
+			s.purgeTable("hfish_attack_ips", "last_seen_at"),
+		)},
+	}
+	return s
+}
+
+// Interval 定时执行间隔，为 0 时不注册定时任务
+func (s *RetentionService) Interval() time.Duration {
+	return s.interval
+}
+
+func (s *RetentionService) Status() (*RetentionStatus, error) {
+	runs, err := s.repo.LatestRuns()
+	if err != nil {
+		return nil, internal("查询保留策略执行记录失败", err)
+	}
+	latest := make(map[string]*models.RetentionRun, len(runs))
+	for i := range runs {
+		latest[runs[i].Policy] = &runs[i]
+	}
+
+	status := &RetentionStatus{Interval: s.interval.String()}
+	for _, p := range s.policies {
+		status.Policies = append(status.Policies, RetentionPolicyStatus{
+			RetentionPolicy: p,
+			Retention:       p.Retention.String(),
+			Enabled:         p.Retention > 0,
+			LastRun:         latest[p.Name],
+		})
+	}
+	return status, nil
+}
+
+func (s *RetentionService) ListRuns(policy string, page, pageSize int) (*models.PaginatedResponse, error) {
+	if policy != "" && s.policy(policy) == nil {
+		return nil, notFound(fmt.Sprintf("保留策略 %q 不存在", policy))
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	runs, total, err := s.repo.ListRuns(policy, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, internal("查询保留策略执行记录失败", err)
+	}
+	return &models.PaginatedResponse{
+		List:     runs,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// Run 依次执行已启用的策略，name 不为空时只执行该策略
+// 单个策略失败不影响其他策略，每个策略的结果都保存为一条执行记录
+func (s *RetentionService) Run(name, source string, actor Actor) ([]models.RetentionRun, error) {
+	var policies []*RetentionPolicy
+	if name != "" {
+		p := s.policy(name)
+		if p == nil {
+			return nil, notFound(fmt.Sprintf("保留策略 %q 不存在", name))
+		}
+		if p.Retention <= 0 {
+			return nil, invalid(fmt.Sprintf("保留策略 %s 未启用", name))
+		}
+		policies = []*RetentionPolicy{p}
+	} else {
+		for _, p := range s.policies {
+			if p.Retention > 0 {
+				policies = append(policies, p)
+			}
+		}
+	}
+
+	if !s.mu.TryLock() {
+		return nil, invalid("保留策略正在执行，请稍后再试")
+	}
+	defer s.mu.Unlock()
+
+	runs := make([]models.RetentionRun, 0, len(policies))
+	for _, p := range policies {
+		runs = append(runs, s.runPolicy(p, source, actor))
+	}
+	return runs, nil
+}
+
+// RunScheduled 由定时任务调用，执行全部已启用的策略
+func (s *RetentionService) RunScheduled() {
+	runs, err := s.Run("", RetentionSourceSchedule, retentionActor)
+	if err != nil {
+		slog.Error("执行保留策略失败", "error", err)
+		return
+	}
+	for _, run := range runs {
+		if run.Status == 1 && run.Rows > 0 {
+			slog.Info("保留策略已清理过期数据", "policy", run.Policy, "rows", run.Rows, "cutoff", run.Cutoff.Format(time.DateTime))
+		}
+	}
+}
+
+func (s *RetentionService) policy(name string) *RetentionPolicy {
+	for _, p := range s.policies {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *RetentionService) runPolicy(p *RetentionPolicy, source string, actor Actor) models.RetentionRun {
+	run := models.RetentionRun{Policy: p.Name, Source: source, Status: 1, StartedAt: time.Now()}
+	run.Cutoff = run.StartedAt.Add(-p.Retention)
+	if actor.UserID != 0 {
+		run.CreatedBy = &actor.UserID
+	}
+
+	rows, file, err := p.purge(p, run.Cutoff, source, actor)
+	run.Rows, run.FinishedAt = rows, time.Now()
+	if file != "" {
+		run.ArchiveFile = &file
+	}
+	if err != nil {
+		slog.Error("执行保留策略失败", "policy", p.Name, "error", err)
+		msg := truncateRunError(err.Error())
+		run.Status, run.ErrorMsg = 0, &msg
+	}
+
+	if err := s.repo.CreateRun(&run); err != nil {
+		slog.Error("保存保留策略执行记录失败", "policy", run.Policy, "error", err)
+	}
+	return run
+}
+
+// archiveOperationLogs 通过哈希链归档操作日志，归档本身会写入审计记录
+func (s *RetentionService) archiveOperationLogs(_ *RetentionPolicy, cutoff time.Time, source string, actor Actor) (int64, string, error) {
+	archive, err := s.chain.Archive(cutoff, source, actor)
+	if err != nil || archive == nil {
+		return 0, "", err
+	}
+	return archive.Count, archive.File, nil
+}
+
+// purgeTable 按时间列清理普通表，策略开启归档时先写入归档文件再删除
+func (s *RetentionService) purgeTable(table, column string) purgeFunc {
+	return func(p *RetentionPolicy, cutoff time.Time, _ string, _ Actor) (int64, string, error) {
+		if !p.Archive {
+			rows, err := s.repo.Purge(table, column, cutoff, nil)
+			return rows, "", err
+		}
+
+		sink, err := newJSONLArchive(s.archiveDir, fmt.Sprintf("%s-%s.jsonl.gz", table, time.Now().Format("20060102-150405.000")))
+		if err != nil {
+			return 0, "", err
+		}
+		defer sink.cleanup()
+
+		rows, err := s.repo.Purge(table, column, cutoff, sink)
+		if err != nil {
+			// 删除失败时数据仍在表中，移除已写好的归档文件，避免下次重复归档
+			sink.remove()
+			return 0, "", err
+		}
+		if sink.count == 0 {
+			return rows, "", nil
+		}
+		return rows, sink.name, nil
+	}
+}
+
+// purgeTables 依次清理多张表，清理条数相加，归档文件名以逗号分隔；某张表失败时不再清理后面的表
+func (s *RetentionService) purgeTables(purges ...purgeFunc) purgeFunc {
+	return func(p *RetentionPolicy, cutoff time.Time, source string, actor Actor) (int64, string, error) {
+		var total int64
+		var files []string
+		for _, purge := range purges {
+			rows, file, err := purge(p, cutoff, source, actor)
+			total += rows
+			if file != "" {
+				files = append(files, file)
+			}
+			if err != nil {
+				return total, strings.Join(files, ","), err
+			}
+		}
+		return total, strings.Join(files, ","), nil
+	}
+}
+
+// jsonlArchive 先写入临时文件，Close 时落盘并重命名，没有数据时不生成文件
+type jsonlArchive struct {
+	dir, name string
+	tmp       *os.File
+	gz        *gzip.Writer
+	enc       *json.Encoder
+	count     int64
+	done      bool
+}
+
+func newJSONLArchive(dir, name string) (*jsonlArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".retention-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("创建归档文件失败: %w", err)
+	}
+	gz := gzip.NewWriter(tmp)
+	return &jsonlArchive{dir: dir, name: name, tmp: tmp, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+func (a *jsonlArchive) Write(row map[string]interface{}) error {
+	a.count++
+	return a.enc.Encode(row)
+}
+
+func (a *jsonlArchive) Close() error {
+	if a.count == 0 {
+		return nil
+	}
+	if err := a.gz.Close(); err != nil {
+		return err
+	}
+	if err := a.tmp.Sync(); err != nil {
+		return err
+	}
+	if err := a.tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(a.tmp.Name(), filepath.Join(a.dir, a.name)); err != nil {
+		return err
+	}
+	a.done = true
+	return nil
+}
+
+// cleanup 删除未完成的临时文件
+func (a *jsonlArchive) cleanup() {
+	a.tmp.Close()
+	os.Remove(a.tmp.Name())
+}
+
+func (a *jsonlArchive) remove() {
+	if a.done {
+		os.Remove(filepath.Join(a.dir, a.name))
+	}
+}
+
+// truncateRunError 错误信息不超过执行记录中 error_msg 列的长度
+func truncateRunError(msg string) string {
+	const maxLen = 500
+	if utf8.RuneCountInString(msg) <= maxLen {
+		return msg
+	}
+	return string([]rune(msg)[:maxLen])
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+type RoleService struct {
+	roles repositories.RoleRepository
+}
+
+func NewRoleService(roles repositories.RoleRepository) *RoleService {
+	return &RoleService{roles: roles}
+}
+
+func (s *RoleService) List(filter repositories.RoleFilter, page, pageSize int) (*models.PaginatedResponse, error) {
+	roles, total, err := s.roles.List(filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, internal("查询角色失败", err)
+	}
+
+	for i := range roles {
+		permissions, err := s.roles.GetPermissions(roles[i].ID)
+		if err != nil {
+			return nil, internal("查询角色权限失败", err)
+		}
+		roles[i].Permissions = permissions
+	}
+
+	return &models.PaginatedResponse{
+		List:     roles,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func (s *RoleService) ListActive() ([]models.Role, error) {
+	roles, err := s.roles.ListActive()
+	if err != nil {
+		return nil, internal("查询角色失败", err)
+	}
+	return roles, nil
+}
+
+func (s *RoleService) Get(id int) (*models.Role, error) {
+	role, err := s.findRole(id)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.roles.GetPermissions(role.ID)
+	if err != nil {
+		return nil, internal("查询角色权限失败", err)
+	}
+	role.Permissions = permissions
+
+	return role, nil
+}
+
+func (s *RoleService) Create(req models.CreateRoleRequest, operatorID int) (*models.Role, error) {
+	exists, err := s.roles.ExistsByNameOrCode(req.RoleName, req.RoleCode)
+	if err != nil {
+		return nil, internal("创建角色失败", err)
+	}
+	if exists {
+		return nil, invalid("角色名称或角色编码已存在")
+	}
+
+	status := 1
+	if req.Status != nil {
+		status = *req.Status
+	}
+
+	role := &models.Role{
+		RoleName:    req.RoleName,
+		RoleCode:    req.RoleCode,
+		Description: req.Description,
+		Status:      status,
+		CreatedBy:   &operatorID,
+	}
+
+	if err := s.roles.Create(role); err != nil {
+		return nil, internal("创建角色失败", err)
+	}
+
+	if len(req.PermissionIDs) > 0 {
+		if err := s.roles.AssignPermissions(role.ID, req.PermissionIDs, &operatorID); err != nil {
+			return nil, internal("分配权限失败", err)
+		}
+	}
+
+	return role, nil
+}
+
+func (s *RoleService) Update(id int, req models.UpdateRoleRequest, operatorID int) error {
+	if _, err := s.findRole(id); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"role_name":   req.RoleName,
+		"description": req.Description,
+		"updated_by":  operatorID,
+	}
+
+	if req.Status != nil {
+		updates["status"] = *req.Status
+	}
+
+	if err := s.roles.Update(id, updates); err != nil {
+		return internal("更新角色失败", err)
+	}
+
+	if req.PermissionIDs != nil {
+		if err := s.roles.ReplacePermissions(id, *req.PermissionIDs, &operatorID); err != nil {
+			return internal("更新角色权限失败", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *RoleService) Delete(id int) error {
+	count, err := s.roles.CountUsers(id)
+	if err != nil {
+		return internal("删除角色失败", err)
+	}
+	if count > 0 {
+		return invalid("该角色下还有用户，无法删除")
+	}
+
+	if _, err := s.findRole(id); err != nil {
+		return err
+	}
+
+	if err := s.roles.Delete(id); err != nil {
+		return internal("删除角色失败", err)
+	}
+
+	return nil
+}
+
+func (s *RoleService) findRole(id int) (*models.Role, error) {
+	role, err := s.roles.FindByID(id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, notFound("角色不存在")
+	}
+	if err != nil {
+		return nil, internal("查询角色失败", err)
+	}
+	return role, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"superhoneypotguard/secrets"
+)
+
+const secretsUsage = `usage: secrets <command>
+
+commands:
+  keygen   print a new random master key for SECRETS_KEY
+  status   count the encrypted values per field and key id
+  rotate   re-encrypt values under the current SECRETS_KEY (old keys must be listed in SECRETS_OLD_KEYS)`
+
+// RunSecretsCLI 执行 secrets 子命令，有值无法重新加密时返回错误
+func RunSecretsCLI(args []string, open func() (*SecretService, error), out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%s", secretsUsage)
+	}
+	switch args[0] {
+	case "keygen":
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, key)
+		return nil
+	case "status", "rotate":
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], secretsUsage)
+	}
+
+	s, err := open()
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "status" {
+		status, err := s.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Current key %s\n", s.KeyID())
+		stale := 0
+		for _, field := range status {
+			keys := make([]string, 0, len(field.Keys))
+			for id, n := range field.Keys {
+				keys = append(keys, fmt.Sprintf("%s=%d", id, n))
+				if id != s.KeyID() {
+					stale += n
+				}
+			}
+			sort.Strings(keys)
+			if len(keys) == 0 {
+				keys = append(keys, "empty")
+			}
+			fmt.Fprintf(out, "  %-32s %s\n", field.Field, strings.Join(keys, " "))
+		}
+		if stale > 0 {
+			fmt.Fprintf(out, "%d value(s) not under the current key, run \"secrets rotate\"\n", stale)
+			return nil
+		}
+		fmt.Fprintln(out, "OK")
+		return nil
+	}
+
+	results, err := s.Rotate()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range results {
+		fmt.Fprintf(out, "  %-32s re-encrypted %d, encrypted %d plaintext\n", r.Field, r.Reencrypted, r.Encrypted)
+		for _, f := range r.Failed {
+			fmt.Fprintf(out, "    FAILED %s\n", f)
+		}
+		failed += len(r.Failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d value(s) could not be re-encrypted", failed)
+	}
+	fmt.Fprintf(out, "OK, all values are under key %s\n", s.KeyID())
+	return nil
+}
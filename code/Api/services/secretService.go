@@ -0,0 +1,157 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/secrets"
+)
+
+// secretPlaintext 状态统计中代表尚未加密的值
+const secretPlaintext = "plaintext"
+
+// secretField 一个加密保存的字段，Label 同时作为加密时的附加数据
+type secretField struct {
+	Label  string
+	Column repositories.SecretColumn
+}
+
+// secretFields 数据库中全部加密保存的字段，新增敏感字段时需在此登记，才会参与重新加密
+func secretFields() []secretField {
+	fields := []secretField{
+		{Label: hfishAPIKeyLabel, Column: repositories.SecretColumn{Table: "hfish_instances", KeyColumn: "id", Column: "api_key"}},
+		{Label: hfishClientKeyLabel, Column: repositories.SecretColumn{Table: "hfish_instances", KeyColumn: "id", Column: "tls_client_key"}},
+	}
+	for _, def := range settingDefinitions {
+		if !def.Secret {
+			continue
+		}
+		fields = append(fields, secretField{
+			Label: settingSecretLabel(def.Key),
+			Column: repositories.SecretColumn{
+				Table: "system_settings", KeyColumn: "setting_key", Column: "setting_value",
+				Where: "setting_key = ?", Args: []interface{}{def.Key},
+			},
+		})
+	}
+	return fields
+}
+
+// settingSecretLabel 敏感系统设置加密时的附加数据
+func settingSecretLabel(key string) string {
+	return "system_settings." + key
+}
+
+// SecretFieldStatus 一个字段中各密钥加密的值的数量
+type SecretFieldStatus struct {
+	Field string
+	// Keys 按密钥 ID 统计，尚未加密的明文记为 plaintext
+	Keys map[string]int
+}
+
+// SecretRotation 一个字段的重新加密结果
+type SecretRotation struct {
+	Field string
+	// Reencrypted 由旧密钥改为主密钥加密的数量
+	Reencrypted int
+	// Encrypted 加密存储之前写入、本次加密的明文数量
+	Encrypted int
+	// Failed 无法处理的行，格式为 "主键: 原因"
+	Failed []string
+}
+
+// SecretService 使用主密钥加解密数据库中的敏感字段（API Key、私钥、SMTP 密码等），
+// 并在更换主密钥后把旧密钥加密的值改用主密钥重新加密。解密后的值只在服务内部使用，不通过接口返回。
+type SecretService struct {
+	repo   repositories.SecretRepository
+	cipher *secrets.Cipher
+}
+
+func NewSecretService(repo repositories.SecretRepository, cfg *config.Config) (*SecretService, error) {
+	cipher, err := secrets.New(cfg.SecretsKey, config.SplitList(cfg.SecretsOldKeys))
+	if err != nil {
+		return nil, fmt.Errorf("初始化密钥失败: %w", err)
+	}
+	return &SecretService{repo: repo, cipher: cipher}, nil
+}
+
+// KeyID 当前主密钥的标识
+func (s *SecretService) KeyID() string {
+	return s.cipher.KeyID()
+}
+
+func (s *SecretService) Encrypt(label, plaintext string) (string, error) {
+	return s.cipher.Encrypt(label, plaintext)
+}
+
+func (s *SecretService) Decrypt(label, value string) (string, error) {
+	return s.cipher.Decrypt(label, value)
+}
+
+// Status 统计每个字段中各密钥加密的值的数量，用于确认旧密钥是否仍在使用
+func (s *SecretService) Status() ([]SecretFieldStatus, error) {
+	var out []SecretFieldStatus
+	for _, field := range secretFields() {
+		values, err := s.repo.List(field.Column)
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", field.Label, err)
+		}
+		status := SecretFieldStatus{Field: field.Label, Keys: make(map[string]int)}
+		for _, v := range values {
+			keyID, err := secrets.KeyIDOfValue(v.Value)
+			if err != nil {
+				keyID = secretPlaintext
+			}
+			status.Keys[keyID]++
+		}
+		out = append(out, status)
+	}
+	return out, nil
+}
+
+// Rotate 把旧密钥加密的值和加密存储之前写入的明文改用主密钥加密。
+// 每行只在值未被并发修改时写入；单行失败不影响其他行，记录在结果中。
+func (s *SecretService) Rotate() ([]SecretRotation, error) {
+	var out []SecretRotation
+	for _, field := range secretFields() {
+		values, err := s.repo.List(field.Column)
+		if err != nil {
+			return out, fmt.Errorf("读取 %s 失败: %w", field.Label, err)
+		}
+
+		result := SecretRotation{Field: field.Label}
+		for _, v := range values {
+			sealed, changed, plaintext, err := s.reseal(field.Label, v.Value)
+			if err == nil && changed {
+				var ok bool
+				ok, err = s.repo.Replace(field.Column, v.Key, v.Value, sealed)
+				if err == nil && !ok {
+					err = fmt.Errorf("值已被修改，请重新执行")
+				}
+			}
+			switch {
+			case err != nil:
+				result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", v.Key, err))
+			case changed && plaintext:
+				result.Encrypted++
+			case changed:
+				result.Reencrypted++
+			}
+		}
+		sort.Strings(result.Failed)
+		out = append(out, result)
+	}
+	return out, nil
+}
+
+// reseal 返回用主密钥加密后的值、是否需要写回以及原值是否为明文
+func (s *SecretService) reseal(label, value string) (string, bool, bool, error) {
+	if !secrets.IsEncrypted(value) {
+		sealed, err := s.cipher.Encrypt(label, value)
+		return sealed, err == nil, true, err
+	}
+	sealed, changed, err := s.cipher.Reencrypt(label, value)
+	return sealed, changed, false, err
+}
@@ -0,0 +1,472 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/secrets"
+)
+
+// 设置值类型
+const (
+	SettingString   = "string"
+	SettingInt      = "int"
+	SettingDuration = "duration"
+)
+
+// redactedSetting 列表中代替敏感设置值的文本；更新时原样提交表示不修改
+const redactedSetting = "******"
+
+// settingDefinition 描述一个可在运行时修改的设置
+type settingDefinition struct {
+	Key         string
+	Type        string
+	Secret      bool
+	Description string
+	// Default 从启动配置中取默认值
+	Default func(cfg *config.Config) string
+	// Validate 在类型解析之后做取值范围检查，可为 nil
+	Validate func(value string) error
+}
+
+var settingDefinitions = []settingDefinition{
+	{
+		Key: "rate_limit_window", Type: SettingDuration, Description: "全局限流的统计窗口，如 15m",
+		Default: func(cfg *config.Config) string { return cfg.RateLimitWindow.String() },
+		// 不短于 1s，最大请求数取上限时补充间隔仍不小于 1µs
+		Validate: minDuration(time.Second),
+	},
+	{
+		Key: "rate_limit_max_requests", Type: SettingInt, Description: "每个 IP 在一个窗口内允许的最大请求数",
+		Default:  func(cfg *config.Config) string { return strconv.Itoa(cfg.RateLimitMax) },
+		Validate: intRange(1, 1000000),
+	},
+	{
+		Key: "smtp_host", Type: SettingString, Description: "SMTP 服务器地址",
+		Default:  func(cfg *config.Config) string { return cfg.SMTPHost },
+		Validate: notEmpty,
+	},
+	{
+		Key: "smtp_port", Type: SettingInt, Description: "SMTP 端口，465 使用 SSL，其余端口使用 STARTTLS",
+		Default:  func(cfg *config.Config) string { return cfg.SMTPPort },
+		Validate: intRange(1, 65535),
+	},
+	{
+		Key: "smtp_user", Type: SettingString, Description: "SMTP 用户名，同时作为发件人地址",
+		Default: func(cfg *config.Config) string { return cfg.SMTPUser },
+	},
+	{
+		Key: "smtp_password", Type: SettingString, Secret: true, Description: "SMTP 密码或授权码",
+		Default: func(cfg *config.Config) string { return cfg.SMTPPassword },
+	},
+}
+
+func findSettingDefinition(key string) (settingDefinition, bool) {
+	for _, def := range settingDefinitions {
+		if def.Key == key {
+			return def, true
+		}
+	}
+	return settingDefinition{}, false
+}
+
+// SMTPSettings SMTP 发信配置
+type SMTPSettings struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+}
+
+// RuntimeSettings 当前生效的运行时设置
+type RuntimeSettings struct {
+	RateLimitWindow time.Duration
+	RateLimitMax    int
+	SMTP            SMTPSettings
+}
+
+// SettingItem 设置列表中的一项
+type SettingItem struct {
+	Key          string     `json:"key"`
+	Value        string     `json:"value"`
+	DefaultValue string     `json:"defaultValue"`
+	Type         string     `json:"type"`
+	Secret       bool       `json:"secret"`
+	Description  string     `json:"description"`
+	Overridden   bool       `json:"overridden"`
+	UpdatedAt    *time.Time `json:"updatedAt"`
+	UpdatedBy    *int       `json:"updatedBy"`
+}
+
+// SettingService 管理存储在数据库中的系统设置，并在变更时通知订阅者；
+// 敏感设置（如 SMTP 密码）加密后保存，内存中保留明文供发信等内部使用
+type SettingService struct {
+	settings repositories.SettingRepository
+	audit    *AuditService
+	secrets  *SecretService
+	cfg      *config.Config
+
+	// writeMu 串行化写操作，保证订阅者按顺序收到变更
+	writeMu   sync.Mutex
+	mu        sync.RWMutex
+	stored    map[string]models.SystemSetting
+	listeners []func(RuntimeSettings)
+}
+
+func NewSettingService(settings repositories.SettingRepository, audit *AuditService, secrets *SecretService, cfg *config.Config) *SettingService {
+	return &SettingService{
+		settings: settings,
+		audit:    audit,
+		secrets:  secrets,
+		cfg:      cfg,
+		stored:   make(map[string]models.SystemSetting),
+	}
+}
+
+// Load 从数据库加载已保存的设置，无效的值会被忽略并回退到默认值
+func (s *SettingService) Load() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	rows, err := s.settings.List()
+	if err != nil {
+		return err
+	}
+
+	stored := make(map[string]models.SystemSetting, len(rows))
+	for _, row := range rows {
+		def, ok := findSettingDefinition(row.Key)
+		if !ok {
+			slog.Warn("忽略未知的系统设置", "setting", row.Key)
+			continue
+		}
+		// 加密存储之前写入的明文照常使用，启动时会被重新加密
+		if def.Secret && secrets.IsEncrypted(row.Value) {
+			plain, err := s.secrets.Decrypt(settingSecretLabel(row.Key), row.Value)
+			if err != nil {
+				slog.Error("解密系统设置失败，使用启动配置", "setting", row.Key, "error", err)
+				continue
+			}
+			row.Value = plain
+		}
+		if _, err := normalizeSetting(def, row.Value); err != nil {
+			slog.Warn("忽略无效的系统设置", "setting", row.Key, "error", err)
+			continue
+		}
+		stored[row.Key] = row
+	}
+
+	s.mu.Lock()
+	s.stored = stored
+	s.mu.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// Subscribe 注册设置变更回调，注册时会立即以当前设置调用一次
+func (s *SettingService) Subscribe(fn func(RuntimeSettings)) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.mu.Unlock()
+
+	fn(s.Current())
+}
+
+// Current 返回当前生效的设置
+func (s *SettingService) Current() RuntimeSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	window, _ := time.ParseDuration(s.valueLocked("rate_limit_window"))
+	maxRequests, _ := strconv.Atoi(s.valueLocked("rate_limit_max_requests"))
+	smtpPort, _ := strconv.Atoi(s.valueLocked("smtp_port"))
+
+	return RuntimeSettings{
+		RateLimitWindow: window,
+		RateLimitMax:    maxRequests,
+		SMTP: SMTPSettings{
+			Host:     s.valueLocked("smtp_host"),
+			Port:     smtpPort,
+			User:     s.valueLocked("smtp_user"),
+			Password: s.valueLocked("smtp_password"),
+		},
+	}
+}
+
+func (s *SettingService) List() []SettingItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]SettingItem, 0, len(settingDefinitions))
+	for _, def := range settingDefinitions {
+		item := SettingItem{
+			Key:          def.Key,
+			Value:        s.valueLocked(def.Key),
+			DefaultValue: def.Default(s.cfg),
+			Type:         def.Type,
+			Secret:       def.Secret,
+			Description:  def.Description,
+		}
+		if row, ok := s.stored[def.Key]; ok {
+			updatedAt := row.UpdatedAt
+			item.Overridden = true
+			item.UpdatedAt = &updatedAt
+			item.UpdatedBy = row.UpdatedBy
+		}
+		if def.Secret {
+			item.Value = redactSetting(item.Value)
+			item.DefaultValue = redactSetting(item.DefaultValue)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// Update 校验并保存一组设置，全部合法时才会写入；每个实际变化的键都会记录审计日志
+func (s *SettingService) Update(values map[string]interface{}, actor Actor) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	type change struct {
+		def      settingDefinition
+		oldValue string
+		newValue string
+	}
+
+	s.mu.RLock()
+	var changes []change
+	var problems []string
+	for _, key := range keys {
+		def, ok := findSettingDefinition(key)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: 未知的设置项", key))
+			continue
+		}
+
+		raw, err := settingString(values[key])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		if def.Secret && raw == redactedSetting {
+			continue
+		}
+
+		value, err := normalizeSetting(def, raw)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+
+		if old := s.valueLocked(key); old != value {
+			changes = append(changes, change{def: def, oldValue: old, newValue: value})
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(problems) > 0 {
+		return invalid("设置校验失败: " + strings.Join(problems, "; "))
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	rows := make([]models.SystemSetting, 0, len(changes))
+	for _, c := range changes {
+		operatorID := actor.UserID
+		value := c.newValue
+		if c.def.Secret {
+			sealed, err := s.secrets.Encrypt(settingSecretLabel(c.def.Key), value)
+			if err != nil {
+				return internal("加密系统设置失败", err)
+			}
+			value = sealed
+		}
+		rows = append(rows, models.SystemSetting{
+			Key:       c.def.Key,
+			Value:     value,
+			UpdatedAt: now,
+			UpdatedBy: &operatorID,
+		})
+	}
+	if err := s.settings.Save(rows); err != nil {
+		return internal("保存系统设置失败", err)
+	}
+
+	s.mu.Lock()
+	for i, row := range rows {
+		row.Value = changes[i].newValue
+		s.stored[row.Key] = row
+	}
+	s.mu.Unlock()
+
+	for _, c := range changes {
+		s.recordChange(actor, "setting.update", "修改系统设置", "PUT", c.def, c.oldValue, c.newValue)
+	}
+
+	s.notify()
+	return nil
+}
+
+// Reset 删除已保存的值，使设置恢复为启动配置中的默认值
+func (s *SettingService) Reset(key string, actor Actor) error {
+	def, ok := findSettingDefinition(key)
+	if !ok {
+		return notFound("设置项不存在")
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.RLock()
+	_, overridden := s.stored[key]
+	old := s.valueLocked(key)
+	s.mu.RUnlock()
+
+	if !overridden {
+		return nil
+	}
+
+	if err := s.settings.Delete(key); err != nil {
+		return internal("重置系统设置失败", err)
+	}
+
+	s.mu.Lock()
+	delete(s.stored, key)
+	s.mu.Unlock()
+
+	s.recordChange(actor, "setting.reset", "重置系统设置", "DELETE", def, old, def.Default(s.cfg))
+
+	s.notify()
+	return nil
+}
+
+// valueLocked 返回当前生效的值，调用方需持有读锁
+func (s *SettingService) valueLocked(key string) string {
+	if row, ok := s.stored[key]; ok {
+		return row.Value
+	}
+	def, _ := findSettingDefinition(key)
+	return def.Default(s.cfg)
+}
+
+func (s *SettingService) notify() {
+	s.mu.RLock()
+	listeners := append([]func(RuntimeSettings){}, s.listeners...)
+	s.mu.RUnlock()
+
+	current := s.Current()
+	for _, fn := range listeners {
+		fn(current)
+	}
+}
+
+// recordChange 每个设置项的变更单独写入一条审计记录
+func (s *SettingService) recordChange(actor Actor, action, verb, method string, def settingDefinition, oldValue, newValue string) {
+	if def.Secret {
+		oldValue, newValue = redactSetting(oldValue), redactSetting(newValue)
+	}
+	event := models.AuditEvent{
+		Action:      action,
+		TargetType:  "setting",
+		TargetID:    def.Key,
+		Description: verb + " " + def.Key,
+		Changes: map[string]models.AuditChange{
+			"value": {Before: oldValue, After: newValue},
+		},
+	}
+	s.audit.Record(actor, event, method, "/api/system/settings/"+def.Key, map[string]string{
+		"key":      def.Key,
+		"oldValue": oldValue,
+		"newValue": newValue,
+	})
+}
+
+func redactSetting(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedSetting
+}
+
+// settingString 将 JSON 中的标量转换为字符串
+func settingString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("只支持字符串或数字")
+	}
+}
+
+// normalizeSetting 按类型解析并返回规范化后的字符串
+func normalizeSetting(def settingDefinition, raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+
+	switch def.Type {
+	case SettingInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("无效的整数 %q", raw)
+		}
+		value = strconv.Itoa(n)
+	case SettingDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return "", fmt.Errorf("无效的时长 %q（示例: 30s、15m、1h）", raw)
+		}
+		value = d.String()
+	}
+
+	if def.Validate != nil {
+		if err := def.Validate(value); err != nil {
+			return "", err
+		}
+	}
+	return value, nil
+}
+
+func minDuration(min time.Duration) func(string) error {
+	return func(value string) error {
+		if d, _ := time.ParseDuration(value); d < min {
+			return fmt.Errorf("不能小于 %s", min)
+		}
+		return nil
+	}
+}
+
+func intRange(min, max int) func(string) error {
+	return func(value string) error {
+		if n, _ := strconv.Atoi(value); n < min || n > max {
+			return fmt.Errorf("必须在 %d 到 %d 之间", min, max)
+		}
+		return nil
+	}
+}
+
+func notEmpty(value string) error {
+	if value == "" {
+		return fmt.Errorf("不能为空")
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/utils"
+)
+
+type UserService struct {
+	users repositories.UserRepository
+}
+
+func NewUserService(users repositories.UserRepository) *UserService {
+	return &UserService{users: users}
+}
+
+func (s *UserService) List(filter repositories.UserFilter, page, pageSize int) (*models.PaginatedResponse, error) {
+	users, total, err := s.users.List(filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, internal("查询用户失败", err)
+	}
+
+	for i := range users {
+		roles, err := s.users.GetRoles(users[i].ID, false)
+		if err != nil {
+			return nil, internal("查询用户角色失败", err)
+		}
+		users[i].Roles = roles
+	}
+
+	return &models.PaginatedResponse{
+		List:     users,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func (s *UserService) Get(id int) (*models.User, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.users.GetRoles(user.ID, false)
+	if err != nil {
+		return nil, internal("查询用户角色失败", err)
+	}
+	user.Roles = roles
+
+	return user, nil
+}
+
+func (s *UserService) Create(req models.CreateUserRequest, operatorID int) (*models.User, error) {
+	exists, err := s.users.ExistsByUsernameOrEmail(req.Username, req.Email)
+	if err != nil {
+		return nil, internal("创建用户失败", err)
+	}
+	if exists {
+		return nil, invalid("用户名或邮箱已存在")
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, internal("密码加密失败", err)
+	}
+
+	status := 1
+	if req.Status != nil {
+		status = *req.Status
+	}
+
+	user := &models.User{
+		Username:  req.Username,
+		Password:  hashedPassword,
+		Email:     req.Email,
+		Phone:     req.Phone,
+		RealName:  req.RealName,
+		Status:    status,
+		CreatedBy: &operatorID,
+	}
+
+	if err := s.users.Create(user); err != nil {
+		return nil, internal("创建用户失败", err)
+	}
+
+	if len(req.RoleIDs) > 0 {
+		if err := s.users.AssignRoles(user.ID, req.RoleIDs, &operatorID); err != nil {
+			return nil, internal("分配角色失败", err)
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserService) Update(id int, req models.UpdateUserRequest, operatorID int) error {
+	if _, err := s.findUser(id); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"email":      req.Email,
+		"phone":      req.Phone,
+		"real_name":  req.RealName,
+		"updated_by": operatorID,
+	}
+
+	if req.Status != nil {
+		updates["status"] = *req.Status
+	}
+
+	if err := s.users.Update(id, updates); err != nil {
+		return internal("更新用户失败", err)
+	}
+
+	if req.RoleIDs != nil {
+		if err := s.users.ReplaceRoles(id, *req.RoleIDs, &operatorID); err != nil {
+			return internal("更新用户角色失败", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *UserService) Delete(id int, operatorID int) error {
+	if id == operatorID {
+		return invalid("不能删除当前登录用户")
+	}
+
+	if _, err := s.findUser(id); err != nil {
+		return err
+	}
+
+	if err := s.users.Delete(id); err != nil {
+		return internal("删除用户失败", err)
+	}
+
+	return nil
+}
+
+func (s *UserService) UpdateStatus(id int, status int, operatorID int) error {
+	if id == operatorID {
+		return invalid("不能修改当前登录用户状态")
+	}
+
+	if _, err := s.findUser(id); err != nil {
+		return err
+	}
+
+	if err := s.users.Update(id, map[string]interface{}{
+		"status":     status,
+		"updated_by": operatorID,
+	}); err != nil {
+		return internal("更新用户状态失败", err)
+	}
+
+	return nil
+}
+
+func (s *UserService) ResetPassword(id int, newPassword string, operatorID int) error {
+	if _, err := s.findUser(id); err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return internal("密码加密失败", err)
+	}
+
+	if err := s.users.Update(id, map[string]interface{}{
+		"password":   hashedPassword,
+		"updated_by": operatorID,
+	}); err != nil {
+		return internal("重置密码失败", err)
+	}
+
+	return nil
+}
+
+// HasPermission 供权限中间件使用，判断用户当前是否拥有指定权限
+func (s *UserService) HasPermission(ctx context.Context, userID int, permissionCode string) (bool, error) {
+	return s.users.HasPermission(ctx, userID, permissionCode)
+}
+
+func (s *UserService) findUser(id int) (*models.User, error) {
+	user, err := s.users.FindByID(id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, notFound("用户不存在")
+	}
+	if err != nil {
+		return nil, internal("查询用户失败", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,301 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/auditchain"
+	"superhoneypotguard/config"
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditCLI 以命令行方式对测试数据库执行 audit 子命令，返回输出和错误
+func (e *testEnv) auditCLI(args ...string) (string, error) {
+	e.t.Helper()
+
+	middleware.FlushLogs()
+	open := func() (auditchain.Chain, error) {
+		logs := repositories.NewLogRepository(e.db)
+		return services.NewAuditChainService(logs, services.NewAuditService(logs), config.AppConfig)
+	}
+	var out bytes.Buffer
+	err := auditchain.RunCLI(args, open, &out)
+	return out.String(), err
+}
+
+func (e *testEnv) verifyChain(token string) auditchain.Report {
+	e.t.Helper()
+
+	middleware.FlushLogs()
+	var report auditchain.Report
+	e.mustOK(http.MethodGet, "/api/log/verify", token, nil, &report)
+	return report
+}
+
+func hasIssue(report auditchain.Report, kind string) bool {
+	for _, issue := range report.Issues {
+		if issue.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	env.createUser(admin, "ivan", "ivan1234", env.roleID(admin, "user"))
+	env.createUser(admin, "judy", "judy1234", env.roleID(admin, "user"))
+
+	report := env.verifyChain(admin)
+	if !report.Valid || report.FirstSeq != 1 || report.Checked < 5 || report.LastSeq != report.Checked {
+		t.Fatalf("expected an intact chain, got %+v", report)
+	}
+
+	var target models.OperationLog
+	if err := env.db.Where("action = ?", "user.create").Order("seq").First(&target).Error; err != nil {
+		t.Fatalf("find user creation log: %v", err)
+	}
+
+	// 修改内容
+	env.db.Model(&models.OperationLog{}).Where("id = ?", target.ID).Update("operation", "查看用户")
+	report = env.verifyChain(admin)
+	if report.Valid || !hasIssue(report, auditchain.IssueModified) || report.Issues[0].ID != target.ID {
+		t.Fatalf("expected modification of log %d to be detected, got %+v", target.ID, report)
+	}
+	env.db.Model(&models.OperationLog{}).Where("id = ?", target.ID).Update("operation", target.Operation)
+	if report = env.verifyChain(admin); !report.Valid {
+		t.Fatalf("expected chain to be intact after restoring the log, got %+v", report.Issues)
+	}
+
+	// 删除中间的日志
+	env.db.Delete(&models.OperationLog{}, target.ID)
+	report = env.verifyChain(admin)
+	if report.Valid || !hasIssue(report, auditchain.IssueGap) || report.Issues[0].Seq != target.Seq+1 {
+		t.Fatalf("expected gap after seq %d, got %+v", target.Seq, report)
+	}
+
+	// 删除和清空接口已移除，对应的权限也不再存在
+	env.expectStatus(http.StatusNotFound, http.MethodDelete, "/api/log/clear", admin, nil)
+	var permissions []struct {
+		PermissionCode string `json:"permissionCode"`
+	}
+	env.mustOK(http.MethodGet, "/api/permission/all", admin, nil, &permissions)
+	for _, p := range permissions {
+		if p.PermissionCode == "log:delete" || p.PermissionCode == "log:clear" {
+			t.Fatalf("expected %s to be removed", p.PermissionCode)
+		}
+	}
+
+	env.createUser(admin, "mallory", "mallory123", env.roleID(admin, "user"))
+	env.expectStatus(http.StatusForbidden, http.MethodGet, "/api/log/verify", env.login("mallory", "mallory123"), nil)
+}
+
+func TestAuditChainSecondPrecisionColumn(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	env.createUser(admin, "oscar", "oscar1234", env.roleID(admin, "user"))
+	env.createUser(admin, "peggy", "peggy1234", env.roleID(admin, "user"))
+	middleware.FlushLogs()
+
+	// 模拟 created_at 为 DATETIME（秒精度）的 MySQL 表：写入时四舍五入到秒
+	var logs []models.OperationLog
+	if err := env.db.Order("seq").Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range logs {
+		rounded := entry.CreatedAt.Round(time.Second)
+		if err := env.db.Model(&models.OperationLog{}).Where("id = ?", entry.ID).Update("created_at", rounded).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if report := env.verifyChain(admin); !report.Valid || report.Checked < int64(len(logs)) {
+		t.Fatalf("expected the chain to survive a second-precision column, got %+v", report)
+	}
+}
+
+func TestAuditChainCheckpoints(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	env.createUser(admin, "kate", "kate1234", env.roleID(admin, "user"))
+
+	out, err := env.auditCLI("checkpoint")
+	if err != nil || !strings.HasPrefix(out, "Checkpoint seq=") {
+		t.Fatalf("write checkpoint: %v\n%s", err, out)
+	}
+	if out, _ := env.auditCLI("checkpoint"); !strings.Contains(out, "unchanged") {
+		t.Fatalf("expected no new checkpoint when the head is unchanged, got %q", out)
+	}
+	env.createUser(admin, "liam", "liam1234", env.roleID(admin, "user"))
+	if out, err := env.auditCLI("verify"); err != nil || !strings.Contains(out, "matched 1") {
+		t.Fatalf("expected chain and checkpoint to verify: %v\n%s", err, out)
+	}
+
+	// 用同样的算法重算整条链可以骗过逐条校验，但与签名检查点对不上
+	var logs []models.OperationLog
+	env.db.Order("seq").Find(&logs)
+	logs[0].Operation = "伪造的日志"
+	auditchain.Link(auditchain.Start, logs)
+	for _, entry := range logs {
+		env.db.Model(&models.OperationLog{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+			"operation": entry.Operation, "prev_hash": entry.PrevHash, "hash": entry.Hash,
+		})
+	}
+	out, err = env.auditCLI("verify")
+	if err == nil || !strings.Contains(out, auditchain.IssueCheckpointMismatch) {
+		t.Fatalf("expected rewritten chain to fail verification: %v\n%s", err, out)
+	}
+
+	// 删除链尾的日志
+	env.db.Order("seq").Find(&logs)
+	cp, err := auditchain.ReadCheckpoints(config.AppConfig.AuditCheckpointFile)
+	if err != nil || len(cp) != 1 {
+		t.Fatalf("read checkpoints: %v %+v", err, cp)
+	}
+	env.db.Where("seq >= ?", cp[0].Seq).Delete(&models.OperationLog{})
+	out, err = env.auditCLI("verify")
+	if err == nil || !strings.Contains(out, auditchain.IssueTruncated) {
+		t.Fatalf("expected truncated chain to fail verification: %v\n%s", err, out)
+	}
+
+	// 其他密钥签名的检查点不被接受
+	other := newSigningKey(t)
+	auditchain.AppendCheckpoint(config.AppConfig.AuditCheckpointFile, auditchain.Sign(other, auditchain.Head{Seq: 1, Hash: logs[0].Hash}, time.Now()))
+	if out, _ := env.auditCLI("verify"); !strings.Contains(out, auditchain.IssueCheckpointInvalid) {
+		t.Fatalf("expected foreign checkpoint to be rejected:\n%s", out)
+	}
+}
+
+func newSigningKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	seed, _, err := auditchain.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := auditchain.SigningKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAuditCheckpointKeyRotation(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	env.createUser(admin, "rita", "rita1234", env.roleID(admin, "user"))
+	if out, err := env.auditCLI("checkpoint"); err != nil {
+		t.Fatalf("write checkpoint: %v\n%s", err, out)
+	}
+	oldKey, _ := auditchain.SigningKey(testSigningKey)
+	oldPub := base64.StdEncoding.EncodeToString(oldKey.Public().(ed25519.PublicKey))
+
+	// 更换签名密钥（以及 JWT 密钥）后，旧检查点需要把旧公钥列为受信任才能通过校验
+	newSeed, _, _ := auditchain.GenerateKey()
+	config.AppConfig.AuditSigningKey = newSeed
+	config.AppConfig.JWTSecret = "rotated-jwt-secret-rotated-jwt-secret"
+	if out, _ := env.auditCLI("verify"); !strings.Contains(out, auditchain.IssueCheckpointInvalid) {
+		t.Fatalf("expected the old checkpoint to be untrusted:\n%s", out)
+	}
+	config.AppConfig.AuditTrustedKeys = oldPub
+	if out, err := env.auditCLI("verify"); err != nil || !strings.Contains(out, "matched 1") {
+		t.Fatalf("expected the old checkpoint to verify with its trusted key: %v\n%s", err, out)
+	}
+
+	// 签名密钥必须单独配置
+	cfg := config.Default()
+	cfg.JWTSecret = "integration-test-secret"
+	cfg.AuditTrustedKeys = "not-a-key"
+	err := cfg.Validate()
+	for _, want := range []string{"audit_signing_key", "audit_trusted_keys"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s validation error, got %v", want, err)
+		}
+	}
+}
+
+func TestAuditLogArchive(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	env.createUser(admin, "nina", "nina1234", env.roleID(admin, "user"))
+	middleware.FlushLogs()
+
+	// 不能归档最短保留期限内的日志
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/log/archive", admin, gin.H{
+		"before": time.Now().Add(time.Hour).Format(time.RFC3339Nano),
+	})
+
+	// 日志时间按秒保存，截止时间取下一个整秒，之后写入的日志不会落在截止时间之前
+	cutoff := time.Now().Truncate(time.Second).Add(time.Second)
+	time.Sleep(time.Until(cutoff) + 5*time.Millisecond)
+
+	// 归档需要单独的 log:archive 权限，只能查看日志的用户不能归档
+	env.createUser(admin, "olga", "olga1234", env.createRole(admin, "log_viewer", "log:manage"))
+	viewer := env.login("olga", "olga1234")
+	env.mustOK(http.MethodGet, "/api/log/archives", viewer, nil, nil)
+	env.expectStatus(http.StatusForbidden, http.MethodPost, "/api/log/archive", viewer, gin.H{"before": cutoff.Format(time.RFC3339Nano)})
+
+	var archive models.AuditArchive
+	env.mustOK(http.MethodPost, "/api/log/archive", admin, gin.H{"before": cutoff.Format(time.RFC3339Nano)}, &archive)
+	if archive.FromSeq != 1 || archive.Count < 3 || archive.ToSeq != archive.Count || archive.LastHash == "" {
+		t.Fatalf("unexpected archive: %+v", archive)
+	}
+
+	// 归档文件包含全部被归档的日志及其哈希
+	f, err := os.Open(filepath.Join(config.AppConfig.AuditArchiveDir, archive.File))
+	if err != nil {
+		t.Fatalf("open archive file: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("read archive file: %v", err)
+	}
+	var lines int64
+	for scanner := bufio.NewScanner(gz); scanner.Scan(); lines++ {
+		if !strings.Contains(scanner.Text(), `"hash":"`) {
+			t.Fatalf("archived log without hash: %s", scanner.Text())
+		}
+	}
+	if lines != archive.Count {
+		t.Fatalf("expected %d archived logs in file, got %d", archive.Count, lines)
+	}
+
+	// 归档本身写入日志，剩余的链从归档锚点继续并通过校验
+	var page logPage
+	env.mustOK(http.MethodGet, "/api/log/list?action=log.archive", admin, nil, &page)
+	if page.Total != 1 || !strings.HasPrefix(page.List[0].Operation, "归档操作日志 #1-#") {
+		t.Fatalf("expected archive to be audited, got %+v", page.List)
+	}
+	report := env.verifyChain(admin)
+	if !report.Valid || report.ArchivedSeq != archive.ToSeq || report.FirstSeq != archive.ToSeq+1 {
+		t.Fatalf("expected chain to continue from the archive, got %+v", report)
+	}
+
+	var archives []models.AuditArchive
+	env.mustOK(http.MethodGet, "/api/log/archives", admin, nil, &archives)
+	if len(archives) != 1 || archives[0].FileSHA256 != archive.FileSHA256 {
+		t.Fatalf("unexpected archives: %+v", archives)
+	}
+
+	// 没有更早的日志时不产生新的归档
+	status, resp := env.do(http.MethodPost, "/api/log/archive", admin, gin.H{"before": cutoff.Format(time.RFC3339Nano)})
+	if status != http.StatusOK || string(resp.Data) != "" && string(resp.Data) != "null" {
+		t.Fatalf("expected empty archive, got %d %s", status, resp.Data)
+	}
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"superhoneypotguard/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type auditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// auditLogs 按查询条件返回日志，按时间倒序
+func (e *testEnv) auditLogs(token, query string) []logEntry {
+	e.t.Helper()
+
+	middleware.FlushLogs()
+	var page logPage
+	e.mustOK(http.MethodGet, "/api/log/list?pageSize=100&"+query, token, nil, &page)
+	return page.List
+}
+
+func changesOf(t *testing.T, entry logEntry) map[string]auditChange {
+	t.Helper()
+	if entry.Changes == nil {
+		t.Fatalf("log entry has no changes: %+v", entry)
+	}
+	var changes map[string]auditChange
+	if err := json.Unmarshal([]byte(*entry.Changes), &changes); err != nil {
+		t.Fatalf("decode changes %q: %v", *entry.Changes, err)
+	}
+	return changes
+}
+
+func TestAuditEventsForUserLifecycle(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	id := env.createUser(admin, "bob", "bob12345", env.roleID(admin, "user"))
+	env.mustOK(http.MethodPatch, pathf("/api/user/%d/status", id), admin, gin.H{"status": 0}, nil)
+	env.expectStatus(http.StatusForbidden, http.MethodPost, "/api/auth/login", "", gin.H{
+		"username": "bob",
+		"password": "bob12345",
+	})
+
+	logs := env.auditLogs(admin, "targetType=user&targetId="+strconv.Itoa(id))
+	if len(logs) != 2 {
+		t.Fatalf("expected create and disable events for bob, got %+v", logs)
+	}
+
+	disable, create := logs[0], logs[1]
+	if *disable.Action != "user.disable" || disable.Operation != "禁用用户 bob" || disable.Status != 1 || *disable.Username != adminUsername {
+		t.Fatalf("unexpected disable event: %+v", disable)
+	}
+	changes := changesOf(t, disable)
+	if len(changes) != 1 || changes["status"].Before != float64(1) || changes["status"].After != float64(0) {
+		t.Fatalf("disable should only change status 1 -> 0: %+v", changes)
+	}
+
+	if *create.Action != "user.create" || create.Operation != "创建用户 bob" {
+		t.Fatalf("unexpected create event: %+v", create)
+	}
+	if changes := changesOf(t, create); changes["username"].After != "bob" || changes["username"].Before != nil {
+		t.Fatalf("create should record the new values: %+v", changes)
+	}
+
+	// 失败的操作同样留下审计事件，status 为 0 并记录失败原因
+	failed := env.auditLogs(admin, "action=auth.login&status=0")
+	if len(failed) != 1 || failed[0].Operation != "用户 bob 登录" || failed[0].ErrorMsg == nil || *failed[0].ErrorMsg != "账号已被禁用" {
+		t.Fatalf("unexpected failed login events: %+v", failed)
+	}
+
+	// 登录前尚未认证，事件中的操作人来自登录的用户
+	logins := env.auditLogs(admin, "action=auth.login&status=1")
+	if len(logins) == 0 || logins[0].Username == nil || *logins[0].Username != adminUsername {
+		t.Fatalf("successful login should be attributed to the user: %+v", logins)
+	}
+
+	// 以 * 结尾按前缀匹配动作
+	if users := env.auditLogs(admin, "action=user.*"); len(users) != 2 {
+		t.Fatalf("expected 2 user.* events, got %+v", users)
+	}
+}
+
+func TestAuditEventRecordsRoleDiff(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	env.mustOK(http.MethodPost, "/api/role/", admin, gin.H{
+		"roleName": "审计员",
+		"roleCode": "auditor",
+	}, &created)
+	env.mustOK(http.MethodPut, pathf("/api/role/%d", created.ID), admin, gin.H{
+		"roleName":    "审计员",
+		"description": "只读访问操作日志",
+	}, nil)
+
+	logs := env.auditLogs(admin, "action=role.update")
+	if len(logs) != 1 || logs[0].Operation != "修改角色 审计员(auditor)" || *logs[0].TargetID != strconv.Itoa(created.ID) {
+		t.Fatalf("unexpected role update events: %+v", logs)
+	}
+	changes := changesOf(t, logs[0])
+	if len(changes) != 1 || changes["description"].Before != nil || changes["description"].After != "只读访问操作日志" {
+		t.Fatalf("role update should record the description change only: %+v", changes)
+	}
+}
+
+func TestAuditEventsForFailedUserOperations(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	id := env.createUser(admin, "bob", "bob12345", env.roleID(admin, "user"))
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/user/", admin, gin.H{
+		"username": "bob",
+		"password": "bob12345",
+	})
+	env.expectStatus(http.StatusNotFound, http.MethodPut, "/api/user/999", admin, gin.H{"realName": "nobody"})
+	env.expectStatus(http.StatusBadRequest, http.MethodPatch, "/api/user/1/status", admin, gin.H{"status": 0})
+	env.expectStatus(http.StatusNotFound, http.MethodPost, "/api/user/999/reset-password", admin, gin.H{"newPassword": "nobody123"})
+
+	// 失败的操作只记录事件，不记录字段变更；创建失败时没有目标 ID
+	failed := env.auditLogs(admin, "action=user.*&status=0")
+	operations := make([]string, 0, len(failed))
+	for _, entry := range failed {
+		if entry.Changes != nil {
+			t.Fatalf("failed %s should not record changes: %s", *entry.Action, *entry.Changes)
+		}
+		operations = append(operations, entry.Operation)
+	}
+	want := []string{"重置用户 #999 的密码", "禁用用户 " + adminUsername, "修改用户 #999", "创建用户 bob"}
+	if strings.Join(operations, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected failed events %v, got %v", want, operations)
+	}
+	if create := failed[3]; create.TargetID != nil && *create.TargetID != "" {
+		t.Fatalf("failed create should have no target id, got %q", *create.TargetID)
+	}
+
+	// 重置密码在操作完成后记录
+	env.mustOK(http.MethodPost, pathf("/api/user/%d/reset-password", id), admin, gin.H{"newPassword": "bob54321"}, nil)
+	reset := env.auditLogs(admin, "action=user.reset_password&status=1")
+	if len(reset) != 1 || reset[0].Operation != "重置用户 bob 的密码" || reset[0].Changes != nil {
+		t.Fatalf("unexpected reset password events: %+v", reset)
+	}
+	env.login("bob", "bob54321")
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegisterWithVerificationCode(t *testing.T) {
+	env := newTestEnv(t)
+	const email = "alice@example.test"
+
+	env.mustOK(http.MethodPost, "/api/auth/send-verification-code", "", gin.H{"email": email}, nil)
+	if n := env.smtp.count(email); n != 1 {
+		t.Fatalf("expected 1 mail to %s, got %d", email, n)
+	}
+	code := env.smtp.lastCode(t, email)
+
+	// 60 秒内重复发送被拒绝
+	env.expectStatus(http.StatusInternalServerError, http.MethodPost, "/api/auth/send-verification-code", "", gin.H{"email": email})
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	resp := env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/auth/register", "", gin.H{
+		"username": "alice",
+		"password": "alice123",
+		"email":    email,
+		"code":     wrong,
+	})
+	if resp.Message != "验证码错误或已过期" {
+		t.Fatalf("unexpected message for wrong code: %q", resp.Message)
+	}
+
+	env.mustOK(http.MethodPost, "/api/auth/register", "", gin.H{
+		"username": "alice",
+		"password": "alice123",
+		"email":    email,
+		"code":     code,
+	}, nil)
+
+	// 验证码使用后失效
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/auth/register", "", gin.H{
+		"username": "alice2",
+		"password": "alice123",
+		"email":    email,
+		"code":     code,
+	})
+
+	// 已注册邮箱不能再次获取注册验证码
+	env.expectStatus(http.StatusInternalServerError, http.MethodPost, "/api/auth/send-verification-code", "", gin.H{"email": email})
+
+	token := env.login("alice", "alice123")
+
+	var current struct {
+		User struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+		} `json:"user"`
+		Roles []struct {
+			RoleCode string `json:"roleCode"`
+		} `json:"roles"`
+	}
+	env.mustOK(http.MethodGet, "/api/auth/current", token, nil, &current)
+	if current.User.Username != "alice" || current.User.Email != email {
+		t.Fatalf("unexpected current user: %+v", current.User)
+	}
+	if len(current.Roles) != 1 || current.Roles[0].RoleCode != "user" {
+		t.Fatalf("expected default role user, got %+v", current.Roles)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	env := newTestEnv(t)
+
+	var data struct {
+		Token string `json:"token"`
+		User  struct {
+			Permissions []struct {
+				PermissionCode string `json:"permissionCode"`
+			} `json:"permissions"`
+		} `json:"user"`
+	}
+	env.mustOK(http.MethodPost, "/api/auth/login", "", gin.H{
+		"username": adminUsername,
+		"password": adminPassword,
+	}, &data)
+	if data.Token == "" {
+		t.Fatal("expected token in login response")
+	}
+
+	codes := make(map[string]bool)
+	for _, p := range data.User.Permissions {
+		codes[p.PermissionCode] = true
+	}
+	for _, want := range []string{"user:manage", "role:manage", "permission:manage", "log:manage", "hfish:view", "hfish:block"} {
+		if !codes[want] {
+			t.Errorf("admin login missing permission %s", want)
+		}
+	}
+
+	env.expectStatus(http.StatusUnauthorized, http.MethodPost, "/api/auth/login", "", gin.H{
+		"username": adminUsername,
+		"password": "wrong-password",
+	})
+	env.expectStatus(http.StatusUnauthorized, http.MethodPost, "/api/auth/login", "", gin.H{
+		"username": "nobody",
+		"password": "whatever",
+	})
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/auth/login", "", gin.H{
+		"username": adminUsername,
+	})
+}
+
+func TestLoginDisabledUser(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	id := env.createUser(admin, "bob", "bob12345", env.roleID(admin, "user"))
+	env.login("bob", "bob12345")
+
+	env.mustOK(http.MethodPatch, pathf("/api/user/%d/status", id), admin, gin.H{"status": 0}, nil)
+
+	resp := env.expectStatus(http.StatusForbidden, http.MethodPost, "/api/auth/login", "", gin.H{
+		"username": "bob",
+		"password": "bob12345",
+	})
+	if resp.Message != "账号已被禁用" {
+		t.Fatalf("unexpected message: %q", resp.Message)
+	}
+}
+
+func TestResetPasswordWithEmailCode(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	env.createUser(admin, "carol", "carol123", env.roleID(admin, "user"))
+	const email = "carol@example.test"
+
+	env.mustOK(http.MethodPost, "/api/auth/send-reset-code", "", gin.H{"email": email}, nil)
+	code := env.smtp.lastCode(t, email)
+
+	token := env.login("carol", "carol123")
+	env.mustOK(http.MethodPost, "/api/password/reset", token, gin.H{
+		"email":       email,
+		"code":        code,
+		"newPassword": "carol456",
+	}, nil)
+
+	env.expectStatus(http.StatusUnauthorized, http.MethodPost, "/api/auth/login", "", gin.H{
+		"username": "carol",
+		"password": "carol123",
+	})
+	env.login("carol", "carol456")
+}
+
+func TestAuthRequired(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, path := range []string{"/api/auth/current", "/api/user/list", "/api/role/list", "/api/log/list", "/api/hfish/attack/ips"} {
+		env.expectStatus(http.StatusUnauthorized, http.MethodGet, path, "", nil)
+		env.expectStatus(http.StatusUnauthorized, http.MethodGet, path, "not-a-jwt", nil)
+	}
+}
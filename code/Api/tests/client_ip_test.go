@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// doFrom 以指定的连接对端地址和请求头发起请求，模拟经过反向代理或伪造请求头的客户端
+func (e *testEnv) doFrom(remoteAddr string, headers map[string]string, method, path string, body interface{}) *httptest.ResponseRecorder {
+	e.t.Helper()
+
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.RemoteAddr = remoteAddr
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	w := httptest.NewRecorder()
+	e.engine.ServeHTTP(w, req)
+	return w
+}
+
+type forwardedLog struct {
+	IP           *string `json:"ip"`
+	ForwardedFor *string `json:"forwardedFor"`
+}
+
+func (e *testEnv) lastLoginIP() string {
+	e.t.Helper()
+
+	var ip string
+	e.db.Raw("SELECT last_login_ip FROM users WHERE username = ?", adminUsername).Scan(&ip)
+	return ip
+}
+
+func TestClientIPIgnoresUntrustedHeaders(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 未配置受信任代理时，伪造的请求头不影响登录记录，但完整保留在转发链中
+	w := env.doFrom("198.51.100.20:40000", map[string]string{"X-Forwarded-For": "1.2.3.4, 5.6.7.8"},
+		http.MethodPost, "/api/auth/login", gin.H{"username": adminUsername, "password": adminPassword})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body.String())
+	}
+	if ip := env.lastLoginIP(); ip != "198.51.100.20" {
+		t.Fatalf("expected last login ip to be the peer address, got %q", ip)
+	}
+
+	// 管理员令牌从默认的对端地址 192.0.2.1 直接登录
+	admin := env.adminToken()
+	middleware.FlushLogs()
+	var logs struct {
+		List []forwardedLog `json:"list"`
+	}
+	env.mustOK(http.MethodGet, "/api/log/list?ip=198.51.100.20", admin, nil, &logs)
+	if len(logs.List) != 1 || logs.List[0].ForwardedFor == nil || *logs.List[0].ForwardedFor != "1.2.3.4, 5.6.7.8, 198.51.100.20" {
+		t.Fatalf("expected forwarding chain to be recorded, got %+v", logs.List)
+	}
+
+	// 没有转发请求头的请求不记录转发链
+	env.mustOK(http.MethodGet, "/api/log/list?ip=192.0.2.1&pageSize=1", admin, nil, &logs)
+	if len(logs.List) != 1 || logs.List[0].ForwardedFor != nil {
+		t.Fatalf("expected no forwarding chain for direct requests, got %+v", logs.List)
+	}
+
+	if report := env.verifyChain(admin); !report.Valid {
+		t.Fatalf("expected valid chain with forwarding chains recorded, got %+v", report)
+	}
+}
+
+func TestClientIPRateLimitCannotBeEvaded(t *testing.T) {
+	env := newTestEnv(t)
+
+	middleware.ConfigureRateLimiter(time.Minute, 3)
+	t.Cleanup(func() { middleware.ConfigureRateLimiter(time.Minute, 100000) })
+
+	// 每次请求伪造不同的来源地址，仍按连接的对端地址限流
+	for i, spoofed := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3", "4.4.4.4"} {
+		w := env.doFrom("198.51.100.30:40000", map[string]string{"X-Forwarded-For": spoofed, "X-Real-IP": spoofed},
+			http.MethodGet, "/api/auth/current", nil)
+		if i < 3 && w.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d should not be limited yet", i+1)
+		}
+		if i == 3 && w.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected spoofed headers not to evade rate limit, got %d", w.Code)
+		}
+	}
+}
+
+func TestClientIPTrustedProxies(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.TrustedProxies = "192.0.2.0/24, 10.0.0.5"
+	})
+
+	// 经过两层受信任代理：客户端在最左侧伪造的地址被忽略，取第一个不受信任的地址
+	w := env.doFrom("192.0.2.10:40000", map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7, 10.0.0.5"},
+		http.MethodPost, "/api/auth/login", gin.H{"username": adminUsername, "password": adminPassword})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body.String())
+	}
+	if ip := env.lastLoginIP(); ip != "203.0.113.7" {
+		t.Fatalf("expected last login ip from trusted proxy chain, got %q", ip)
+	}
+
+	middleware.FlushLogs()
+	var logs struct {
+		List []forwardedLog `json:"list"`
+	}
+	env.mustOK(http.MethodGet, "/api/log/list?ip=203.0.113.7", env.adminToken(), nil, &logs)
+	if len(logs.List) != 1 || logs.List[0].ForwardedFor == nil || *logs.List[0].ForwardedFor != "1.2.3.4, 203.0.113.7, 10.0.0.5, 192.0.2.10" {
+		t.Fatalf("unexpected log for proxied request: %+v", logs.List)
+	}
+
+	// X-Real-IP 只在没有 X-Forwarded-For 时使用
+	env.doFrom("192.0.2.10:40000", map[string]string{"X-Real-IP": "198.51.100.9"},
+		http.MethodPost, "/api/auth/login", gin.H{"username": adminUsername, "password": adminPassword})
+	if ip := env.lastLoginIP(); ip != "198.51.100.9" {
+		t.Fatalf("expected X-Real-IP from trusted proxy, got %q", ip)
+	}
+
+	// 不受信任的对端即使带有请求头也使用对端地址
+	env.doFrom("198.51.100.40:40000", map[string]string{"X-Forwarded-For": "203.0.113.7"},
+		http.MethodPost, "/api/auth/login", gin.H{"username": adminUsername, "password": adminPassword})
+	if ip := env.lastLoginIP(); ip != "198.51.100.40" {
+		t.Fatalf("expected untrusted peer address, got %q", ip)
+	}
+}
+
+func TestTrustedProxiesValidation(t *testing.T) {
+	cfg := config.Default()
+	cfg.JWTSecret = "integration-test-secret"
+	cfg.TrustedProxies = "10.0.0.0/8,not-an-ip"
+	cfg.RemoteIPHeaders = ""
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("expected invalid trusted proxies to be rejected")
+	}
+	for _, want := range []string{"trusted_proxies", "not-an-ip", "remote_ip_headers"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("validation error should mention %s: %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package tests
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeTable 按 ID 保存记录的内存表，是各仓储内存实现的公共部分，服务层测试无需数据库
+type fakeTable[T any] struct {
+	rows   map[int]*T
+	nextID int
+	// failWith 不为空时所有写操作返回该错误，用于模拟数据库故障
+	failWith error
+	// id 返回记录的主键字段
+	id func(row *T) *int
+}
+
+func newFakeTable[T any](id func(row *T) *int) fakeTable[T] {
+	return fakeTable[T]{rows: make(map[int]*T), id: id}
+}
+
+// find 返回记录的副本，不存在时返回 ErrNotFound
+func (t *fakeTable[T]) find(id int) (*T, error) {
+	row, ok := t.rows[id]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+	copied := *row
+	return &copied, nil
+}
+
+// findBy 返回 ID 最小的满足 match 的记录
+func (t *fakeTable[T]) findBy(match func(row *T) bool) (*T, error) {
+	rows := t.all(match)
+	if len(rows) == 0 {
+		return nil, repositories.ErrNotFound
+	}
+	return &rows[0], nil
+}
+
+func (t *fakeTable[T]) exists(match func(row *T) bool) (bool, error) {
+	return len(t.all(match)) > 0, nil
+}
+
+// all 按 ID 顺序返回满足 match 的记录，match 为 nil 时返回全部
+func (t *fakeTable[T]) all(match func(row *T) bool) []T {
+	ids := make([]int, 0, len(t.rows))
+	for id := range t.rows {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	rows := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if match == nil || match(t.rows[id]) {
+			rows = append(rows, *t.rows[id])
+		}
+	}
+	return rows
+}
+
+// page 返回满足 match 的记录中的一页及总数
+func (t *fakeTable[T]) page(match func(row *T) bool, offset, limit int) ([]T, int64, error) {
+	rows := t.all(match)
+	total := int64(len(rows))
+	if offset >= len(rows) {
+		return []T{}, total, nil
+	}
+	end := offset + limit
+	if end > len(rows) {
+		end = len(rows)
+	}
+	return rows[offset:end], total, nil
+}
+
+func (t *fakeTable[T]) insert(row *T) error {
+	if t.failWith != nil {
+		return t.failWith
+	}
+	t.nextID++
+	*t.id(row) = t.nextID
+	copied := *row
+	t.rows[t.nextID] = &copied
+	return nil
+}
+
+// update 对记录调用 apply，记录不存在时什么也不做
+func (t *fakeTable[T]) update(id int, apply func(row *T)) error {
+	if t.failWith != nil {
+		return t.failWith
+	}
+	if row, ok := t.rows[id]; ok {
+		apply(row)
+	}
+	return nil
+}
+
+func (t *fakeTable[T]) remove(id int) error {
+	if t.failWith != nil {
+		return t.failWith
+	}
+	delete(t.rows, id)
+	return nil
+}
+
+// fakeUserRepository 内存实现的用户仓储
+type fakeUserRepository struct {
+	fakeTable[models.User]
+	roles       map[int][]int
+	permissions map[int][]string
+}
+
+func newFakeUserRepository() *fakeUserRepository {
+	return &fakeUserRepository{
+		fakeTable:   newFakeTable(func(user *models.User) *int { return &user.ID }),
+		roles:       make(map[int][]int),
+		permissions: make(map[int][]string),
+	}
+}
+
+func (r *fakeUserRepository) FindByID(id int) (*models.User, error) {
+	return r.find(id)
+}
+
+func (r *fakeUserRepository) FindByUsername(username string) (*models.User, error) {
+	return r.findBy(func(user *models.User) bool { return user.Username == username })
+}
+
+func (r *fakeUserRepository) FindByEmail(email string) (*models.User, error) {
+	return r.findBy(func(user *models.User) bool { return user.Email != nil && *user.Email == email })
+}
+
+func (r *fakeUserRepository) ExistsByUsernameOrEmail(username string, email *string) (bool, error) {
+	return r.exists(func(user *models.User) bool {
+		return user.Username == username || (email != nil && user.Email != nil && *user.Email == *email)
+	})
+}
+
+func (r *fakeUserRepository) List(filter repositories.UserFilter, offset, limit int) ([]models.User, int64, error) {
+	return r.page(func(user *models.User) bool {
+		return filter.Username == "" || user.Username == filter.Username
+	}, offset, limit)
+}
+
+func (r *fakeUserRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(r.rows)), nil
+}
+
+func (r *fakeUserRepository) Create(user *models.User) error {
+	return r.insert(user)
+}
+
+func (r *fakeUserRepository) Update(id int, updates map[string]interface{}) error {
+	return r.update(id, func(user *models.User) {
+		if email, ok := updates["email"].(*string); ok && email != nil {
+			user.Email = email
+		}
+		if status, ok := updates["status"].(int); ok {
+			user.Status = status
+		}
+		if password, ok := updates["password"].(string); ok {
+			user.Password = password
+		}
+		if updatedBy, ok := updates["updated_by"].(int); ok {
+			user.UpdatedBy = &updatedBy
+		}
+	})
+}
+
+func (r *fakeUserRepository) Delete(id int) error {
+	if err := r.remove(id); err != nil {
+		return err
+	}
+	delete(r.roles, id)
+	return nil
+}
+
+func (r *fakeUserRepository) GetRoles(userID int, activeOnly bool) ([]models.Role, error) {
+	roles := make([]models.Role, 0, len(r.roles[userID]))
+	for _, id := range r.roles[userID] {
+		roles = append(roles, models.Role{ID: id})
+	}
+	return roles, nil
+}
+
+func (r *fakeUserRepository) GetPermissions(userID int) ([]models.Permission, error) {
+	permissions := make([]models.Permission, 0, len(r.permissions[userID]))
+	for _, code := range r.permissions[userID] {
+		permissions = append(permissions, models.Permission{PermissionCode: code})
+	}
+	return permissions, nil
+}
+
+func (r *fakeUserRepository) AssignRoles(userID int, roleIDs []int, createdBy *int) error {
+	if r.failWith != nil {
+		return r.failWith
+	}
+	r.roles[userID] = append(r.roles[userID], roleIDs...)
+	return nil
+}
+
+func (r *fakeUserRepository) ReplaceRoles(userID int, roleIDs []int, createdBy *int) error {
+	if r.failWith != nil {
+		return r.failWith
+	}
+	r.roles[userID] = append([]int(nil), roleIDs...)
+	return nil
+}
+
+func (r *fakeUserRepository) HasPermission(ctx context.Context, userID int, permissionCode string) (bool, error) {
+	for _, code := range r.permissions[userID] {
+		if code == permissionCode {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeRoleRepository 内存实现的角色仓储
+type fakeRoleRepository struct {
+	fakeTable[models.Role]
+	permissions map[int][]int
+	userCounts  map[int]int64
+}
+
+func newFakeRoleRepository() *fakeRoleRepository {
+	return &fakeRoleRepository{
+		fakeTable:   newFakeTable(func(role *models.Role) *int { return &role.ID }),
+		permissions: make(map[int][]int),
+		userCounts:  make(map[int]int64),
+	}
+}
+
+func (r *fakeRoleRepository) FindByID(id int) (*models.Role, error) {
+	return r.find(id)
+}
+
+func (r *fakeRoleRepository) FindByCode(roleCode string) (*models.Role, error) {
+	return r.findBy(func(role *models.Role) bool { return role.RoleCode == roleCode })
+}
+
+func (r *fakeRoleRepository) ExistsByNameOrCode(roleName, roleCode string) (bool, error) {
+	return r.exists(func(role *models.Role) bool { return role.RoleName == roleName || role.RoleCode == roleCode })
+}
+
+func (r *fakeRoleRepository) List(filter repositories.RoleFilter, offset, limit int) ([]models.Role, int64, error) {
+	return r.page(func(role *models.Role) bool {
+		return filter.RoleName == "" || role.RoleName == filter.RoleName
+	}, offset, limit)
+}
+
+func (r *fakeRoleRepository) ListActive() ([]models.Role, error) {
+	return r.all(nil), nil
+}
+
+func (r *fakeRoleRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(r.rows)), nil
+}
+
+func (r *fakeRoleRepository) CountUsers(roleID int) (int64, error) {
+	return r.userCounts[roleID], nil
+}
+
+func (r *fakeRoleRepository) Create(role *models.Role) error {
+	return r.insert(role)
+}
+
+func (r *fakeRoleRepository) Update(id int, updates map[string]interface{}) error {
+	return r.update(id, func(role *models.Role) {
+		if name, ok := updates["role_name"].(*string); ok && name != nil {
+			role.RoleName = *name
+		}
+		if status, ok := updates["status"].(int); ok {
+			role.Status = status
+		}
+	})
+}
+
+func (r *fakeRoleRepository) Delete(id int) error {
+	if err := r.remove(id); err != nil {
+		return err
+	}
+	delete(r.permissions, id)
+	return nil
+}
+
+func (r *fakeRoleRepository) GetPermissions(roleID int) ([]models.Permission, error) {
+	permissions := make([]models.Permission, 0, len(r.permissions[roleID]))
+	for _, id := range r.permissions[roleID] {
+		permissions = append(permissions, models.Permission{ID: id})
+	}
+	return permissions, nil
+}
+
+func (r *fakeRoleRepository) AssignPermissions(roleID int, permissionIDs []int, createdBy *int) error {
+	if r.failWith != nil {
+		return r.failWith
+	}
+	r.permissions[roleID] = append(r.permissions[roleID], permissionIDs...)
+	return nil
+}
+
+func (r *fakeRoleRepository) ReplacePermissions(roleID int, permissionIDs []int, createdBy *int) error {
+	if r.failWith != nil {
+		return r.failWith
+	}
+	r.permissions[roleID] = append([]int(nil), permissionIDs...)
+	return nil
+}
+
+// fakePermissionRepository 内存实现的权限仓储
+type fakePermissionRepository struct {
+	fakeTable[models.Permission]
+}
+
+func newFakePermissionRepository() *fakePermissionRepository {
+	return &fakePermissionRepository{
+		fakeTable: newFakeTable(func(permission *models.Permission) *int { return &permission.ID }),
+	}
+}
+
+func (r *fakePermissionRepository) FindByID(id int) (*models.Permission, error) {
+	return r.find(id)
+}
+
+func (r *fakePermissionRepository) ExistsByCode(permissionCode string) (bool, error) {
+	return r.exists(func(permission *models.Permission) bool { return permission.PermissionCode == permissionCode })
+}
+
+func (r *fakePermissionRepository) ListAll() ([]models.Permission, error) {
+	list := r.all(nil)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].SortOrder < list[j].SortOrder })
+	return list, nil
+}
+
+func (r *fakePermissionRepository) ListActive() ([]models.Permission, error) {
+	all, _ := r.ListAll()
+	var active []models.Permission
+	for _, permission := range all {
+		if permission.Status == 1 {
+			active = append(active, permission)
+		}
+	}
+	return active, nil
+}
+
+func (r *fakePermissionRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(r.rows)), nil
+}
+
+func (r *fakePermissionRepository) CountChildren(parentID int) (int64, error) {
+	children := r.all(func(permission *models.Permission) bool { return permission.ParentID == parentID })
+	return int64(len(children)), nil
+}
+
+func (r *fakePermissionRepository) Create(permission *models.Permission) error {
+	return r.insert(permission)
+}
+
+func (r *fakePermissionRepository) Update(id int, updates map[string]interface{}) error {
+	return r.update(id, func(permission *models.Permission) {
+		if name, ok := updates["permission_name"].(*string); ok && name != nil {
+			permission.PermissionName = *name
+		}
+		if status, ok := updates["status"].(int); ok {
+			permission.Status = status
+		}
+	})
+}
+
+func (r *fakePermissionRepository) Delete(id int) error {
+	return r.remove(id)
+}
+
+// useFastBCrypt 密码哈希使用最低成本，测试结束后恢复全局配置
+func useFastBCrypt(t *testing.T) {
+	t.Helper()
+
+	prev := config.AppConfig
+	cfg := config.Default()
+	cfg.BCryptCost = bcrypt.MinCost
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = prev })
+}
@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/geoip"
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/services"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// writeCityMMDB 生成一个 GeoLite2-City 格式的测试数据库，
+// 203.0.113.0/24 位于洛杉矶，192.0.2.0/24（httptest 请求的来源地址）位于深圳
+func writeCityMMDB(t *testing.T, path string) {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType:            "GeoLite2-City",
+		IncludeReservedNetworks: true,
+		RecordSize:              24,
+	})
+	if err != nil {
+		t.Fatalf("create mmdb: %v", err)
+	}
+	names := func(en, zh string) mmdbtype.Map {
+		return mmdbtype.Map{"en": mmdbtype.String(en), "zh-CN": mmdbtype.String(zh)}
+	}
+	records := map[string]mmdbtype.Map{
+		"203.0.113.0/24": {
+			"country":      mmdbtype.Map{"iso_code": mmdbtype.String("US"), "names": names("United States", "美国")},
+			"subdivisions": mmdbtype.Slice{mmdbtype.Map{"names": names("California", "加利福尼亚州")}},
+			"city":         mmdbtype.Map{"names": names("Los Angeles", "洛杉矶")},
+		},
+		"192.0.2.0/24": {
+			"country":      mmdbtype.Map{"iso_code": mmdbtype.String("CN"), "names": names("China", "中国")},
+			"subdivisions": mmdbtype.Slice{mmdbtype.Map{"names": names("Guangdong", "广东")}},
+			// 只有英文名称时回退到英文
+			"city": mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String("Shenzhen")}},
+		},
+	}
+	for cidr, record := range records {
+		_, network, _ := net.ParseCIDR(cidr)
+		if err := tree.Insert(network, record); err != nil {
+			t.Fatalf("insert %s: %v", cidr, err)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create mmdb file: %v", err)
+	}
+	defer f.Close()
+	if _, err := tree.WriteTo(f); err != nil {
+		t.Fatalf("write mmdb: %v", err)
+	}
+}
+
+// writeGeoCSV 写入 CSV 数据库，并把修改时间推后，确保 Reload 能发现变化
+func writeGeoCSV(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write csv database: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("touch csv database: %v", err)
+	}
+}
+
+const asnCSV = `network,country_code,country,region,city,asn,as_org
+# ASN 库只有自治系统信息
+203.0.113.0/24,,,,,AS64500,Example Transit
+192.0.2.0-192.0.2.255,,,,,4134,Chinanet
+`
+
+func newGeoTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	dir := t.TempDir()
+	cityDB := filepath.Join(dir, "city.mmdb")
+	asnDB := filepath.Join(dir, "asn.csv")
+	writeCityMMDB(t, cityDB)
+	writeGeoCSV(t, asnDB, asnCSV, time.Now())
+
+	return newTestEnv(t, func(cfg *config.Config) {
+		cfg.GeoIPDatabase = cityDB
+		cfg.GeoIPASNDatabase = asnDB
+		cfg.GeoIPLanguage = "zh-CN"
+	})
+}
+
+func TestGeoIPLookup(t *testing.T) {
+	env := newGeoTestEnv(t)
+	token := env.adminToken()
+
+	var result services.GeoLookup
+	env.mustOK(http.MethodGet, "/api/geo/203.0.113.7", token, nil, &result)
+	if result.Location != "美国 加利福尼亚州 洛杉矶 AS64500" {
+		t.Fatalf("unexpected location: %q", result.Location)
+	}
+	if geo := result.Geo; geo == nil || geo.CountryCode != "US" || geo.City != "洛杉矶" || geo.ASN != 64500 || geo.ASOrg != "Example Transit" {
+		t.Fatalf("unexpected geo: %+v", result.Geo)
+	}
+
+	env.mustOK(http.MethodGet, "/api/geo/::ffff:192.0.2.10", token, nil, &result)
+	if result.Location != "中国 广东 Shenzhen AS4134" {
+		t.Fatalf("expected mapped IPv4 address to fall back to english names, got %q", result.Location)
+	}
+
+	env.mustOK(http.MethodGet, "/api/geo/10.1.2.3", token, nil, &result)
+	if result.Location != "内网" || result.Geo == nil || !result.Geo.Private {
+		t.Fatalf("expected private address, got %+v", result)
+	}
+
+	env.expectStatus(http.StatusNotFound, http.MethodGet, "/api/geo/8.8.8.8", token, nil)
+	env.expectStatus(http.StatusBadRequest, http.MethodGet, "/api/geo/not-an-ip", token, nil)
+	env.expectStatus(http.StatusUnauthorized, http.MethodGet, "/api/geo/203.0.113.7", "", nil)
+	// 与 HFish 数据相同，需要 hfish:view 权限
+	env.createUser(token, "victor", "victor123", env.roleID(token, "user"))
+	env.expectStatus(http.StatusForbidden, http.MethodGet, "/api/geo/203.0.113.7", env.login("victor", "victor123"), nil)
+}
+
+func TestGeoIPEnrichment(t *testing.T) {
+	env := newGeoTestEnv(t)
+	token := env.adminToken()
+
+	// 登录记录与操作日志都带上来源地址 192.0.2.1 的位置
+	var current struct {
+		User struct {
+			LastLoginLocation *string `json:"lastLoginLocation"`
+		} `json:"user"`
+	}
+	env.mustOK(http.MethodGet, "/api/auth/current", token, nil, &current)
+	if loc := current.User.LastLoginLocation; loc == nil || *loc != "中国 广东 Shenzhen AS4134" {
+		t.Fatalf("unexpected last login location: %v", loc)
+	}
+
+	middleware.FlushLogs()
+	var logs logPage
+	env.mustOK(http.MethodGet, "/api/log/list?pageSize=100", token, nil, &logs)
+	if len(logs.List) == 0 {
+		t.Fatalf("expected operation logs")
+	}
+	for _, entry := range logs.List {
+		if entry.Location == nil || *entry.Location != "中国 广东 Shenzhen AS4134" {
+			t.Fatalf("expected log %d to be located, got %v", entry.ID, entry.Location)
+		}
+	}
+	// 位置参与哈希计算，补充位置后链仍然有效
+	if report := env.verifyChain(token); !report.Valid {
+		t.Fatalf("expected valid chain, got %+v", report)
+	}
+
+	var ips struct {
+		List []struct {
+			IP  string          `json:"ip"`
+			Geo *geoip.Location `json:"geo"`
+		} `json:"list"`
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips", token, nil, &ips)
+	if len(ips.List) != 2 || ips.List[0].Geo == nil || ips.List[0].Geo.City != "洛杉矶" || ips.List[1].Geo != nil {
+		t.Fatalf("expected only 203.0.113.7 to be located, got %+v", ips.List)
+	}
+
+	var details struct {
+		List []struct {
+			Geo *geoip.Location `json:"geo"`
+		} `json:"list"`
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/attack/details", token, nil, &details)
+	if len(details.List) != 1 || details.List[0].Geo == nil || details.List[0].Geo.ASN != 64500 {
+		t.Fatalf("unexpected attack details: %+v", details.List)
+	}
+}
+
+func TestGeoIPWithoutDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.adminToken()
+
+	env.expectStatus(http.StatusInternalServerError, http.MethodGet, "/api/geo/203.0.113.7", token, nil)
+
+	var ips struct {
+		List []struct {
+			Geo *geoip.Location `json:"geo"`
+		} `json:"list"`
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips", token, nil, &ips)
+	if len(ips.List) != 2 || ips.List[0].Geo != nil {
+		t.Fatalf("expected no geo without database, got %+v", ips.List)
+	}
+}
+
+func TestGeoIPReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.csv")
+	modTime := time.Now().Add(-time.Hour)
+	writeGeoCSV(t, path, "203.0.113.0/24,US,美国,,洛杉矶\n", modTime)
+
+	resolver, err := geoip.NewResolver([]string{path}, "zh-CN")
+	if err != nil {
+		t.Fatalf("load database: %v", err)
+	}
+	if got := resolver.Locate("203.0.113.7"); got != "美国 洛杉矶" {
+		t.Fatalf("unexpected location: %q", got)
+	}
+
+	// 文件未变化时不重新加载
+	if reloaded, err := resolver.Reload(); err != nil || reloaded {
+		t.Fatalf("expected no reload, got %v, %v", reloaded, err)
+	}
+
+	modTime = modTime.Add(time.Minute)
+	writeGeoCSV(t, path, "203.0.113.0/24,JP,日本,,东京\n", modTime)
+	if reloaded, err := resolver.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected reload, got %v, %v", reloaded, err)
+	}
+	if got := resolver.Locate("203.0.113.7"); got != "日本 东京" {
+		t.Fatalf("expected reloaded location, got %q", got)
+	}
+
+	// 新文件有误时继续使用原来的数据库
+	modTime = modTime.Add(time.Minute)
+	writeGeoCSV(t, path, "203.0.113.0/24,JP,日本,,东京\n203.0.113.128/25,KR,韩国,,首尔\n", modTime)
+	if _, err := resolver.Reload(); err == nil {
+		t.Fatalf("expected overlapping ranges to be rejected")
+	}
+	if got := resolver.Locate("203.0.113.7"); got != "日本 东京" {
+		t.Fatalf("expected previous database to stay loaded, got %q", got)
+	}
+
+	// 启动时文件不存在，之后由 Reload 加载
+	missing := filepath.Join(t.TempDir(), "later.csv")
+	resolver, err = geoip.NewResolver([]string{missing}, "zh-CN")
+	if err == nil || !resolver.Enabled() || !resolver.LoadedAt().IsZero() {
+		t.Fatalf("expected missing database to fail without disabling the resolver, got %v", err)
+	}
+	writeGeoCSV(t, missing, "198.51.100.0-198.51.100.255,DE,德国\n", time.Now())
+	if reloaded, err := resolver.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected database to load once present, got %v, %v", reloaded, err)
+	}
+	if got := resolver.Locate("198.51.100.9"); got != "德国" {
+		t.Fatalf("unexpected location: %q", got)
+	}
+}
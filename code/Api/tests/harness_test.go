@@ -0,0 +1,306 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/database"
+	"superhoneypotguard/lifecycle"
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/migrations"
+	"superhoneypotguard/routes"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	adminUsername = "admin"
+	adminPassword = "admin123"
+	hfishAPIKey   = "test-hfish-key"
+	// testSecretsKey 测试环境的主密钥（base64 编码的 32 字节）
+	testSecretsKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	// testSigningKey 测试环境的检查点签名密钥（base64 编码的 32 字节种子）
+	testSigningKey = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+// testEnv 一个完整的 API 实例：内存 SQLite、假 SMTP 服务和模拟 HFish
+type testEnv struct {
+	t      *testing.T
+	db     *gorm.DB
+	engine *gin.Engine
+	smtp   *smtpSink
+	hfish  *hfishMock
+	lc     *lifecycle.Manager
+}
+
+// TestMain 启动整个测试二进制共用的操作日志写入协程，落盘目录使用临时目录
+func TestMain(m *testing.M) {
+	spoolDir, err := os.MkdirTemp("", "superhoneypotguard-log-spool-")
+	if err != nil {
+		log.Fatalf("create log spool dir: %v", err)
+	}
+	opts := middleware.DefaultLogPipelineOptions
+	opts.SpoolDir = spoolDir
+	if err := middleware.ConfigureLogPipeline(opts); err != nil {
+		log.Fatalf("configure log pipeline: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go middleware.RunLogWriter(ctx)
+
+	code := m.Run()
+
+	cancel()
+	os.RemoveAll(spoolDir)
+	os.Exit(code)
+}
+
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// newTestEnv configure 用于在初始化路由前调整默认的测试配置
+func newTestEnv(t *testing.T, configure ...func(cfg *config.Config)) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	smtp := newSMTPSink(t)
+	hfish := newHFishMock(t, hfishAPIKey)
+
+	config.AppConfig = &config.Config{
+		GinMode:         gin.TestMode,
+		DBDriver:        database.DriverSQLite,
+		DBPath:          database.MemoryPath,
+		JWTSecret:       "integration-test-secret",
+		SecretsKey:      testSecretsKey,
+		JWTExpiresIn:    24 * time.Hour,
+		BCryptCost:      bcrypt.MinCost,
+		RateLimitWindow: time.Minute,
+		RateLimitMax:    100000,
+		RemoteIPHeaders: "X-Forwarded-For,X-Real-IP",
+		SMTPHost:        smtp.host,
+		SMTPPort:        smtp.port,
+		SMTPUser:        "noreply@superhoneypotguard.test",
+		HFishBaseURL:    hfish.server.URL + "/api/v1",
+		HFishAPIKey:     hfishAPIKey,
+
+		HealthCheckTimeout: time.Second,
+		HealthCritical:     "database,workers",
+
+		AuditArchiveDir:     filepath.Join(t.TempDir(), "audit-archive"),
+		AuditCheckpointFile: filepath.Join(t.TempDir(), "audit-checkpoints.jsonl"),
+		AuditSigningKey:     testSigningKey,
+
+		// 保留策略不定时执行，由测试手动触发
+		RetentionArchiveDir:        filepath.Join(t.TempDir(), "retention-archive"),
+		RetentionArchive:           "verification_codes",
+		RetentionOperationLogs:     24 * time.Hour,
+		RetentionVerificationCodes: time.Hour,
+	}
+	for _, fn := range configure {
+		fn(config.AppConfig)
+	}
+
+	db, err := database.Open(config.AppConfig)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db.Logger = logger.Discard
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	database.DB = db
+
+	t.Cleanup(func() {
+		middleware.FlushLogs()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// 限流器为进程级全局状态，每个测试环境重新创建
+	if err := middleware.InitRateLimiter(); err != nil {
+		t.Fatalf("init rate limiter: %v", err)
+	}
+	t.Cleanup(func() { middleware.CloseRateLimiter() })
+
+	lc := lifecycle.New()
+	t.Cleanup(func() {
+		if err := lc.Shutdown(5 * time.Second); err != nil {
+			t.Errorf("shutdown background jobs: %v", err)
+		}
+	})
+
+	r := gin.New()
+	if err := utils.ConfigureClientIP(r, config.SplitList(config.AppConfig.TrustedProxies), config.SplitList(config.AppConfig.RemoteIPHeaders)); err != nil {
+		t.Fatalf("configure trusted proxies: %v", err)
+	}
+	routes.SetupRoutes(r, db, lc)
+
+	return &testEnv{t: t, db: db, engine: r, smtp: smtp, hfish: hfish, lc: lc}
+}
+
+// do 发起请求并解析统一响应结构
+func (e *testEnv) do(method, path, token string, body interface{}) (int, apiResponse) {
+	e.t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatalf("marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	e.engine.ServeHTTP(w, req)
+
+	var resp apiResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		e.t.Fatalf("%s %s: invalid JSON response %q: %v", method, path, w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+// mustOK 断言请求成功并把 data 解析到 out
+func (e *testEnv) mustOK(method, path, token string, body interface{}, out interface{}) {
+	e.t.Helper()
+
+	status, resp := e.do(method, path, token, body)
+	if status != http.StatusOK || !resp.Success {
+		e.t.Fatalf("%s %s: expected success, got %d %q", method, path, status, resp.Message)
+	}
+	if out != nil {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			e.t.Fatalf("%s %s: decode data: %v", method, path, err)
+		}
+	}
+}
+
+// expectStatus 断言请求失败并返回指定状态码
+func (e *testEnv) expectStatus(want int, method, path, token string, body interface{}) apiResponse {
+	e.t.Helper()
+
+	status, resp := e.do(method, path, token, body)
+	if status != want {
+		e.t.Fatalf("%s %s: expected status %d, got %d %q", method, path, want, status, resp.Message)
+	}
+	if want != http.StatusOK && resp.Success {
+		e.t.Fatalf("%s %s: expected failure response, got success", method, path)
+	}
+	return resp
+}
+
+func (e *testEnv) login(username, password string) string {
+	e.t.Helper()
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	e.mustOK(http.MethodPost, "/api/auth/login", "", gin.H{
+		"username": username,
+		"password": password,
+	}, &data)
+	if data.Token == "" {
+		e.t.Fatalf("login %s: empty token", username)
+	}
+	return data.Token
+}
+
+func (e *testEnv) adminToken() string {
+	return e.login(adminUsername, adminPassword)
+}
+
+// createUser 以管理员身份创建用户，并返回新用户 ID
+func (e *testEnv) createUser(adminToken, username, password string, roleIDs ...int) int {
+	e.t.Helper()
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	e.mustOK(http.MethodPost, "/api/user/", adminToken, gin.H{
+		"username": username,
+		"password": password,
+		"email":    username + "@example.test",
+		"roleIds":  roleIDs,
+	}, &created)
+	return created.ID
+}
+
+// roleID 通过角色编码查找角色 ID
+func (e *testEnv) roleID(adminToken, roleCode string) int {
+	e.t.Helper()
+
+	var roles []struct {
+		ID       int    `json:"id"`
+		RoleCode string `json:"roleCode"`
+	}
+	e.mustOK(http.MethodGet, "/api/role/all", adminToken, nil, &roles)
+	for _, role := range roles {
+		if role.RoleCode == roleCode {
+			return role.ID
+		}
+	}
+	e.t.Fatalf("role %q not found", roleCode)
+	return 0
+}
+
+// createRole 以管理员身份创建拥有指定权限的角色，并返回角色 ID
+func (e *testEnv) createRole(adminToken, roleCode string, permissionCodes ...string) int {
+	e.t.Helper()
+
+	var permissions []struct {
+		ID             int    `json:"id"`
+		PermissionCode string `json:"permissionCode"`
+	}
+	e.mustOK(http.MethodGet, "/api/permission/all", adminToken, nil, &permissions)
+	ids := make([]int, 0, len(permissionCodes))
+	for _, code := range permissionCodes {
+		found := false
+		for _, p := range permissions {
+			if p.PermissionCode == code {
+				ids = append(ids, p.ID)
+				found = true
+			}
+		}
+		if !found {
+			e.t.Fatalf("permission %q not found", code)
+		}
+	}
+
+	var role struct {
+		ID int `json:"id"`
+	}
+	e.mustOK(http.MethodPost, "/api/role/", adminToken, gin.H{
+		"roleName":      roleCode,
+		"roleCode":      roleCode,
+		"permissionIds": ids,
+	}, &role)
+	return role.ID
+}
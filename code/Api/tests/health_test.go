@@ -0,0 +1,323 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/lifecycle"
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/services"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// ready 请求就绪检查，返回状态码和检查结果
+// ready 以管理员身份读取就绪检查明细
+func (e *testEnv) ready() (int, services.HealthReport) {
+	e.t.Helper()
+
+	status, resp := e.do(http.MethodGet, "/api/system/health", e.adminToken(), nil)
+	var report services.HealthReport
+	if err := json.Unmarshal(resp.Data, &report); err != nil {
+		e.t.Fatalf("ready: decode report: %v", err)
+	}
+	if resp.Success != report.Ready() {
+		e.t.Fatalf("ready: success %v does not match status %q", resp.Success, report.Status)
+	}
+	return status, report
+}
+
+// probe 以未认证的探针身份请求就绪检查，返回状态码和原始 data
+func (e *testEnv) probe() (int, map[string]json.RawMessage) {
+	e.t.Helper()
+
+	w := e.doFrom("192.0.2.1:40000", nil, http.MethodGet, "/api/health/ready", nil)
+	var resp apiResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		e.t.Fatalf("probe: invalid JSON response %q: %v", w.Body.String(), err)
+	}
+	var data map[string]json.RawMessage
+	if len(resp.Data) == 0 {
+		return w.Code, data
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		e.t.Fatalf("probe: decode data: %v", err)
+	}
+	return w.Code, data
+}
+
+func component(report services.HealthReport, name string) services.ComponentHealth {
+	for _, c := range report.Components {
+		if c.Name == name {
+			return c
+		}
+	}
+	return services.ComponentHealth{}
+}
+
+// closedPort 返回一个当前没有监听的本地端口
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func TestHealthLive(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, path := range []string{"/api/health", "/api/health/live"} {
+		var live services.LivenessReport
+		env.mustOK(http.MethodGet, path, "", nil, &live)
+		if live.Status != services.HealthOK || live.StartedAt.IsZero() {
+			t.Fatalf("%s: unexpected liveness report %+v", path, live)
+		}
+	}
+}
+
+func TestHealthReady(t *testing.T) {
+	env := newTestEnv(t)
+
+	status, report := env.ready()
+	if status != http.StatusOK || report.Status != services.HealthOK {
+		t.Fatalf("expected ready, got %d %+v", status, report)
+	}
+	var names []string
+	for _, c := range report.Components {
+		names = append(names, c.Name)
+		if c.Status != services.HealthOK {
+			t.Fatalf("expected %s to be ok, got %+v", c.Name, c)
+		}
+	}
+	if got := strings.Join(names, ","); got != "database,hfish,smtp,workers" {
+		t.Fatalf("unexpected components %s", got)
+	}
+	if !component(report, "database").Critical || component(report, "hfish").Critical {
+		t.Fatalf("unexpected critical flags %+v", report.Components)
+	}
+
+	// HFish 拒绝请求时只是降级，错误信息中不包含 api_key
+	env.hfish.rotateAPIKey("rotated-key")
+	status, report = env.ready()
+	if status != http.StatusOK || report.Status != services.HealthDegraded {
+		t.Fatalf("expected degraded, got %d %+v", status, report)
+	}
+	hfish := component(report, "hfish")
+	if hfish.Status != services.HealthDown || !strings.Contains(hfish.Error, "api key invalid") {
+		t.Fatalf("unexpected hfish status %+v", hfish)
+	}
+	if strings.Contains(hfish.Error, hfishAPIKey) {
+		t.Fatalf("hfish error must not expose the api key: %q", hfish.Error)
+	}
+
+	// 未认证的探针只能看到整体状态
+	status, data := env.probe()
+	if status != http.StatusOK || string(data["status"]) != `"`+services.HealthDegraded+`"` {
+		t.Fatalf("expected degraded probe, got %d %v", status, data)
+	}
+	if len(data) != 2 || data["timestamp"] == nil {
+		t.Fatalf("probe must only expose status and timestamp, got %v", data)
+	}
+
+	// 组件明细需要 system:settings 权限
+	env.expectStatus(http.StatusUnauthorized, http.MethodGet, "/api/system/health", "", nil)
+	roleID := env.createRole(env.adminToken(), "health-viewer", "hfish:view")
+	env.createUser(env.adminToken(), "health-viewer", "Viewer@123", roleID)
+	env.expectStatus(http.StatusForbidden, http.MethodGet, "/api/system/health", env.login("health-viewer", "Viewer@123"), nil)
+}
+
+func TestHealthReadyUnreachableDependencies(t *testing.T) {
+	port := closedPort(t)
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.SMTPPort = strconv.Itoa(port)
+		cfg.HFishBaseURL = "http://127.0.0.1:" + strconv.Itoa(port) + "/api/v1"
+	})
+
+	status, report := env.ready()
+	if status != http.StatusOK || report.Status != services.HealthDegraded {
+		t.Fatalf("expected degraded, got %d %+v", status, report)
+	}
+	for _, name := range []string{"hfish", "smtp"} {
+		if c := component(report, name); c.Status != services.HealthDown || c.Error == "" {
+			t.Fatalf("expected %s to be down, got %+v", name, c)
+		}
+	}
+	if hfish := component(report, "hfish"); strings.Contains(hfish.Error, hfishAPIKey) || !strings.HasPrefix(hfish.Error, "default: 调用 HFish API 失败") {
+		t.Fatalf("unexpected hfish error %q", hfish.Error)
+	}
+}
+
+func TestHealthCriticalComponents(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.HealthCritical = "database,hfish"
+	})
+
+	env.hfish.rotateAPIKey("rotated-key")
+	status, report := env.ready()
+	if status != http.StatusServiceUnavailable || report.Status != services.HealthUnavailable {
+		t.Fatalf("expected unavailable, got %d %+v", status, report)
+	}
+	if !component(report, "hfish").Critical {
+		t.Fatalf("expected hfish to be critical")
+	}
+	if status, data := env.probe(); status != http.StatusServiceUnavailable || data["components"] != nil {
+		t.Fatalf("expected unavailable probe without components, got %d %v", status, data)
+	}
+
+	// 存活检查不受依赖组件影响
+	env.mustOK(http.MethodGet, "/api/health/live", "", nil, nil)
+}
+
+func TestHealthStaleWorker(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.HealthCritical = "workers"
+	})
+
+	env.lc.Add(lifecycle.Component{
+		Name: "stuck",
+		Run: func(ctx context.Context) error {
+			lifecycle.Beat(ctx, 10*time.Millisecond)
+			<-ctx.Done()
+			return nil
+		},
+	})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, report := env.ready()
+		workers := component(report, "workers")
+		if status == http.StatusServiceUnavailable && strings.Contains(workers.Error, "stuck") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected stale worker to fail readiness, got %d %+v", status, workers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHealthRedis(t *testing.T) {
+	srv := miniredis.RunT(t)
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.RateLimitBackend = "redis"
+		cfg.RedisHost = srv.Host()
+		cfg.RedisPort = srv.Port()
+	})
+
+	_, report := env.ready()
+	if c := component(report, "redis"); c.Name == "" || c.Status != services.HealthOK {
+		t.Fatalf("expected redis to be checked, got %+v", report.Components)
+	}
+
+	srv.Close()
+	status, report := env.ready()
+	if c := component(report, "redis"); c.Status != services.HealthDown {
+		t.Fatalf("expected redis to be down, got %+v", c)
+	}
+	if status != http.StatusOK || report.Status != services.HealthDegraded {
+		t.Fatalf("expected degraded, got %d %s", status, report.Status)
+	}
+}
+
+func TestHealthProbesRateLimitedButNotLogged(t *testing.T) {
+	env := newTestEnv(t)
+	middleware.ConfigureRateLimiter(time.Minute, 1)
+
+	// 存活检查不访问依赖，不限流；就绪检查套用全局限流
+	for i := 0; i < 5; i++ {
+		env.mustOK(http.MethodGet, "/api/health/live", "", nil, nil)
+	}
+	if status, _ := env.probe(); status != http.StatusOK {
+		t.Fatalf("expected the first readiness probe to pass, got %d", status)
+	}
+	if status, _ := env.probe(); status != http.StatusTooManyRequests {
+		t.Fatalf("expected readiness probes to be rate limited, got %d", status)
+	}
+
+	middleware.FlushLogs()
+	var probes int64
+	env.db.Raw("SELECT COUNT(*) FROM operation_logs WHERE url LIKE ?", "/api/health%").Scan(&probes)
+	if probes != 0 {
+		t.Fatalf("expected probes not to be written to the operation log, got %d", probes)
+	}
+}
+
+// 未认证的就绪检查复用最近一次结果并合并并发请求，不会放大到 HFish
+func TestHealthReadyCached(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.HealthCheckTimeout = time.Minute
+	})
+	hfishCalls := func() int {
+		env.hfish.mu.Lock()
+		defer env.hfish.mu.Unlock()
+		return len(env.hfish.requestIDs)
+	}
+
+	before := hfishCalls()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env.doFrom("192.0.2.1:40000", nil, http.MethodGet, "/api/health/ready", nil)
+		}()
+	}
+	wg.Wait()
+	if got := hfishCalls() - before; got != 1 {
+		t.Fatalf("expected concurrent probes to share one HFish check, got %d", got)
+	}
+	if status, _ := env.probe(); status != http.StatusOK || hfishCalls()-before != 1 {
+		t.Fatalf("expected a cached probe result, got %d with %d HFish calls", status, hfishCalls()-before)
+	}
+
+	// 明细接口每次重新检查，结果同时刷新探针的缓存
+	env.hfish.rotateAPIKey("rotated-key")
+	if _, report := env.ready(); report.Status != services.HealthDegraded {
+		t.Fatalf("expected a fresh degraded report, got %+v", report)
+	}
+	if _, data := env.probe(); string(data["status"]) != `"`+services.HealthDegraded+`"` {
+		t.Fatalf("expected the probe to see the refreshed report, got %v", data)
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	svc := services.NewHealthService(50*time.Millisecond,
+		services.HealthCheck{Name: "fast", Check: func(ctx context.Context) error { return nil }},
+		services.HealthCheck{Name: "slow", Critical: true, Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		services.HealthCheck{Name: "broken", Check: func(ctx context.Context) error { return errors.New("boom") }},
+	)
+
+	start := time.Now()
+	report := svc.Ready(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("checks should run in parallel with a timeout, took %s", elapsed)
+	}
+	if report.Ready() || report.Status != services.HealthUnavailable {
+		t.Fatalf("expected unavailable, got %s", report.Status)
+	}
+	if c := component(*report, "slow"); c.Status != services.HealthDown || !strings.Contains(c.Error, "检查超时") {
+		t.Fatalf("unexpected slow check %+v", c)
+	}
+	if c := component(*report, "broken"); c.Error != "boom" {
+		t.Fatalf("unexpected broken check %+v", c)
+	}
+	if c := component(*report, "fast"); c.Status != services.HealthOK {
+		t.Fatalf("unexpected fast check %+v", c)
+	}
+}
@@ -0,0 +1,390 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/secrets"
+	"superhoneypotguard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type hfishInstance struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	BaseURL       string `json:"baseUrl"`
+	APIKey        string `json:"apiKey"`
+	TLSSkipVerify bool   `json:"tlsSkipVerify"`
+	Enabled       bool   `json:"enabled"`
+}
+
+// addHFishInstance 登记一个指向 mock 的实例，返回实例 ID
+func (e *testEnv) addHFishInstance(token, name string, mock *hfishMock, apiKey string) int {
+	e.t.Helper()
+
+	var created hfishInstance
+	e.mustOK(http.MethodPost, "/api/hfish/instances", token, gin.H{
+		"name":    name,
+		"baseUrl": mock.server.URL + "/api/v1",
+		"apiKey":  apiKey,
+	}, &created)
+	return created.ID
+}
+
+func TestSecretsCipher(t *testing.T) {
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := secrets.New(key, nil)
+	if err != nil {
+		t.Fatalf("create cipher: %v", err)
+	}
+
+	sealed, err := cipher.Encrypt("hfish_instances.api_key", "plain-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, "v1:"+cipher.KeyID()+":") || strings.Contains(sealed, "plain-key") {
+		t.Fatalf("unexpected ciphertext %q", sealed)
+	}
+	if again, _ := cipher.Encrypt("hfish_instances.api_key", "plain-key"); again == sealed {
+		t.Fatalf("encryption must use a random nonce")
+	}
+	if plain, err := cipher.Decrypt("hfish_instances.api_key", sealed); err != nil || plain != "plain-key" {
+		t.Fatalf("decrypt: %q %v", plain, err)
+	}
+
+	// 密文不能挪用到其他字段
+	if _, err := cipher.Decrypt("system_settings.smtp_password", sealed); err == nil {
+		t.Fatalf("expected label mismatch to fail")
+	}
+
+	// 其他密钥无法解密
+	other, _ := secrets.New(testSecretsKey, nil)
+	if _, err := other.Decrypt("hfish_instances.api_key", sealed); !errors.Is(err, secrets.ErrUnknownKey) {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+
+	if _, err := secrets.New("not-base64", nil); err == nil {
+		t.Fatalf("expected invalid key to be rejected")
+	}
+	// 主密钥必须单独配置
+	if _, err := secrets.New("", nil); !errors.Is(err, secrets.ErrNoKey) {
+		t.Fatalf("expected a missing key to be rejected, got %v", err)
+	}
+	cfg := config.Default()
+	cfg.JWTSecret = "integration-test-secret"
+	for _, key := range []string{"", "c2hvcnQ="} {
+		cfg.SecretsKey = key
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "secrets_key") {
+			t.Fatalf("%q: expected secrets_key validation error, got %v", key, err)
+		}
+	}
+}
+
+func TestHFishInstanceLegacyImport(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	var instances []hfishInstance
+	env.mustOK(http.MethodGet, "/api/hfish/instances", admin, nil, &instances)
+	if len(instances) != 1 {
+		t.Fatalf("expected the configured HFish to be imported, got %+v", instances)
+	}
+	legacy := instances[0]
+	if legacy.Name != "default" || legacy.BaseURL != env.hfish.server.URL+"/api/v1" || legacy.APIKey != "******" || legacy.TLSSkipVerify || !legacy.Enabled {
+		t.Fatalf("unexpected imported instance %+v", legacy)
+	}
+
+	// 数据库中只保存密文
+	var stored string
+	env.db.Raw("SELECT api_key FROM hfish_instances WHERE id = ?", legacy.ID).Scan(&stored)
+	if !strings.HasPrefix(stored, "v1:") || strings.Contains(stored, hfishAPIKey) {
+		t.Fatalf("api key must be stored encrypted, got %q", stored)
+	}
+}
+
+func TestHFishInstanceLegacyImportVerifiesTLS(t *testing.T) {
+	// 默认校验证书，自签名证书的 HFish 在第一次调用时即报告证书校验失败
+	mock := newHFishTLSMock(t, hfishAPIKey, nil)
+	env := newTestEnv(t, func(cfg *config.Config) { cfg.HFishBaseURL = mock.server.URL + "/api/v1" })
+	admin := env.adminToken()
+
+	resp := env.expectStatus(http.StatusInternalServerError, http.MethodGet, "/api/hfish/sys/info", admin, nil)
+	if !strings.Contains(resp.Message, "证书校验失败") {
+		t.Fatalf("expected a certificate verification error, got %q", resp.Message)
+	}
+
+	// 显式开启后才沿用不校验证书的行为
+	mock = newHFishTLSMock(t, hfishAPIKey, nil)
+	env = newTestEnv(t, func(cfg *config.Config) {
+		cfg.HFishBaseURL = mock.server.URL + "/api/v1"
+		cfg.HFishLegacySkipVerify = true
+	})
+	admin = env.adminToken()
+	var instances []hfishInstance
+	env.mustOK(http.MethodGet, "/api/hfish/instances", admin, nil, &instances)
+	if len(instances) != 1 || !instances[0].TLSSkipVerify {
+		t.Fatalf("expected the imported instance to skip verification, got %+v", instances)
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/sys/info", admin, nil, nil)
+}
+
+func TestHFishInstanceCRUD(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	other := newHFishMock(t, "other-key")
+	id := env.addHFishInstance(admin, "site-b", other, "other-key")
+
+	var detail hfishInstance
+	env.mustOK(http.MethodGet, pathf("/api/hfish/instances/%d", id), admin, nil, &detail)
+	if detail.Name != "site-b" || detail.APIKey != "******" || detail.TLSSkipVerify || !detail.Enabled {
+		t.Fatalf("unexpected instance %+v", detail)
+	}
+
+	// 名称重复、地址无效、缺少 API Key
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/hfish/instances", admin, gin.H{
+		"name": "site-b", "baseUrl": other.server.URL, "apiKey": "k",
+	})
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/hfish/instances", admin, gin.H{
+		"name": "site-c", "baseUrl": "hfish:4433", "apiKey": "k",
+	})
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/hfish/instances", admin, gin.H{
+		"name": "site-c", "baseUrl": other.server.URL,
+	})
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, pathf("/api/hfish/instances/%d", id), admin, gin.H{"name": "default"})
+
+	// 提交脱敏值时保留原 API Key，实例仍可调用
+	sysInfo := pathf("/api/hfish/sys/info?instanceId=%d", id)
+	env.mustOK(http.MethodPut, pathf("/api/hfish/instances/%d", id), admin, gin.H{"name": "site-b2", "apiKey": "******"}, nil)
+	env.mustOK(http.MethodGet, sysInfo, admin, nil, nil)
+
+	// 更换 API Key 立即生效
+	other.rotateAPIKey("rotated-key")
+	env.expectStatus(http.StatusInternalServerError, http.MethodGet, sysInfo, admin, nil)
+	env.mustOK(http.MethodPut, pathf("/api/hfish/instances/%d", id), admin, gin.H{"apiKey": "rotated-key"}, nil)
+	env.mustOK(http.MethodGet, sysInfo, admin, nil, nil)
+
+	// 审计记录字段变更，API Key 只记录发生了替换
+	logs := env.auditLogs(admin, "action=hfish_instance.update")
+	if len(logs) != 3 || logs[2].Status != 0 {
+		t.Fatalf("expected a rejected and two applied update events, got %+v", logs)
+	}
+	if logs[0].Changes == nil || *logs[0].Changes != `{"apiKey":"******"}` {
+		t.Fatalf("expected api key replacement to be audited without its value, got %v", logs[0].Changes)
+	}
+	if changes := changesOf(t, logs[1]); changes["name"].Before != "site-b" || changes["name"].After != "site-b2" {
+		t.Fatalf("expected rename to be audited, got %+v", changes)
+	}
+	if _, ok := changesOf(t, logs[1])["apiKey"]; ok {
+		t.Fatalf("redacted api key must not count as a change")
+	}
+	for _, entry := range env.capturedLogs(admin, "operation=/api/hfish/instances") {
+		if entry.Params != nil && (strings.Contains(*entry.Params, "other-key") || strings.Contains(*entry.Params, "rotated-key")) {
+			t.Fatalf("operation log leaks the api key: %s", *entry.Params)
+		}
+	}
+
+	env.mustOK(http.MethodDelete, pathf("/api/hfish/instances/%d", id), admin, nil, nil)
+	env.expectStatus(http.StatusNotFound, http.MethodGet, pathf("/api/hfish/instances/%d", id), admin, nil)
+	env.expectStatus(http.StatusNotFound, http.MethodGet, pathf("/api/hfish/attack/ips?instanceId=%d", id), admin, nil)
+}
+
+// 修改地址时不能沿用已保存的 API Key，否则操作员可以把密钥发往自己的服务器
+func TestHFishInstanceMoveRequiresSecrets(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	other := newHFishMock(t, "other-key")
+	id := env.addHFishInstance(admin, "site-b", other, "other-key")
+	path := pathf("/api/hfish/instances/%d", id)
+	attackIPs := pathf("/api/hfish/attack/ips?instanceId=%d", id)
+
+	rogue := newHFishMock(t, "rogue-key")
+	rogueURL := rogue.server.URL + "/api/v1"
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, path, admin, gin.H{"baseUrl": rogueURL})
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, path, admin, gin.H{"baseUrl": rogueURL, "apiKey": "******"})
+	env.mustOK(http.MethodGet, attackIPs, admin, nil, nil)
+	if ids := rogue.receivedRequestIDs(); len(ids) != 0 {
+		t.Fatalf("expected no request to reach the new address, got %d", len(ids))
+	}
+
+	// 地址不变时可以不填写 API Key
+	env.mustOK(http.MethodPut, path, admin, gin.H{"baseUrl": other.server.URL + "/api/v1", "remark": "same host"}, nil)
+
+	// 同时提供新 API Key 时可以修改地址
+	env.mustOK(http.MethodPut, path, admin, gin.H{"baseUrl": rogueURL, "apiKey": "rogue-key"}, nil)
+	env.mustOK(http.MethodGet, attackIPs, admin, nil, nil)
+	if len(rogue.receivedRequestIDs()) == 0 {
+		t.Fatalf("expected the instance to use the new address")
+	}
+}
+
+func TestHFishInstanceAggregation(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	other := newHFishMock(t, "other-key")
+	id := env.addHFishInstance(admin, "site-b", other, "other-key")
+
+	var ips struct {
+		List []struct {
+			IP         string `json:"ip"`
+			InstanceID int    `json:"instanceId"`
+			Instance   string `json:"instance"`
+		} `json:"list"`
+		Total int64 `json:"total"`
+	}
+	// 两个实例返回相同的数据，按 IP 排序后相同地址的行保持实例顺序
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips?sortBy=ip&sortOrder=asc", admin, nil, &ips)
+	if ips.Total != 4 || len(ips.List) != 4 || ips.List[0].Instance != "default" || ips.List[1].Instance != "site-b" || ips.List[1].InstanceID != id {
+		t.Fatalf("expected rows from both instances, got %+v", ips)
+	}
+	env.mustOK(http.MethodGet, pathf("/api/hfish/attack/ips?instanceId=%d", id), admin, nil, &ips)
+	if ips.Total != 2 || ips.List[0].Instance != "site-b" {
+		t.Fatalf("expected rows from site-b only, got %+v", ips)
+	}
+	env.expectStatus(http.StatusBadRequest, http.MethodGet, "/api/hfish/attack/ips?instanceId=abc", admin, nil)
+
+	var info services.SysInfo
+	env.mustOK(http.MethodGet, "/api/hfish/sys/info", admin, nil, &info)
+	if info.TotalHoneypots != 10 || info.TotalAttacks != 2468 || info.SystemStatus != "running" || len(info.Instances) != 2 {
+		t.Fatalf("unexpected aggregated sys info %+v", info)
+	}
+
+	// 同步失败的实例返回已保存的数据，并在响应头中标记为过期
+	other.rotateAPIKey("rotated-key")
+	w := env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + admin}, http.MethodGet, "/api/hfish/attack/ips", nil)
+	if w.Code != http.StatusOK || w.Header().Get("X-HFish-Stale") != pathf("%d", id) {
+		t.Fatalf("expected stale data for site-b, got %d %q", w.Code, w.Header().Get("X-HFish-Stale"))
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips", admin, nil, &ips)
+	if ips.Total != 4 {
+		t.Fatalf("expected stored rows of the failing instance to be served, got %+v", ips)
+	}
+
+	// 直接调用 HFish 的接口返回其余实例的数据，并在响应头中列出失败的实例
+	w = env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + admin}, http.MethodGet, "/api/hfish/account/info", nil)
+	if w.Code != http.StatusOK || w.Header().Get("X-HFish-Unavailable") != pathf("%d", id) {
+		t.Fatalf("expected partial result, got %d %q", w.Code, w.Header().Get("X-HFish-Unavailable"))
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/sys/info", admin, nil, &info)
+	if info.SystemStatus != "partial" || len(info.Instances) != 1 {
+		t.Fatalf("expected partial sys info, got %+v", info)
+	}
+
+	// 全部失败时直接调用 HFish 的接口返回错误，攻击数据仍返回已保存的数据
+	env.hfish.rotateAPIKey("rotated-key")
+	resp := env.expectStatus(http.StatusInternalServerError, http.MethodGet, "/api/hfish/account/info", admin, nil)
+	if !strings.Contains(resp.Message, "default: api key invalid") || !strings.Contains(resp.Message, "site-b: api key invalid") {
+		t.Fatalf("unexpected message %q", resp.Message)
+	}
+	w = env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + admin}, http.MethodGet, "/api/hfish/attack/ips", nil)
+	if w.Code != http.StatusOK || w.Header().Get("X-HFish-Stale") != pathf("1,%d", id) {
+		t.Fatalf("expected stale data for every instance, got %d %q", w.Code, w.Header().Get("X-HFish-Stale"))
+	}
+
+	// 停用的实例不参与汇总，也不能单独查询
+	env.mustOK(http.MethodPut, pathf("/api/hfish/instances/%d", id), admin, gin.H{"enabled": false}, nil)
+	resp = env.expectStatus(http.StatusInternalServerError, http.MethodGet, "/api/hfish/sys/info", admin, nil)
+	if resp.Message != "api key invalid" {
+		t.Fatalf("expected only the default instance to be called, got %q", resp.Message)
+	}
+	env.expectStatus(http.StatusBadRequest, http.MethodGet, pathf("/api/hfish/attack/ips?instanceId=%d", id), admin, nil)
+}
+
+func TestHFishBlockFanOut(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	other := newHFishMock(t, "other-key")
+	id := env.addHFishInstance(admin, "site-b", other, "other-key")
+
+	var results []services.HFishBlockResult
+	env.mustOK(http.MethodPost, "/api/hfish/block/ip", admin, gin.H{"ip": "203.0.113.7", "instanceIds": []int{id}}, &results)
+	if len(results) != 1 || !results[0].Success || results[0].Instance != "site-b" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if len(env.hfish.blockedIPs()) != 0 || len(other.blockedIPs()) != 1 {
+		t.Fatalf("block should only reach site-b")
+	}
+
+	// 未指定实例时发往全部已启用的实例
+	env.mustOK(http.MethodPost, "/api/hfish/block/ip", admin, gin.H{"ip": "203.0.113.8"}, &results)
+	if len(results) != 2 || !results[0].Success || !results[1].Success {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if blocked := env.hfish.blockedIPs(); len(blocked) != 1 || blocked[0] != "203.0.113.8" {
+		t.Fatalf("expected default to block 203.0.113.8, got %v", blocked)
+	}
+
+	// 部分实例失败时返回各实例的结果
+	other.rotateAPIKey("rotated-key")
+	env.mustOK(http.MethodPost, "/api/hfish/block/ip", admin, gin.H{"ip": "203.0.113.9"}, &results)
+	if len(results) != 2 || !results[0].Success || results[1].Success || results[1].Message != "封禁 IP 失败: api key invalid" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	env.expectStatus(http.StatusNotFound, http.MethodPost, "/api/hfish/block/ip", admin, gin.H{"ip": "203.0.113.9", "instanceIds": []int{999}})
+}
+
+func TestHFishInstancePermissions(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	var permissions []struct {
+		ID             int    `json:"id"`
+		PermissionCode string `json:"permissionCode"`
+	}
+	env.mustOK(http.MethodGet, "/api/permission/all", admin, nil, &permissions)
+	var viewID int
+	for _, p := range permissions {
+		if p.PermissionCode == "hfish:view" {
+			viewID = p.ID
+		}
+	}
+	var role struct {
+		ID int `json:"id"`
+	}
+	env.mustOK(http.MethodPost, "/api/role/", admin, gin.H{
+		"roleName":      "分析员",
+		"roleCode":      "analyst",
+		"permissionIds": []int{viewID},
+	}, &role)
+	env.createUser(admin, "grace", "grace123", role.ID)
+	token := env.login("grace", "grace123")
+
+	// 查看数据的用户可以列出实例用于筛选，但不能管理实例
+	var instances []hfishInstance
+	env.mustOK(http.MethodGet, "/api/hfish/instances", token, nil, &instances)
+	if len(instances) != 1 || instances[0].APIKey != "******" {
+		t.Fatalf("unexpected instances %+v", instances)
+	}
+	env.expectStatus(http.StatusForbidden, http.MethodPost, "/api/hfish/instances", token, gin.H{
+		"name": "site-c", "baseUrl": "https://hfish.example.test/api/v1", "apiKey": "k",
+	})
+	env.expectStatus(http.StatusForbidden, http.MethodDelete, pathf("/api/hfish/instances/%d", instances[0].ID), token, nil)
+}
+
+func TestHFishHealthChecksEveryInstance(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	other := newHFishMock(t, "other-key")
+	env.addHFishInstance(admin, "site-b", other, "other-key")
+	if _, report := env.ready(); component(report, "hfish").Status != services.HealthOK {
+		t.Fatalf("expected hfish to be up, got %+v", component(report, "hfish"))
+	}
+
+	other.rotateAPIKey("rotated-key")
+	_, report := env.ready()
+	if hfish := component(report, "hfish"); hfish.Status != services.HealthDown || !strings.HasPrefix(hfish.Error, "site-b: ") || strings.Contains(hfish.Error, "default") {
+		t.Fatalf("expected only site-b to be reported, got %+v", hfish)
+	}
+}
@@ -0,0 +1,259 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryDetail 攻击详情查询结果中用到的字段
+type queryDetail struct {
+	ID          string `json:"id"`
+	IP          string `json:"ip"`
+	Port        int    `json:"port"`
+	RequestTime string `json:"request_time"`
+	Instance    string `json:"instance"`
+}
+
+type queryDetailPage struct {
+	List     []queryDetail `json:"list"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+}
+
+// attackDetailSeed 每个实例返回的攻击详情，覆盖不同的地址、协议、端口和时间
+var attackDetailSeed = []map[string]interface{}{
+	{"id": "1", "ip": "203.0.113.7", "attack_type": "brute_force", "protocol": "ssh", "port": 22, "request_time": "2026-10-18 08:00:00", "account": "root"},
+	{"id": "2", "ip": "203.0.113.8", "attack_type": "brute_force", "protocol": "SSH", "port": 2222, "request_time": "2026-10-18 09:00:00", "account": "admin"},
+	{"id": "3", "ip": "198.51.100.9", "attack_type": "web_scan", "protocol": "http", "port": 80, "request_time": "2026-10-17 10:00:00", "account": ""},
+	{"id": "4", "ip": "2001:db8::1", "attack_type": "web_scan", "protocol": "https", "port": 443, "request_time": "2026-10-16 12:00:00", "account": ""},
+	{"id": "5", "ip": "203.0.113.7", "attack_type": "exploit", "protocol": "redis", "port": 6379, "request_time": "2026-10-18 10:30:00", "account": "Administrator"},
+}
+
+func (e *testEnv) queryDetails(token string, query url.Values) queryDetailPage {
+	e.t.Helper()
+
+	var page queryDetailPage
+	e.mustOK(http.MethodGet, "/api/hfish/attack/details?"+query.Encode(), token, nil, &page)
+	return page
+}
+
+func TestHFishQueryFilters(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	env.hfish.setAttackDetails(attackDetailSeed)
+
+	cases := []struct {
+		query url.Values
+		want  int64
+	}{
+		{url.Values{}, 5},
+		{url.Values{"ip": {"203.0.113.7"}}, 2},
+		{url.Values{"ip": {"203.0.113.0/24"}}, 3},
+		{url.Values{"ip": {"2001:db8::/32"}}, 1},
+		{url.Values{"attackType": {"BRUTE_FORCE"}}, 2},
+		{url.Values{"attackType": {"web_scan,exploit"}}, 3},
+		{url.Values{"protocol": {"ssh"}}, 2},
+		{url.Values{"port": {"22, 80"}}, 2},
+		{url.Values{"account": {"admin"}}, 2},
+		{url.Values{"startTime": {"2026-10-18 00:00:00"}}, 3},
+		{url.Values{"startTime": {"2026-10-17"}, "endTime": {"2026-10-18 09:00:00"}}, 2},
+		{url.Values{"ip": {"203.0.113.0/24"}, "protocol": {"ssh"}, "port": {"2222"}}, 1},
+		{url.Values{"ip": {"192.0.2.0/24"}}, 0},
+	}
+	for _, tc := range cases {
+		if got := env.queryDetails(admin, tc.query); got.Total != tc.want || int64(len(got.List)) != tc.want {
+			t.Errorf("%s: expected %d details, got %d (%d listed)", tc.query.Encode(), tc.want, got.Total, len(got.List))
+		}
+	}
+
+	// 攻击 IP 按活跃区间与时间段有交集筛选
+	var ips struct {
+		List []struct {
+			IP string `json:"ip"`
+		} `json:"list"`
+		Total int64 `json:"total"`
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips?startTime=2026-10-18", admin, nil, &ips)
+	if ips.Total != 1 || ips.List[0].IP != "203.0.113.7" {
+		t.Fatalf("expected only 203.0.113.7 active on 2026-10-18, got %+v", ips)
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips?ip=198.51.100.0/24", admin, nil, &ips)
+	if ips.Total != 1 || ips.List[0].IP != "198.51.100.9" {
+		t.Fatalf("expected only 198.51.100.9, got %+v", ips)
+	}
+
+	for _, path := range []string{
+		"/api/hfish/attack/ips?attackType=brute_force",
+		"/api/hfish/attack/ips?account=root",
+		"/api/hfish/attack/ips?sortBy=port",
+		"/api/hfish/attack/details?ip=not-an-ip",
+		"/api/hfish/attack/details?port=ssh",
+		"/api/hfish/attack/details?port=70000",
+		"/api/hfish/attack/details?startTime=yesterday",
+		"/api/hfish/attack/details?startTime=2026-10-18&endTime=2026-10-17",
+		"/api/hfish/attack/details?sortBy=payload",
+		"/api/hfish/attack/details?sortOrder=up",
+	} {
+		env.expectStatus(http.StatusBadRequest, http.MethodGet, path, admin, nil)
+	}
+}
+
+func TestHFishQuerySortAndPage(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	env.hfish.setAttackDetails(attackDetailSeed)
+	other := newHFishMock(t, "other-key")
+	other.setAttackDetails(attackDetailSeed[:2])
+	env.addHFishInstance(admin, "site-b", other, "other-key")
+
+	// 默认按请求时间倒序，相同时间的行保持实例顺序
+	page := env.queryDetails(admin, url.Values{"pageSize": {"3"}})
+	if page.Total != 7 || page.Page != 1 || page.PageSize != 3 || len(page.List) != 3 {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	if page.List[0].ID != "5" || page.List[1].ID != "2" || page.List[1].Instance != "default" || page.List[2].Instance != "site-b" {
+		t.Fatalf("expected newest details first, got %+v", page.List)
+	}
+
+	// 翻页覆盖全部结果，没有重复也没有遗漏
+	seen := make(map[string]bool)
+	for n := 1; n <= 3; n++ {
+		page = env.queryDetails(admin, url.Values{"pageSize": {"3"}, "page": {pathf("%d", n)}})
+		for _, row := range page.List {
+			seen[row.Instance+"/"+row.ID] = true
+		}
+	}
+	if len(page.List) != 1 || len(seen) != 7 {
+		t.Fatalf("expected 7 distinct details over 3 pages, got %d (last page %+v)", len(seen), page.List)
+	}
+	if page = env.queryDetails(admin, url.Values{"pageSize": {"3"}, "page": {"9"}}); page.Total != 7 || page.List == nil || len(page.List) != 0 {
+		t.Fatalf("expected an empty list past the last page, got %+v", page)
+	}
+
+	page = env.queryDetails(admin, url.Values{"sortBy": {"port"}, "sortOrder": {"asc"}, "instanceId": {"1"}})
+	for i, want := range []int{22, 80, 443, 2222, 6379} {
+		if page.List[i].Port != want {
+			t.Fatalf("expected ports sorted ascending, got %+v", page.List)
+		}
+	}
+	page = env.queryDetails(admin, url.Values{"sortBy": {"ip"}, "instanceId": {"1"}})
+	if page.List[0].IP != "2001:db8::1" || page.List[len(page.List)-1].IP != "198.51.100.9" {
+		t.Fatalf("expected addresses sorted numerically descending, got %+v", page.List)
+	}
+
+	var ips struct {
+		List []struct {
+			IP    string `json:"ip"`
+			Count int    `json:"count"`
+		} `json:"list"`
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips?sortBy=count&sortOrder=asc&pageSize=1", admin, nil, &ips)
+	if len(ips.List) != 1 || ips.List[0].Count != 3 {
+		t.Fatalf("expected the least active ip first, got %+v", ips.List)
+	}
+}
+
+func TestHFishQueryIngestsAttackData(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) { cfg.HFishSyncInterval = time.Hour })
+	admin := env.adminToken()
+	env.hfish.setAttackDetails(attackDetailSeed)
+
+	var instances []hfishInstance
+	env.mustOK(http.MethodGet, "/api/hfish/instances", admin, nil, &instances)
+	id := instances[0].ID
+	storedDetails := func() int64 {
+		var count int64
+		env.db.Table("hfish_attack_details").Where("instance_id = ?", id).Count(&count)
+		return count
+	}
+
+	if page := env.queryDetails(admin, url.Values{"pageSize": {"2"}}); page.Total != 5 || len(page.List) != 2 {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	if got := storedDetails(); got != 5 {
+		t.Fatalf("expected 5 ingested details, got %d", got)
+	}
+
+	// 同步间隔内的翻页和筛选只查询数据库，不再调用 HFish
+	calls := len(env.hfish.receivedRequestIDs())
+	env.queryDetails(admin, url.Values{"page": {"3"}, "pageSize": {"2"}})
+	if page := env.queryDetails(admin, url.Values{"protocol": {"ssh"}}); page.Total != 2 {
+		t.Fatalf("expected 2 ssh details from the database, got %+v", page)
+	}
+	if got := len(env.hfish.receivedRequestIDs()); got != calls {
+		t.Fatalf("expected no HFish calls within the sync interval, got %d more", got-calls)
+	}
+
+	// 修改实例后重新同步；HFish 端已清理的记录仍保留，已有记录不重复写入
+	env.hfish.setAttackDetails([]map[string]interface{}{
+		attackDetailSeed[4],
+		{"id": "6", "ip": "192.0.2.10", "attack_type": "web_scan", "protocol": "http", "port": 8080, "request_time": "2026-10-19 07:00:00", "account": ""},
+	})
+	env.mustOK(http.MethodPut, pathf("/api/hfish/instances/%d", id), admin, gin.H{"remark": "resync"}, nil)
+	page := env.queryDetails(admin, url.Values{})
+	if page.Total != 6 || page.List[0].ID != "6" {
+		t.Fatalf("expected history plus the new detail, got %+v", page)
+	}
+	if got := storedDetails(); got != 6 {
+		t.Fatalf("expected 6 stored details, got %d", got)
+	}
+
+	// 删除实例时一并删除从该实例同步的数据
+	env.mustOK(http.MethodDelete, pathf("/api/hfish/instances/%d", id), admin, nil, nil)
+	var stored int64
+	env.db.Table("hfish_attack_ips").Where("instance_id = ?", id).Count(&stored)
+	if got := storedDetails(); got != 0 || stored != 0 {
+		t.Fatalf("expected synced data to be deleted with the instance, got %d details and %d ips", got, stored)
+	}
+
+	// 删除前已开始的同步不能再为已删除的实例写入数据
+	attacks := repositories.NewHFishAttackRepository(env.db)
+	if err := attacks.SaveIPs([]models.HFishAttackIP{{InstanceID: id, IP: "192.0.2.10", SyncedAt: time.Now()}}); err == nil {
+		t.Fatal("expected saving attack ips for a deleted instance to be rejected")
+	}
+	if err := attacks.SaveDetails([]models.HFishAttackDetail{{InstanceID: id, SourceID: "7", SyncedAt: time.Now()}}); err == nil {
+		t.Fatal("expected saving attack details for a deleted instance to be rejected")
+	}
+}
+
+func TestHFishQueryResponseSizeLimit(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) { cfg.HFishMaxResponseSize = 1 })
+	admin := env.adminToken()
+	env.hfish.setAttackDetails([]map[string]interface{}{
+		{"id": "1", "ip": "203.0.113.7", "attack_type": "web_scan", "protocol": "http", "port": 80, "request_time": "2026-10-18 08:00:00", "payload": strings.Repeat("A", 2<<20)},
+	})
+
+	logs := captureLogs(t, "warn")
+	w := env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + admin}, http.MethodGet, "/api/hfish/attack/details", nil)
+	if w.Code != http.StatusOK || w.Header().Get("X-HFish-Stale") != "1" || !strings.Contains(logs.String(), "超过 1 MB 上限") {
+		t.Fatalf("expected the oversized response to be rejected, got %d %q:\n%s", w.Code, w.Header().Get("X-HFish-Stale"), logs.String())
+	}
+	var stored int64
+	env.db.Table("hfish_attack_details").Count(&stored)
+	if stored != 0 {
+		t.Fatalf("expected nothing to be ingested from an oversized response, got %d rows", stored)
+	}
+	// 其他接口的响应未超出上限，不受影响
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips", admin, nil, nil)
+
+	cfg := config.Default()
+	cfg.JWTSecret = "integration-test-secret"
+	cfg.SecretsKey = testSecretsKey
+	cfg.AuditSigningKey = testSigningKey
+	cfg.HFishSyncInterval = -time.Second
+	cfg.HFishMaxResponseSize = 0
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "hfish_sync_interval") || !strings.Contains(err.Error(), "hfish_max_response_size") {
+		t.Fatalf("expected invalid sync settings to be rejected, got %v", err)
+	}
+}
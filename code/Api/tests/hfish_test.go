@@ -0,0 +1,261 @@
+package tests
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hfishMock 模拟 HFish 管理端 API，校验 api_key 并记录封禁请求和收到的请求 ID
+type hfishMock struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	apiKey      string
+	details     []map[string]interface{}
+	blocked     []string
+	requestIDs  []string
+	traceparent []string
+}
+
+func newHFishMock(t *testing.T, apiKey string) *hfishMock {
+	t.Helper()
+
+	m := newUnstartedHFishMock(t, apiKey)
+	m.server.Start()
+	return m
+}
+
+// newHFishTLSMock 以 HTTPS 启动 mock，clientCAs 不为空时要求客户端提供由其签发的证书
+func newHFishTLSMock(t *testing.T, apiKey string, clientCAs *x509.CertPool) *hfishMock {
+	t.Helper()
+
+	m := newUnstartedHFishMock(t, apiKey)
+	if clientCAs != nil {
+		m.server.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+	m.server.StartTLS()
+	return m
+}
+
+func newUnstartedHFishMock(t *testing.T, apiKey string) *hfishMock {
+	t.Helper()
+
+	m := &hfishMock{apiKey: apiKey}
+	mux := http.NewServeMux()
+
+	reply := func(w http.ResponseWriter, success bool, message string, data interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": success,
+			"message": message,
+			"data":    data,
+		})
+	}
+
+	handle := func(path, method string, fn func(w http.ResponseWriter, r *http.Request)) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != method {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			m.mu.Lock()
+			m.requestIDs = append(m.requestIDs, r.Header.Get("X-Request-ID"))
+			m.traceparent = append(m.traceparent, r.Header.Get("traceparent"))
+			m.mu.Unlock()
+			if r.URL.Query().Get("api_key") != m.currentAPIKey() {
+				reply(w, false, "api key invalid", nil)
+				return
+			}
+			fn(w, r)
+		})
+	}
+
+	handle("/api/v1/attack/ip", http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		reply(w, true, "", []map[string]interface{}{
+			{"id": "1", "ip": "203.0.113.7", "count": 42, "first_seen": "2026-10-01 08:00:00", "last_seen": "2026-10-18 23:59:00"},
+			{"id": "2", "ip": "198.51.100.9", "count": 3, "first_seen": "2026-10-17 10:00:00", "last_seen": "2026-10-17 10:05:00"},
+		})
+	})
+
+	handle("/api/v1/attack/detail", http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		details := m.details
+		m.mu.Unlock()
+		if details == nil {
+			details = []map[string]interface{}{
+				{"id": "10", "ip": "203.0.113.7", "attack_type": "brute_force", "protocol": "ssh", "port": 22, "payload": "root:123456", "request_time": "2026-10-18 23:59:00", "account": "root"},
+			}
+		}
+		reply(w, true, "", details)
+	})
+
+	handle("/api/v1/attack/account", http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		reply(w, true, "", []map[string]interface{}{
+			{"id": "20", "username": "root", "password": "123456", "protocol": "ssh", "ip": "203.0.113.7", "attack_count": 17},
+		})
+	})
+
+	handle("/api/v1/hfish/sys_info", http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		reply(w, true, "", map[string]interface{}{
+			"total_honeypots": 5, "active_honeypots": 4, "total_attacks": 1234,
+			"last_attack_time": "2026-10-18 23:59:00", "system_status": "running",
+		})
+	})
+
+	handle("/api/v1/attack/ip/block", http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			IP string `json:"ip"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IP == "" {
+			reply(w, false, "请求数据非法", nil)
+			return
+		}
+		if req.IP == "10.0.0.1" {
+			reply(w, false, "内网地址不允许封禁", nil)
+			return
+		}
+		m.mu.Lock()
+		m.blocked = append(m.blocked, req.IP)
+		m.mu.Unlock()
+		reply(w, true, "", nil)
+	})
+
+	m.server = httptest.NewUnstartedServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+// setAttackDetails 替换攻击详情接口返回的数据
+func (m *hfishMock) setAttackDetails(details []map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.details = details
+}
+
+// rotateAPIKey 模拟 HFish 端更换 API Key，使服务端持有的旧 Key 失效
+func (m *hfishMock) rotateAPIKey(apiKey string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiKey = apiKey
+}
+
+func (m *hfishMock) currentAPIKey() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.apiKey
+}
+
+func (m *hfishMock) blockedIPs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.blocked...)
+}
+
+func (m *hfishMock) receivedRequestIDs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.requestIDs...)
+}
+
+func (m *hfishMock) receivedTraceparents() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.traceparent...)
+}
+
+func TestHFishProxy(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.adminToken()
+
+	var ips struct {
+		List []struct {
+			IP    string `json:"ip"`
+			Count int    `json:"count"`
+		} `json:"list"`
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips", token, nil, &ips)
+	if len(ips.List) != 2 || ips.List[0].IP != "203.0.113.7" || ips.List[0].Count != 42 {
+		t.Fatalf("unexpected attack ips: %+v", ips.List)
+	}
+
+	var details struct {
+		List []struct {
+			AttackType string `json:"attack_type"`
+			Port       int    `json:"port"`
+		} `json:"list"`
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/attack/details", token, nil, &details)
+	if len(details.List) != 1 || details.List[0].AttackType != "brute_force" || details.List[0].Port != 22 {
+		t.Fatalf("unexpected attack details: %+v", details.List)
+	}
+
+	var accounts []struct {
+		Username    string `json:"username"`
+		AttackCount int    `json:"attack_count"`
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/account/info", token, nil, &accounts)
+	if len(accounts) != 1 || accounts[0].AttackCount != 17 {
+		t.Fatalf("unexpected account info: %+v", accounts)
+	}
+
+	var info struct {
+		TotalHoneypots int    `json:"total_honeypots"`
+		SystemStatus   string `json:"system_status"`
+	}
+	env.mustOK(http.MethodGet, "/api/hfish/sys/info", token, nil, &info)
+	if info.TotalHoneypots != 5 || info.SystemStatus != "running" {
+		t.Fatalf("unexpected sys info: %+v", info)
+	}
+}
+
+func TestHFishBlockIP(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.adminToken()
+
+	env.mustOK(http.MethodPost, "/api/hfish/block/ip", token, gin.H{"ip": "203.0.113.7", "reason": "ssh brute force"}, nil)
+	if blocked := env.hfish.blockedIPs(); len(blocked) != 1 || blocked[0] != "203.0.113.7" {
+		t.Fatalf("expected HFish to receive block for 203.0.113.7, got %v", blocked)
+	}
+
+	resp := env.expectStatus(http.StatusInternalServerError, http.MethodPost, "/api/hfish/block/ip", token, gin.H{"ip": "10.0.0.1"})
+	if resp.Message != "封禁 IP 失败: 内网地址不允许封禁" {
+		t.Fatalf("unexpected block failure message: %q", resp.Message)
+	}
+
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/hfish/block/ip", token, gin.H{"reason": "missing ip"})
+}
+
+func TestHFishRejectsInvalidAPIKey(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.adminToken()
+
+	env.hfish.rotateAPIKey("rotated-key")
+
+	resp := env.expectStatus(http.StatusInternalServerError, http.MethodGet, "/api/hfish/sys/info", token, nil)
+	if resp.Message != "api key invalid" {
+		t.Fatalf("unexpected message: %q", resp.Message)
+	}
+}
+
+func TestHFishRequiresPermission(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	env.createUser(admin, "analyst", "analyst123", env.roleID(admin, "user"))
+	token := env.login("analyst", "analyst123")
+
+	env.expectStatus(http.StatusForbidden, http.MethodGet, "/api/hfish/attack/ips", token, nil)
+	env.expectStatus(http.StatusForbidden, http.MethodPost, "/api/hfish/block/ip", token, gin.H{"ip": "203.0.113.7"})
+
+	if blocked := env.hfish.blockedIPs(); len(blocked) != 0 {
+		t.Fatalf("forbidden request reached HFish: %v", blocked)
+	}
+}
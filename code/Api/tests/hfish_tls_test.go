@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type hfishTLSInstance struct {
+	ID            int    `json:"id"`
+	TLSSkipVerify bool   `json:"tlsSkipVerify"`
+	TLSCACert     string `json:"tlsCaCert"`
+	TLSPinSHA256  string `json:"tlsPinSha256"`
+	TLSClientCert string `json:"tlsClientCert"`
+	TLSClientKey  string `json:"tlsClientKey"`
+}
+
+// serverCertPEM 返回 mock 的服务端证书（httptest 的证书自签名，可直接作为 CA）
+func serverCertPEM(mock *hfishMock) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mock.server.Certificate().Raw}))
+}
+
+// serverFingerprint 返回 mock 服务端证书的 SHA-256 指纹，格式同 openssl x509 -fingerprint -sha256
+func serverFingerprint(mock *hfishMock) string {
+	sum := sha256.Sum256(mock.server.Certificate().Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// newClientCA 生成一个 CA 以及由它签发的客户端证书和私钥（PEM）
+func newClientCA(t *testing.T) (*x509.CertPool, string, string) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ca key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test client ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create ca cert: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate client key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "superhoneypotguard"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create client cert: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal client key: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return pool, string(certPEM), string(keyPEM)
+}
+
+func TestHFishInstanceVerifiesCertificates(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	mock := newHFishTLSMock(t, "tls-key", nil)
+	id := env.addHFishInstance(admin, "site-tls", mock, "tls-key")
+	path := pathf("/api/hfish/instances/%d", id)
+	sysInfo := pathf("/api/hfish/sys/info?instanceId=%d", id)
+
+	// 默认校验证书，自签名证书不被信任
+	resp := env.expectStatus(http.StatusInternalServerError, http.MethodGet, sysInfo, admin, nil)
+	if !strings.Contains(resp.Message, "certificate") {
+		t.Fatalf("expected a certificate error, got %q", resp.Message)
+	}
+
+	env.mustOK(http.MethodPut, path, admin, gin.H{"tlsCaCert": serverCertPEM(mock)}, nil)
+	env.mustOK(http.MethodGet, sysInfo, admin, nil, nil)
+
+	// CA 和指纹同时配置时两者都要满足
+	wrongPin := strings.Repeat("ab", 32)
+	env.mustOK(http.MethodPut, path, admin, gin.H{"tlsPinSha256": wrongPin}, nil)
+	resp = env.expectStatus(http.StatusInternalServerError, http.MethodGet, sysInfo, admin, nil)
+	if !strings.Contains(resp.Message, "指纹") {
+		t.Fatalf("expected a fingerprint mismatch, got %q", resp.Message)
+	}
+
+	// 只配置指纹时以指纹代替证书链校验，指纹统一保存为小写十六进制
+	env.mustOK(http.MethodPut, path, admin, gin.H{"tlsCaCert": "", "tlsPinSha256": wrongPin + ", " + serverFingerprint(mock)}, nil)
+	env.mustOK(http.MethodGet, sysInfo, admin, nil, nil)
+	var instance hfishTLSInstance
+	env.mustOK(http.MethodGet, path, admin, nil, &instance)
+	want := wrongPin + "," + strings.ToLower(strings.ReplaceAll(serverFingerprint(mock), ":", ""))
+	if instance.TLSCACert != "" || instance.TLSPinSHA256 != want {
+		t.Fatalf("expected normalized pins, got %+v", instance)
+	}
+
+	// 无效或相互冲突的设置被拒绝，原设置保持不变
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, path, admin, gin.H{"tlsPinSha256": "not-a-fingerprint"})
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, path, admin, gin.H{"tlsCaCert": "-----BEGIN CERTIFICATE-----\nbroken\n-----END CERTIFICATE-----"})
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, path, admin, gin.H{"tlsSkipVerify": true})
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/hfish/instances", admin, gin.H{
+		"name": "site-bad", "baseUrl": mock.server.URL + "/api/v1", "apiKey": "tls-key", "tlsSkipVerify": true, "tlsCaCert": serverCertPEM(mock),
+	})
+	env.mustOK(http.MethodGet, sysInfo, admin, nil, nil)
+
+	// 显式开启跳过校验后可以连接，但健康检查给出警告
+	env.mustOK(http.MethodPut, path, admin, gin.H{"tlsPinSha256": "", "tlsSkipVerify": true}, nil)
+	env.mustOK(http.MethodGet, sysInfo, admin, nil, nil)
+	_, report := env.ready()
+	hfish := component(report, "hfish")
+	if hfish.Status != services.HealthOK || !containsWarning(hfish.Warnings, "site-tls: 未校验 TLS 证书") {
+		t.Fatalf("expected an insecure warning for site-tls, got %+v", hfish)
+	}
+}
+
+func TestHFishInstanceClientCertificate(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	clientCAs, certPEM, keyPEM := newClientCA(t)
+	mock := newHFishTLSMock(t, "mtls-key", clientCAs)
+	var created hfishTLSInstance
+	env.mustOK(http.MethodPost, "/api/hfish/instances", admin, gin.H{
+		"name":         "site-mtls",
+		"baseUrl":      mock.server.URL + "/api/v1",
+		"apiKey":       "mtls-key",
+		"tlsPinSha256": serverFingerprint(mock),
+	}, &created)
+	path := pathf("/api/hfish/instances/%d", created.ID)
+	sysInfo := pathf("/api/hfish/sys/info?instanceId=%d", created.ID)
+
+	env.expectStatus(http.StatusInternalServerError, http.MethodGet, sysInfo, admin, nil)
+
+	// 证书和私钥必须成对提供
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, path, admin, gin.H{"tlsClientCert": certPEM})
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, path, admin, gin.H{"tlsClientCert": certPEM, "tlsClientKey": "not a key"})
+
+	env.mustOK(http.MethodPut, path, admin, gin.H{"tlsClientCert": certPEM, "tlsClientKey": keyPEM}, nil)
+	env.mustOK(http.MethodGet, sysInfo, admin, nil, nil)
+
+	var instance hfishTLSInstance
+	env.mustOK(http.MethodGet, path, admin, nil, &instance)
+	if instance.TLSClientCert != strings.TrimSpace(certPEM) || instance.TLSClientKey != "******" {
+		t.Fatalf("expected the client key to be masked, got %+v", instance)
+	}
+
+	// 提交脱敏值表示不修改私钥
+	env.mustOK(http.MethodPut, path, admin, gin.H{"tlsClientKey": "******"}, nil)
+	env.mustOK(http.MethodGet, sysInfo, admin, nil, nil)
+
+	logs := env.auditLogs(admin, "action=hfish_instance.update")
+	if len(logs) < 2 {
+		t.Fatalf("expected update audit events, got %+v", logs)
+	}
+	// 客户端证书记录变更内容，私钥只记录发生了替换
+	if logs[1].Changes == nil || !strings.Contains(*logs[1].Changes, `"tlsClientCert":{`) ||
+		!strings.Contains(*logs[1].Changes, `"tlsClientKey":"******"`) || strings.Contains(*logs[1].Changes, "PRIVATE KEY") {
+		t.Fatalf("expected client key replacement to be audited without its value, got %v", logs[1].Changes)
+	}
+	for _, entry := range env.capturedLogs(admin, "operation=/api/hfish/instances") {
+		if entry.Params != nil && strings.Contains(*entry.Params, "PRIVATE KEY") {
+			t.Fatalf("operation log leaks the client key: %s", *entry.Params)
+		}
+	}
+
+	// 修改地址时必须重新提供客户端私钥
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, path, admin, gin.H{"baseUrl": mock.server.URL + "/api/v2", "apiKey": "mtls-key"})
+	env.expectStatus(http.StatusBadRequest, http.MethodPut, path, admin, gin.H{"baseUrl": mock.server.URL + "/api/v2", "apiKey": "mtls-key", "tlsClientKey": "******"})
+
+	// 清空客户端证书时同时清除私钥
+	env.mustOK(http.MethodPut, path, admin, gin.H{"tlsClientCert": ""}, nil)
+	env.mustOK(http.MethodGet, path, admin, nil, &instance)
+	if instance.TLSClientCert != "" || instance.TLSClientKey != "" {
+		t.Fatalf("expected client certificate to be removed, got %+v", instance)
+	}
+	env.expectStatus(http.StatusInternalServerError, http.MethodGet, sysInfo, admin, nil)
+}
+
+func TestHFishHealthWarnsAboutPlaintext(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	plain := newHFishMock(t, "plain-key")
+	env.addHFishInstance(admin, "site-plain", plain, "plain-key")
+
+	_, report := env.ready()
+	hfish := component(report, "hfish")
+	if !containsWarning(hfish.Warnings, "site-plain: 使用明文 HTTP 连接") {
+		t.Fatalf("expected a plaintext warning for site-plain, got %+v", hfish)
+	}
+	// 从启动配置导入的实例默认校验证书
+	if containsWarning(hfish.Warnings, "default: 未校验 TLS 证书") {
+		t.Fatalf("imported instance must verify certificates by default, got %+v", hfish)
+	}
+
+	// 显式开启 HFISH_LEGACY_TLS_SKIP_VERIFY 时需要提示管理员
+	env = newTestEnv(t, func(cfg *config.Config) { cfg.HFishLegacySkipVerify = true })
+	_, report = env.ready()
+	if hfish = component(report, "hfish"); !containsWarning(hfish.Warnings, "default: 未校验 TLS 证书") {
+		t.Fatalf("expected an insecure warning for the imported instance, got %+v", hfish)
+	}
+}
+
+func containsWarning(warnings []string, prefix string) bool {
+	for _, w := range warnings {
+		if strings.HasPrefix(w, prefix) {
+			return true
+		}
+	}
+	return false
+}
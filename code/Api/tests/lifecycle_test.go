@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"superhoneypotguard/lifecycle"
+)
+
+func TestLifecycleShutdownOrder(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+
+	lc := lifecycle.New()
+	for _, name := range []string{"database", "log writer", "http"} {
+		lc.Add(lifecycle.Component{
+			Name: name,
+			Run: func(ctx context.Context) error {
+				<-ctx.Done()
+				record(name + " exited")
+				return nil
+			},
+			Stop: func(ctx context.Context) error {
+				record(name + " stop")
+				return nil
+			},
+		})
+	}
+
+	if err := lc.Shutdown(time.Second); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	// 每个组件完全退出后才停止下一个，顺序与注册顺序相反
+	want := []string{
+		"http stop", "http exited",
+		"log writer stop", "log writer exited",
+		"database stop", "database exited",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("unexpected shutdown order:\n got %v\nwant %v", events, want)
+	}
+}
+
+func TestLifecycleShutdownTimeout(t *testing.T) {
+	lc := lifecycle.New()
+	release := make(chan struct{})
+	defer close(release)
+
+	lc.Add(lifecycle.Component{
+		Name: "stuck",
+		Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	})
+
+	err := lc.Shutdown(50 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Fatalf("expected timeout error naming the stuck component, got %v", err)
+	}
+}
+
+// HTTP 排空用完等待时间后，日志写入仍有自己的时间在数据库关闭前写完缓冲的日志
+func TestLifecycleSlowDrainKeepsLogs(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		closed bool
+		stored []string
+	)
+	buffered := []string{"login", "update user", "delete role"}
+
+	lc := lifecycle.New()
+	lc.Add(lifecycle.Component{
+		Name: "database",
+		Stop: func(ctx context.Context) error {
+			mu.Lock()
+			closed = true
+			mu.Unlock()
+			return nil
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "log writer",
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			for _, entry := range buffered {
+				time.Sleep(20 * time.Millisecond)
+				mu.Lock()
+				if !closed {
+					stored = append(stored, entry)
+				}
+				mu.Unlock()
+			}
+			return nil
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "http",
+		Stop: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	err := lc.Shutdown(100 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "http") || strings.Contains(err.Error(), "log writer") {
+		t.Fatalf("expected only the http drain to time out, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(stored, buffered) {
+		t.Fatalf("expected buffered logs to reach the store before it closed, got %v", stored)
+	}
+}
+
+func TestLifecycleWaitReturnsComponentError(t *testing.T) {
+	lc := lifecycle.New()
+	boom := errors.New("listen tcp :3000: address already in use")
+	lc.Add(lifecycle.Component{
+		Name: "http",
+		Run: func(ctx context.Context) error {
+			return boom
+		},
+	})
+
+	if err := lc.Wait(); !errors.Is(err, boom) {
+		t.Fatalf("Wait should return the component error, got %v", err)
+	}
+}
+
+func TestLifecycleHeartbeats(t *testing.T) {
+	lc := lifecycle.New()
+	defer lc.Shutdown(time.Second)
+
+	var runs sync.WaitGroup
+	runs.Add(1)
+	lc.Add(lifecycle.Component{Name: "database"})
+	lc.Add(lifecycle.Component{
+		Name: "scheduler",
+		Run:  lifecycle.Every(10*time.Millisecond, func() {}),
+	})
+	lc.Add(lifecycle.Component{
+		Name: "stuck",
+		Run: func(ctx context.Context) error {
+			// 只报告一次心跳后卡住
+			lifecycle.Beat(ctx, 10*time.Millisecond)
+			runs.Done()
+			<-ctx.Done()
+			return nil
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "crashed",
+		Run: func(ctx context.Context) error {
+			return errors.New("connection reset")
+		},
+	})
+
+	runs.Wait()
+	time.Sleep(50 * time.Millisecond)
+
+	status := make(map[string]lifecycle.ComponentStatus)
+	for _, s := range lc.Status() {
+		status[s.Name] = s
+	}
+	if _, ok := status["database"]; ok || len(status) != 3 {
+		t.Fatalf("expected only components with background tasks, got %+v", status)
+	}
+	if s := status["scheduler"]; !s.Healthy() || s.LastBeat.IsZero() {
+		t.Fatalf("expected scheduler to keep beating, got %+v", s)
+	}
+	if s := status["stuck"]; !s.Running || !s.Stale || s.Healthy() {
+		t.Fatalf("expected stuck component to be stale, got %+v", s)
+	}
+	if s := status["crashed"]; s.Running || s.Error != "connection reset" {
+		t.Fatalf("expected crashed component to report its error, got %+v", s)
+	}
+}
@@ -0,0 +1,279 @@
+package tests
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+)
+
+const searchLogCount = 30
+
+// seedSearchLogs 直接写入 30 条可预测的日志：
+// 第 i 条的 IP 轮流取自 10.0.0.0/24、192.168.1.0/24 和 2001:db8::/32，
+// 执行时间为 (i%5)*100ms，记录时间从 base 起每条间隔 1 分钟，奇数条为 GET，其余为 POST
+func seedSearchLogs(t *testing.T, env *testEnv, base time.Time) {
+	t.Helper()
+
+	userID := 42
+	logs := make([]models.OperationLog, 0, searchLogCount)
+	for i := 0; i < searchLogCount; i++ {
+		var ip string
+		switch i % 3 {
+		case 0:
+			ip = fmt.Sprintf("10.0.0.%d", i)
+		case 1:
+			ip = fmt.Sprintf("192.168.1.%d", i)
+		default:
+			ip = fmt.Sprintf("2001:db8::%x", i)
+		}
+		method := http.MethodPost
+		if i%2 == 1 {
+			method = http.MethodGet
+		}
+		url := fmt.Sprintf("/api/search-test/%d", i)
+		params := fmt.Sprintf(`{"n":%d}`, i)
+		if i == 7 {
+			params = `=HYPERLINK("http://evil.example")`
+		}
+		entry := models.OperationLog{
+			Operation:   fmt.Sprintf("搜索测试 %d", i),
+			Method:      &method,
+			URL:         &url,
+			IP:          &ip,
+			Params:      &params,
+			Status:      1,
+			ExecuteTime: (i % 5) * 100,
+			CreatedAt:   base.Add(time.Duration(i) * time.Minute),
+		}
+		if i < 5 {
+			entry.UserID = &userID
+		}
+		logs = append(logs, entry)
+	}
+	if err := repositories.NewLogRepository(env.db).Create(logs); err != nil {
+		t.Fatalf("seed logs: %v", err)
+	}
+}
+
+type searchEntry struct {
+	ID          int       `json:"id"`
+	IP          string    `json:"ip"`
+	ExecuteTime int       `json:"executeTime"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (e *testEnv) searchLogs(token string, query url.Values) []searchEntry {
+	e.t.Helper()
+
+	query.Set("keyword", "search-test")
+	query.Set("pageSize", "100")
+	var page struct {
+		List  []searchEntry `json:"list"`
+		Total int64         `json:"total"`
+	}
+	e.mustOK(http.MethodGet, "/api/log/list?"+query.Encode(), token, nil, &page)
+	if int64(len(page.List)) != page.Total {
+		e.t.Fatalf("%s: expected all %d logs on one page, got %d", query.Encode(), page.Total, len(page.List))
+	}
+	return page.List
+}
+
+func TestOperationLogSearch(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	base := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	seedSearchLogs(t, env, base)
+
+	cases := []struct {
+		query url.Values
+		want  int
+	}{
+		{url.Values{}, searchLogCount},
+		{url.Values{"ip": {"10.0.0.0/24"}}, 10},
+		{url.Values{"ip": {"10.0.0.9"}}, 1},
+		{url.Values{"ip": {"192.168.0.0/16"}}, 10},
+		{url.Values{"ip": {"2001:db8::/32"}}, 10},
+		{url.Values{"ip": {"172.16.0.0/12"}}, 0},
+		{url.Values{"method": {"get"}}, 15},
+		{url.Values{"method": {"GET,POST"}}, searchLogCount},
+		{url.Values{"userId": {"42"}}, 5},
+		{url.Values{"minExecuteTime": {"300"}}, 12},
+		{url.Values{"startTime": {base.Add(10 * time.Minute).Format(time.DateTime)}, "endTime": {base.Add(20 * time.Minute).Format(time.DateTime)}}, 10},
+		{url.Values{"startTime": {base.Add(25 * time.Minute).Format(time.RFC3339)}}, 5},
+		{url.Values{"ip": {"10.0.0.0/8"}, "method": {"POST"}, "minExecuteTime": {"100"}}, 4},
+	}
+	for _, tc := range cases {
+		if got := env.searchLogs(admin, tc.query); len(got) != tc.want {
+			t.Errorf("%s: expected %d logs, got %d", tc.query.Encode(), tc.want, len(got))
+		}
+	}
+
+	// 免费文本匹配请求体
+	var page logPage
+	env.mustOK(http.MethodGet, "/api/log/list?keyword=HYPERLINK", admin, nil, &page)
+	if page.Total != 1 {
+		t.Fatalf("expected keyword to match the request body, got %d", page.Total)
+	}
+
+	// 排序
+	list := env.searchLogs(admin, url.Values{"sortBy": {"executeTime"}, "sortOrder": {"asc"}})
+	if !sort.SliceIsSorted(list, func(i, j int) bool { return list[i].ExecuteTime < list[j].ExecuteTime }) {
+		t.Fatalf("expected logs sorted by execute time ascending")
+	}
+	list = env.searchLogs(admin, url.Values{})
+	if !sort.SliceIsSorted(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) }) {
+		t.Fatalf("expected logs sorted by time descending by default")
+	}
+
+	for _, query := range []string{"ip=not-an-ip", "startTime=yesterday", "sortBy=password", "sortOrder=up", "userId=x", "minExecuteTime=-1",
+		"startTime=" + url.QueryEscape(base.Format(time.DateTime)) + "&endTime=" + url.QueryEscape(base.Format(time.DateTime))} {
+		env.expectStatus(http.StatusBadRequest, http.MethodGet, "/api/log/list?"+query, admin, nil)
+	}
+}
+
+func TestOperationLogCursorPagination(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	seedSearchLogs(t, env, time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	for _, sortQuery := range []string{"", "&sortBy=executeTime", "&sortBy=executeTime&sortOrder=asc", "&sortBy=id&sortOrder=asc"} {
+		var collected []searchEntry
+		cursor, pages := "", 0
+		for {
+			var page struct {
+				List       []searchEntry `json:"list"`
+				NextCursor string        `json:"nextCursor"`
+			}
+			env.mustOK(http.MethodGet, "/api/log/list?keyword=search-test&pageSize=7&cursor="+cursor+sortQuery, admin, nil, &page)
+			collected = append(collected, page.List...)
+			pages++
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+
+		// 游标翻页的结果与一次性查询的结果相同，没有重复也没有遗漏
+		query, _ := url.ParseQuery(strings.TrimPrefix(sortQuery, "&"))
+		all := env.searchLogs(admin, query)
+		if pages != 5 || len(collected) != len(all) {
+			t.Fatalf("%q: expected %d logs over 5 pages, got %d over %d", sortQuery, len(all), len(collected), pages)
+		}
+		for i := range all {
+			if collected[i].ID != all[i].ID {
+				t.Fatalf("%q: position %d differs: cursor %d, offset %d", sortQuery, i, collected[i].ID, all[i].ID)
+			}
+		}
+	}
+
+	// 游标与排序方式绑定
+	var page struct {
+		NextCursor string `json:"nextCursor"`
+	}
+	env.mustOK(http.MethodGet, "/api/log/list?keyword=search-test&pageSize=7&cursor=", admin, nil, &page)
+	env.expectStatus(http.StatusBadRequest, http.MethodGet, "/api/log/list?sortBy=executeTime&cursor="+page.NextCursor, admin, nil)
+	env.expectStatus(http.StatusBadRequest, http.MethodGet, "/api/log/list?cursor=garbage", admin, nil)
+}
+
+// download 发起请求并返回原始响应
+func (e *testEnv) download(path, token string) *httptest.ResponseRecorder {
+	e.t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	e.engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		e.t.Fatalf("GET %s: expected 200, got %d %s", path, w.Code, w.Body.String())
+	}
+	return w
+}
+
+func TestOperationLogExport(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	seedSearchLogs(t, env, time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	// CSV：表头 + 每条日志一行，公式开头的内容被转义
+	w := env.download("/api/log/export?format=csv&keyword=search-test&sortOrder=asc", admin)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, ".csv") {
+		t.Fatalf("unexpected content disposition %q", cd)
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(w.Body.String(), "\xEF\xBB\xBF"))).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(records) != searchLogCount+1 || records[0][0] != "日志ID" || records[1][11] != "10.0.0.0" {
+		t.Fatalf("unexpected csv: %d rows, header %v, first %v", len(records), records[0], records[1])
+	}
+	if params := records[8][16]; !strings.HasPrefix(params, "'=") {
+		t.Fatalf("formula should be neutralized in csv, got %q", params)
+	}
+
+	// JSON Lines：每行一个对象，只包含 10.0.0.0/24 的日志
+	w = env.download("/api/log/export?format=jsonl&keyword=search-test&ip=10.0.0.0/24", admin)
+	var lines int
+	for scanner := bufio.NewScanner(w.Body); scanner.Scan(); lines++ {
+		var row struct {
+			IP   string `json:"ip"`
+			Hash string `json:"hash"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil || !strings.HasPrefix(row.IP, "10.0.0.") || row.Hash == "" {
+			t.Fatalf("unexpected jsonl row %s: %v", scanner.Text(), err)
+		}
+	}
+	if lines != 10 {
+		t.Fatalf("expected 10 jsonl rows, got %d", lines)
+	}
+
+	// XLSX：合法的 zip 包，工作表包含表头和全部日志
+	w = env.download("/api/log/export?format=xlsx&keyword=search-test", admin)
+	body := w.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("open xlsx: %v", err)
+	}
+	var sheet []byte
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			sheet, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+	if rows := strings.Count(string(sheet), "<row "); rows != searchLogCount+1 || !strings.Contains(string(sheet), "搜索测试 29") {
+		t.Fatalf("expected %d rows in xlsx sheet, got %d", searchLogCount+1, rows)
+	}
+
+	env.expectStatus(http.StatusBadRequest, http.MethodGet, "/api/log/export?format=pdf", admin, nil)
+	env.expectStatus(http.StatusBadRequest, http.MethodGet, "/api/log/export?ip=bogus", admin, nil)
+
+	// 导出操作本身写入审计日志
+	middleware.FlushLogs()
+	var page logPage
+	env.mustOK(http.MethodGet, "/api/log/list?action=log.export&sortOrder=asc", admin, nil, &page)
+	if page.Total != 3 || page.List[0].Operation != "导出操作日志（CSV，30 条）" || page.List[1].Operation != "导出操作日志（JSONL，10 条）" {
+		t.Fatalf("unexpected export audit logs: %+v", page.List)
+	}
+
+	env.createUser(admin, "oscar", "oscar1234", env.roleID(admin, "user"))
+	env.expectStatus(http.StatusForbidden, http.MethodGet, "/api/log/export", env.login("oscar", "oscar1234"), nil)
+}
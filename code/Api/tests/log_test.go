@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/redact"
+
+	"github.com/gin-gonic/gin"
+)
+
+type logEntry struct {
+	ID         int     `json:"id"`
+	Username   *string `json:"username"`
+	Operation  string  `json:"operation"`
+	Method     *string `json:"method"`
+	Location   *string `json:"location"`
+	Status     int     `json:"status"`
+	ErrorMsg   *string `json:"errorMsg"`
+	Action     *string `json:"action"`
+	TargetType *string `json:"targetType"`
+	TargetID   *string `json:"targetId"`
+	Changes    *string `json:"changes"`
+}
+
+type logPage struct {
+	List  []logEntry `json:"list"`
+	Total int64      `json:"total"`
+}
+
+func TestOperationLogs(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	env.createUser(admin, "heidi", "heidi123", env.roleID(admin, "user"))
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/user/", admin, gin.H{
+		"username": "heidi",
+		"password": "heidi123",
+	})
+	middleware.FlushLogs()
+
+	var page logPage
+	env.mustOK(http.MethodGet, "/api/log/list?username=admin&action=user.create&pageSize=50", admin, nil, &page)
+	if page.Total != 2 {
+		t.Fatalf("expected 2 user creation logs, got %d: %+v", page.Total, page.List)
+	}
+
+	var succeeded, failed int
+	for _, entry := range page.List {
+		if entry.Method == nil || *entry.Method != http.MethodPost {
+			t.Fatalf("unexpected method in log: %+v", entry)
+		}
+		if entry.Status == 1 {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+	if succeeded != 1 || failed != 1 {
+		t.Fatalf("expected one success and one failure, got %d/%d", succeeded, failed)
+	}
+
+	env.mustOK(http.MethodGet, "/api/log/list?status=0", admin, nil, &page)
+	if page.Total != 1 {
+		t.Fatalf("expected 1 failed log, got %d", page.Total)
+	}
+
+	id := page.List[0].ID
+	var entry logEntry
+	env.mustOK(http.MethodGet, pathf("/api/log/%d", id), admin, nil, &entry)
+	if entry.Operation != "创建用户 heidi" || entry.ErrorMsg == nil || *entry.ErrorMsg == "" {
+		t.Fatalf("unexpected log entry: %+v", entry)
+	}
+
+	// 日志不能再通过接口删除或清空
+	env.expectStatus(http.StatusNotFound, http.MethodDelete, pathf("/api/log/%d", id), admin, nil)
+	env.expectStatus(http.StatusNotFound, http.MethodDelete, "/api/log/clear", admin, nil)
+	env.mustOK(http.MethodGet, pathf("/api/log/%d", id), admin, nil, &entry)
+}
+
+func TestOperationLogSpoolReplay(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	middleware.FlushLogs()
+	before := middleware.GetLogPipelineStats()
+
+	// 日志表不可用期间的日志写入落盘目录，而不是丢弃
+	if err := env.db.Exec("ALTER TABLE operation_logs RENAME TO operation_logs_offline").Error; err != nil {
+		t.Fatalf("take log table offline: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		env.expectStatus(http.StatusUnauthorized, http.MethodGet, "/api/auth/current", "", nil)
+	}
+	middleware.FlushLogs()
+
+	stats := middleware.GetLogPipelineStats()
+	if stats.SpoolPending != 3 || stats.Spilled-before.Spilled != 3 || stats.Dropped != before.Dropped {
+		t.Fatalf("expected 3 spooled logs and no drops, before %+v after %+v", before, stats)
+	}
+
+	// 恢复后回放，记录时间保持为请求发生的时间
+	restoredAt := time.Now()
+	if err := env.db.Exec("ALTER TABLE operation_logs_offline RENAME TO operation_logs").Error; err != nil {
+		t.Fatalf("bring log table back: %v", err)
+	}
+	middleware.FlushLogs()
+
+	var pipelineStats middleware.LogPipelineStats
+	env.mustOK(http.MethodGet, "/api/log/pipeline", admin, nil, &pipelineStats)
+	if !pipelineStats.SpoolEnabled || pipelineStats.SpoolPending != 0 || pipelineStats.Replayed-before.Replayed != 3 {
+		t.Fatalf("expected spool to be replayed, before %+v after %+v", before, pipelineStats)
+	}
+
+	var page struct {
+		List []struct {
+			CreatedAt time.Time `json:"createdAt"`
+		} `json:"list"`
+		Total int64 `json:"total"`
+	}
+	env.mustOK(http.MethodGet, "/api/log/list?operation=/api/auth/current", admin, nil, &page)
+	if page.Total != 3 {
+		t.Fatalf("expected 3 replayed logs, got %d", page.Total)
+	}
+	for _, entry := range page.List {
+		if !entry.CreatedAt.Before(restoredAt) {
+			t.Fatalf("replayed log should keep its original time, got %v (restored at %v)", entry.CreatedAt, restoredAt)
+		}
+	}
+
+	env.createUser(admin, "mallory", "mallory123", env.roleID(admin, "user"))
+	env.expectStatus(http.StatusForbidden, http.MethodGet, "/api/log/pipeline", env.login("mallory", "mallory123"), nil)
+}
+
+type capturedLog struct {
+	Operation string  `json:"operation"`
+	Method    *string `json:"method"`
+	Params    *string `json:"params"`
+	Result    *string `json:"result"`
+}
+
+// capturedLogs 返回符合查询条件的日志，按时间倒序
+func (e *testEnv) capturedLogs(token, query string) []capturedLog {
+	e.t.Helper()
+
+	middleware.FlushLogs()
+	var page struct {
+		List []capturedLog `json:"list"`
+	}
+	e.mustOK(http.MethodGet, "/api/log/list?pageSize=100&"+query, token, nil, &page)
+	return page.List
+}
+
+func TestOperationLogRedaction(t *testing.T) {
+	env := newTestEnv(t)
+	const email = "olivia@example.test"
+
+	env.mustOK(http.MethodPost, "/api/auth/send-verification-code", "", gin.H{"email": email}, nil)
+	code := env.smtp.lastCode(t, email)
+	env.mustOK(http.MethodPost, "/api/auth/register", "", gin.H{
+		"username": "olivia",
+		"password": "olivia-plain-pass",
+		"email":    email,
+		"code":     code,
+	}, nil)
+	userToken := env.login("olivia", "olivia-plain-pass")
+
+	admin := env.adminToken()
+	env.mustOK(http.MethodPut, "/api/system/settings", admin, gin.H{
+		"settings": gin.H{"smtp_password": "plain-smtp-password"},
+	}, nil)
+
+	secrets := []string{"olivia-plain-pass", code, userToken, admin, "plain-smtp-password"}
+	for _, query := range []string{"action=auth.register", "action=auth.login", "operation=/api/system/settings"} {
+		logs := env.capturedLogs(admin, query)
+		if len(logs) == 0 {
+			t.Fatalf("no logs for %s", query)
+		}
+		for _, entry := range logs {
+			if entry.Params == nil || !strings.Contains(*entry.Params, `"******"`) {
+				t.Fatalf("%s: params should be redacted: %+v", query, entry.Params)
+			}
+			for _, secret := range secrets {
+				if strings.Contains(*entry.Params, secret) || (entry.Result != nil && strings.Contains(*entry.Result, secret)) {
+					t.Fatalf("%s: log leaks %q: params=%s", query, secret, *entry.Params)
+				}
+			}
+		}
+	}
+
+	// 路径规则与不记录内容的路由
+	redactor, err := redact.New(redact.DefaultKeys, []string{"$.data.user.email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = middleware.ConfigureLogCapture(middleware.LogCaptureOptions{
+		Redactor: redactor,
+		Exclude:  []string{"GET /api/role/*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { middleware.ConfigureLogCapture(middleware.LogCaptureOptions{}) })
+
+	env.mustOK(http.MethodGet, "/api/auth/current", userToken, nil, nil)
+	env.mustOK(http.MethodGet, "/api/role/all", admin, nil, nil)
+
+	current := env.capturedLogs(admin, "operation=/api/auth/current")
+	if result := *current[0].Result; strings.Contains(result, email) || !strings.Contains(result, `"email":"******"`) || !strings.Contains(result, `"username":"olivia"`) {
+		t.Fatalf("email should be redacted by path rule: %s", result)
+	}
+	roles := env.capturedLogs(admin, "operation=/api/role/all")
+	if roles[0].Params == nil || *roles[0].Params != "" || *roles[0].Result != "" {
+		t.Fatalf("excluded route should not capture bodies: %+v", roles[0])
+	}
+}
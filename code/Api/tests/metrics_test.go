@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const metricsToken = "metrics-scrape-token"
+
+// scrapeMetrics 抓取 /metrics 并按 "名称{标签}" 解析出各序列的值
+func (e *testEnv) scrapeMetrics() map[string]float64 {
+	e.t.Helper()
+
+	w := e.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + metricsToken}, http.MethodGet, "/metrics", nil)
+	if w.Code != http.StatusOK {
+		e.t.Fatalf("scrape metrics: status %d: %s", w.Code, w.Body.String())
+	}
+
+	series := make(map[string]float64)
+	scanner := bufio.NewScanner(w.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			e.t.Fatalf("invalid metric line %q", line)
+		}
+		series[line[:i]] = value
+	}
+	return series
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.MetricsToken = metricsToken
+		cfg.RateLimitPolicies = "auth=1/1m:ip"
+	})
+
+	// 未携带或携带错误的令牌时拒绝抓取
+	if w := env.doFrom("192.0.2.1:40000", nil, http.MethodGet, "/metrics", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected scrape without token to be rejected, got %d", w.Code)
+	}
+	if w := env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer wrong"}, http.MethodGet, "/metrics", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected scrape with wrong token to be rejected, got %d", w.Code)
+	}
+
+	// 计数在整个测试进程内累计，按抓取前后的差值判断
+	before := env.scrapeMetrics()
+	admin := env.adminToken()
+
+	env.mustOK(http.MethodGet, "/api/user/1", admin, nil, nil)
+	env.expectStatus(http.StatusNotFound, http.MethodGet, "/api/user/999", admin, nil)
+	env.expectStatus(http.StatusNotFound, http.MethodGet, "/api/no-such-route/42", admin, nil)
+	// 非标准方法统一记为 OTHER
+	env.doFrom("192.0.2.1:40000", nil, "BREW", "/api/no-such-route/43", nil)
+
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips", admin, nil, nil)
+	env.mustOK(http.MethodPost, "/api/hfish/block/ip", admin, gin.H{"ip": "203.0.113.7"}, nil)
+	env.expectStatus(http.StatusInternalServerError, http.MethodPost, "/api/hfish/block/ip", admin, gin.H{"ip": "10.0.0.1"})
+	// 同步被拒绝时返回已保存的数据
+	env.hfish.rotateAPIKey("rotated-key")
+	env.mustOK(http.MethodGet, "/api/hfish/attack/ips", admin, nil, nil)
+
+	env.mustOK(http.MethodPost, "/api/auth/send-verification-code", "", gin.H{"email": "metrics@example.test"}, nil)
+	env.expectStatus(http.StatusInternalServerError, http.MethodPost, "/api/auth/send-verification-code", "", gin.H{"email": "metrics@example.test"})
+
+	// 同一地址第二次登录被 auth 策略拒绝
+	login := gin.H{"username": adminUsername, "password": adminPassword}
+	env.doFrom("198.51.100.70:40000", nil, http.MethodPost, "/api/auth/login", login)
+	if w := env.doFrom("198.51.100.70:40000", nil, http.MethodPost, "/api/auth/login", login); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected second login to be limited, got %d", w.Code)
+	}
+
+	middleware.FlushLogs()
+	after := env.scrapeMetrics()
+
+	for series, want := range map[string]float64{
+		// 路由标签使用路由模板，不随路径参数增长
+		`superhoneypotguard_http_requests_total{method="GET",route="/api/user/:id",status="200"}`:                 1,
+		`superhoneypotguard_http_requests_total{method="GET",route="/api/user/:id",status="404"}`:                 1,
+		`superhoneypotguard_http_requests_total{method="GET",route="unmatched",status="404"}`:                     1,
+		`superhoneypotguard_http_requests_total{method="OTHER",route="unmatched",status="404"}`:                   1,
+		`superhoneypotguard_http_requests_total{method="POST",route="/api/auth/login",status="429"}`:              1,
+		`superhoneypotguard_http_request_duration_seconds_count{method="GET",route="/api/user/:id",status="200"}`: 1,
+		`superhoneypotguard_ratelimit_rejections_total{key="ip",policy="auth"}`:                                   1,
+		`superhoneypotguard_hfish_api_requests_total{endpoint="/attack/ip",outcome="success"}`:                    1,
+		`superhoneypotguard_hfish_api_requests_total{endpoint="/attack/ip",outcome="rejected"}`:                   1,
+		`superhoneypotguard_hfish_api_request_duration_seconds_count{endpoint="/attack/ip"}`:                      2,
+		`superhoneypotguard_hfish_ip_actions_total{action="block",outcome="success"}`:                             1,
+		`superhoneypotguard_hfish_ip_actions_total{action="block",outcome="rejected"}`:                            1,
+		`superhoneypotguard_email_sends_total{kind="verification",outcome="sent"}`:                                1,
+		`superhoneypotguard_email_sends_total{kind="verification",outcome="refused"}`:                             1,
+	} {
+		if got := after[series] - before[series]; got != want {
+			t.Fatalf("%s: expected to increase by %v, got %v", series, want, got)
+		}
+	}
+
+	for series := range after {
+		if strings.Contains(series, `method="BREW"`) {
+			t.Fatalf("unexpected series with a custom method: %s", series)
+		}
+	}
+
+	// 抓取请求本身不受限流影响，也不写入操作日志
+	if _, ok := after[`superhoneypotguard_http_requests_total{method="GET",route="/metrics",status="200"}`]; !ok {
+		t.Fatalf("expected scrapes to be counted")
+	}
+	var scrapes int64
+	env.db.Raw("SELECT COUNT(*) FROM operation_logs WHERE url = ?", "/metrics").Scan(&scrapes)
+	if scrapes != 0 {
+		t.Fatalf("expected scrapes not to be written to the operation log, got %d", scrapes)
+	}
+
+	if after["superhoneypotguard_operation_log_ingestion_lag_seconds_count"] <= before["superhoneypotguard_operation_log_ingestion_lag_seconds_count"] {
+		t.Fatalf("expected ingestion lag to be observed for written logs")
+	}
+	if after["superhoneypotguard_operation_log_written_total"] <= before["superhoneypotguard_operation_log_written_total"] {
+		t.Fatalf("expected written logs to be counted")
+	}
+	for _, series := range []string{
+		"superhoneypotguard_operation_log_queue_depth",
+		"superhoneypotguard_operation_log_queue_capacity",
+		"superhoneypotguard_operation_log_dropped_total",
+		"superhoneypotguard_db_open_connections",
+		"superhoneypotguard_db_wait_count_total",
+		"go_goroutines",
+	} {
+		if _, ok := after[series]; !ok {
+			t.Fatalf("expected %s to be exported", series)
+		}
+	}
+	if after["superhoneypotguard_operation_log_queue_capacity"] != float64(middleware.GetLogPipelineStats().QueueCapacity) {
+		t.Fatalf("expected queue capacity to match the log pipeline")
+	}
+}
+
+func TestMetricsWithoutToken(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 未配置令牌时不校验
+	if w := env.doFrom("192.0.2.1:40000", nil, http.MethodGet, "/metrics", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "superhoneypotguard_http_requests_total") {
+		t.Fatalf("expected metrics without token, got %d", w.Code)
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"superhoneypotguard/models"
+	"superhoneypotguard/services"
+)
+
+func TestPermissionServiceWithFakeRepository(t *testing.T) {
+	repo := newFakePermissionRepository()
+	svc := services.NewPermissionService(repo)
+
+	menu, err := svc.Create(models.CreatePermissionRequest{PermissionName: "蜜罐", PermissionCode: "hfish", PermissionType: "menu"})
+	if err != nil {
+		t.Fatalf("create menu: %v", err)
+	}
+	if menu.Status != 1 || menu.ParentID != 0 {
+		t.Fatalf("expected an enabled top-level permission, got %+v", menu)
+	}
+	order := 2
+	if _, err := svc.Create(models.CreatePermissionRequest{PermissionName: "查看", PermissionCode: "hfish:view", PermissionType: "button", ParentID: &menu.ID, SortOrder: &order}); err != nil {
+		t.Fatalf("create button: %v", err)
+	}
+	disabled := 0
+	if _, err := svc.Create(models.CreatePermissionRequest{PermissionName: "配置", PermissionCode: "hfish:config", PermissionType: "button", ParentID: &menu.ID, Status: &disabled}); err != nil {
+		t.Fatalf("create disabled button: %v", err)
+	}
+	if _, err := svc.Create(models.CreatePermissionRequest{PermissionName: "重复", PermissionCode: "hfish", PermissionType: "menu"}); services.KindOf(err) != services.KindInvalid {
+		t.Fatalf("expected a duplicate permission code to be rejected as invalid, got %v", err)
+	}
+
+	tree, err := svc.Tree()
+	if err != nil {
+		t.Fatalf("permission tree: %v", err)
+	}
+	if len(tree) != 1 || len(tree[0].Children) != 2 || tree[0].Children[0].PermissionCode != "hfish:config" {
+		t.Fatalf("expected hfish with children ordered by sort order, got %+v", tree)
+	}
+	active, err := svc.ListActive()
+	if err != nil || len(active) != 2 {
+		t.Fatalf("expected 2 active permissions, got %+v, %v", active, err)
+	}
+
+	name := "HFish 蜜罐"
+	permissionType := "menu"
+	if err := svc.Update(menu.ID, models.UpdatePermissionRequest{PermissionName: &name, PermissionType: &permissionType}); err != nil {
+		t.Fatalf("update permission: %v", err)
+	}
+	if got, _ := svc.Get(menu.ID); got.PermissionName != name {
+		t.Fatalf("expected the renamed permission, got %+v", got)
+	}
+	if err := svc.Update(99, models.UpdatePermissionRequest{PermissionName: &name, PermissionType: &permissionType}); services.KindOf(err) != services.KindNotFound {
+		t.Fatalf("expected updating a missing permission to be not found, got %v", err)
+	}
+
+	// 还有子权限时拒绝删除，且不触碰仓储
+	if err := svc.Delete(menu.ID); services.KindOf(err) != services.KindInvalid {
+		t.Fatalf("expected deleting a permission with children to be rejected, got %v", err)
+	}
+	if _, ok := repo.rows[menu.ID]; !ok {
+		t.Fatal("permission with children was deleted")
+	}
+
+	// 仓储错误统一映射为内部错误，并保留原始错误
+	leaf := tree[0].Children[0].ID
+	dbErr := errors.New("database is locked")
+	repo.failWith = dbErr
+	err = svc.Delete(leaf)
+	if services.KindOf(err) != services.KindInternal || !errors.Is(err, dbErr) {
+		t.Fatalf("expected a wrapped internal error, got %v", err)
+	}
+
+	repo.failWith = nil
+	if err := svc.Delete(leaf); err != nil {
+		t.Fatalf("delete permission: %v", err)
+	}
+	if err := svc.Delete(leaf); services.KindOf(err) != services.KindNotFound {
+		t.Fatalf("expected deleting a deleted permission to be not found, got %v", err)
+	}
+}
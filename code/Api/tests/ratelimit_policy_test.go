@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitParsePolicies(t *testing.T) {
+	policies, err := ratelimit.ParsePolicies(" auth=10/15m:ip, hfish = 120/1m : user ,export=3/1h,, ")
+	if err != nil {
+		t.Fatalf("parse policies: %v", err)
+	}
+	var got []string
+	for _, p := range policies {
+		got = append(got, p.String())
+	}
+	want := "auth=10/15m0s:ip hfish=120/1m0s:user export=3/1h0m0s:ip"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("expected %q, got %q", want, strings.Join(got, " "))
+	}
+
+	for spec, want := range map[string]string{
+		"auth":                "格式",
+		"a:b=1/1m":            "格式",
+		"auth=0/1m":           "次数",
+		"auth=10":             "次数",
+		"auth=10/forever":     "窗口",
+		"auth=10/1m:session":  "维度",
+		"auth=1/1m,auth=2/1m": "重复",
+		"global=1/1m":         "保留",
+		"export=1/1h:apikey":  "维度",
+		"export=1/1h,x=":      "次数",
+		"auth=2000000/1s":     "1µs",
+	} {
+		if _, err := ratelimit.ParsePolicies(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected error mentioning %s, got %v", spec, want, err)
+		}
+	}
+
+	cfg := config.Default()
+	cfg.JWTSecret = "integration-test-secret"
+	cfg.RateLimitPolicies = "auth=10/1m:device"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "rate_limit_policies") {
+		t.Fatalf("expected invalid policies to be rejected, got %v", err)
+	}
+
+	// 补充间隔为 0 时 Redis 脚本会除以 0，全局限流同样拒绝
+	cfg.RateLimitPolicies = ""
+	cfg.RateLimitWindow = time.Millisecond
+	cfg.RateLimitMax = 10000
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "rate_limit_max_requests") {
+		t.Fatalf("expected a sub-microsecond refill interval to be rejected, got %v", err)
+	}
+}
+
+// doAs 以默认来源地址和令牌发起请求，返回原始响应以便检查响应头
+func (e *testEnv) doAs(token, method, path string) *httptest.ResponseRecorder {
+	return e.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + token}, method, path, nil)
+}
+
+type rateLimitStatus struct {
+	Backend  string `json:"backend"`
+	Policies []struct {
+		Name   string `json:"name"`
+		Limit  int    `json:"limit"`
+		Window string `json:"window"`
+		Key    string `json:"key"`
+	} `json:"policies"`
+	Throttled []struct {
+		Policy     string `json:"policy"`
+		KeyType    string `json:"keyType"`
+		Key        string `json:"key"`
+		RetryAfter int    `json:"retryAfter"`
+	} `json:"throttled"`
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.RateLimitPolicies = "auth=2/1m:ip,hfish=3/1m:user,export=1/1h:user"
+	})
+
+	// 默认来源地址登录两次，用完 auth 策略的配额
+	admin := env.adminToken()
+	bobID := env.createUser(admin, "bob", "bob12345", env.roleID(admin, "user"))
+	bob := env.login("bob", "bob12345")
+
+	// 登录按 IP 限流，其他来源地址不受影响
+	login := gin.H{"username": "bob", "password": "wrong-password"}
+	for i := 0; i < 2; i++ {
+		if w := env.doFrom("198.51.100.50:40000", nil, http.MethodPost, "/api/auth/login", login); w.Code == http.StatusTooManyRequests {
+			t.Fatalf("login %d should not be limited yet", i+1)
+		}
+	}
+	w := env.doFrom("198.51.100.50:40000", nil, http.MethodPost, "/api/auth/login", login)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("expected login to be limited for 30s, got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Fatalf("unexpected rate limit headers: %v", w.Header())
+	}
+	if w := env.doFrom("198.51.100.51:40000", nil, http.MethodPost, "/api/auth/login", login); w.Code == http.StatusTooManyRequests {
+		t.Fatalf("expected other addresses to keep their own quota")
+	}
+
+	// HFish 接口按用户限流：没有权限的请求同样计数，同一地址的其他用户不受影响
+	for i := 0; i < 3; i++ {
+		if w := env.doAs(bob, http.MethodGet, "/api/hfish/sys/info"); w.Code != http.StatusForbidden {
+			t.Fatalf("request %d: expected 403 before the limit, got %d", i+1, w.Code)
+		}
+	}
+	if w := env.doAs(bob, http.MethodGet, "/api/hfish/sys/info"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 4th hfish request to be limited, got %d", w.Code)
+	}
+
+	// 全局限流与 hfish 策略同时生效时，响应头取剩余配额较少的 hfish 策略
+	w = env.doAs(admin, http.MethodGet, "/api/hfish/sys/info")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected admin to keep its own quota, got %d: %s", w.Code, w.Body.String())
+	}
+	for name, want := range map[string]string{
+		"RateLimit-Limit":     "3",
+		"RateLimit-Remaining": "2",
+		"RateLimit-Reset":     "20",
+		"RateLimit-Policy":    "3;w=60",
+	} {
+		if got := w.Header().Get(name); got != want {
+			t.Fatalf("expected %s %q, got %q", name, want, got)
+		}
+	}
+	if w.Header().Get("Retry-After") != "" {
+		t.Fatalf("expected no Retry-After on allowed requests")
+	}
+
+	// 导出按用户限流
+	if w := env.doAs(admin, http.MethodGet, "/api/log/export?format=csv"); w.Code != http.StatusOK {
+		t.Fatalf("export: expected 200, got %d", w.Code)
+	}
+	if w := env.doAs(admin, http.MethodGet, "/api/log/export?format=csv"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3600" {
+		t.Fatalf("expected second export to be limited for an hour, got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	var status rateLimitStatus
+	env.mustOK(http.MethodGet, "/api/system/ratelimit", admin, nil, &status)
+	var policies []string
+	for _, p := range status.Policies {
+		policies = append(policies, fmt.Sprintf("%s=%d/%s:%s", p.Name, p.Limit, p.Window, p.Key))
+	}
+	if got := strings.Join(policies, ","); status.Backend != "memory" || got != "global=100000/1m0s:ip,auth=2/1m0s:ip,export=1/1h0m0s:user,hfish=3/1m0s:user" {
+		t.Fatalf("unexpected policies: %s %s", status.Backend, got)
+	}
+
+	// 按解除时间从晚到早排列
+	var throttled []string
+	for _, c := range status.Throttled {
+		if c.RetryAfter <= 0 {
+			t.Fatalf("expected positive retry after, got %+v", c)
+		}
+		throttled = append(throttled, c.Policy+":"+c.KeyType+":"+c.Key)
+	}
+	want := fmt.Sprintf("export:user:1,auth:ip:198.51.100.50,hfish:user:%d", bobID)
+	if got := strings.Join(throttled, ","); got != want {
+		t.Fatalf("expected throttled clients %s, got %s", want, got)
+	}
+
+	// 只有系统设置权限可以查看
+	env.expectStatus(http.StatusForbidden, http.MethodGet, "/api/system/ratelimit", bob, nil)
+}
+
+func TestRateLimitPolicyUnconfigured(t *testing.T) {
+	env := newTestEnv(t)
+	middleware.ConfigureRateLimiter(time.Minute, 5)
+
+	// 未配置的策略不限流，只剩全局限流的响应头
+	w := env.doFrom("198.51.100.60:40000", nil, http.MethodPost, "/api/auth/login", gin.H{"username": "nobody", "password": "x"})
+	if w.Code == http.StatusTooManyRequests || w.Header().Get("RateLimit-Limit") != "5" || w.Header().Get("RateLimit-Remaining") != "4" {
+		t.Fatalf("expected only the global limit, got %d %v", w.Code, w.Header())
+	}
+
+	var status rateLimitStatus
+	env.mustOK(http.MethodGet, "/api/system/ratelimit", env.adminToken(), nil, &status)
+	if len(status.Policies) != 1 || status.Policies[0].Name != "global" || status.Throttled == nil || len(status.Throttled) != 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/ratelimit"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitBackend 一个限流后端及推进其时钟的方法
+type rateLimitBackend struct {
+	limiter ratelimit.Limiter
+	advance func(d time.Duration)
+}
+
+func newMemoryBackend() rateLimitBackend {
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	m := ratelimit.NewMemory()
+	m.Now = func() time.Time { return now }
+	return rateLimitBackend{limiter: m, advance: func(d time.Duration) { now = now.Add(d) }}
+}
+
+// newRedisBackend 基于进程内的 Redis 替身，脚本读取的服务器时间与 key 的过期时间同步推进
+func newRedisBackend(t *testing.T, srv *miniredis.Miniredis) rateLimitBackend {
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	srv.SetTime(now)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	limiter := ratelimit.NewRedis(client, "test:ratelimit:")
+	t.Cleanup(func() { limiter.Close() })
+	return rateLimitBackend{limiter: limiter, advance: func(d time.Duration) {
+		now = now.Add(d)
+		srv.SetTime(now)
+		srv.FastForward(d)
+	}}
+}
+
+func TestRateLimitBackends(t *testing.T) {
+	backends := map[string]func(t *testing.T) rateLimitBackend{
+		"memory": func(*testing.T) rateLimitBackend { return newMemoryBackend() },
+		"redis":  func(t *testing.T) rateLimitBackend { return newRedisBackend(t, miniredis.RunT(t)) },
+	}
+	rule := ratelimit.Rule{Window: time.Minute, Max: 3}
+	ctx := context.Background()
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			b := newBackend(t)
+			allow := func(key string) ratelimit.Result {
+				t.Helper()
+				result, err := b.limiter.Allow(ctx, key, rule)
+				if err != nil {
+					t.Fatalf("allow %s: %v", key, err)
+				}
+				return result
+			}
+
+			// 一次性用完 3 次配额
+			for want := 2; want >= 0; want-- {
+				if r := allow("ip:198.51.100.1"); !r.Allowed || r.Remaining != want || r.Limit != 3 {
+					t.Fatalf("expected remaining %d, got %+v", want, r)
+				}
+			}
+			r := allow("ip:198.51.100.1")
+			if r.Allowed || r.RetryAfter != 20*time.Second || r.ResetAfter != time.Minute {
+				t.Fatalf("expected 4th request to be rejected for 20s, got %+v", r)
+			}
+
+			// 被拒绝的 key 出现在限流列表中，直到可以再次请求
+			throttled, err := b.limiter.Throttled(ctx)
+			if err != nil {
+				t.Fatalf("list throttled: %v", err)
+			}
+			until := time.Date(2026, 10, 19, 8, 0, 20, 0, time.UTC)
+			if len(throttled) != 1 || throttled[0].Key != "ip:198.51.100.1" || !throttled[0].Until.Equal(until) {
+				t.Fatalf("expected 198.51.100.1 to be throttled until %s, got %+v", until, throttled)
+			}
+
+			// 其他 key 不受影响
+			if r := allow("ip:198.51.100.2"); !r.Allowed || r.Remaining != 2 {
+				t.Fatalf("expected independent quota, got %+v", r)
+			}
+
+			// 每 20 秒恢复一次配额
+			b.advance(20 * time.Second)
+			if throttled, _ := b.limiter.Throttled(ctx); len(throttled) != 0 {
+				t.Fatalf("expected throttle to expire, got %+v", throttled)
+			}
+			if r := allow("ip:198.51.100.1"); !r.Allowed || r.Remaining != 0 {
+				t.Fatalf("expected one request after 20s, got %+v", r)
+			}
+			if r := allow("ip:198.51.100.1"); r.Allowed {
+				t.Fatalf("expected quota to be exhausted again, got %+v", r)
+			}
+
+			b.advance(time.Minute)
+			if r := allow("ip:198.51.100.1"); !r.Allowed || r.Remaining != 2 {
+				t.Fatalf("expected full quota after a window, got %+v", r)
+			}
+		})
+	}
+}
+
+func TestRateLimitMemoryCleanup(t *testing.T) {
+	b := newMemoryBackend()
+	m := b.limiter.(*ratelimit.Memory)
+	rule := ratelimit.Rule{Window: time.Minute, Max: 10}
+
+	m.Allow(context.Background(), "a", rule)
+	b.advance(30 * time.Second)
+	m.Allow(context.Background(), "b", rule)
+	b.advance(time.Second)
+
+	// a 的配额已恢复满额，b 还没有
+	m.Cleanup()
+	if m.Len() != 1 {
+		t.Fatalf("expected only recovered keys to be removed, got %d keys", m.Len())
+	}
+}
+
+func TestRateLimitRedisSharedAcrossReplicas(t *testing.T) {
+	srv := miniredis.RunT(t)
+	first := newRedisBackend(t, srv)
+	second := newRedisBackend(t, srv)
+	rule := ratelimit.Rule{Window: time.Minute, Max: 4}
+	ctx := context.Background()
+
+	// 两个实例交替处理同一 IP 的请求，共享 4 次配额
+	for i := 0; i < 4; i++ {
+		limiter := first.limiter
+		if i%2 == 1 {
+			limiter = second.limiter
+		}
+		if r, err := limiter.Allow(ctx, "ip:203.0.113.7", rule); err != nil || !r.Allowed {
+			t.Fatalf("request %d: expected to be allowed, got %+v, %v", i+1, r, err)
+		}
+	}
+	if r, _ := second.limiter.Allow(ctx, "ip:203.0.113.7", rule); r.Allowed {
+		t.Fatalf("expected quota to be shared between replicas, got %+v", r)
+	}
+
+	// key 在配额恢复满额后自动过期
+	key := "test:ratelimit:ip:203.0.113.7"
+	if ttl := srv.TTL(key); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("expected key to expire within the window, ttl %s", ttl)
+	}
+	first.advance(time.Minute)
+	if srv.Exists(key) {
+		t.Fatalf("expected key to expire once the quota is restored")
+	}
+}
+
+func TestRateLimitRedisFallback(t *testing.T) {
+	srv := miniredis.RunT(t)
+	limiter := ratelimit.NewRedis(redis.NewClient(&redis.Options{Addr: srv.Addr(), MaxRetries: -1}), "test:ratelimit:")
+	defer limiter.Close()
+	rule := ratelimit.Rule{Window: time.Minute, Max: 1}
+	ctx := context.Background()
+
+	// Redis 不可用时退回到进程内限流，仍然限流而不是直接放行
+	logs := captureLogs(t, "warn")
+	srv.Close()
+	if r, err := limiter.Allow(ctx, "ip:198.51.100.1", rule); err != nil || !r.Allowed {
+		t.Fatalf("expected fallback to allow first request, got %+v, %v", r, err)
+	}
+	if r, _ := limiter.Allow(ctx, "ip:198.51.100.1", rule); r.Allowed {
+		t.Fatalf("expected fallback to enforce the limit, got %+v", r)
+	}
+	if !limiter.Degraded() {
+		t.Fatalf("expected limiter to report degraded state")
+	}
+	if n := strings.Count(logs.String(), "Redis 限流不可用"); n != 1 {
+		t.Fatalf("expected the fallback to be logged once, got %d:\n%s", n, logs.String())
+	}
+
+	if err := srv.Restart(); err != nil {
+		t.Fatalf("restart redis: %v", err)
+	}
+	if r, err := limiter.Allow(ctx, "ip:198.51.100.1", rule); err != nil || !r.Allowed {
+		t.Fatalf("expected redis to be used again after recovery, got %+v, %v", r, err)
+	}
+	if limiter.Degraded() {
+		t.Fatalf("expected limiter to recover")
+	}
+}
+
+// 限流脚本只访问各自的限流 key，不带统一的 hash tag，Redis Cluster 中按客户端分散到各个槽
+func TestRateLimitRedisKeysSpreadAcrossSlots(t *testing.T) {
+	srv := miniredis.RunT(t)
+	backend := newRedisBackend(t, srv).limiter
+	rule := ratelimit.Rule{Window: time.Minute, Max: 1}
+
+	for i := 0; i < 2; i++ {
+		backend.Allow(context.Background(), "ip:198.51.100.1", rule)
+	}
+	keys := srv.Keys()
+	sort.Strings(keys)
+	if want := []string{"test:ratelimit:ip:198.51.100.1", "test:ratelimit:throttled"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected %v, got %v", want, keys)
+	}
+	throttled, err := backend.Throttled(context.Background())
+	if err != nil || len(throttled) != 1 || throttled[0].Key != "ip:198.51.100.1" {
+		t.Fatalf("expected the throttled key to be listed, got %+v, %v", throttled, err)
+	}
+}
+
+func TestRateLimitMiddlewareRedisBackend(t *testing.T) {
+	srv := miniredis.RunT(t)
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.RateLimitBackend = "redis"
+		cfg.RedisHost = srv.Host()
+		cfg.RedisPort = srv.Port()
+	})
+	middleware.ConfigureRateLimiter(time.Minute, 2)
+
+	env.expectStatus(http.StatusUnauthorized, http.MethodGet, "/api/auth/current", "", nil)
+	env.expectStatus(http.StatusUnauthorized, http.MethodGet, "/api/auth/current", "", nil)
+	env.expectStatus(http.StatusTooManyRequests, http.MethodGet, "/api/auth/current", "", nil)
+
+	if !srv.Exists("superhoneypotguard:ratelimit:global:ip:192.0.2.1") {
+		t.Fatalf("expected rate limit state in redis, got keys %v", srv.Keys())
+	}
+}
@@ -0,0 +1,285 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func pathf(format string, args ...interface{}) string {
+	return fmt.Sprintf(format, args...)
+}
+
+func TestPermissionDenied(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	env.createUser(admin, "dave", "dave1234", env.roleID(admin, "user"))
+	token := env.login("dave", "dave1234")
+
+	env.mustOK(http.MethodGet, "/api/auth/current", token, nil, nil)
+	for _, path := range []string{"/api/user/list", "/api/role/list", "/api/permission/tree", "/api/log/list", "/api/dashboard/stats"} {
+		resp := env.expectStatus(http.StatusForbidden, http.MethodGet, path, token, nil)
+		if resp.Message != "权限不足" {
+			t.Fatalf("GET %s: unexpected message %q", path, resp.Message)
+		}
+	}
+	env.expectStatus(http.StatusForbidden, http.MethodPost, "/api/user/", token, gin.H{
+		"username": "mallory",
+		"password": "mallory123",
+	})
+
+	env.mustOK(http.MethodGet, "/api/user/list", admin, nil, nil)
+}
+
+func TestUserCRUD(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	userRole := env.roleID(admin, "user")
+
+	id := env.createUser(admin, "erin", "erin1234", userRole)
+
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/user/", admin, gin.H{
+		"username": "erin",
+		"password": "erin1234",
+	})
+
+	var user struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Status   int    `json:"status"`
+		Roles    []struct {
+			RoleCode string `json:"roleCode"`
+		} `json:"roles"`
+	}
+	env.mustOK(http.MethodGet, pathf("/api/user/%d", id), admin, nil, &user)
+	if user.Username != "erin" || user.Status != 1 || len(user.Roles) != 1 || user.Roles[0].RoleCode != "user" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+
+	var page struct {
+		List []struct {
+			Username string `json:"username"`
+		} `json:"list"`
+		Total int64 `json:"total"`
+	}
+	env.mustOK(http.MethodGet, "/api/user/list?username=eri", admin, nil, &page)
+	if page.Total != 1 || len(page.List) != 1 || page.List[0].Username != "erin" {
+		t.Fatalf("unexpected user list: %+v", page)
+	}
+
+	adminRole := env.roleID(admin, "admin")
+	env.mustOK(http.MethodPut, pathf("/api/user/%d", id), admin, gin.H{
+		"email":    "erin@new.example.test",
+		"realName": "Erin",
+		"roleIds":  []int{adminRole},
+	}, nil)
+	env.mustOK(http.MethodGet, pathf("/api/user/%d", id), admin, nil, &user)
+	if user.Email != "erin@new.example.test" || len(user.Roles) != 1 || user.Roles[0].RoleCode != "admin" {
+		t.Fatalf("update not applied: %+v", user)
+	}
+
+	// 角色变更后新令牌拥有管理员权限
+	erin := env.login("erin", "erin1234")
+	env.mustOK(http.MethodGet, "/api/user/list", erin, nil, nil)
+
+	env.mustOK(http.MethodPost, pathf("/api/user/%d/reset-password", id), admin, gin.H{"newPassword": "erin5678"}, nil)
+	env.expectStatus(http.StatusUnauthorized, http.MethodPost, "/api/auth/login", "", gin.H{
+		"username": "erin",
+		"password": "erin1234",
+	})
+	env.login("erin", "erin5678")
+
+	env.mustOK(http.MethodDelete, pathf("/api/user/%d", id), admin, nil, nil)
+	env.expectStatus(http.StatusNotFound, http.MethodGet, pathf("/api/user/%d", id), admin, nil)
+}
+
+func TestUserCannotModifySelf(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	var current struct {
+		User struct {
+			ID int `json:"id"`
+		} `json:"user"`
+	}
+	env.mustOK(http.MethodGet, "/api/auth/current", admin, nil, &current)
+
+	resp := env.expectStatus(http.StatusBadRequest, http.MethodDelete, pathf("/api/user/%d", current.User.ID), admin, nil)
+	if resp.Message != "不能删除当前登录用户" {
+		t.Fatalf("unexpected message: %q", resp.Message)
+	}
+	env.expectStatus(http.StatusBadRequest, http.MethodPatch, pathf("/api/user/%d/status", current.User.ID), admin, gin.H{"status": 0})
+}
+
+func TestRoleCRUD(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	var permissions []struct {
+		ID             int    `json:"id"`
+		PermissionCode string `json:"permissionCode"`
+	}
+	env.mustOK(http.MethodGet, "/api/permission/all", admin, nil, &permissions)
+	permissionID := func(code string) int {
+		for _, p := range permissions {
+			if p.PermissionCode == code {
+				return p.ID
+			}
+		}
+		t.Fatalf("permission %q not found", code)
+		return 0
+	}
+
+	var role struct {
+		ID int `json:"id"`
+	}
+	env.mustOK(http.MethodPost, "/api/role/", admin, gin.H{
+		"roleName":      "审计员",
+		"roleCode":      "auditor",
+		"permissionIds": []int{permissionID("log:manage")},
+	}, &role)
+	if role.ID == 0 {
+		t.Fatal("expected role id")
+	}
+
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/role/", admin, gin.H{
+		"roleName": "审计员",
+		"roleCode": "auditor",
+	})
+
+	// 拥有该角色的用户只能访问日志
+	env.createUser(admin, "frank", "frank123", role.ID)
+	frank := env.login("frank", "frank123")
+	env.mustOK(http.MethodGet, "/api/log/list", frank, nil, nil)
+	env.expectStatus(http.StatusForbidden, http.MethodGet, "/api/user/list", frank, nil)
+
+	env.mustOK(http.MethodPut, pathf("/api/role/%d", role.ID), admin, gin.H{
+		"roleName":      "审计管理员",
+		"permissionIds": []int{permissionID("log:manage"), permissionID("user:manage")},
+	}, nil)
+
+	var detail struct {
+		RoleName    string `json:"roleName"`
+		Permissions []struct {
+			PermissionCode string `json:"permissionCode"`
+		} `json:"permissions"`
+	}
+	env.mustOK(http.MethodGet, pathf("/api/role/%d", role.ID), admin, nil, &detail)
+	if detail.RoleName != "审计管理员" || len(detail.Permissions) != 2 {
+		t.Fatalf("unexpected role detail: %+v", detail)
+	}
+
+	// 权限按请求实时校验，无需重新登录
+	env.mustOK(http.MethodGet, "/api/user/list", frank, nil, nil)
+
+	resp := env.expectStatus(http.StatusBadRequest, http.MethodDelete, pathf("/api/role/%d", role.ID), admin, nil)
+	if resp.Message != "该角色下还有用户，无法删除" {
+		t.Fatalf("unexpected message: %q", resp.Message)
+	}
+
+	var empty struct {
+		ID int `json:"id"`
+	}
+	env.mustOK(http.MethodPost, "/api/role/", admin, gin.H{"roleName": "临时", "roleCode": "temp"}, &empty)
+	env.mustOK(http.MethodDelete, pathf("/api/role/%d", empty.ID), admin, nil, nil)
+	env.expectStatus(http.StatusNotFound, http.MethodGet, pathf("/api/role/%d", empty.ID), admin, nil)
+}
+
+func TestPermissionCRUD(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	var parent struct {
+		ID int `json:"id"`
+	}
+	env.mustOK(http.MethodPost, "/api/permission/", admin, gin.H{
+		"permissionName": "报表",
+		"permissionCode": "report",
+		"permissionType": "menu",
+		"path":           "/report",
+	}, &parent)
+
+	var child struct {
+		ID int `json:"id"`
+	}
+	env.mustOK(http.MethodPost, "/api/permission/", admin, gin.H{
+		"permissionName": "导出报表",
+		"permissionCode": "report:export",
+		"permissionType": "button",
+		"parentId":       parent.ID,
+	}, &child)
+
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/permission/", admin, gin.H{
+		"permissionName": "重复",
+		"permissionCode": "report",
+		"permissionType": "menu",
+	})
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/permission/", admin, gin.H{
+		"permissionName": "类型错误",
+		"permissionCode": "report:bad",
+		"permissionType": "page",
+	})
+
+	type node struct {
+		ID             int    `json:"id"`
+		PermissionCode string `json:"permissionCode"`
+		Children       []struct {
+			PermissionCode string `json:"permissionCode"`
+		} `json:"children"`
+	}
+	var tree []node
+	env.mustOK(http.MethodGet, "/api/permission/tree", admin, nil, &tree)
+	var found *node
+	for i := range tree {
+		if tree[i].ID == parent.ID {
+			found = &tree[i]
+		}
+	}
+	if found == nil || len(found.Children) != 1 || found.Children[0].PermissionCode != "report:export" {
+		t.Fatalf("report permission not nested in tree: %+v", found)
+	}
+
+	env.mustOK(http.MethodPut, pathf("/api/permission/%d", child.ID), admin, gin.H{
+		"permissionName": "导出",
+		"permissionType": "api",
+		"parentId":       parent.ID,
+	}, nil)
+
+	var detail struct {
+		PermissionName string `json:"permissionName"`
+		PermissionType string `json:"permissionType"`
+	}
+	env.mustOK(http.MethodGet, pathf("/api/permission/%d", child.ID), admin, nil, &detail)
+	if detail.PermissionName != "导出" || detail.PermissionType != "api" {
+		t.Fatalf("update not applied: %+v", detail)
+	}
+
+	resp := env.expectStatus(http.StatusBadRequest, http.MethodDelete, pathf("/api/permission/%d", parent.ID), admin, nil)
+	if resp.Message != "该权限下还有子权限，无法删除" {
+		t.Fatalf("unexpected message: %q", resp.Message)
+	}
+
+	env.mustOK(http.MethodDelete, pathf("/api/permission/%d", child.ID), admin, nil, nil)
+	env.mustOK(http.MethodDelete, pathf("/api/permission/%d", parent.ID), admin, nil, nil)
+	env.expectStatus(http.StatusNotFound, http.MethodGet, pathf("/api/permission/%d", parent.ID), admin, nil)
+}
+
+func TestDashboardStats(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	env.createUser(admin, "grace", "grace123", env.roleID(admin, "user"))
+
+	var stats struct {
+		UserCount       int64 `json:"userCount"`
+		RoleCount       int64 `json:"roleCount"`
+		PermissionCount int64 `json:"permissionCount"`
+	}
+	env.mustOK(http.MethodGet, "/api/dashboard/stats", admin, nil, &stats)
+	if stats.UserCount != 2 || stats.RoleCount < 2 || stats.PermissionCount == 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,301 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type retentionStatus struct {
+	Interval string `json:"interval"`
+	Policies []struct {
+		Name      string               `json:"name"`
+		Retention string               `json:"retention"`
+		Enabled   bool                 `json:"enabled"`
+		Archive   bool                 `json:"archive"`
+		LastRun   *models.RetentionRun `json:"lastRun"`
+	} `json:"policies"`
+}
+
+func (s retentionStatus) lastRun(policy string) *models.RetentionRun {
+	for _, p := range s.Policies {
+		if p.Name == policy {
+			return p.LastRun
+		}
+	}
+	return nil
+}
+
+// seedExpiredData 写入 2 条两天前的操作日志和 5 条验证码，
+// 其中 3 条验证码过期超过 1 小时，按测试配置的保留策略应被清理
+func seedExpiredData(t *testing.T, env *testEnv) {
+	t.Helper()
+
+	old := time.Now().Add(-48 * time.Hour)
+	logs := []models.OperationLog{
+		{Operation: "过期日志 1", Status: 1, CreatedAt: old},
+		{Operation: "过期日志 2", Status: 1, CreatedAt: old.Add(time.Minute)},
+	}
+	if err := repositories.NewLogRepository(env.db).Create(logs); err != nil {
+		t.Fatalf("seed logs: %v", err)
+	}
+
+	now := time.Now()
+	for i, expires := range []time.Duration{-3 * time.Hour, -2 * time.Hour, -90 * time.Minute, -30 * time.Minute, 5 * time.Minute} {
+		code := models.VerificationCode{
+			Code:      fmt.Sprintf("%06d", i),
+			Email:     fmt.Sprintf("user%d@example.com", i),
+			ExpiresAt: now.Add(expires),
+			SentAt:    now.Add(expires - 5*time.Minute),
+		}
+		if err := env.db.Create(&code).Error; err != nil {
+			t.Fatalf("seed verification codes: %v", err)
+		}
+	}
+}
+
+func TestRetentionPolicies(t *testing.T) {
+	env := newTestEnv(t)
+	seedExpiredData(t, env)
+	admin := env.adminToken()
+
+	var status retentionStatus
+	env.mustOK(http.MethodGet, "/api/system/retention", admin, nil, &status)
+	if len(status.Policies) != 3 || status.lastRun("operation_logs") != nil || status.lastRun("verification_codes") != nil {
+		t.Fatalf("unexpected retention status before any run: %+v", status)
+	}
+
+	var runs []models.RetentionRun
+	env.mustOK(http.MethodPost, "/api/system/retention/run", admin, nil, &runs)
+	if len(runs) != 2 {
+		t.Fatalf("expected both policies to run, got %+v", runs)
+	}
+	for _, run := range runs {
+		if run.Status != 1 || run.Source != services.RetentionSourceManual || run.ArchiveFile == nil {
+			t.Fatalf("unexpected run: %+v", run)
+		}
+	}
+	if runs[0].Policy != "operation_logs" || runs[0].Rows != 2 {
+		t.Fatalf("expected 2 operation logs to be archived, got %+v", runs[0])
+	}
+	if runs[1].Policy != "verification_codes" || runs[1].Rows != 3 {
+		t.Fatalf("expected 3 verification codes to be purged, got %+v", runs[1])
+	}
+
+	// 验证码归档文件包含被删除的全部行
+	f, err := os.Open(filepath.Join(config.AppConfig.RetentionArchiveDir, *runs[1].ArchiveFile))
+	if err != nil {
+		t.Fatalf("open archive file: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("read archive file: %v", err)
+	}
+	var archived []string
+	for scanner := bufio.NewScanner(gz); scanner.Scan(); {
+		var row struct {
+			Code  string `json:"code"`
+			Email string `json:"email"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil || row.Email == "" {
+			t.Fatalf("unexpected archived row %s: %v", scanner.Text(), err)
+		}
+		archived = append(archived, row.Code)
+	}
+	if strings.Join(archived, ",") != "000000,000001,000002" {
+		t.Fatalf("unexpected archived codes: %v", archived)
+	}
+
+	var remaining int64
+	env.db.Model(&models.VerificationCode{}).Count(&remaining)
+	if remaining != 2 {
+		t.Fatalf("expected 2 verification codes to remain, got %d", remaining)
+	}
+
+	// 操作日志通过哈希链归档，剩余的链仍然有效
+	if _, err := os.Stat(filepath.Join(config.AppConfig.AuditArchiveDir, *runs[0].ArchiveFile)); err != nil {
+		t.Fatalf("expected operation log archive file: %v", err)
+	}
+	if report := env.verifyChain(admin); !report.Valid || report.ArchivedSeq != 2 {
+		t.Fatalf("expected chain to continue after retention, got %+v", report)
+	}
+	var audit models.OperationLog
+	env.db.Where("action = ?", "log.archive").First(&audit)
+	if audit.URL == nil || *audit.URL != "/api/system/retention/run" || audit.Params == nil || !strings.Contains(*audit.Params, `"source":"manual"`) {
+		t.Fatalf("expected the archive to be audited with the triggering request, got %+v", audit)
+	}
+
+	env.mustOK(http.MethodGet, "/api/system/retention", admin, nil, &status)
+	if last := status.lastRun("verification_codes"); last == nil || last.Rows != 3 || last.CreatedBy == nil {
+		t.Fatalf("expected last run to be reported, got %+v", last)
+	}
+
+	// 再次执行没有需要清理的数据，也不生成归档文件
+	env.mustOK(http.MethodPost, "/api/system/retention/run", admin, gin.H{"policy": "verification_codes"}, &runs)
+	if len(runs) != 1 || runs[0].Rows != 0 || runs[0].ArchiveFile != nil {
+		t.Fatalf("expected an empty run, got %+v", runs)
+	}
+	entries, _ := os.ReadDir(config.AppConfig.RetentionArchiveDir)
+	if len(entries) != 1 {
+		t.Fatalf("expected only one archive file, got %d", len(entries))
+	}
+
+	var page struct {
+		List  []models.RetentionRun `json:"list"`
+		Total int64                 `json:"total"`
+	}
+	env.mustOK(http.MethodGet, "/api/system/retention/runs?policy=verification_codes", admin, nil, &page)
+	if page.Total != 2 || page.List[0].Rows != 0 || page.List[1].Rows != 3 {
+		t.Fatalf("unexpected run history: %+v", page)
+	}
+
+	env.expectStatus(http.StatusNotFound, http.MethodPost, "/api/system/retention/run", admin, gin.H{"policy": "alerts"})
+	env.expectStatus(http.StatusNotFound, http.MethodGet, "/api/system/retention/runs?policy=alerts", admin, nil)
+	// 测试配置中未设置攻击详情的保留时长，该策略未启用
+	env.expectStatus(http.StatusBadRequest, http.MethodPost, "/api/system/retention/run", admin, gin.H{"policy": "attack_events"})
+
+	// 手动执行写入审计日志
+	middleware.FlushLogs()
+	var logs logPage
+	env.mustOK(http.MethodGet, "/api/log/list?action=retention.run", admin, nil, &logs)
+	if logs.Total != 2 || logs.List[1].Operation != "执行数据保留策略（operation_logs 2 条，verification_codes 3 条）" {
+		t.Fatalf("expected retention runs to be audited, got %+v", logs.List)
+	}
+
+	env.createUser(admin, "peggy", "peggy1234", env.roleID(admin, "user"))
+	env.expectStatus(http.StatusForbidden, http.MethodPost, "/api/system/retention/run", env.login("peggy", "peggy1234"), nil)
+}
+
+func TestRetentionAttackEvents(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.RetentionAttackEvents = 24 * time.Hour
+		cfg.RetentionArchive = "attack_events"
+	})
+	admin := env.adminToken()
+
+	// 超过保留时长的攻击详情在同步时不写入
+	now := time.Now()
+	env.hfish.setAttackDetails([]map[string]interface{}{
+		{"id": "1", "ip": "203.0.113.7", "attack_type": "brute_force", "protocol": "ssh", "port": 22, "request_time": now.Add(-48 * time.Hour).Format(time.DateTime)},
+		{"id": "2", "ip": "203.0.113.8", "attack_type": "brute_force", "protocol": "ssh", "port": 22, "request_time": now.Add(-time.Hour).Format(time.DateTime)},
+	})
+	if page := env.queryDetails(admin, nil); page.Total != 1 || page.List[0].ID != "2" {
+		t.Fatalf("expected only the recent detail to be ingested, got %+v", page)
+	}
+
+	// 保留时长缩短前已同步的记录由策略清理并归档，攻击 IP 按最后出现时间清理
+	old, recent := now.Add(-72*time.Hour), now.Add(-time.Hour)
+	if err := env.db.Create(&models.HFishAttackDetail{InstanceID: 1, SourceID: "0", IP: "198.51.100.9", RequestTime: old.Format(time.DateTime), RequestedAt: &old}).Error; err != nil {
+		t.Fatalf("seed attack detail: %v", err)
+	}
+	for _, row := range []models.HFishAttackIP{
+		{InstanceID: 1, IP: "192.0.2.50", LastSeen: old.Format(time.DateTime), LastSeenAt: &old},
+		{InstanceID: 1, IP: "192.0.2.51", LastSeen: recent.Format(time.DateTime), LastSeenAt: &recent},
+	} {
+		if err := env.db.Create(&row).Error; err != nil {
+			t.Fatalf("seed attack ip: %v", err)
+		}
+	}
+	var runs []models.RetentionRun
+	env.mustOK(http.MethodPost, "/api/system/retention/run", admin, gin.H{"policy": "attack_events"}, &runs)
+	if len(runs) != 1 || runs[0].Status != 1 || runs[0].Rows != 2 || runs[0].ArchiveFile == nil {
+		t.Fatalf("expected one attack detail and one attack ip to be archived, got %+v", runs)
+	}
+	files := strings.Split(*runs[0].ArchiveFile, ",")
+	if len(files) != 2 || !strings.HasPrefix(files[0], "hfish_attack_details-") || !strings.HasPrefix(files[1], "hfish_attack_ips-") {
+		t.Fatalf("expected an archive file per table, got %q", *runs[0].ArchiveFile)
+	}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(config.AppConfig.RetentionArchiveDir, file)); err != nil {
+			t.Fatalf("expected attack data archive file: %v", err)
+		}
+	}
+	var ips []string
+	env.db.Table("hfish_attack_ips").Order("ip").Pluck("ip", &ips)
+	if len(ips) != 1 || ips[0] != "192.0.2.51" {
+		t.Fatalf("expected only the recently seen ip to remain, got %v", ips)
+	}
+
+	// 重新同步后被清理的记录不会再次写入
+	if page := env.queryDetails(admin, nil); page.Total != 1 || page.List[0].ID != "2" {
+		t.Fatalf("expected purged details to stay purged, got %+v", page)
+	}
+
+	cfg := config.Default()
+	cfg.JWTSecret = "integration-test-secret"
+	cfg.SecretsKey = testSecretsKey
+	cfg.AuditSigningKey = testSigningKey
+	cfg.RetentionAttackEvents = -time.Hour
+	cfg.RetentionArchive = "attack_events,alerts"
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "retention_attack_events") || !strings.Contains(err.Error(), `"alerts"`) || strings.Contains(err.Error(), `"attack_events"`) {
+		t.Fatalf("unexpected validation result: %v", err)
+	}
+}
+
+func TestRetentionCLI(t *testing.T) {
+	env := newTestEnv(t)
+	seedExpiredData(t, env)
+
+	open := func() (*services.RetentionService, error) {
+		repos := repositories.NewRepositories(env.db)
+		chain, err := services.NewAuditChainService(repos.Logs, services.NewAuditService(repos.Logs), config.AppConfig)
+		if err != nil {
+			return nil, err
+		}
+		return services.NewRetentionService(repos.Retention, chain, config.AppConfig), nil
+	}
+
+	var out bytes.Buffer
+	if err := services.RunRetentionCLI([]string{"run", "verification_codes"}, open, &out); err != nil {
+		t.Fatalf("retention run: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "purged 3 row(s)") || !strings.HasSuffix(out.String(), "OK\n") {
+		t.Fatalf("unexpected run output:\n%s", out.String())
+	}
+
+	// 命令行归档操作日志时不记录请求和操作人 ID
+	out.Reset()
+	if err := services.RunRetentionCLI([]string{"run", "operation_logs"}, open, &out); err != nil {
+		t.Fatalf("retention run: %v\n%s", err, out.String())
+	}
+	var audit models.OperationLog
+	env.db.Where("action = ?", "log.archive").First(&audit)
+	if audit.Method != nil || audit.URL != nil || audit.UserID != nil || audit.Params == nil || !strings.Contains(*audit.Params, `"source":"cli"`) {
+		t.Fatalf("expected a CLI archive without request details, got %+v", audit)
+	}
+	var archive models.AuditArchive
+	env.db.First(&archive)
+	if archive.ID == 0 || archive.CreatedBy != nil {
+		t.Fatalf("expected the archive to have no creator, got %+v", archive)
+	}
+
+	out.Reset()
+	if err := services.RunRetentionCLI([]string{"status"}, open, &out); err != nil {
+		t.Fatalf("retention status: %v", err)
+	}
+	if !strings.Contains(out.String(), "operation_logs") || !strings.Contains(out.String(), "never run") || !strings.Contains(out.String(), "3 row(s)") {
+		t.Fatalf("unexpected status output:\n%s", out.String())
+	}
+
+	if err := services.RunRetentionCLI([]string{"purge"}, open, &out); err == nil {
+		t.Fatalf("expected unknown command to fail")
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/services"
+)
+
+func TestRoleServiceWithFakeRepository(t *testing.T) {
+	repo := newFakeRoleRepository()
+	svc := services.NewRoleService(repo)
+
+	role, err := svc.Create(models.CreateRoleRequest{RoleName: "审计员", RoleCode: "auditor", PermissionIDs: []int{3, 5}}, 1)
+	if err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	if role.Status != 1 || role.CreatedBy == nil || *role.CreatedBy != 1 {
+		t.Fatalf("expected an enabled role created by operator 1, got %+v", role)
+	}
+	if _, err := svc.Create(models.CreateRoleRequest{RoleName: "其他", RoleCode: "auditor"}, 1); services.KindOf(err) != services.KindInvalid {
+		t.Fatalf("expected a duplicate role code to be rejected as invalid, got %v", err)
+	}
+
+	got, err := svc.Get(role.ID)
+	if err != nil {
+		t.Fatalf("get role: %v", err)
+	}
+	if len(got.Permissions) != 2 || got.Permissions[0].ID != 3 || got.Permissions[1].ID != 5 {
+		t.Fatalf("expected permissions [3 5], got %+v", got.Permissions)
+	}
+
+	name := "安全审计员"
+	if err := svc.Update(role.ID, models.UpdateRoleRequest{RoleName: &name, PermissionIDs: &[]int{7}}, 1); err != nil {
+		t.Fatalf("update role: %v", err)
+	}
+	page, err := svc.List(repositories.RoleFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("list roles: %v", err)
+	}
+	roles := page.List.([]models.Role)
+	if page.Total != 1 || roles[0].RoleName != name || len(roles[0].Permissions) != 1 || roles[0].Permissions[0].ID != 7 {
+		t.Fatalf("expected the renamed role with permission 7, got %+v", page)
+	}
+
+	if err := svc.Update(99, models.UpdateRoleRequest{RoleName: &name}, 1); services.KindOf(err) != services.KindNotFound {
+		t.Fatalf("expected updating a missing role to be not found, got %v", err)
+	}
+	if _, err := svc.Get(99); services.KindOf(err) != services.KindNotFound {
+		t.Fatalf("expected getting a missing role to be not found, got %v", err)
+	}
+
+	// 角色下还有用户时拒绝删除，且不触碰仓储
+	repo.userCounts[role.ID] = 2
+	if err := svc.Delete(role.ID); services.KindOf(err) != services.KindInvalid {
+		t.Fatalf("expected deleting a role with users to be rejected, got %v", err)
+	}
+	if _, ok := repo.rows[role.ID]; !ok {
+		t.Fatal("role with users was deleted")
+	}
+
+	// 仓储错误统一映射为内部错误，并保留原始错误
+	repo.userCounts[role.ID] = 0
+	dbErr := errors.New("database is locked")
+	repo.failWith = dbErr
+	err = svc.Delete(role.ID)
+	if services.KindOf(err) != services.KindInternal || !errors.Is(err, dbErr) {
+		t.Fatalf("expected a wrapped internal error, got %v", err)
+	}
+
+	repo.failWith = nil
+	if err := svc.Delete(role.ID); err != nil {
+		t.Fatalf("delete role: %v", err)
+	}
+	if err := svc.Delete(role.ID); services.KindOf(err) != services.KindNotFound {
+		t.Fatalf("expected deleting a deleted role to be not found, got %v", err)
+	}
+}
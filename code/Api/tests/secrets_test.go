@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"superhoneypotguard/config"
+	"superhoneypotguard/lifecycle"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/routes"
+	"superhoneypotguard/secrets"
+	"superhoneypotguard/services"
+	"superhoneypotguard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// restart 修改配置后在同一数据库上重新初始化路由，模拟更换配置后重启服务
+func (e *testEnv) restart(configure func(cfg *config.Config)) {
+	e.t.Helper()
+
+	configure(config.AppConfig)
+	lc := lifecycle.New()
+	e.t.Cleanup(func() {
+		lc.Shutdown(5 * time.Second)
+	})
+	r := gin.New()
+	if err := utils.ConfigureClientIP(r, config.SplitList(config.AppConfig.TrustedProxies), config.SplitList(config.AppConfig.RemoteIPHeaders)); err != nil {
+		e.t.Fatalf("configure trusted proxies: %v", err)
+	}
+	routes.SetupRoutes(r, e.db, lc)
+	e.engine, e.lc = r, lc
+}
+
+// storedSecret 直接读取数据库中保存的值
+func (e *testEnv) storedSecret(table, keyColumn, column, key string) string {
+	e.t.Helper()
+
+	var value string
+	if err := e.db.Table(table).Select(column).Where(keyColumn+" = ?", key).Scan(&value).Error; err != nil {
+		e.t.Fatalf("read %s.%s: %v", table, column, err)
+	}
+	return value
+}
+
+// secretsCLI 以当前配置执行 secrets 子命令
+func (e *testEnv) secretsCLI(args ...string) (string, error) {
+	e.t.Helper()
+
+	var out bytes.Buffer
+	err := services.RunSecretsCLI(args, func() (*services.SecretService, error) {
+		return services.NewSecretService(repositories.NewSecretRepository(e.db), config.AppConfig)
+	}, &out)
+	return out.String(), err
+}
+
+func generateKey(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := secrets.New(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, c.KeyID()
+}
+
+func TestSecretsKeyRing(t *testing.T) {
+	keyA, idA := generateKey(t)
+	keyB, idB := generateKey(t)
+	const label = "system_settings.smtp_password"
+
+	a, _ := secrets.New(keyA, nil)
+	sealed, _ := a.Encrypt(label, "smtp-secret")
+
+	// 旧密钥只用于解密，新值使用主密钥
+	b, err := secrets.New(keyB, []string{keyA})
+	if err != nil {
+		t.Fatalf("create cipher: %v", err)
+	}
+	if plain, err := b.Decrypt(label, sealed); err != nil || plain != "smtp-secret" {
+		t.Fatalf("decrypt with old key: %q %v", plain, err)
+	}
+	resealed, changed, err := b.Reencrypt(label, sealed)
+	if err != nil || !changed || !strings.HasPrefix(resealed, "v1:"+idB+":") {
+		t.Fatalf("expected value to move to key %s, got %q %v %v", idB, resealed, changed, err)
+	}
+	if again, changed, _ := b.Reencrypt(label, resealed); changed || again != resealed {
+		t.Fatalf("values under the primary key must be left alone")
+	}
+	if _, err := a.Decrypt(label, resealed); !errors.Is(err, secrets.ErrUnknownKey) {
+		t.Fatalf("old key must not decrypt the new value, got %v", err)
+	}
+	if id, _ := secrets.KeyIDOfValue(sealed); id != idA {
+		t.Fatalf("expected key id %s, got %s", idA, id)
+	}
+
+	if secrets.IsEncrypted("smtp-secret") || !secrets.IsEncrypted(sealed) {
+		t.Fatalf("IsEncrypted must tell plaintext from ciphertext")
+	}
+	if _, err := secrets.New(keyB, []string{"c2hvcnQ="}); err == nil {
+		t.Fatalf("expected invalid old key to be rejected")
+	}
+	cfg := config.Default()
+	cfg.JWTSecret = "integration-test-secret"
+	cfg.SecretsOldKeys = keyA + ",not-a-key"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "secrets_old_keys") {
+		t.Fatalf("expected secrets_old_keys validation error, got %v", err)
+	}
+}
+
+// settingSecretLabel SMTP 密码加密时的附加数据
+const settingSecretLabel = "system_settings.smtp_password"
+
+func TestSecretsEncryptedAtRest(t *testing.T) {
+	keyA, idA := generateKey(t)
+	env := newTestEnv(t, func(cfg *config.Config) { cfg.SecretsKey = keyA })
+	admin := env.adminToken()
+
+	env.mustOK(http.MethodPut, "/api/system/settings", admin, gin.H{
+		"settings": gin.H{"smtp_password": "smtp-secret"},
+	}, nil)
+	stored := env.storedSecret("system_settings", "setting_key", "setting_value", "smtp_password")
+	if !strings.HasPrefix(stored, "v1:"+idA+":") || strings.Contains(stored, "smtp-secret") {
+		t.Fatalf("smtp_password must be stored encrypted, got %q", stored)
+	}
+	if got := env.settings(admin)["smtp_password"]; got.Value != "******" {
+		t.Fatalf("smtp_password must be masked, got %+v", got)
+	}
+
+	// 加密存储之前写入的明文在启动时被加密，且仍然生效
+	if err := env.db.Table("system_settings").Where("setting_key = ?", "smtp_password").Update("setting_value", "legacy-password").Error; err != nil {
+		t.Fatal(err)
+	}
+	env.restart(func(cfg *config.Config) {})
+	stored = env.storedSecret("system_settings", "setting_key", "setting_value", "smtp_password")
+	if !strings.HasPrefix(stored, "v1:"+idA+":") {
+		t.Fatalf("legacy plaintext must be encrypted on startup, got %q", stored)
+	}
+	secretService, err := services.NewSecretService(repositories.NewSecretRepository(env.db), config.AppConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settingService := services.NewSettingService(repositories.NewSettingRepository(env.db), services.NewAuditService(repositories.NewLogRepository(env.db)), secretService, config.AppConfig)
+	if err := settingService.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := settingService.Current().SMTP.Password; got != "legacy-password" {
+		t.Fatalf("expected decrypted smtp password, got %q", got)
+	}
+
+	// 密文与 JWT 密钥无关，更换 JWT 密钥不影响解密
+	config.AppConfig.JWTSecret = "rotated-jwt-secret-rotated-jwt-secret"
+	secretService, _ = services.NewSecretService(repositories.NewSecretRepository(env.db), config.AppConfig)
+	if plain, err := secretService.Decrypt(settingSecretLabel, stored); err != nil || plain != "legacy-password" {
+		t.Fatalf("expected the value to survive a JWT secret rotation, got %q %v", plain, err)
+	}
+}
+
+func TestSecretsKeyRotation(t *testing.T) {
+	keyA, idA := generateKey(t)
+	keyB, idB := generateKey(t)
+	env := newTestEnv(t, func(cfg *config.Config) { cfg.SecretsKey = keyA })
+	admin := env.adminToken()
+
+	env.mustOK(http.MethodPut, "/api/system/settings", admin, gin.H{
+		"settings": gin.H{"smtp_password": "smtp-secret"},
+	}, nil)
+	out, err := env.secretsCLI("status")
+	if err != nil || !strings.Contains(out, "Current key "+idA) || !strings.Contains(out, "OK") {
+		t.Fatalf("unexpected status output %q: %v", out, err)
+	}
+
+	// 新主密钥 + 旧密钥：启动时重新加密，数据照常可用
+	env.restart(func(cfg *config.Config) {
+		cfg.SecretsKey = keyB
+		cfg.SecretsOldKeys = keyA
+	})
+	apiKey := env.storedSecret("hfish_instances", "name", "api_key", "default")
+	password := env.storedSecret("system_settings", "setting_key", "setting_value", "smtp_password")
+	for _, value := range []string{apiKey, password} {
+		if !strings.HasPrefix(value, "v1:"+idB+":") {
+			t.Fatalf("expected value to be re-encrypted under %s, got %q", idB, value)
+		}
+	}
+	out, err = env.secretsCLI("status")
+	if err != nil || strings.Contains(out, idA) || !strings.Contains(out, "OK") {
+		t.Fatalf("expected no values under the old key, got %q: %v", out, err)
+	}
+	out, err = env.secretsCLI("rotate")
+	if err != nil || !strings.Contains(out, "re-encrypted 0") {
+		t.Fatalf("rotate must be idempotent, got %q: %v", out, err)
+	}
+
+	// 删除旧密钥后依然可用
+	env.restart(func(cfg *config.Config) { cfg.SecretsOldKeys = "" })
+	env.mustOK(http.MethodGet, "/api/hfish/sys/info", admin, nil, nil)
+
+	// 缺少旧密钥时无法解密，CLI 报告失败的行，实例调用返回错误而不是泄露密文
+	keyC, _ := generateKey(t)
+	config.AppConfig.SecretsKey = keyC
+	out, err = env.secretsCLI("rotate")
+	if err == nil || !strings.Contains(out, "FAILED") {
+		t.Fatalf("expected rotate to fail without the old key, got %q: %v", out, err)
+	}
+	env.restart(func(cfg *config.Config) {})
+	resp := env.expectStatus(http.StatusInternalServerError, http.MethodGet, "/api/hfish/sys/info", admin, nil)
+	if !strings.Contains(resp.Message, "解密 API Key 失败") {
+		t.Fatalf("expected a decrypt error, got %q", resp.Message)
+	}
+
+	if out, err := env.secretsCLI("keygen"); err != nil || len(strings.TrimSpace(out)) != 44 {
+		t.Fatalf("unexpected keygen output %q: %v", out, err)
+	}
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type settingItem struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	Secret     bool   `json:"secret"`
+	Overridden bool   `json:"overridden"`
+}
+
+func (e *testEnv) settings(token string) map[string]settingItem {
+	e.t.Helper()
+
+	var items []settingItem
+	e.mustOK(http.MethodGet, "/api/system/settings", token, nil, &items)
+	byKey := make(map[string]settingItem, len(items))
+	for _, item := range items {
+		byKey[item.Key] = item
+	}
+	return byKey
+}
+
+func TestSystemSettingsList(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	settings := env.settings(admin)
+	if got := settings["smtp_port"]; got.Value != env.smtp.port || got.Overridden {
+		t.Fatalf("unexpected smtp_port: %+v", got)
+	}
+	if got := settings["smtp_password"]; !got.Secret || got.Value != "" {
+		t.Fatalf("unexpected smtp_password: %+v", got)
+	}
+	env.mustOK(http.MethodPut, "/api/system/settings", admin, gin.H{
+		"settings": gin.H{"smtp_password": "smtp-secret"},
+	}, nil)
+	if got := env.settings(admin)["smtp_password"]; got.Value != "******" || !got.Overridden {
+		t.Fatalf("smtp_password must be redacted: %+v", got)
+	}
+
+	env.createUser(admin, "ivan", "ivan1234", env.roleID(admin, "user"))
+	token := env.login("ivan", "ivan1234")
+	env.expectStatus(http.StatusForbidden, http.MethodGet, "/api/system/settings", token, nil)
+	env.expectStatus(http.StatusForbidden, http.MethodPut, "/api/system/settings", token, gin.H{
+		"settings": gin.H{"rate_limit_max_requests": 1},
+	})
+}
+
+func TestSystemSettingsValidation(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	resp := env.expectStatus(http.StatusBadRequest, http.MethodPut, "/api/system/settings", admin, gin.H{
+		"settings": gin.H{
+			"smtp_port":         70000,
+			"rate_limit_window": "soon",
+			"no_such_setting":   "x",
+			"smtp_host":         "smtp.example.test",
+		},
+	})
+	for _, key := range []string{"smtp_port", "rate_limit_window", "no_such_setting"} {
+		if !strings.Contains(resp.Message, key) {
+			t.Errorf("validation message should mention %s: %q", key, resp.Message)
+		}
+	}
+
+	// 窗口过短时补充间隔可能不足 1µs
+	resp = env.expectStatus(http.StatusBadRequest, http.MethodPut, "/api/system/settings", admin, gin.H{
+		"settings": gin.H{"rate_limit_window": "500ms"},
+	})
+	if !strings.Contains(resp.Message, "rate_limit_window") {
+		t.Errorf("validation message should mention rate_limit_window: %q", resp.Message)
+	}
+
+	// 任意一项不合法时整体不生效
+	if got := env.settings(admin)["smtp_host"]; got.Overridden {
+		t.Fatalf("smtp_host must not be saved when the request is rejected: %+v", got)
+	}
+}
+
+func TestSystemSettingsHotReload(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	// SMTP 端口切换到另一个邮件服务后，验证码发往新的服务
+	sink := newSMTPSink(t)
+	env.mustOK(http.MethodPut, "/api/system/settings", admin, gin.H{
+		"settings": gin.H{"smtp_port": sink.port},
+	}, nil)
+	env.mustOK(http.MethodPost, "/api/auth/send-verification-code", "", gin.H{"email": "judy@example.test"}, nil)
+	if sink.count("judy@example.test") != 1 || env.smtp.count("judy@example.test") != 0 {
+		t.Fatal("verification mail should be delivered through the reconfigured SMTP server")
+	}
+
+	// 提交脱敏值表示保留原值
+	env.mustOK(http.MethodPut, "/api/system/settings", admin, gin.H{
+		"settings": gin.H{"smtp_password": "******"},
+	}, nil)
+	if got := env.settings(admin)["smtp_password"]; got.Overridden {
+		t.Fatalf("redacted value must not be saved: %+v", got)
+	}
+
+	// 重置后恢复启动配置
+	env.mustOK(http.MethodDelete, "/api/system/settings/smtp_port", admin, nil, nil)
+	env.mustOK(http.MethodPost, "/api/auth/send-verification-code", "", gin.H{"email": "kate@example.test"}, nil)
+	if env.smtp.count("kate@example.test") != 1 || sink.count("kate@example.test") != 0 {
+		t.Fatal("verification mail should go back to the original SMTP server")
+	}
+	if got := env.settings(admin)["smtp_port"]; got.Overridden {
+		t.Fatalf("smtp_port should be reset: %+v", got)
+	}
+
+	env.expectStatus(http.StatusNotFound, http.MethodDelete, "/api/system/settings/no_such_setting", admin, nil)
+}
+
+func TestSystemSettingsAudit(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	env.mustOK(http.MethodPut, "/api/system/settings", admin, gin.H{
+		"settings": gin.H{
+			"rate_limit_window": "30m",
+			"smtp_password":     "new-smtp-password",
+		},
+	}, nil)
+	env.mustOK(http.MethodDelete, "/api/system/settings/rate_limit_window", admin, nil, nil)
+
+	var page struct {
+		List []struct {
+			Username  *string `json:"username"`
+			Operation string  `json:"operation"`
+			Action    *string `json:"action"`
+			TargetID  *string `json:"targetId"`
+			Params    *string `json:"params"`
+		} `json:"list"`
+		Total int64 `json:"total"`
+	}
+	env.mustOK(http.MethodGet, "/api/log/list?operation=系统设置&pageSize=50", admin, nil, &page)
+	if page.Total != 3 {
+		t.Fatalf("expected 3 audit entries, got %d: %+v", page.Total, page.List)
+	}
+
+	var sawWindow, sawPassword, sawReset bool
+	for _, entry := range page.List {
+		if entry.Username == nil || *entry.Username != adminUsername || entry.Params == nil {
+			t.Fatalf("audit entry missing actor or detail: %+v", entry)
+		}
+		params := *entry.Params
+		if strings.Contains(params, "new-smtp-password") {
+			t.Fatalf("audit entry leaks secret: %s", params)
+		}
+		switch {
+		case entry.Operation == "重置系统设置 rate_limit_window" && *entry.Action == "setting.reset" &&
+			*entry.TargetID == "rate_limit_window" && strings.Contains(params, `"oldValue":"30m0s"`):
+			sawReset = true
+		case strings.Contains(params, `"newValue":"30m0s"`):
+			sawWindow = true
+		case strings.Contains(params, `"key":"smtp_password"`) && strings.Contains(params, `"newValue":"******"`):
+			sawPassword = true
+		}
+	}
+	if !sawWindow || !sawPassword || !sawReset {
+		t.Fatalf("missing audit entries (window=%v password=%v reset=%v): %+v", sawWindow, sawPassword, sawReset, page.List)
+	}
+}
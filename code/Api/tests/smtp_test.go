@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"bufio"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpSink 只实现收信所需最小子集的 SMTP 服务，不支持 STARTTLS 与 AUTH
+type smtpSink struct {
+	host     string
+	port     string
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []sinkMessage
+}
+
+type sinkMessage struct {
+	To   []string
+	Data string
+}
+
+var verificationCodePattern = regexp.MustCompile(`>(\d{6})</strong>`)
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen smtp sink: %v", err)
+	}
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	s := &smtpSink{host: host, port: port, listener: l}
+
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+
+	return s
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpSink) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		io.WriteString(conn, line+"\r\n")
+	}
+
+	reply("220 sink.test ESMTP")
+
+	var current sinkMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 sink.test")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			current = sinkMessage{}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			addr := strings.TrimSpace(line[len("RCPT TO:"):])
+			current.To = append(current.To, strings.Trim(addr, "<>\r\n "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			current.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, current)
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// count 返回发往指定邮箱的邮件数量
+func (s *smtpSink) count(email string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, m := range s.messages {
+		for _, to := range m.To {
+			if to == email {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// lastCode 取出最近一封发往 email 的邮件中的验证码
+func (s *smtpSink) lastCode(t *testing.T, email string) string {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		m := s.messages[i]
+		for _, to := range m.To {
+			if to != email {
+				continue
+			}
+			decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(m.Data)))
+			if err != nil {
+				t.Fatalf("decode mail body: %v", err)
+			}
+			match := verificationCodePattern.FindStringSubmatch(string(decoded))
+			if match == nil {
+				t.Fatalf("no verification code in mail to %s", email)
+			}
+			return match[1]
+		}
+	}
+
+	t.Fatalf("no mail sent to %s", email)
+	return ""
+}
@@ -0,0 +1,346 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"superhoneypotguard/middleware"
+	"superhoneypotguard/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// exportedSpan 文件导出器输出的 span，只解析测试用到的字段
+type exportedSpan struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		TraceID string
+		SpanID  string
+	}
+	Attributes []struct {
+		Key   string
+		Value struct {
+			Value interface{}
+		}
+	}
+	Status struct {
+		Code string
+	}
+}
+
+func (s exportedSpan) attr(key string) interface{} {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value.Value
+		}
+	}
+	return nil
+}
+
+// traceFile 写入临时文件的追踪数据
+type traceFile struct {
+	t        *testing.T
+	path     string
+	provider *tracing.Provider
+}
+
+// captureTraces 启用写入临时文件的追踪并设为全局 TracerProvider，测试结束后恢复为不记录
+func captureTraces(t *testing.T, samplePercent int) *traceFile {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	p, err := tracing.New(tracing.Options{Exporter: tracing.ExporterFile, FilePath: path, SamplePercent: samplePercent})
+	if err != nil {
+		t.Fatalf("create trace provider: %v", err)
+	}
+	otel.SetTracerProvider(p.TracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		p.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+	return &traceFile{t: t, path: path, provider: p}
+}
+
+// spans 导出缓冲的 span 并读取文件中的全部 span
+func (f *traceFile) spans() []exportedSpan {
+	f.t.Helper()
+
+	if err := f.provider.ForceFlush(context.Background()); err != nil {
+		f.t.Fatalf("flush spans: %v", err)
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		f.t.Fatalf("open trace file: %v", err)
+	}
+	defer file.Close()
+
+	var spans []exportedSpan
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var s exportedSpan
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			f.t.Fatalf("invalid span %q: %v", scanner.Text(), err)
+		}
+		spans = append(spans, s)
+	}
+	return spans
+}
+
+// inTrace 返回属于 traceID 的 span
+func inTrace(spans []exportedSpan, traceID string) []exportedSpan {
+	var out []exportedSpan
+	for _, s := range spans {
+		if s.SpanContext.TraceID == traceID {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func findSpan(spans []exportedSpan, name string) (exportedSpan, bool) {
+	for _, s := range spans {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return exportedSpan{}, false
+}
+
+const (
+	upstreamTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	upstreamSpanID  = "00f067aa0ba902b7"
+)
+
+func TestTracingRequestSpans(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	traces := captureTraces(t, 100)
+	logs := captureLogs(t, "info")
+
+	// 沿用上游的 traceparent，响应头返回追踪 ID
+	w := env.doFrom("192.0.2.1:40000", map[string]string{
+		"Authorization": "Bearer " + admin,
+		"traceparent":   "00-" + upstreamTraceID + "-" + upstreamSpanID + "-01",
+	}, http.MethodGet, "/api/dashboard/stats", nil)
+	if w.Code != http.StatusOK || w.Header().Get(middleware.TraceIDHeader) != upstreamTraceID {
+		t.Fatalf("expected trace id header, got %d %q", w.Code, w.Header().Get(middleware.TraceIDHeader))
+	}
+
+	spans := inTrace(traces.spans(), upstreamTraceID)
+	server, ok := findSpan(spans, "GET /api/dashboard/stats")
+	if !ok || server.Parent.SpanID != upstreamSpanID {
+		t.Fatalf("expected server span under the upstream span, got %+v", spans)
+	}
+	if server.attr("http.route") != "/api/dashboard/stats" || server.attr("http.response.status_code") != float64(http.StatusOK) {
+		t.Fatalf("unexpected server span attributes %+v", server.Attributes)
+	}
+	permission, ok := findSpan(spans, "PermissionMiddleware")
+	if !ok || permission.Parent.SpanID != server.SpanContext.SpanID || permission.attr("permission.allowed") != true {
+		t.Fatalf("expected permission span under the server span, got %+v", permission)
+	}
+
+	// 权限查询挂在权限校验下，统计查询挂在请求下
+	var permissionQueries, statsQueries int
+	for _, s := range spans {
+		if s.Name != "gorm.query" {
+			continue
+		}
+		if s.attr("db.system") != "sqlite" {
+			t.Fatalf("unexpected db span %+v", s)
+		}
+		switch s.Parent.SpanID {
+		case permission.SpanContext.SpanID:
+			permissionQueries++
+		case server.SpanContext.SpanID:
+			statsQueries++
+		}
+	}
+	if permissionQueries != 1 || statsQueries != 4 {
+		t.Fatalf("expected 1 permission query and 4 stats queries, got %d and %d", permissionQueries, statsQueries)
+	}
+
+	// 访问日志带有追踪 ID
+	var linked bool
+	for _, line := range logs.lines(t) {
+		if line["msg"] == "请求完成" && line["trace_id"] == upstreamTraceID {
+			linked = true
+		}
+	}
+	if !linked {
+		t.Fatalf("expected access log to carry the trace id:\n%s", logs.String())
+	}
+}
+
+func TestTracingOutboundCalls(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	traces := captureTraces(t, 100)
+
+	// 调用 HFish 记录客户端 span，并通过 traceparent 传给 HFish
+	w := env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + admin}, http.MethodGet, "/api/hfish/attack/ips", nil)
+	traceID := w.Header().Get(middleware.TraceIDHeader)
+	if w.Code != http.StatusOK || len(traceID) != 32 {
+		t.Fatalf("expected a new trace, got %d %q", w.Code, traceID)
+	}
+	spans := inTrace(traces.spans(), traceID)
+	server, _ := findSpan(spans, "GET /api/hfish/attack/ips")
+	hfish, ok := findSpan(spans, "HFish POST /attack/ip")
+	if !ok || hfish.Parent.SpanID != server.SpanContext.SpanID {
+		t.Fatalf("expected HFish span under the server span, got %+v", spans)
+	}
+	if hfish.attr("url.path") != "/attack/ip" || hfish.attr("hfish.outcome") != "success" || hfish.attr("http.response.status_code") != float64(http.StatusOK) {
+		t.Fatalf("unexpected HFish span attributes %+v", hfish.Attributes)
+	}
+	parents := env.hfish.receivedTraceparents()
+	if len(parents) == 0 || !strings.Contains(parents[len(parents)-1], "-"+traceID+"-"+hfish.SpanContext.SpanID+"-") {
+		t.Fatalf("expected HFish to receive the trace context, got %v", parents)
+	}
+	raw, _ := os.ReadFile(traces.path)
+	if strings.Contains(string(raw), hfishAPIKey) {
+		t.Fatalf("spans must not contain the HFish api key")
+	}
+
+	// HFish 拒绝请求时 span 标记为失败
+	env.hfish.rotateAPIKey("rotated-key")
+	w = env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + admin}, http.MethodGet, "/api/hfish/attack/ips", nil)
+	failed, _ := findSpan(inTrace(traces.spans(), w.Header().Get(middleware.TraceIDHeader)), "HFish POST /attack/ip")
+	if failed.Status.Code != "Error" || failed.attr("hfish.outcome") != "rejected" {
+		t.Fatalf("expected failed HFish span, got %+v", failed)
+	}
+
+	// 发送邮件记录 SMTP span，不包含收件人
+	w = env.doFrom("192.0.2.1:40000", nil, http.MethodPost, "/api/auth/send-verification-code", gin.H{"email": "trace@example.test"})
+	smtp, ok := findSpan(inTrace(traces.spans(), w.Header().Get(middleware.TraceIDHeader)), "SMTP send")
+	if w.Code != http.StatusOK || !ok || smtp.attr("server.address") != env.smtp.host {
+		t.Fatalf("expected SMTP span, got %d %+v", w.Code, smtp)
+	}
+	raw, _ = os.ReadFile(traces.path)
+	if strings.Contains(string(raw), "trace@example.test") {
+		t.Fatalf("spans must not contain the recipient")
+	}
+}
+
+func TestTracingOperationLogLink(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+	traces := captureTraces(t, 100)
+
+	w := env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + admin}, http.MethodGet, "/api/hfish/sys/info", nil)
+	traceID := w.Header().Get(middleware.TraceIDHeader)
+	if traceID == "" {
+		t.Fatalf("expected a trace id, got %d", w.Code)
+	}
+
+	middleware.FlushLogs()
+	var page struct {
+		List []struct {
+			URL     *string `json:"url"`
+			TraceID *string `json:"traceId"`
+		} `json:"list"`
+		Total int64 `json:"total"`
+	}
+	env.mustOK(http.MethodGet, "/api/log/list?traceId="+traceID, admin, nil, &page)
+	if page.Total != 1 || *page.List[0].URL != "/api/hfish/sys/info" || *page.List[0].TraceID != traceID {
+		t.Fatalf("expected the operation log to link the trace, got %+v", page)
+	}
+	if report := env.verifyChain(admin); !report.Valid {
+		t.Fatalf("expected chain to verify, got %+v", report.Issues)
+	}
+
+	// 后台写入操作日志不产生追踪，所有数据库 span 都挂在请求下
+	for _, s := range traces.spans() {
+		if strings.HasPrefix(s.Name, "gorm.") && s.Parent.SpanID == "0000000000000000" {
+			t.Fatalf("unexpected root db span %+v", s)
+		}
+	}
+}
+
+func TestTracingSamplingAndDisabled(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.adminToken()
+
+	// 未启用追踪时不返回追踪 ID，操作日志也不记录
+	w := env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + admin}, http.MethodGet, "/api/dashboard/stats", nil)
+	if w.Code != http.StatusOK || w.Header().Get(middleware.TraceIDHeader) != "" {
+		t.Fatalf("expected no trace id without tracing, got %q", w.Header().Get(middleware.TraceIDHeader))
+	}
+	middleware.FlushLogs()
+	var linked int64
+	env.db.Raw("SELECT COUNT(*) FROM operation_logs WHERE trace_id IS NOT NULL").Scan(&linked)
+	if linked != 0 {
+		t.Fatalf("expected no trace ids without tracing, got %d", linked)
+	}
+
+	// 采样比例为 0 时不记录新的追踪
+	traces := captureTraces(t, 0)
+	w = env.doFrom("192.0.2.1:40000", map[string]string{"Authorization": "Bearer " + admin}, http.MethodGet, "/api/dashboard/stats", nil)
+	if w.Header().Get(middleware.TraceIDHeader) != "" {
+		t.Fatalf("expected unsampled request to have no trace id")
+	}
+	if spans := traces.spans(); len(spans) != 0 {
+		t.Fatalf("expected no spans, got %d", len(spans))
+	}
+
+	// 上游已采样的追踪仍然记录
+	env.doFrom("192.0.2.1:40000", map[string]string{
+		"Authorization": "Bearer " + admin,
+		"traceparent":   "00-" + upstreamTraceID + "-" + upstreamSpanID + "-01",
+	}, http.MethodGet, "/api/dashboard/stats", nil)
+	if spans := inTrace(traces.spans(), upstreamTraceID); len(spans) == 0 {
+		t.Fatalf("expected sampled upstream trace to be recorded")
+	}
+}
+
+func TestTracingOTLPExporter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Content-Type"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer collector.Close()
+
+	p, err := tracing.New(tracing.Options{Exporter: tracing.ExporterOTLP, Endpoint: collector.URL, SamplePercent: 100})
+	if err != nil {
+		t.Fatalf("create otlp provider: %v", err)
+	}
+	_, span := p.TracerProvider().Tracer("test").Start(context.Background(), "work")
+	span.End()
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 1 || requests[0] != "POST /v1/traces application/x-protobuf" {
+		t.Fatalf("unexpected collector requests %v", requests)
+	}
+
+	if _, err := tracing.New(tracing.Options{Exporter: "zipkin"}); err == nil {
+		t.Fatalf("expected unknown exporter to be rejected")
+	}
+	if _, err := tracing.New(tracing.Options{Exporter: tracing.ExporterOTLP, Endpoint: "localhost:4318"}); err == nil {
+		t.Fatalf("expected endpoint without scheme to be rejected")
+	}
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"superhoneypotguard/models"
+	"superhoneypotguard/repositories"
+	"superhoneypotguard/services"
+	"superhoneypotguard/utils"
+)
+
+func TestUserServiceWithFakeRepository(t *testing.T) {
+	useFastBCrypt(t)
+	repo := newFakeUserRepository()
+	// 操作者 ID 为 1，新建用户从 2 开始
+	repo.nextID = 1
+	svc := services.NewUserService(repo)
+
+	email := "carol@example.test"
+	user, err := svc.Create(models.CreateUserRequest{Username: "carol", Password: "carol1234", Email: &email, RoleIDs: []int{2}}, 1)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if user.Status != 1 || user.CreatedBy == nil || *user.CreatedBy != 1 {
+		t.Fatalf("expected an enabled user created by operator 1, got %+v", user)
+	}
+	if stored := repo.rows[user.ID].Password; stored == "carol1234" || !utils.ComparePassword("carol1234", stored) {
+		t.Fatalf("expected the password to be stored as a bcrypt hash, got %q", stored)
+	}
+	if _, err := svc.Create(models.CreateUserRequest{Username: "carol2", Password: "carol1234", Email: &email}, 1); services.KindOf(err) != services.KindInvalid {
+		t.Fatalf("expected a duplicate email to be rejected as invalid, got %v", err)
+	}
+
+	got, err := svc.Get(user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if len(got.Roles) != 1 || got.Roles[0].ID != 2 {
+		t.Fatalf("expected role [2], got %+v", got.Roles)
+	}
+
+	if err := svc.Update(user.ID, models.UpdateUserRequest{RoleIDs: &[]int{3, 4}}, 1); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	page, err := svc.List(repositories.UserFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	users := page.List.([]models.User)
+	if page.Total != 1 || len(users[0].Roles) != 2 || users[0].Roles[0].ID != 3 {
+		t.Fatalf("expected the user with roles [3 4], got %+v", page)
+	}
+
+	if err := svc.ResetPassword(user.ID, "newpass123", 1); err != nil {
+		t.Fatalf("reset password: %v", err)
+	}
+	if !utils.ComparePassword("newpass123", repo.rows[user.ID].Password) {
+		t.Fatal("expected the reset password to be hashed and stored")
+	}
+
+	// 不能停用或删除当前登录用户，且不触碰仓储
+	if err := svc.UpdateStatus(user.ID, 0, user.ID); services.KindOf(err) != services.KindInvalid {
+		t.Fatalf("expected disabling oneself to be rejected, got %v", err)
+	}
+	if err := svc.Delete(user.ID, user.ID); services.KindOf(err) != services.KindInvalid {
+		t.Fatalf("expected deleting oneself to be rejected, got %v", err)
+	}
+	if repo.rows[user.ID].Status != 1 {
+		t.Fatal("user disabled itself")
+	}
+
+	if err := svc.UpdateStatus(99, 0, 1); services.KindOf(err) != services.KindNotFound {
+		t.Fatalf("expected updating a missing user to be not found, got %v", err)
+	}
+
+	repo.permissions[user.ID] = []string{"hfish:view"}
+	if ok, err := svc.HasPermission(context.Background(), user.ID, "hfish:view"); err != nil || !ok {
+		t.Fatalf("expected hfish:view to be granted, got %v, %v", ok, err)
+	}
+	if ok, _ := svc.HasPermission(context.Background(), user.ID, "user:delete"); ok {
+		t.Fatal("expected user:delete not to be granted")
+	}
+
+	// 仓储错误统一映射为内部错误，并保留原始错误
+	dbErr := errors.New("database is locked")
+	repo.failWith = dbErr
+	err = svc.Delete(user.ID, 1)
+	if services.KindOf(err) != services.KindInternal || !errors.Is(err, dbErr) {
+		t.Fatalf("expected a wrapped internal error, got %v", err)
+	}
+
+	repo.failWith = nil
+	if err := svc.Delete(user.ID, 1); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if _, err := svc.Get(user.ID); services.KindOf(err) != services.KindNotFound {
+		t.Fatalf("expected a deleted user to be not found, got %v", err)
+	}
+}
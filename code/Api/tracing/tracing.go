@@ -0,0 +1,171 @@
+// Package tracing OpenTelemetry 链路追踪
+//
+// 追踪数据通过 OTLP/HTTP 发送到 Collector，或逐行写入本地 JSON 文件便于排查和测试。
+// 进入的请求、权限校验、数据库查询、调用 HFish API 和发送邮件各自记录为一个 span；
+// 请求头中的 traceparent 会被沿用，调用 HFish 时同样带上 traceparent。
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName 上报的服务名，同时用作 Tracer 名称
+const ServiceName = "superhoneypotguard"
+
+// 导出方式
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// Options 追踪配置
+type Options struct {
+	// Exporter 可选 none、otlp、file，none 时不记录
+	Exporter string
+	// Endpoint OTLP/HTTP 地址，如 http://localhost:4318，未指定路径时使用 /v1/traces
+	Endpoint string
+	// FilePath file 导出时写入的文件，每行一个 span 的 JSON
+	FilePath string
+	// SamplePercent 新建追踪的采样比例（0-100），沿用上游的追踪时服从上游的采样决定
+	SamplePercent int
+}
+
+// Provider 持有导出器，退出前调用 Shutdown 把缓冲的 span 全部导出
+type Provider struct {
+	tp   *sdktrace.TracerProvider
+	file *os.File
+}
+
+// Setup 按配置创建 Provider 并设为全局 TracerProvider，同时启用 W3C Trace Context 传播
+func Setup(opts Options) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	p, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	if p.tp != nil {
+		otel.SetTracerProvider(p.tp)
+	}
+	return p, nil
+}
+
+// New 按配置创建 Provider，不修改全局设置
+func New(opts Options) (*Provider, error) {
+	p := &Provider{}
+	var exporter sdktrace.SpanExporter
+	switch opts.Exporter {
+	case ExporterNone, "":
+		return p, nil
+	case ExporterOTLP:
+		endpoint, err := otlpEndpoint(opts.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+		if err != nil {
+			return nil, fmt.Errorf("创建 OTLP 导出器失败: %w", err)
+		}
+	case ExporterFile:
+		if err := os.MkdirAll(filepath.Dir(opts.FilePath), 0o755); err != nil {
+			return nil, fmt.Errorf("创建追踪文件目录失败: %w", err)
+		}
+		file, err := os.OpenFile(opts.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("打开追踪文件失败: %w", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("创建文件导出器失败: %w", err)
+		}
+		p.file = file
+	default:
+		return nil, fmt.Errorf("无效的追踪导出方式 %q", opts.Exporter)
+	}
+
+	p.tp = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(float64(opts.SamplePercent)/100))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+	)
+	return p, nil
+}
+
+// TracerProvider 返回 SDK 的 TracerProvider，未启用追踪时为 nil
+func (p *Provider) TracerProvider() *sdktrace.TracerProvider {
+	return p.tp
+}
+
+// ForceFlush 立即导出缓冲的 span
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	if p.tp == nil {
+		return nil
+	}
+	return p.tp.ForceFlush(ctx)
+}
+
+// Shutdown 导出剩余的 span 后关闭导出器和追踪文件
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.tp == nil {
+		return nil
+	}
+	err := p.tp.Shutdown(ctx)
+	if p.file != nil {
+		if cerr := p.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// otlpEndpoint 补全 OTLP/HTTP 的 traces 路径
+func otlpEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("无效的 OTLP 地址 %q", endpoint)
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
+
+// Tracer 返回本服务的 Tracer，未启用追踪时创建的 span 不会被记录
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
+
+// TraceID 取出 context 中 span 的追踪 ID，没有或未采样时返回空字符串
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// End 结束 span，err 不为 nil 时记录错误并标记为失败
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxForwardedChainLength 与 operation_logs.forwarded_for 列的长度一致
+const maxForwardedChainLength = 500
+
+// remoteIPHeaders 读取客户端地址的请求头，由 ConfigureClientIP 设置
+var remoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// ConfigureClientIP 设置受信任的反向代理（IP 或 CIDR）及携带客户端地址的请求头
+// 只有直接来自受信任代理的请求才会采用请求头中的地址，trustedProxies 为空时始终使用连接的对端地址
+func ConfigureClientIP(r *gin.Engine, trustedProxies, headers []string) error {
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		return err
+	}
+	r.RemoteIPHeaders = headers
+	remoteIPHeaders = headers
+	return nil
+}
+
+// GetClientIP 请求的客户端地址，限流、登录记录和操作日志都以此为准
+// 转发请求头按从右到左的顺序跳过受信任的代理，伪造的请求头不会生效
+func GetClientIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// ForwardedChain 请求头声明的完整转发链，按 "客户端, 代理..., 对端地址" 的顺序排列，没有转发请求头时返回空字符串
+// 请求头可能被伪造，结果只用于事后追查，不能作为客户端地址使用
+func ForwardedChain(c *gin.Context) string {
+	var hops []string
+	for _, name := range remoteIPHeaders {
+		for _, value := range c.Request.Header.Values(name) {
+			for _, hop := range strings.Split(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			break
+		}
+	}
+	if len(hops) == 0 {
+		return ""
+	}
+
+	if remote, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr)); err == nil {
+		hops = append(hops, remote)
+	}
+	chain := strings.Join(hops, ", ")
+	if len(chain) > maxForwardedChainLength {
+		chain = chain[:maxForwardedChainLength]
+	}
+	return chain
+}
@@ -16,7 +16,7 @@ func GenerateToken(claims *models.Claims) (string, error) {
 		"username":    claims.Username,
 		"roles":       claims.Roles,
 		"permissions": claims.Permissions,
-		"exp":         time.Now().Add(time.Hour * 24).Unix(),
+		"exp":         time.Now().Add(cfg.JWTExpiresIn).Unix(),
 	})
 
 	return token.SignedString([]byte(cfg.JWTSecret))
@@ -23,14 +23,13 @@ func ErrorResponse(c *gin.Context, statusCode int, message string) {
 	})
 }
 
-func GetClientIP(c *gin.Context) string {
-	if ip := c.GetHeader("X-Forwarded-For"); ip != "" {
-		return ip
-	}
-	if ip := c.GetHeader("X-Real-IP"); ip != "" {
-		return ip
-	}
-	return c.ClientIP()
+// StatusResponse 以指定状态码返回带数据的响应，用于失败时仍需返回详细结果的接口（如就绪检查）
+func StatusResponse(c *gin.Context, statusCode int, success bool, message string, data interface{}) {
+	c.JSON(statusCode, models.Response{
+		Success: success,
+		Message: message,
+		Data:    data,
+	})
 }
 
 func ToJSON(v interface{}) string {
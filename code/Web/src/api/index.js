@@ -45,14 +45,21 @@ export const dashboardAPI = {
 export const logAPI = {
   getList: (params) => request.get('/log/list', { params }),
   getById: (id) => request.get(`/log/${id}`),
-  delete: (id) => request.delete(`/log/${id}`),
-  clear: () => request.delete('/log/clear')
+  verify: () => request.get('/log/verify'),
+  archive: (before) => request.post('/log/archive', { before }),
+  getArchives: () => request.get('/log/archives'),
+  export: (params) => request.get('/log/export', { params, responseType: 'blob', timeout: 0 })
 }
 
 export const hfishAPI = {
-  getAttackIPs: () => request.post('/hfish/attack/ips'),
-  getAttackDetails: () => request.post('/hfish/attack/details'),
-  getAccountInfo: () => request.post('/hfish/account/info'),
-  getSysInfo: () => request.get('/hfish/sys/info'),
-  blockIP: (data) => request.post('/hfish/block/ip', data)
+  getAttackIPs: (params) => request.get('/hfish/attack/ips', { params }),
+  getAttackDetails: (params) => request.get('/hfish/attack/details', { params }),
+  getAccountInfo: (params) => request.get('/hfish/account/info', { params }),
+  getSysInfo: (params) => request.get('/hfish/sys/info', { params }),
+  blockIP: (data) => request.post('/hfish/block/ip', data),
+  getInstances: () => request.get('/hfish/instances'),
+  getInstance: (id) => request.get(`/hfish/instances/${id}`),
+  createInstance: (data) => request.post('/hfish/instances', data),
+  updateInstance: (id, data) => request.put(`/hfish/instances/${id}`, data),
+  deleteInstance: (id) => request.delete(`/hfish/instances/${id}`)
 }
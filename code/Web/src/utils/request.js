@@ -21,6 +21,10 @@ request.interceptors.request.use(
 
 request.interceptors.response.use(
   response => {
+    // 文件下载直接返回原始响应
+    if (response.config.responseType === 'blob') {
+      return response
+    }
     const res = response.data
     if (!res.success) {
       message.error(res.message || '请求失败')